@@ -1,28 +1,45 @@
 package main
 
 import (
-	"sync"
+	"context"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/olezhek28/wether-service/internal/app"
 	"github.com/olezhek28/wether-service/internal/config"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
 )
 
+// shutdownTimeout ограничивает ожидание завершения активных вызовов при остановке
+const shutdownTimeout = 10 * time.Second
+
 func main() {
-	var wg sync.WaitGroup
 	cfg := config.MustLoad()
 
+	// Уровень и формат логов из конфигурации; логгер становится логгером по умолчанию
+	pkg.SetUpLogger(cfg.Log)
+
+	// Контекст отменяется по SIGINT или SIGTERM, после чего сервис останавливается
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	app := app.New(cfg)
 
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		app.Server.MustRun()
-	}()
+	go app.Server.MustRun()
+
+	go app.GRPC.MustRun()
+
+	app.Cron.Start()
+
+	if app.MQTT != nil {
+		app.MQTT.Start()
+	}
+
+	<-ctx.Done()
 
-	go func() {
-		defer wg.Done()
-		app.Cron.Start()
-	}()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	wg.Wait()
+	app.Stop(shutdownCtx)
 }
@@ -2,21 +2,45 @@ package app
 
 import (
 	"context"
+	"log/slog"
+	nethttp "net/http"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-co-op/gocron/v2"
+	"github.com/jackc/pgx/v5/multitracer"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/olezhek28/wether-service/internal/clients"
 	"github.com/olezhek28/wether-service/internal/config"
 	"github.com/olezhek28/wether-service/internal/cron"
+	"github.com/olezhek28/wether-service/internal/events"
+	"github.com/olezhek28/wether-service/internal/grpc"
 	"github.com/olezhek28/wether-service/internal/handlers"
 	"github.com/olezhek28/wether-service/internal/http"
+	"github.com/olezhek28/wether-service/internal/metrics"
+	"github.com/olezhek28/wether-service/internal/mqtt"
 	"github.com/olezhek28/wether-service/internal/services"
 	"github.com/olezhek28/wether-service/internal/storage"
 	"github.com/olezhek28/wether-service/internal/storage/postgres"
+	"github.com/olezhek28/wether-service/internal/tracing"
 )
 
 type App struct {
 	Server *http.Server
+	GRPC   *grpc.Server
 	Cron   gocron.Scheduler
+	// Подписчик на темы датчиков MQTT, nil, если брокер не задан
+	MQTT *mqtt.Subscriber
+
+	// Отправляет накопленные спаны и останавливает экспортер трассировки
+	stopTracing func(context.Context) error
+
+	// Фоновые задачи (LISTEN/NOTIFY, обновление JWKS) работают до отмены своего контекста,
+	// Stop отменяет его и дожидается их завершения до закрытия пула соединений
+	cancelBackground context.CancelFunc
+	background       sync.WaitGroup
+	pool             *pgxpool.Pool
 }
 
 func New(config *config.Config) *App {
@@ -29,22 +53,119 @@ func New(config *config.Config) *App {
 		panic(err)
 	}
 
-	srv := http.NewServer(ctx, config.Port, config.Host, r)
+	// При заданном сертификате сервер принимает HTTPS, сертификат перечитывается при замене файлов
+	srv := http.NewServer(ctx, config.Port, config.Host, r, config.TLS)
+
+	// Метрики Prometheus: HTTP, внешние API, сбор по расписанию и запросы к базе
+	m := metrics.New()
+
+	// Трассировка OpenTelemetry настраивается до создания клиентов и обработчиков,
+	// которые передают контекст трассировки
+	stopTracing := tracing.New(ctx, config.Tracing)
 
-	postgres := postgres.New(ctx, config)
+	// pgx принимает один трассировщик запросов, поэтому метрики и спаны объединяются
+	postgres := postgres.New(ctx, config, multitracer.New(m.QueryTracer(), tracing.QueryTracer()))
+
+	app := &App{pool: postgres, stopTracing: stopTracing}
+	background, cancelBackground := context.WithCancel(ctx)
+	app.cancelBackground = cancelBackground
 
 	weatherDB := storage.New(postgres)
 
-	service := services.New(weatherDB, weatherDB)
+	// Создаем HTTP-клиенты с таймаутом для предотвращения зависаний и общие для сервиса
+	// клиенты внешних API. У каждого API свой клиент, чтобы метрики и спаны различали их,
+	// а пул соединений общий
+	transport := nethttp.DefaultTransport
+	newClient := func(name string) *nethttp.Client {
+		return &nethttp.Client{
+			Timeout:   10 * time.Second,
+			Transport: tracing.Transport(name, m.Transport(name, transport)),
+		}
+	}
+	geocodingClient := clients.NewGeocoding(newClient(metrics.ClientGeocoding))
+	openMeteo := clients.NewOpenMeteo(newClient(metrics.ClientOpenMeteo))
 
-	h := handlers.New(r, service)
-	h.Init()
+	// Брокер раздает новые показания и оповещения потокам SSE и WebSocket;
+	// события приходят через LISTEN/NOTIFY от любой реплики, сохранившей их
+	broker := events.NewBroker()
+	app.background.Go(func() { weatherDB.ListenEvents(background, broker) })
 
-	c := cron.New(scheduler, service)
+	service := services.New(
+		weatherDB,
+		weatherDB,
+		geocodingClient,
+		openMeteo,
+		broker,
+		weatherDB,
+		config.Alerts,
+		config.Weather,
+	)
+
+	// Ключи API проверяются для всех маршрутов HTTP API, счетчики квот общие для реплик
+	keys := services.NewKeys(storage.NewKeys(postgres), config.Auth)
+
+	// Собственные метеостанции пишут показания в ряды городов через сервис погоды
+	stations := services.NewStations(storage.NewStations(postgres), service, config.Stations)
+
+	// Если настроен JWKS, административные маршруты требуют JWT с разрешениями по ролям
+	// вместо ключа API с областью admin
+	var tokens handlers.TokenVerifier
+	if config.Auth.JWT.Enabled() {
+		jwks := clients.NewJWKS(newClient(metrics.ClientJWKS), config.Auth.JWT.JWKSFile, config.Auth.JWT.JWKSURL)
+		tokenService := services.NewTokens(ctx, jwks, config.Auth.JWT)
+		app.background.Go(func() { tokenService.RefreshKeys(background) })
+		tokens = tokenService
+	}
+
+	c := cron.New(scheduler, service, config.Cron.Interval, config.Cron.Cities, m)
 	c.Init(ctx)
 
-	return &App{
-		Server: srv,
-		Cron:   scheduler,
+	// Показания датчиков из MQTT сохраняются тем же сервисом, что и собранные по расписанию
+	var subscriber *mqtt.Subscriber
+	if config.MQTT.Enabled() {
+		subscriber = mqtt.New(config.MQTT, service, m)
+	}
+
+	// /readyz проверяет базу, планировщик сбора и свежесть показаний собираемых городов
+	health := services.NewHealth(weatherDB, weatherDB, service, c, config.Cron.Cities, config.Health)
+
+	// Возраст последних показаний собираемых городов считается по базе при каждом запросе /metrics,
+	// ряды помечаются ключами городов
+	m.RegisterFreshness(weatherDB, service, config.Cron.Cities)
+
+	h := handlers.New(r, service, keys, stations, tokens, health, m, config)
+	h.Init()
+
+	// gRPC API для внутренних сервисов работает на отдельном порту поверх того же сервиса
+	grpcServer := grpc.NewServer(ctx, config.GRPC.Port, config.Host, service, config.Stream)
+
+	app.Server = srv
+	app.GRPC = grpcServer
+	app.Cron = scheduler
+	app.MQTT = subscriber
+
+	return app
+}
+
+// Stop останавливает компоненты приложения: сначала сбор данных и прием показаний MQTT,
+// затем gRPC-сервер, дожидаясь завершения активных вызовов до отмены ctx, потом фоновые
+// задачи, после которых закрывается пул соединений с базой, и в конце отправляет накопленные спаны
+// HTTP-сервер пока не поддерживает плавную остановку (см. http.Server.Stop)
+func (a *App) Stop(ctx context.Context) {
+	if err := a.Cron.Shutdown(); err != nil {
+		slog.Error("failed to stop scheduler", "error", err)
+	}
+	if a.MQTT != nil {
+		a.MQTT.Stop()
+	}
+
+	a.GRPC.Stop(ctx)
+
+	a.cancelBackground()
+	a.background.Wait()
+	a.pool.Close()
+
+	if err := a.stopTracing(ctx); err != nil {
+		slog.Error("failed to flush spans", "error", err)
 	}
 }
@@ -0,0 +1,244 @@
+package charts
+
+import (
+	"image/color"
+	"math"
+	"time"
+)
+
+// Point - точка ряда: время и значение
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series - ряд значений одной переменной
+// Каждый ряд рисуется на своей панели со своей шкалой: у переменных разные единицы
+type Series struct {
+	Name   string  // Подпись панели, пустая - без подписи
+	Unit   string  // Единица значений в подписях оси Y, например °C
+	Points []Point // Точки в порядке возрастания времени
+}
+
+// Theme определяет цвета графика
+type Theme struct {
+	Background color.RGBA
+	Grid       color.RGBA   // Линии сетки
+	Axis       color.RGBA   // Оси, деления и надпись об отсутствии данных
+	Text       color.RGBA   // Заголовок и подписи делений
+	Lines      []color.RGBA // Цвета рядов по порядку, повторяются по кругу
+}
+
+// Встроенные темы
+var (
+	ThemeLight = Theme{
+		Background: rgb(0xffffff),
+		Grid:       rgb(0xe5e7eb),
+		Axis:       rgb(0x9ca3af),
+		Text:       rgb(0x374151),
+		Lines:      []color.RGBA{rgb(0x2563eb), rgb(0xdc2626), rgb(0x16a34a), rgb(0xd97706)},
+	}
+	ThemeDark = Theme{
+		Background: rgb(0x111827),
+		Grid:       rgb(0x374151),
+		Axis:       rgb(0x6b7280),
+		Text:       rgb(0xd1d5db),
+		Lines:      []color.RGBA{rgb(0x60a5fa), rgb(0xf87171), rgb(0x4ade80), rgb(0xfbbf24)},
+	}
+)
+
+// Options определяет параметры графика
+type Options struct {
+	Width  int    // Ширина в пикселях
+	Height int    // Высота в пикселях, делится между панелями рядов поровну
+	Title  string // Заголовок над графиком, пустой - без заголовка
+	// Диапазон оси X. Нулевые границы берутся из первой и последней точки,
+	// поэтому для графика за период их стоит задать, чтобы пропуски в начале и конце были видны
+	From, To time.Time
+	// Соседние точки дальше друг от друга, чем Gap, не соединяются линией:
+	// отсутствие данных не выдается за плавное изменение. 0 - соединять все точки
+	Gap time.Duration
+	// Цвета графика, нулевое значение - ThemeLight
+	Theme Theme
+}
+
+// Отступы области построения от краев графика
+const (
+	marginLeft   = 52
+	marginRight  = 16
+	marginTop    = 30
+	marginBottom = 28
+	panelGap     = 24 // Между панелями: место для подписи следующей панели
+)
+
+// Целевое количество делений осей
+const (
+	yTicks = 5
+	xTicks = 6
+)
+
+// xTickSteps - допустимые шаги делений оси времени в порядке возрастания
+var xTickSteps = []time.Duration{
+	15 * time.Minute, 30 * time.Minute, time.Hour, 3 * time.Hour, 6 * time.Hour,
+	12 * time.Hour, 24 * time.Hour, 2 * 24 * time.Hour, 7 * 24 * time.Hour, 14 * 24 * time.Hour,
+}
+
+// layout - размеры области построения панели и шкалы ее осей
+type layout struct {
+	left, top, width, height float64 // Область построения в пикселях
+
+	from, to time.Time // Диапазон оси X, общий для всех панелей
+	min, max float64   // Диапазон оси Y, расширенный до делений
+	step     float64   // Шаг делений оси Y
+}
+
+// newPanels рассчитывает области построения и шкалы панелей рядов
+// Панели делят высоту графика поровну и имеют общий диапазон времени
+func newPanels(series []Series, opts Options) []layout {
+	from, to := timeRange(series, opts)
+
+	n := max(len(series), 1)
+	height := (float64(opts.Height-marginTop-marginBottom) - float64(n-1)*panelGap) / float64(n)
+
+	panels := make([]layout, n)
+	for i := range panels {
+		panels[i] = layout{
+			left:   marginLeft,
+			top:    marginTop + float64(i)*(height+panelGap),
+			width:  float64(opts.Width - marginLeft - marginRight),
+			height: height,
+			from:   from,
+			to:     to,
+		}
+		var points []Point
+		if i < len(series) {
+			points = series[i].Points
+		}
+		panels[i].scale(points)
+	}
+	return panels
+}
+
+// timeRange возвращает диапазон оси X: из параметров или по крайним точкам рядов
+func timeRange(series []Series, opts Options) (time.Time, time.Time) {
+	from, to := opts.From, opts.To
+	for _, s := range series {
+		if len(s.Points) == 0 {
+			continue
+		}
+		if first := s.Points[0].Time; opts.From.IsZero() && (from.IsZero() || first.Before(from)) {
+			from = first
+		}
+		if last := s.Points[len(s.Points)-1].Time; opts.To.IsZero() && last.After(to) {
+			to = last
+		}
+	}
+	if !to.After(from) {
+		// Единственная точка или пустой ряд: ось в час вокруг нее
+		from, to = from.Add(-30*time.Minute), from.Add(30*time.Minute)
+	}
+	return from, to
+}
+
+// scale рассчитывает шкалу оси Y по значениям точек
+func (l *layout) scale(points []Point) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		lo, hi = math.Min(lo, p.Value), math.Max(hi, p.Value)
+	}
+	switch {
+	case len(points) == 0:
+		lo, hi = 0, 1
+	case lo == hi:
+		lo, hi = lo-1, hi+1
+	}
+	l.step = niceStep((hi - lo) / yTicks)
+	l.min = math.Floor(lo/l.step) * l.step
+	l.max = math.Ceil(hi/l.step) * l.step
+}
+
+// x возвращает горизонтальную координату момента t
+func (l layout) x(t time.Time) float64 {
+	return l.left + l.width*float64(t.Sub(l.from))/float64(l.to.Sub(l.from))
+}
+
+// y возвращает вертикальную координату значения v
+func (l layout) y(v float64) float64 {
+	return l.top + l.height*(l.max-v)/(l.max-l.min)
+}
+
+// valueTicks возвращает значения делений оси Y
+func (l layout) valueTicks() []float64 {
+	n := int(math.Round((l.max - l.min) / l.step))
+	ticks := make([]float64, 0, n+1)
+	for i := 0; i <= n; i++ {
+		// Деление считается от начала оси, а не прибавлением шага, чтобы не накапливать
+		// ошибку округления (0.1+0.2), и приводится к кратному шагу, чтобы не получить -0
+		v := math.Round((l.min+float64(i)*l.step)/l.step) * l.step
+		if v == 0 {
+			v = 0
+		}
+		ticks = append(ticks, v)
+	}
+	return ticks
+}
+
+// timeTicks возвращает моменты делений оси X, кратные шагу, и формат их подписей
+// Деления считаются в UTC, как и время показаний
+func (l layout) timeTicks() ([]time.Time, string) {
+	span := l.to.Sub(l.from)
+	step := xTickSteps[len(xTickSteps)-1]
+	for _, s := range xTickSteps {
+		if span/s <= xTicks {
+			step = s
+			break
+		}
+	}
+
+	format := "15:04"
+	if step >= 24*time.Hour {
+		format = "02.01"
+	}
+
+	var ticks []time.Time
+	for t := l.from.UTC().Truncate(step); !t.After(l.to); t = t.Add(step) {
+		if !t.Before(l.from) {
+			ticks = append(ticks, t)
+		}
+	}
+	return ticks, format
+}
+
+// segments разбивает ряд на участки без разрывов длиннее gap
+func segments(points []Point, gap time.Duration) [][]Point {
+	var result [][]Point
+	start := 0
+	for i := 1; i <= len(points); i++ {
+		if i == len(points) || (gap > 0 && points[i].Time.Sub(points[i-1].Time) > gap) {
+			if i > start {
+				result = append(result, points[start:i])
+			}
+			start = i
+		}
+	}
+	return result
+}
+
+// niceStep округляет шаг делений вверх до 1, 2 или 5, умноженных на степень десяти
+func niceStep(raw float64) float64 {
+	if raw <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if raw <= m*magnitude {
+			return m * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// rgb возвращает непрозрачный цвет по значению 0xRRGGBB
+func rgb(v uint32) color.RGBA {
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
@@ -0,0 +1,205 @@
+package charts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// series возвращает ряд со значениями values через step начиная с start
+func series(start time.Time, step time.Duration, values ...float64) []Point {
+	points := make([]Point, len(values))
+	for i, v := range values {
+		points[i] = Point{Time: start.Add(time.Duration(i) * step), Value: v}
+	}
+	return points
+}
+
+// render рисует график и проверяет, что результат - корректный XML
+func render(t *testing.T, series []Series, opts Options) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := SVG(&buf, series, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid SVG: %v\n%s", err, buf.String())
+		}
+	}
+	return buf.String()
+}
+
+func TestNiceStep(t *testing.T) {
+	tests := map[float64]float64{0.13: 0.2, 0.9: 1, 1: 1, 1.2: 2, 3: 5, 7: 10, 42: 50, 0: 1}
+	for raw, want := range tests {
+		if got := niceStep(raw); got != want {
+			t.Errorf("niceStep(%g) = %g, want %g", raw, got, want)
+		}
+	}
+}
+
+func TestValueTicks(t *testing.T) {
+	l := newPanels([]Series{{Points: series(time.Now(), time.Hour, -0.3, 0.6)}}, Options{Width: 400, Height: 200})[0]
+
+	ticks := l.valueTicks()
+	want := []float64{-0.4, -0.2, 0, 0.2, 0.4, 0.6}
+	if len(ticks) != len(want) {
+		t.Fatalf("ticks = %v, want %v", ticks, want)
+	}
+	for i := range want {
+		if formatValue(ticks[i], l.step) != formatValue(want[i], l.step) {
+			t.Errorf("tick %d = %s, want %s", i, formatValue(ticks[i], l.step), formatValue(want[i], l.step))
+		}
+	}
+	if got := formatValue(ticks[2], l.step); got != "0.0" {
+		t.Errorf("zero tick = %q, want 0.0 without sign", got)
+	}
+}
+
+func TestTimeTicks(t *testing.T) {
+	from := time.Date(2026, 1, 10, 7, 20, 0, 0, time.UTC)
+
+	tests := []struct {
+		span      time.Duration
+		wantStep  time.Duration
+		wantFirst string
+		format    string
+	}{
+		{24 * time.Hour, 6 * time.Hour, "12:00", "15:04"},
+		{7 * 24 * time.Hour, 2 * 24 * time.Hour, "11.01", "02.01"},
+	}
+	for _, tt := range tests {
+		l := newPanels(nil, Options{Width: 400, Height: 200, From: from, To: from.Add(tt.span)})[0]
+		ticks, format := l.timeTicks()
+		if format != tt.format || len(ticks) < 2 {
+			t.Fatalf("span %s: %d ticks in format %q", tt.span, len(ticks), format)
+		}
+		if got := ticks[0].Format(format); got != tt.wantFirst {
+			t.Errorf("span %s: first tick %s, want %s", tt.span, got, tt.wantFirst)
+		}
+		if step := ticks[1].Sub(ticks[0]); step != tt.wantStep {
+			t.Errorf("span %s: step %s, want %s", tt.span, step, tt.wantStep)
+		}
+	}
+}
+
+func TestSegments(t *testing.T) {
+	start := time.Now()
+	points := append(series(start, time.Hour, 1, 2, 3), series(start.Add(10*time.Hour), time.Hour, 4)...)
+
+	if got := segments(points, 0); len(got) != 1 {
+		t.Errorf("without gap: %d segments, want 1", len(got))
+	}
+	got := segments(points, 90*time.Minute)
+	if len(got) != 2 || len(got[0]) != 3 || len(got[1]) != 1 {
+		t.Errorf("with gap: %v, want segments of 3 and 1 points", got)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	start := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	points := append(series(start, time.Hour, -3, -1.5, 0.5, 2), series(start.Add(8*time.Hour), time.Hour, 1)...)
+
+	svg := render(t, []Series{{Unit: "°C", Points: points}}, Options{
+		Width:  600,
+		Height: 240,
+		Title:  `Moscow <"avg">`,
+		From:   start,
+		To:     start.Add(12 * time.Hour),
+		Gap:    90 * time.Minute,
+	})
+
+	if !strings.Contains(svg, `Moscow &lt;&#34;avg&#34;&gt;`) {
+		t.Error("title is not escaped")
+	}
+	// Четыре соседние точки - линия, точка после разрыва - круг
+	if strings.Count(svg, "<polyline") != 1 || strings.Count(svg, "<circle") != 1 {
+		t.Errorf("want one polyline and one circle:\n%s", svg)
+	}
+	if !strings.Contains(svg, ">2°C<") || !strings.Contains(svg, ">-3°C<") {
+		t.Errorf("value axis does not cover the data:\n%s", svg)
+	}
+	if !strings.Contains(svg, ">06:00<") {
+		t.Errorf("time axis has no 06:00 tick:\n%s", svg)
+	}
+}
+
+func TestSVGEmpty(t *testing.T) {
+	now := time.Now()
+	svg := render(t, []Series{{Name: "Температура"}}, Options{Width: 300, Height: 150, From: now.Add(-24 * time.Hour), To: now})
+
+	if !strings.Contains(svg, "нет данных") || strings.Contains(svg, "<polyline") {
+		t.Errorf("empty chart:\n%s", svg)
+	}
+}
+
+func TestSVGPanels(t *testing.T) {
+	start := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	svg := render(t, []Series{
+		{Name: "Температура", Unit: "°C", Points: series(start, time.Hour, 1, 2, 3)},
+		{Name: "Влажность", Unit: "%", Points: series(start.Add(time.Hour), time.Hour, 40, 90)},
+	}, Options{Width: 600, Height: 400, Theme: ThemeDark})
+
+	if strings.Count(svg, "<polyline") != 2 {
+		t.Errorf("want a line per series:\n%s", svg)
+	}
+	// У каждой панели своя шкала и цвет, подписи времени только под нижней
+	for _, want := range []string{">3.0°C<", ">90%<", `fill="#60a5fa" font-weight="bold" text-anchor="end">Температура<`, `stroke="#f87171"`, `fill="#111827"`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("chart has no %q", want)
+		}
+	}
+	if n := strings.Count(svg, ">01:00<"); n != 1 {
+		t.Errorf("time labels drawn %d times, want once", n)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	start := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	data := []Series{{Name: "Температура", Unit: "°C", Points: series(start, time.Hour, -3, 5, 1, 8)}}
+
+	for name, theme := range map[string]Theme{"light": ThemeLight, "dark": ThemeDark} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := PNG(&buf, data, Options{Width: 320, Height: 160, Title: "Москва", Theme: theme}); err != nil {
+				t.Fatal(err)
+			}
+
+			img, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := img.Bounds().Size(); size.X != 320 || size.Y != 160 {
+				t.Fatalf("size = %v, want 320x160", size)
+			}
+			if got := color.RGBAModel.Convert(img.At(1, 1)); got != theme.Background {
+				t.Errorf("background = %v, want %v", got, theme.Background)
+			}
+
+			// Линия ряда закрашивает пиксели его цветом, подписи - цветом текста
+			counts := make(map[color.Color]int)
+			for y := range 160 {
+				for x := range 320 {
+					counts[color.RGBAModel.Convert(img.At(x, y))]++
+				}
+			}
+			if counts[theme.Lines[0]] < 50 {
+				t.Errorf("%d pixels of the line color, want a visible line", counts[theme.Lines[0]])
+			}
+			if counts[theme.Text] == 0 {
+				t.Error("no text is drawn")
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package charts
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// Размеры шрифта подписей и заголовка
+const (
+	fontSize  = 11
+	titleSize = 13
+)
+
+// anchor - выравнивание текста относительно точки
+type anchor int
+
+const (
+	anchorStart anchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+// canvas - поверхность, на которой рисуется график: SVG или растровое изображение
+// Координаты в пикселях от левого верхнего угла, y текста - базовая линия
+type canvas interface {
+	background(c color.RGBA)
+	line(x1, y1, x2, y2, width float64, c color.RGBA)
+	polyline(points [][2]float64, width float64, c color.RGBA)
+	circle(x, y, r float64, c color.RGBA)
+	text(x, y float64, s string, size float64, bold bool, a anchor, c color.RGBA)
+}
+
+// plot рисует ряды на поверхности: по панели на ряд, подписи времени под нижней панелью
+// Для пустого ряда рисуются оси и надпись об отсутствии данных
+func plot(c canvas, series []Series, opts Options) {
+	theme := opts.Theme
+	if len(theme.Lines) == 0 {
+		theme = ThemeLight
+	}
+	panels := newPanels(series, opts)
+
+	c.background(theme.Background)
+	if opts.Title != "" {
+		c.text(marginLeft, 18, opts.Title, titleSize, true, anchorStart, theme.Text)
+	}
+
+	for i, l := range panels {
+		var s Series
+		if i < len(series) {
+			s = series[i]
+		}
+		lineColor := theme.Lines[i%len(theme.Lines)]
+
+		if s.Name != "" {
+			c.text(l.left+l.width, l.top-8, s.Name, fontSize, true, anchorEnd, lineColor)
+		}
+
+		// Сетка и подписи оси значений
+		for _, v := range l.valueTicks() {
+			y := l.y(v)
+			c.line(l.left, y, l.left+l.width, y, 1, theme.Grid)
+			c.text(l.left-6, y+fontSize*0.35, formatValue(v, l.step)+s.Unit, fontSize, false, anchorEnd, theme.Text)
+		}
+
+		// Деления оси времени на каждой панели, подписи - только под нижней
+		ticks, format := l.timeTicks()
+		bottom := l.top + l.height
+		for _, t := range ticks {
+			x := l.x(t)
+			c.line(x, bottom, x, bottom+4, 1, theme.Axis)
+			if i == len(panels)-1 {
+				c.text(x, bottom+16, t.UTC().Format(format), fontSize, false, anchorMiddle, theme.Text)
+			}
+		}
+		c.line(l.left, bottom, l.left+l.width, bottom, 1, theme.Axis)
+
+		if len(s.Points) == 0 {
+			c.text(l.left+l.width/2, l.top+l.height/2+fontSize*0.35, "нет данных", fontSize, false, anchorMiddle, theme.Axis)
+			continue
+		}
+
+		for _, segment := range segments(s.Points, opts.Gap) {
+			// Одиночную точку между разрывами линия не покажет, поэтому она рисуется кругом
+			if len(segment) == 1 {
+				c.circle(l.x(segment[0].Time), l.y(segment[0].Value), 2.5, lineColor)
+				continue
+			}
+
+			points := make([][2]float64, len(segment))
+			for j, p := range segment {
+				points[j] = [2]float64{l.x(p.Time), l.y(p.Value)}
+			}
+			c.polyline(points, 2, lineColor)
+		}
+	}
+}
+
+// formatValue форматирует подпись деления с числом знаков после запятой, достаточным для шага
+func formatValue(v, step float64) string {
+	decimals := 0
+	if step < 1 {
+		decimals = int(math.Ceil(-math.Log10(step)))
+	}
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
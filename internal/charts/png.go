@@ -0,0 +1,227 @@
+package charts
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// PNG записывает в w линейный график рядов в формате PNG
+// Линии растеризуются со сглаживанием, подписи рисуются шрифтами Go (латиница и кириллица)
+func PNG(w io.Writer, series []Series, opts Options) error {
+	c, err := newRasterCanvas(opts.Width, opts.Height)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	plot(c, series, opts)
+	return png.Encode(w, c.img)
+}
+
+// fonts - разобранные шрифты Go, общие для всех графиков
+// Начертания (font.Face) не потокобезопасны, поэтому создаются для каждого графика
+var fonts = sync.OnceValues(func() ([2]*opentype.Font, error) {
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return [2]*opentype.Font{}, err
+	}
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return [2]*opentype.Font{}, err
+	}
+	return [2]*opentype.Font{regular, bold}, nil
+})
+
+// faceKey - размер и насыщенность начертания
+type faceKey struct {
+	size float64
+	bold bool
+}
+
+// rasterCanvas рисует график в изображении RGBA
+type rasterCanvas struct {
+	img   *image.RGBA
+	fonts [2]*opentype.Font
+	faces map[faceKey]font.Face
+}
+
+func newRasterCanvas(width, height int) (*rasterCanvas, error) {
+	f, err := fonts()
+	if err != nil {
+		return nil, err
+	}
+	return &rasterCanvas{
+		img:   image.NewRGBA(image.Rect(0, 0, width, height)),
+		fonts: f,
+		faces: make(map[faceKey]font.Face),
+	}, nil
+}
+
+// close освобождает начертания
+func (c *rasterCanvas) close() {
+	for _, face := range c.faces {
+		face.Close()
+	}
+}
+
+func (c *rasterCanvas) background(col color.RGBA) {
+	draw.Draw(c.img, c.img.Bounds(), image.NewUniform(col), image.Point{}, draw.Src)
+}
+
+func (c *rasterCanvas) line(x1, y1, x2, y2, width float64, col color.RGBA) {
+	// Линия толщиной в пиксель по центру пикселя закрашивает один ряд, а не два наполовину
+	if width == 1 {
+		x1, y1, x2, y2 = snap(x1), snap(y1), snap(x2), snap(y2)
+	}
+	c.polyline([][2]float64{{x1, y1}, {x2, y2}}, width, col)
+}
+
+// polyline рисует ломаную как объединение прямоугольников вдоль отрезков
+// и кругов в вершинах, которые дают скругленные соединения
+func (c *rasterCanvas) polyline(points [][2]float64, width float64, col color.RGBA) {
+	hw := width / 2
+	var polygons [][][2]float64
+	for i := 1; i < len(points); i++ {
+		p, q := points[i-1], points[i]
+		dx, dy := q[0]-p[0], q[1]-p[1]
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		// Нормаль к отрезку длиной в половину толщины
+		nx, ny := -dy/length*hw, dx/length*hw
+		polygons = append(polygons, [][2]float64{
+			{p[0] + nx, p[1] + ny}, {q[0] + nx, q[1] + ny}, {q[0] - nx, q[1] - ny}, {p[0] - nx, p[1] - ny},
+		})
+	}
+	// У горизонтальных и вертикальных линий толщиной в пиксель соединений нет: это сетка и оси
+	if len(points) > 2 || width > 1 {
+		for _, p := range points {
+			polygons = append(polygons, circlePolygon(p[0], p[1], hw))
+		}
+	}
+	c.fill(polygons, col)
+}
+
+func (c *rasterCanvas) circle(x, y, r float64, col color.RGBA) {
+	c.fill([][][2]float64{circlePolygon(x, y, r)}, col)
+}
+
+func (c *rasterCanvas) text(x, y float64, s string, size float64, bold bool, a anchor, col color.RGBA) {
+	face := c.face(size, bold)
+	d := font.Drawer{Dst: c.img, Src: image.NewUniform(col), Face: face}
+
+	width := float64(d.MeasureString(s)) / 64
+	switch a {
+	case anchorMiddle:
+		x -= width / 2
+	case anchorEnd:
+		x -= width
+	}
+	d.Dot = fixed.Point26_6{X: fixed.Int26_6(math.Round(x * 64)), Y: fixed.Int26_6(math.Round(y * 64))}
+	d.DrawString(s)
+}
+
+// face возвращает начертание размера size, создавая его при первом обращении
+func (c *rasterCanvas) face(size float64, bold bool) font.Face {
+	key := faceKey{size: size, bold: bold}
+	if face, ok := c.faces[key]; ok {
+		return face
+	}
+
+	f := c.fonts[0]
+	if bold {
+		f = c.fonts[1]
+	}
+	// Ошибка возможна только при некорректных параметрах, которые здесь постоянны
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		panic(err)
+	}
+	c.faces[key] = face
+	return face
+}
+
+// fill закрашивает объединение многоугольников со сглаживанием
+// Растеризуется только охватывающий их прямоугольник
+func (c *rasterCanvas) fill(polygons [][][2]float64, col color.RGBA) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, polygon := range polygons {
+		for _, p := range polygon {
+			minX, minY = math.Min(minX, p[0]), math.Min(minY, p[1])
+			maxX, maxY = math.Max(maxX, p[0]), math.Max(maxY, p[1])
+		}
+	}
+	bounds := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).
+		Intersect(c.img.Bounds())
+	if bounds.Empty() {
+		return
+	}
+
+	r := vector.NewRasterizer(bounds.Dx(), bounds.Dy())
+	for _, polygon := range polygons {
+		// Растеризатор суммирует площади со знаком, поэтому перекрывающиеся многоугольники
+		// должны обходиться в одном направлении, иначе пересечение станет дырой
+		if signedArea(polygon) < 0 {
+			polygon = reversed(polygon)
+		}
+		for i, p := range polygon {
+			x, y := float32(p[0]-float64(bounds.Min.X)), float32(p[1]-float64(bounds.Min.Y))
+			if i == 0 {
+				r.MoveTo(x, y)
+			} else {
+				r.LineTo(x, y)
+			}
+		}
+		r.ClosePath()
+	}
+	r.Draw(c.img, bounds, image.NewUniform(col), image.Point{})
+}
+
+// snap сдвигает координату в центр пикселя
+func snap(v float64) float64 {
+	return math.Floor(v) + 0.5
+}
+
+// circlePolygon приближает круг многоугольником
+func circlePolygon(x, y, r float64) [][2]float64 {
+	const n = 16
+	polygon := make([][2]float64, n)
+	for i := range polygon {
+		a := 2 * math.Pi * float64(i) / n
+		polygon[i] = [2]float64{x + r*math.Cos(a), y + r*math.Sin(a)}
+	}
+	return polygon
+}
+
+// signedArea возвращает площадь многоугольника со знаком направления обхода
+func signedArea(polygon [][2]float64) float64 {
+	var area float64
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		area += p[0]*q[1] - q[0]*p[1]
+	}
+	return area / 2
+}
+
+// reversed возвращает вершины многоугольника в обратном порядке
+func reversed(polygon [][2]float64) [][2]float64 {
+	result := make([][2]float64, len(polygon))
+	for i, p := range polygon {
+		result[len(polygon)-1-i] = p
+	}
+	return result
+}
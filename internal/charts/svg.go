@@ -0,0 +1,75 @@
+package charts
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image/color"
+	"io"
+)
+
+// SVG записывает в w линейный график рядов в формате SVG
+func SVG(w io.Writer, series []Series, opts Options) error {
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="%d">`,
+		opts.Width, opts.Height, opts.Width, opts.Height, fontSize)
+	plot(c, series, opts)
+	c.b.WriteString(`</svg>`)
+
+	_, err := w.Write(c.b.Bytes())
+	return err
+}
+
+// svgCanvas рисует график элементами SVG
+type svgCanvas struct {
+	b bytes.Buffer
+}
+
+func (c *svgCanvas) background(col color.RGBA) {
+	fmt.Fprintf(&c.b, `<rect width="100%%" height="100%%" fill="%s"/>`, hex(col))
+}
+
+func (c *svgCanvas) line(x1, y1, x2, y2, width float64, col color.RGBA) {
+	fmt.Fprintf(&c.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"`, x1, y1, x2, y2, hex(col))
+	if width != 1 {
+		fmt.Fprintf(&c.b, ` stroke-width="%g"`, width)
+	}
+	c.b.WriteString(`/>`)
+}
+
+func (c *svgCanvas) polyline(points [][2]float64, width float64, col color.RGBA) {
+	fmt.Fprintf(&c.b, `<polyline fill="none" stroke="%s" stroke-width="%g" stroke-linejoin="round" points="`, hex(col), width)
+	for i, p := range points {
+		if i > 0 {
+			c.b.WriteByte(' ')
+		}
+		fmt.Fprintf(&c.b, "%.1f,%.1f", p[0], p[1])
+	}
+	c.b.WriteString(`"/>`)
+}
+
+func (c *svgCanvas) circle(x, y, r float64, col color.RGBA) {
+	fmt.Fprintf(&c.b, `<circle cx="%.1f" cy="%.1f" r="%g" fill="%s"/>`, x, y, r, hex(col))
+}
+
+func (c *svgCanvas) text(x, y float64, s string, size float64, bold bool, a anchor, col color.RGBA) {
+	fmt.Fprintf(&c.b, `<text x="%.1f" y="%.1f" fill="%s"`, x, y, hex(col))
+	if size != fontSize {
+		fmt.Fprintf(&c.b, ` font-size="%g"`, size)
+	}
+	if bold {
+		c.b.WriteString(` font-weight="bold"`)
+	}
+	switch a {
+	case anchorMiddle:
+		c.b.WriteString(` text-anchor="middle"`)
+	case anchorEnd:
+		c.b.WriteString(` text-anchor="end"`)
+	}
+	c.b.WriteString(`>` + html.EscapeString(s) + `</text>`)
+}
+
+// hex возвращает цвет в формате #rrggbb
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -1,23 +1,31 @@
 package clients
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log/slog"
 	"net/http"
+	"net/url"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
 )
 
 // geocodingUrl - шаблон URL для Geocoding API Open-Meteo
 // Параметры:
 // - name=%s: название города для поиска
 // - count=1: возвращать только первый результат
-// - language=ru: язык возвращаемых данных (русский)
+// - language=%s: язык названий в ответе
 // - format=json: формат ответа (JSON)
-const geocodingUrl = "https://geocoding-api.open-meteo.com/v1/search?name=%s&count=1&language=ru&format=json"
+const geocodingUrl = "https://geocoding-api.open-meteo.com/v1/search?name=%s&count=1&language=%s&format=json"
+
+// geocodingLanguage - язык названий, которые показываются в ответах сервиса
+const geocodingLanguage = "ru"
 
 // GeocodingResponse представляет структуру ответа от Geocoding API
 // Содержит информацию о найденном городе и его координатах
 type GeocodingResponse struct {
+	ID        int64   `json:"id"`        // Идентификатор места в GeoNames
 	Name      string  `json:"name"`      // Название города
 	Country   string  `json:"country"`   // Название страны
 	Latitude  float64 `json:"latitude"`  // Географическая широта
@@ -39,14 +47,25 @@ func NewGeocoding(httpClient *http.Client) *Geocoding {
 }
 
 // GetCoordinate выполняет запрос к Geocoding API для получения координат города
-// Возвращает информацию о городе и его координаты или ошибку в случае неудачи
-func (g *Geocoding) GetCoordinate(city string) (GeocodingResponse, error) {
+// Возвращает информацию о городе (название на русском) и его координаты или ошибку в случае неудачи
+// Если город не найден, возвращает models.ErrCityNotFound
+func (g *Geocoding) GetCoordinate(ctx context.Context, city string) (GeocodingResponse, error) {
+	return g.GetLocalized(ctx, city, geocodingLanguage)
+}
+
+// GetLocalized выполняет тот же поиск, что и GetCoordinate, с названием места на языке language
+// (код ISO 639-1). Используется для псевдонимов города на других языках
+func (g *Geocoding) GetLocalized(ctx context.Context, city, language string) (GeocodingResponse, error) {
 	// Формируем URL запроса с подстановкой названия города
-	res, err := g.httpClient.Get(
-		fmt.Sprintf(geocodingUrl, city),
-	)
+	// Название экранируется, так как может содержать пробелы и не-ASCII символы
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(geocodingUrl, url.QueryEscape(city), url.QueryEscape(language)), nil)
 	if err != nil {
-		slog.Error(err.Error())
+		return GeocodingResponse{}, err
+	}
+
+	res, err := g.httpClient.Do(req)
+	if err != nil {
+		pkg.FromContext(ctx).Error("geocoding request failed", "city", city, "error", err)
 		return GeocodingResponse{}, err // Возвращаем ошибку сети или таймаута
 	}
 
@@ -58,8 +77,8 @@ func (g *Geocoding) GetCoordinate(city string) (GeocodingResponse, error) {
 	// Ожидаем статус 200 OK, иначе считаем запрос неудачным
 	if res.StatusCode != http.StatusOK {
 		err := fmt.Errorf("status code %d", res.StatusCode)
-		slog.Error(err.Error())
-		return GeocodingResponse{}, fmt.Errorf("status code %d", res.StatusCode)
+		pkg.FromContext(ctx).Error("geocoding request failed", "city", city, "error", err)
+		return GeocodingResponse{}, err
 	}
 
 	// Структура для парсинга JSON ответа
@@ -72,11 +91,16 @@ func (g *Geocoding) GetCoordinate(city string) (GeocodingResponse, error) {
 	// json.NewDecoder более эффективен для потокового чтения
 	err = json.NewDecoder(res.Body).Decode(&geoResp)
 	if err != nil {
-		slog.Error(err.Error())
+		pkg.FromContext(ctx).Error("failed to decode geocoding response", "city", city, "error", err)
 		return GeocodingResponse{}, err // Возвращаем ошибку парсинга JSON
 	}
 
-	// Возвращаем первый (и единственный) результат из массива
 	// Так как в запросе указано count=1, массив содержит 0 или 1 элемент
+	// Пустой массив означает, что город с таким названием не найден
+	if len(geoResp.Results) == 0 {
+		return GeocodingResponse{}, models.ErrCityNotFound
+	}
+
+	// Возвращаем первый (и единственный) результат из массива
 	return geoResp.Results[0], nil
 }
@@ -0,0 +1,72 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// maxJWKSBytes ограничивает размер документа JWKS, чтобы ошибочный URL не исчерпал память
+const maxJWKSBytes = 1 << 20
+
+// JWKS - источник открытых ключей подписи JWT: файл или URL провайдера удостоверений
+type JWKS struct {
+	httpClient *http.Client // HTTP-клиент для загрузки по URL
+	file       string       // Путь к файлу JWKS
+	url        string       // URL JWKS, используется, если файл не задан
+}
+
+// NewJWKS создает источник JWKS. Задается либо путь к файлу, либо URL
+func NewJWKS(httpClient *http.Client, file, url string) *JWKS {
+	return &JWKS{
+		httpClient: httpClient,
+		file:       file,
+		url:        url,
+	}
+}
+
+// Load читает текущий набор ключей
+// Каждый вызов заново читает файл или запрашивает URL, кэширование остается вызывающему
+func (j *JWKS) Load(ctx context.Context) (jose.JSONWebKeySet, error) {
+	var raw []byte
+	var err error
+	if j.file != "" {
+		raw, err = os.ReadFile(j.file)
+	} else {
+		raw, err = j.fetch(ctx)
+	}
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return jose.JSONWebKeySet{}, fmt.Errorf("decode JWKS: %w", err)
+	}
+	return keys, nil
+}
+
+// fetch загружает документ JWKS по URL
+func (j *JWKS) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := j.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: status code %d", res.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, maxJWKSBytes))
+}
@@ -1,18 +1,21 @@
 package clients
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log/slog"
 	"net/http"
+
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
 )
 
 // openMeteoUrl - шаблон URL для Open-Meteo Weather API
 // Параметры:
 // - latitude=%f: географическая широта (подставляется как float)
 // - longitude=%f: географическая долгота (подставляется как float)
-// - current=temperature_2m: запрос текущей температуры на высоте 2 метра
-const openMeteoUrl = "https://api.open-meteo.com/v1/forecast?latitude=%f&longitude=%f&current=temperature_2m"
+// - current=temperature_2m,relative_humidity_2m,wind_speed_10m: текущие температура
+// и относительная влажность на высоте 2 метра и скорость ветра на высоте 10 метров (км/ч)
+const openMeteoUrl = "https://api.open-meteo.com/v1/forecast?latitude=%f&longitude=%f&current=temperature_2m,relative_humidity_2m,wind_speed_10m"
 
 // OpenMeteoResponse представляет структуру ответа от Open-Meteo API
 // Содержит текущие погодные данные для запрошенных координат
@@ -20,6 +23,10 @@ type OpenMeteoResponse struct {
 	Current struct {
 		Time          string  `json:"time"`           // Временная метка измерения в формате ISO 8601
 		Temperature2m float64 `json:"temperature_2m"` // Температура воздуха на высоте 2 метра в градусах Цельсия
+		// Относительная влажность на высоте 2 метра в процентах, nil - в ответе нет значения
+		RelativeHumidity2m *float64 `json:"relative_humidity_2m"`
+		// Скорость ветра на высоте 10 метров в км/ч, nil - в ответе нет значения
+		WindSpeed10m *float64 `json:"wind_speed_10m"`
 	}
 }
 
@@ -40,14 +47,17 @@ func NewOpenMeteo(httpClient *http.Client) *OpenMeteo {
 // GetTemperature выполняет запрос к Open-Meteo API для получения текущей температуры
 // Принимает географические координаты (широту и долготу)
 // Возвращает структуру с температурой и временем измерения или ошибку
-func (c *OpenMeteo) GetTemperature(lat, long float64) (OpenMeteoResponse, error) {
+func (c *OpenMeteo) GetTemperature(ctx context.Context, lat, long float64) (OpenMeteoResponse, error) {
 	// Формируем URL запроса с подстановкой координат
 	// fmt.Sprintf с %f форматирует float значения в строку
-	res, err := c.httpClient.Get(
-		fmt.Sprintf(openMeteoUrl, lat, long),
-	)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(openMeteoUrl, lat, long), nil)
+	if err != nil {
+		return OpenMeteoResponse{}, err
+	}
+
+	res, err := c.httpClient.Do(req)
 	if err != nil {
-		slog.Error(err.Error())
+		pkg.FromContext(ctx).Error("open-meteo request failed", "latitude", lat, "longitude", long, "error", err)
 		return OpenMeteoResponse{}, err // Возвращаем ошибки сети, таймаута и т.д.
 	}
 
@@ -58,8 +68,8 @@ func (c *OpenMeteo) GetTemperature(lat, long float64) (OpenMeteoResponse, error)
 	// Статус 200 OK указывает на успешное выполнение запроса
 	if res.StatusCode != http.StatusOK {
 		err := fmt.Errorf("status code %d", res.StatusCode)
-		slog.Error(err.Error())
-		return OpenMeteoResponse{}, fmt.Errorf("status code %d", res.StatusCode)
+		pkg.FromContext(ctx).Error("open-meteo request failed", "latitude", lat, "longitude", long, "error", err)
+		return OpenMeteoResponse{}, err
 	}
 
 	// Создаем структуру для парсинга JSON ответа
@@ -69,7 +79,7 @@ func (c *OpenMeteo) GetTemperature(lat, long float64) (OpenMeteoResponse, error)
 	// Это более эффективно чем чтение всего тела в память и затем парсинг
 	err = json.NewDecoder(res.Body).Decode(&response)
 	if err != nil {
-		slog.Error(err.Error())
+		pkg.FromContext(ctx).Error("failed to decode open-meteo response", "latitude", lat, "longitude", long, "error", err)
 		return OpenMeteoResponse{}, err // Возвращаем ошибки парсинга JSON
 	}
 
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"log"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
@@ -11,10 +14,23 @@ import (
 
 // Config содержит все конфигурационные параметры сервиса.
 type Config struct {
-	Env  string `yaml:"env" env-default:"local"`
-	Port int    `yaml:"port"`
-	Host string `yaml:"host"`
-	DB   DBConfig
+	Env       string    `yaml:"env" env-default:"local"`
+	Port      int       `yaml:"port"`
+	Host      string    `yaml:"host"`
+	TLS       TLSConfig `yaml:"tls"`
+	DB        DBConfig
+	GRPC      GRPCConfig      `yaml:"grpc"`
+	Weather   WeatherConfig   `yaml:"weather"`
+	Cron      CronConfig      `yaml:"cron"`
+	Stream    StreamConfig    `yaml:"stream"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Health    HealthConfig    `yaml:"health"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Log       LogConfig       `yaml:"log"`
+	Dashboard DashboardConfig `yaml:"dashboard"`
+	Stations  StationsConfig  `yaml:"stations"`
+	MQTT      MQTTConfig      `yaml:"mqtt"`
+	Alerts    []AlertRule     `yaml:"alerts"`
 }
 
 // DBConfig определяет параметры подключения к базе данных.
@@ -27,6 +43,253 @@ type DBConfig struct {
 	DBHost   string `env:"DB_HOST"`     // Адрес хоста БД
 }
 
+// Минимальные версии TLS
+const (
+	TLSVersion12 = "1.2"
+	TLSVersion13 = "1.3"
+)
+
+// TLSConfig определяет терминацию TLS в HTTP-сервере.
+// Если сертификат не задан, сервер принимает обычный HTTP, как раньше
+type TLSConfig struct {
+	// Пути к сертификату (с цепочкой промежуточных) и закрытому ключу в PEM
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE"`
+	// Минимальная версия TLS: 1.2 или 1.3
+	MinVersion string `yaml:"min_version" env:"TLS_MIN_VERSION" env-default:"1.2"`
+	// Интервал проверки файлов сертификата и ключа: измененные файлы перечитываются без перезапуска
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"TLS_RELOAD_INTERVAL" env-default:"1m"`
+	// Путь к сертификатам CA клиентов в PEM. Если задан, административные маршруты
+	// требуют клиентский сертификат, подписанный одним из них
+	ClientCAFile string `yaml:"client_ca_file" env:"TLS_CLIENT_CA_FILE"`
+}
+
+// Enabled сообщает, задан ли сертификат сервера
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// GRPCConfig определяет параметры gRPC-сервера.
+type GRPCConfig struct {
+	// Порт gRPC-сервера, отдельный от порта HTTP. Хост общий с HTTP-сервером
+	Port int `yaml:"port" env:"GRPC_PORT" env-default:"9090"`
+}
+
+// WeatherConfig определяет параметры выдачи погодных данных.
+type WeatherConfig struct {
+	// Максимальное время с момента получения сохраненного показания. Если данные
+	// получены раньше, GET /{city} запрашивает свежие данные во внешних API. 0 - без ограничения
+	MaxAge time.Duration `yaml:"max_age" env:"WEATHER_MAX_AGE" env-default:"30m"`
+	// Возраст показания, начиная с которого ответ помечается флагом stale. 0 - не помечать
+	StaleAfter time.Duration `yaml:"stale_after" env:"WEATHER_STALE_AFTER" env-default:"1h"`
+	// Языки (ISO 639-1), на которых названия впервые геокодированного города сохраняются
+	// его псевдонимами, чтобы город находился по названию на любом из них
+	AliasLanguages []string `yaml:"alias_languages" env:"WEATHER_ALIAS_LANGUAGES" env-separator:"," env-default:"en,ru"`
+}
+
+// CronConfig определяет параметры периодического сбора погодных данных.
+type CronConfig struct {
+	// Интервал между запусками сбора. Также определяет срок кэширования ответов GET /{city}
+	Interval time.Duration `yaml:"interval" env:"CRON_INTERVAL" env-default:"10s"`
+	// Города, показания которых собираются по расписанию. Их свежесть проверяет /readyz
+	Cities []string `yaml:"cities" env:"CRON_CITIES" env-separator:"," env-default:"moscow"`
+}
+
+// HealthConfig определяет параметры проверок готовности.
+type HealthConfig struct {
+	// Сколько интервалов сбора может пройти с последнего показания города,
+	// прежде чем /readyz сочтет данные устаревшими
+	FreshnessIntervals int `yaml:"freshness_intervals" env:"HEALTH_FRESHNESS_INTERVALS" env-default:"3"`
+	// Таймаут проверки соединения с базой
+	PingTimeout time.Duration `yaml:"ping_timeout" env:"HEALTH_PING_TIMEOUT" env-default:"2s"`
+}
+
+// Форматы записей лога
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// LogConfig определяет параметры логирования.
+type LogConfig struct {
+	// Минимальный уровень записей: debug, info, warn или error
+	Level string `yaml:"level" env:"LOG_LEVEL" env-default:"info"`
+	// Формат записей: json или text
+	Format string `yaml:"format" env:"LOG_FORMAT" env-default:"json"`
+}
+
+// Экспортеры спанов OpenTelemetry
+const (
+	TracingExporterOTLP   = "otlp"   // OTLP/gRPC, например в OpenTelemetry Collector или Jaeger
+	TracingExporterStdout = "stdout" // JSON в стандартный вывод, для локальной отладки
+	TracingExporterOff    = "off"    // спаны не записываются, контекст трассировки только передается дальше
+)
+
+// TracingConfig определяет параметры трассировки OpenTelemetry.
+type TracingConfig struct {
+	// Экспортер спанов: otlp, stdout или off
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"off"`
+	// Адрес OTLP/gRPC приемника в виде host:port. Пустое значение - адрес из стандартных
+	// переменных OTEL_EXPORTER_OTLP_*, по умолчанию localhost:4317
+	Endpoint string `yaml:"endpoint" env:"TRACING_ENDPOINT"`
+	// Подключаться к OTLP приемнику без TLS
+	Insecure bool `yaml:"insecure" env:"TRACING_INSECURE"`
+	// Доля записываемых трасс от 0 до 1. Решение родительского спана из traceparent соблюдается
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+	// Имя сервиса в ресурсе спанов (service.name)
+	ServiceName string `yaml:"service_name" env:"TRACING_SERVICE_NAME" env-default:"weather-service"`
+}
+
+// StreamConfig определяет параметры потоковой выдачи показаний (SSE и WebSocket).
+type StreamConfig struct {
+	// Интервал отправки heartbeat (комментарии SSE, ping-кадры WebSocket),
+	// чтобы прокси не закрывали простаивающее соединение
+	Heartbeat time.Duration `yaml:"heartbeat" env:"STREAM_HEARTBEAT" env-default:"15s"`
+	// Максимальное количество пропущенных показаний, досылаемых при переподключении с Last-Event-ID
+	ResumeLimit int `yaml:"resume_limit" env:"STREAM_RESUME_LIMIT" env-default:"1000"`
+	// Источники (Origin), с которых браузеры могут открывать WebSocket. "*" - любые
+	AllowedOrigins []string `yaml:"allowed_origins" env:"STREAM_ALLOWED_ORIGINS" env-separator:","`
+}
+
+// AuthConfig определяет параметры аутентификации HTTP API ключами.
+type AuthConfig struct {
+	// Требовать ключ API в заголовке X-API-Key. Если выключено, API открыт, как раньше
+	Enabled bool `yaml:"enabled" env:"AUTH_ENABLED"`
+	// Ключ с областью admin для создания первых ключей через /api/v1/admin/keys.
+	// Не хранится в базе и не ограничен квотами, поэтому задается только через окружение
+	BootstrapKey string `env:"AUTH_BOOTSTRAP_KEY"`
+	// Проверка JWT для административных маршрутов
+	JWT JWTConfig `yaml:"jwt"`
+}
+
+// JWTConfig определяет проверку bearer-токенов административных маршрутов.
+// Если источник JWKS не задан, административные маршруты защищены ключом API с областью admin
+type JWTConfig struct {
+	// Путь к файлу JWKS с открытыми ключами подписи. Задается либо файл, либо URL
+	JWKSFile string `yaml:"jwks_file" env:"AUTH_JWT_JWKS_FILE"`
+	// URL JWKS провайдера удостоверений
+	JWKSURL string `yaml:"jwks_url" env:"AUTH_JWT_JWKS_URL"`
+	// Интервал перечитывания JWKS, чтобы подхватывать ротацию ключей
+	Refresh time.Duration `yaml:"refresh" env:"AUTH_JWT_REFRESH" env-default:"10m"`
+	// Ожидаемые claims iss и aud. Пустое значение - не проверять
+	Issuer   string `yaml:"issuer" env:"AUTH_JWT_ISSUER"`
+	Audience string `yaml:"audience" env:"AUTH_JWT_AUDIENCE"`
+	// Claim со списком ролей. Вложенный claim задается через точку, например realm_access.roles
+	RolesClaim string `yaml:"roles_claim" env:"AUTH_JWT_ROLES_CLAIM" env-default:"roles"`
+	// Разрешения, выдаваемые каждой роли
+	Roles map[string][]string `yaml:"roles"`
+}
+
+// Enabled сообщает, задан ли источник JWKS
+func (c JWTConfig) Enabled() bool {
+	return c.JWKSFile != "" || c.JWKSURL != ""
+}
+
+// DashboardConfig определяет встроенную веб-панель с графиками температуры.
+type DashboardConfig struct {
+	// Отдавать панель по /dashboard. Панель показывает отслеживаемые города
+	// (cron.cities) по сохраненным показаниям, поэтому включается явно
+	Enabled bool `yaml:"enabled" env:"DASHBOARD_ENABLED"`
+	// Отдавать панель без ключа API при включенном auth.enabled. Открытая панель
+	// не расходует квоты, но и не обращается к внешним API
+	Public bool `yaml:"public" env:"DASHBOARD_PUBLIC"`
+	// Срок сессии панели: браузер входит ключом API один раз и дальше предъявляет cookie сессии
+	SessionTTL time.Duration `yaml:"session_ttl" env:"DASHBOARD_SESSION_TTL" env-default:"12h"`
+	// Ключ подписи cookie сессий. Реплики за балансировщиком должны использовать общий ключ.
+	// Если не задан, каждая реплика создает случайный ключ при запуске
+	SessionSecret string `env:"DASHBOARD_SESSION_SECRET"`
+}
+
+// StationsConfig определяет прием показаний собственных метеостанций.
+type StationsConfig struct {
+	// Максимальное количество показаний в одном запросе станции
+	MaxBatch int `yaml:"max_batch" env:"STATIONS_MAX_BATCH" env-default:"500"`
+	// Насколько время показания может опережать часы сервера: часы станции могут спешить
+	MaxFuture time.Duration `yaml:"max_future" env:"STATIONS_MAX_FUTURE" env-default:"5m"`
+	// Насколько старые показания принимаются, например накопленные станцией без связи
+	MaxPast time.Duration `yaml:"max_past" env:"STATIONS_MAX_PAST" env-default:"168h"`
+	// Сколько хранится результат запроса с ключом идемпотентности для ответа на повтор
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl" env:"STATIONS_IDEMPOTENCY_TTL" env-default:"24h"`
+}
+
+// Форматы времени измерения в сообщениях MQTT
+const (
+	MQTTTimestampRFC3339   = "rfc3339" // Строка RFC 3339, например 2025-01-02T03:04:05Z
+	MQTTTimestampUnix      = "unix"    // Секунды Unix, число или строка
+	MQTTTimestampUnixMilli = "unix_ms" // Миллисекунды Unix, число или строка
+)
+
+// MQTTConfig определяет прием показаний датчиков через брокер MQTT.
+// Если брокер не задан, сервис не подключается к MQTT
+type MQTTConfig struct {
+	// Адрес брокера, например tcp://mqtt:1883 или ssl://mqtt:8883
+	Broker string `yaml:"broker" env:"MQTT_BROKER"`
+	// Префикс идентификатора клиента. К нему добавляется случайный суффикс,
+	// чтобы реплики сервиса не вытесняли друг друга с брокера
+	ClientID string `yaml:"client_id" env:"MQTT_CLIENT_ID" env-default:"weather-service"`
+	// Учетные данные брокера. Пароль задается только через окружение
+	Username string `yaml:"username" env:"MQTT_USERNAME"`
+	Password string `env:"MQTT_PASSWORD"`
+	// Уровень QoS подписок: 0, 1 или 2
+	QoS int `yaml:"qos" env:"MQTT_QOS" env-default:"1"`
+	// Таймаут установки соединения с брокером
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"MQTT_CONNECT_TIMEOUT" env-default:"10s"`
+	// Максимальная пауза между попытками переподключения, паузы растут до нее экспоненциально.
+	// Если брокер недоступен при запуске, первое подключение повторяется с этой паузой
+	MaxReconnectInterval time.Duration `yaml:"max_reconnect_interval" env:"MQTT_MAX_RECONNECT_INTERVAL" env-default:"1m"`
+	// Допустимое время измерения относительно часов сервера, как у станций. Показания вне окна
+	// отклоняются: обычно это неверный timestamp_format (unix вместо unix_ms и наоборот)
+	MaxFuture time.Duration `yaml:"max_future" env:"MQTT_MAX_FUTURE" env-default:"5m"`
+	MaxPast   time.Duration `yaml:"max_past" env:"MQTT_MAX_PAST" env-default:"168h"`
+	// Подписки на темы и разбор их сообщений
+	Subscriptions []MQTTSubscription `yaml:"subscriptions"`
+}
+
+// Enabled сообщает, задан ли брокер
+func (c MQTTConfig) Enabled() bool {
+	return c.Broker != ""
+}
+
+// MQTTSubscription описывает подписку на темы датчиков и разбор их сообщений в формате JSON.
+// Город показания берется из поля сообщения Fields.City, уровня темы {city} или City - в этом порядке
+type MQTTSubscription struct {
+	// Шаблон темы с подстановками MQTT + и #. Уровень {city} совпадает с любым значением,
+	// как +, и задает город показания, например sensors/{city}/+/state
+	Topic string `yaml:"topic"`
+	// Город всех показаний подписки, если его не задают тема и сообщение
+	City string `yaml:"city"`
+	// Пути к значениям в сообщении
+	Fields MQTTFields `yaml:"fields"`
+	// Единицы значений в сообщениях: C, F или K и km/h, m/s, mph или kn. По умолчанию °C и км/ч
+	TemperatureUnit string `yaml:"temperature_unit"`
+	WindSpeedUnit   string `yaml:"wind_speed_unit"`
+	// Формат времени измерения: rfc3339, unix или unix_ms. По умолчанию rfc3339
+	TimestampFormat string `yaml:"timestamp_format"`
+}
+
+// MQTTFields задает пути к значениям в JSON сообщения. Вложенное поле задается через точку,
+// например data.temp. Пустой путь - поле с именем по умолчанию
+type MQTTFields struct {
+	Temperature string `yaml:"temperature"` // Температура, обязательна. По умолчанию temperature
+	Humidity    string `yaml:"humidity"`    // Относительная влажность, %. По умолчанию humidity
+	WindSpeed   string `yaml:"wind_speed"`  // Скорость ветра. По умолчанию wind_speed
+	// Время измерения. По умолчанию timestamp, без него - время получения сообщения
+	Timestamp string `yaml:"timestamp"`
+	// Город показания. По умолчанию город не берется из сообщения
+	City string `yaml:"city"`
+}
+
+// AlertRule описывает правило оповещения: условие над переменной показания города.
+// Оповещение публикуется в канал Channel, когда условие начинает выполняться.
+type AlertRule struct {
+	Name      string  `yaml:"name"`      // Название правила
+	Channel   string  `yaml:"channel"`   // Канал, на который подписываются клиенты
+	City      string  `yaml:"city"`      // Город, показания которого проверяются
+	Variable  string  `yaml:"variable"`  // Переменная показания из models.Variables (по умолчанию temperature)
+	Op        string  `yaml:"op"`        // Оператор сравнения: <, <=, >, >=
+	Threshold float64 `yaml:"threshold"` // Пороговое значение
+}
+
 // MustLoad загружает конфигурацию из файла или завершает работу при ошибке.
 // Функция ищет путь к конфигурационному файлу через флаги командной строки
 // или переменные окружения. Если путь не указан - вызывает панику.
@@ -58,10 +321,112 @@ func MustLoadByPath(path string) *Config {
 	if err != nil {
 		panic("failed to read config: " + err.Error())
 	}
+
+	if err := config.validate(); err != nil {
+		panic("invalid config: " + err.Error())
+	}
 	return &config
 
 }
 
+// validate проверяет значения, с которыми сервис не может работать
+func (c *Config) validate() error {
+	if c.Cron.Interval <= 0 {
+		return errors.New("cron.interval must be positive")
+	}
+	if len(c.Cron.Cities) == 0 {
+		return errors.New("cron.cities must not be empty")
+	}
+	if c.Health.FreshnessIntervals <= 0 {
+		return errors.New("health.freshness_intervals must be positive")
+	}
+	if c.Health.PingTimeout <= 0 {
+		return errors.New("health.ping_timeout must be positive")
+	}
+	if c.Stream.Heartbeat <= 0 {
+		return errors.New("stream.heartbeat must be positive")
+	}
+	if c.Stream.ResumeLimit <= 0 {
+		return errors.New("stream.resume_limit must be positive")
+	}
+	if tls := c.TLS; tls.Enabled() {
+		if tls.CertFile == "" || tls.KeyFile == "" {
+			return errors.New("tls.cert_file and tls.key_file must be set together")
+		}
+		if tls.MinVersion != TLSVersion12 && tls.MinVersion != TLSVersion13 {
+			return errors.New("tls.min_version must be 1.2 or 1.3")
+		}
+		if tls.ReloadInterval <= 0 {
+			return errors.New("tls.reload_interval must be positive")
+		}
+	} else if c.TLS.ClientCAFile != "" {
+		return errors.New("tls.client_ca_file requires tls.cert_file and tls.key_file")
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		return errors.New("log.level must be one of debug, info, warn, error")
+	}
+	if c.Log.Format != LogFormatJSON && c.Log.Format != LogFormatText {
+		return errors.New("log.format must be json or text")
+	}
+	switch c.Tracing.Exporter {
+	case TracingExporterOTLP, TracingExporterStdout, TracingExporterOff:
+	default:
+		return errors.New("tracing.exporter must be one of otlp, stdout, off")
+	}
+	if !(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1) {
+		return errors.New("tracing.sample_ratio must be between 0 and 1")
+	}
+	if c.Dashboard.Enabled && c.Dashboard.SessionTTL <= 0 {
+		return errors.New("dashboard.session_ttl must be positive")
+	}
+	if c.Stations.MaxBatch <= 0 {
+		return errors.New("stations.max_batch must be positive")
+	}
+	if c.Stations.MaxFuture <= 0 || c.Stations.MaxPast <= 0 {
+		return errors.New("stations.max_future and stations.max_past must be positive")
+	}
+	if c.Stations.IdempotencyTTL <= 0 {
+		return errors.New("stations.idempotency_ttl must be positive")
+	}
+	if mqtt := c.MQTT; mqtt.Enabled() {
+		if mqtt.QoS < 0 || mqtt.QoS > 2 {
+			return errors.New("mqtt.qos must be 0, 1 or 2")
+		}
+		if mqtt.ConnectTimeout <= 0 || mqtt.MaxReconnectInterval <= 0 {
+			return errors.New("mqtt.connect_timeout and mqtt.max_reconnect_interval must be positive")
+		}
+		if mqtt.MaxFuture <= 0 || mqtt.MaxPast <= 0 {
+			return errors.New("mqtt.max_future and mqtt.max_past must be positive")
+		}
+		if len(mqtt.Subscriptions) == 0 {
+			return errors.New("mqtt.subscriptions must not be empty")
+		}
+		for _, s := range mqtt.Subscriptions {
+			if s.Topic == "" {
+				return errors.New("mqtt.subscriptions: topic must not be empty")
+			}
+			switch s.TimestampFormat {
+			case "", MQTTTimestampRFC3339, MQTTTimestampUnix, MQTTTimestampUnixMilli:
+			default:
+				return errors.New("mqtt.subscriptions: timestamp_format must be one of rfc3339, unix, unix_ms")
+			}
+		}
+	}
+	if jwt := c.Auth.JWT; jwt.Enabled() {
+		if jwt.JWKSFile != "" && jwt.JWKSURL != "" {
+			return errors.New("auth.jwt.jwks_file and auth.jwt.jwks_url are mutually exclusive")
+		}
+		if jwt.Refresh <= 0 {
+			return errors.New("auth.jwt.refresh must be positive")
+		}
+		if len(jwt.Roles) == 0 {
+			return errors.New("auth.jwt.roles must map at least one role to permissions")
+		}
+	}
+	return nil
+}
+
 // fetchConfigPath извлекает путь к конфигурационному файлу из:
 // 1. Флагов командной строки (--config_path)
 // 2. Переменной окружения CONFIG_PATH
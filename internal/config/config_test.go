@@ -0,0 +1,156 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	valid := func() Config {
+		return Config{
+			Cron:    CronConfig{Interval: 10 * time.Minute, Cities: []string{"moscow"}},
+			Stream:  StreamConfig{Heartbeat: 15 * time.Second, ResumeLimit: 1000},
+			Health:  HealthConfig{FreshnessIntervals: 3, PingTimeout: 2 * time.Second},
+			Tracing: TracingConfig{Exporter: TracingExporterOff, SampleRatio: 1},
+			Log:     LogConfig{Level: "info", Format: "json"},
+			Stations: StationsConfig{
+				MaxBatch:       500,
+				MaxFuture:      5 * time.Minute,
+				MaxPast:        168 * time.Hour,
+				IdempotencyTTL: 24 * time.Hour,
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*Config)
+		want   string // Фрагмент ошибки, пустой для корректной конфигурации
+	}{
+		{name: "valid", mutate: func(*Config) {}},
+		{name: "zero heartbeat", mutate: func(c *Config) { c.Stream.Heartbeat = 0 }, want: "stream.heartbeat"},
+		{name: "negative heartbeat", mutate: func(c *Config) { c.Stream.Heartbeat = -time.Second }, want: "stream.heartbeat"},
+		{name: "zero cron interval", mutate: func(c *Config) { c.Cron.Interval = 0 }, want: "cron.interval"},
+		{name: "no cron cities", mutate: func(c *Config) { c.Cron.Cities = nil }, want: "cron.cities"},
+		{name: "zero freshness intervals", mutate: func(c *Config) { c.Health.FreshnessIntervals = 0 }, want: "health.freshness_intervals"},
+		{name: "zero ping timeout", mutate: func(c *Config) { c.Health.PingTimeout = 0 }, want: "health.ping_timeout"},
+		{name: "zero resume limit", mutate: func(c *Config) { c.Stream.ResumeLimit = 0 }, want: "stream.resume_limit"},
+		{name: "tls", mutate: func(c *Config) { c.TLS = validTLS() }},
+		{name: "mutual tls", mutate: func(c *Config) {
+			c.TLS = validTLS()
+			c.TLS.ClientCAFile = "ca.pem"
+		}},
+		{name: "tls without key", mutate: func(c *Config) {
+			c.TLS = validTLS()
+			c.TLS.KeyFile = ""
+		}, want: "set together"},
+		{name: "tls 1.1", mutate: func(c *Config) {
+			c.TLS = validTLS()
+			c.TLS.MinVersion = "1.1"
+		}, want: "tls.min_version"},
+		{name: "tls zero reload interval", mutate: func(c *Config) {
+			c.TLS = validTLS()
+			c.TLS.ReloadInterval = 0
+		}, want: "tls.reload_interval"},
+		{name: "client ca without tls", mutate: func(c *Config) { c.TLS.ClientCAFile = "ca.pem" }, want: "tls.client_ca_file"},
+		{name: "debug text log", mutate: func(c *Config) { c.Log = LogConfig{Level: "debug", Format: "text"} }},
+		{name: "unknown log level", mutate: func(c *Config) { c.Log.Level = "verbose" }, want: "log.level"},
+		{name: "unknown log format", mutate: func(c *Config) { c.Log.Format = "logfmt" }, want: "log.format"},
+		{name: "otlp tracing", mutate: func(c *Config) { c.Tracing.Exporter = TracingExporterOTLP }},
+		{name: "unknown tracing exporter", mutate: func(c *Config) { c.Tracing.Exporter = "jaeger" }, want: "tracing.exporter"},
+		{name: "empty tracing exporter", mutate: func(c *Config) { c.Tracing.Exporter = "" }, want: "tracing.exporter"},
+		{name: "sample ratio above one", mutate: func(c *Config) { c.Tracing.SampleRatio = 1.5 }, want: "tracing.sample_ratio"},
+		{name: "negative sample ratio", mutate: func(c *Config) { c.Tracing.SampleRatio = -0.1 }, want: "tracing.sample_ratio"},
+		{name: "dashboard zero session ttl", mutate: func(c *Config) { c.Dashboard = DashboardConfig{Enabled: true} }, want: "dashboard.session_ttl"},
+		{name: "zero station batch", mutate: func(c *Config) { c.Stations.MaxBatch = 0 }, want: "stations.max_batch"},
+		{name: "zero station max past", mutate: func(c *Config) { c.Stations.MaxPast = 0 }, want: "stations.max_past"},
+		{name: "zero idempotency ttl", mutate: func(c *Config) { c.Stations.IdempotencyTTL = 0 }, want: "stations.idempotency_ttl"},
+		{name: "mqtt", mutate: func(c *Config) { c.MQTT = validMQTT() }},
+		{name: "mqtt qos 3", mutate: func(c *Config) {
+			c.MQTT = validMQTT()
+			c.MQTT.QoS = 3
+		}, want: "mqtt.qos"},
+		{name: "mqtt zero reconnect interval", mutate: func(c *Config) {
+			c.MQTT = validMQTT()
+			c.MQTT.MaxReconnectInterval = 0
+		}, want: "mqtt.max_reconnect_interval"},
+		{name: "mqtt zero max past", mutate: func(c *Config) {
+			c.MQTT = validMQTT()
+			c.MQTT.MaxPast = 0
+		}, want: "mqtt.max_past"},
+		{name: "mqtt without subscriptions", mutate: func(c *Config) {
+			c.MQTT = validMQTT()
+			c.MQTT.Subscriptions = nil
+		}, want: "mqtt.subscriptions"},
+		{name: "mqtt empty topic", mutate: func(c *Config) {
+			c.MQTT = validMQTT()
+			c.MQTT.Subscriptions[0].Topic = ""
+		}, want: "topic"},
+		{name: "mqtt unknown timestamp format", mutate: func(c *Config) {
+			c.MQTT = validMQTT()
+			c.MQTT.Subscriptions[0].TimestampFormat = "iso"
+		}, want: "timestamp_format"},
+		{name: "jwt", mutate: func(c *Config) { c.Auth.JWT = validJWT() }},
+		{name: "jwt file and url", mutate: func(c *Config) {
+			c.Auth.JWT = validJWT()
+			c.Auth.JWT.JWKSURL = "https://idp.example.com/jwks.json"
+		}, want: "mutually exclusive"},
+		{name: "jwt zero refresh", mutate: func(c *Config) {
+			c.Auth.JWT = validJWT()
+			c.Auth.JWT.Refresh = 0
+		}, want: "auth.jwt.refresh"},
+		{name: "jwt without roles", mutate: func(c *Config) {
+			c.Auth.JWT = validJWT()
+			c.Auth.JWT.Roles = nil
+		}, want: "auth.jwt.roles"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.mutate(&cfg)
+
+			err := cfg.validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("got %v, want error mentioning %s", err, tt.want)
+			}
+		})
+	}
+}
+
+// validJWT возвращает корректную конфигурацию проверки JWT с JWKS из файла
+func validJWT() JWTConfig {
+	return JWTConfig{
+		JWKSFile: "jwks.json",
+		Refresh:  10 * time.Minute,
+		Roles:    map[string][]string{"admin": {"keys:read", "keys:write"}},
+	}
+}
+
+// validTLS возвращает корректную конфигурацию TLS
+func validTLS() TLSConfig {
+	return TLSConfig{
+		CertFile:       "server.pem",
+		KeyFile:        "server-key.pem",
+		MinVersion:     TLSVersion12,
+		ReloadInterval: time.Minute,
+	}
+}
+
+// validMQTT возвращает корректную конфигурацию MQTT с одной подпиской
+func validMQTT() MQTTConfig {
+	return MQTTConfig{
+		Broker:               "tcp://localhost:1883",
+		ClientID:             "weather-service",
+		QoS:                  1,
+		ConnectTimeout:       10 * time.Second,
+		MaxReconnectInterval: time.Minute,
+		MaxFuture:            5 * time.Minute,
+		MaxPast:              168 * time.Hour,
+		Subscriptions:        []MQTTSubscription{{Topic: "sensors/{city}/+"}},
+	}
+}
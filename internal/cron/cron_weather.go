@@ -2,100 +2,119 @@ package cron
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
-	"net/http"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
-	"github.com/olezhek28/wether-service/internal/clients"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+	"github.com/olezhek28/wether-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// city - константа с названием города для которого собирается погода
-const city = "moscow"
-
-// WeatherService определяет контракт для сохранения погодных данных
+// WeatherService определяет контракт для сбора и сохранения погодных данных
 // Используется для внедрения зависимости в cron-сервис
 type WeatherService interface {
-	AddWeather(ctx context.Context, name string, temperature float64, timestamp time.Time) error
+	RefreshWeather(ctx context.Context, city string) (models.WeatherDTO, error)
+}
+
+// Metrics определяет контракт учета запусков сбора
+type Metrics interface {
+	ObserveCollection(city string, duration time.Duration, err error)
 }
 
 // CronWeather представляет сервис для периодического сбора погодных данных
 // Выполняет запланированные задачи по сбору температуры через внешние API
 type CronWeather struct {
-	client          *http.Client       // HTTP-клиент для выполнения запросов к API
-	scheduler       gocron.Scheduler   // Планировщик задач для cron-выполнения
-	geocodingClient *clients.Geocoding // Клиент для получения координат города
-	openMeteo       *clients.OpenMeteo // Клиент для получения погодных данных
-	weatherService  WeatherService     // Сервис для сохранения данных в хранилище
+	scheduler      gocron.Scheduler // Планировщик задач для cron-выполнения
+	weatherService WeatherService   // Сервис для получения и сохранения данных
+	interval       time.Duration    // Интервал между запусками сбора
+	cities         []string         // Города, для которых собирается погода
+	metrics        Metrics          // Метрики запусков сбора по городам
+	job            gocron.Job       // Задача сбора, создается в Init
 }
 
 // New создает новый экземпляр CronWeather с инициализированными зависимостями
-// Принимает планировщик задач и сервис погоды для внедрения зависимостей
-func New(sheduler gocron.Scheduler, weatherService WeatherService) *CronWeather {
-	// Создаем HTTP-клиент с таймаутом для предотвращения зависаний
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	// Инициализируем клиенты для внешних API
-	geocodingClient := clients.NewGeocoding(client)
-	openMeteo := clients.NewOpenMeteo(client)
-
+// Принимает планировщик задач, сервис погоды, интервал сбора данных, список городов и метрики
+func New(sheduler gocron.Scheduler, weatherService WeatherService, interval time.Duration, cities []string, metrics Metrics) *CronWeather {
 	return &CronWeather{
-		client:          client,
-		scheduler:       sheduler,
-		geocodingClient: geocodingClient,
-		openMeteo:       openMeteo,
-		weatherService:  weatherService,
+		scheduler:      sheduler,
+		weatherService: weatherService,
+		interval:       interval,
+		cities:         cities,
+		metrics:        metrics,
 	}
 }
 
 // Init инициализирует cron-задачи и возвращает список созданных jobs
-// Создает периодическую задачу, которая выполняется каждые 10 секунд
+// Создает периодическую задачу, которая выполняется с интервалом из конфигурации
 func (c *CronWeather) Init(ctx context.Context) ([]gocron.Job, error) {
 	// Создаем новую задачу в планировщике:
-	// - DurationJob(10*time.Second) - задача выполняется каждые 10 секунд
+	// - DurationJob(c.interval) - задача выполняется каждые c.interval
 	// - NewTask(c.cronTask, ctx) - выполняемая функция с контекстом
 	job, err := c.scheduler.NewJob(gocron.DurationJob(
-		10*time.Second,
+		c.interval,
 	), gocron.NewTask(c.cronTask, ctx))
 	if err != nil {
 		slog.Error(err.Error())
 		panic(err) // Паника в случае ошибки создания задачи (можно заменить на логирование)
 	}
+	c.job = job
 
 	return []gocron.Job{job}, nil
 }
 
-// cronTask - основная функция, выполняемая по расписанию
-// Собирает данные о погоде и сохраняет их в хранилище
-func (c *CronWeather) cronTask(ctx context.Context) {
-	// 1. Получаем координаты города через геокодинг API
-	geocodingRes, err := c.geocodingClient.GetCoordinate(city)
-	if err != nil {
-		slog.Error(err.Error())
-		return // В случае ошибки просто выходим (можно добавить логирование)
+// State возвращает состояние задачи сбора для проверки готовности
+// До запуска планировщика и после его остановки Scheduled равно false
+func (c *CronWeather) State() models.CollectorState {
+	state := models.CollectorState{Interval: c.interval}
+	if c.job == nil {
+		return state
 	}
 
-	// 2. Получаем температуру по координатам через OpenMeteo API
-	openmeteoRes, err := c.openMeteo.GetTemperature(geocodingRes.Latitude, geocodingRes.Longitude)
-	if err != nil {
-		slog.Error(err.Error())
-		return
+	nextRun, err := c.job.NextRun()
+	if err != nil || nextRun.IsZero() {
+		return state
 	}
+	state.Scheduled = true
+	state.NextRun = nextRun
+	state.LastRun, _ = c.job.LastRun()
+	return state
+}
 
-	// 3. Парсим временную метку из строкового формата
-	// Формат "2006-01-02T15:04" - стандартный для Go (RFC 3339)
-	timestamp, err := time.Parse("2006-01-02T15:04", openmeteoRes.Current.Time)
-	if err != nil {
-		slog.Error(err.Error()) // Логируем ошибку парсинга времени
-		return
+// cronTask - основная функция, выполняемая по расписанию
+// Запрашивает текущую погоду во внешних API и сохраняет ее в хранилище
+// Каждый запуск - корневой спан трассировки, спаны обновления городов дочерние к нему
+func (c *CronWeather) cronTask(ctx context.Context) {
+	ctx, span := tracing.Start(ctx, "cron.CollectWeather",
+		trace.WithNewRoot(),
+		trace.WithAttributes(attribute.StringSlice("weather.cities", c.cities)),
+	)
+	defer span.End()
+
+	// Записи сервиса, хранилища и клиентов за время запуска связаны с его трассой
+	log := pkg.WithTrace(ctx, pkg.FromContext(ctx).With("job", "collect_weather"))
+	ctx = pkg.WithLogger(ctx, log)
+
+	// Геокодинг, запрос температуры и сохранение выполняются сервисом,
+	// тем же кодом, что и read-through в GET /{city}
+	// Ошибка одного города не мешает сбору остальных
+	failed := 0
+	for _, city := range c.cities {
+		start := time.Now()
+		_, err := c.weatherService.RefreshWeather(ctx, city)
+		c.metrics.ObserveCollection(city, time.Since(start), err)
+		if err != nil {
+			log.Error("weather collection failed", "city", city, "error", err)
+			span.RecordError(err, trace.WithAttributes(attribute.String("weather.city", city)))
+			failed++
+		}
 	}
 
-	// 4. Сохраняем полученные данные в хранилище через сервис
-	err = c.weatherService.AddWeather(ctx, city, openmeteoRes.Current.Temperature2m, timestamp)
-	if err != nil {
-		slog.Error(err.Error())
-		return
+	if failed > 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("%d of %d cities failed", failed, len(c.cities)))
 	}
 }
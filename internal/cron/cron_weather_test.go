@@ -0,0 +1,142 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/olezhek28/wether-service/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeWeatherService запоминает города, для которых запрашивался сбор,
+// и спаны, в которых он выполнялся
+type fakeWeatherService struct {
+	mu      sync.Mutex
+	cities  []string
+	parents []trace.SpanContext
+	failed  string // Город, сбор которого завершается ошибкой
+}
+
+func (f *fakeWeatherService) RefreshWeather(ctx context.Context, city string) (models.WeatherDTO, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.cities = append(f.cities, city)
+	f.parents = append(f.parents, trace.SpanContextFromContext(ctx))
+	if city == f.failed {
+		return models.WeatherDTO{}, errors.New("upstream unavailable")
+	}
+	return models.WeatherDTO{Name: city}, nil
+}
+
+// fakeMetrics запоминает результаты запусков сбора по городам
+type fakeMetrics struct {
+	mu      sync.Mutex
+	results map[string]error
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{results: make(map[string]error)}
+}
+
+func (f *fakeMetrics) ObserveCollection(city string, _ time.Duration, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.results[city] = err
+}
+
+func TestCronTaskCollectsAllCities(t *testing.T) {
+	svc := &fakeWeatherService{failed: "moscow"}
+	metrics := newFakeMetrics()
+	c := New(nil, svc, time.Minute, []string{"moscow", "paris"}, metrics)
+
+	c.cronTask(context.Background())
+
+	// Ошибка первого города не прерывает сбор остальных
+	if !slices.Equal(svc.cities, []string{"moscow", "paris"}) {
+		t.Errorf("collected %v, want moscow and paris", svc.cities)
+	}
+
+	// Каждый город учитывается в метриках со своим результатом
+	if err, ok := metrics.results["moscow"]; !ok || err == nil {
+		t.Errorf("moscow observed with %v, want failure", err)
+	}
+	if err, ok := metrics.results["paris"]; !ok || err != nil {
+		t.Errorf("paris observed with %v (present %t), want success", err, ok)
+	}
+}
+
+func TestCronTaskRootSpan(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	svc := &fakeWeatherService{failed: "paris"}
+	c := New(nil, svc, time.Minute, []string{"moscow", "paris"}, newFakeMetrics())
+
+	// Контекст запуска может нести спан, например спан старта приложения,
+	// но запуск сбора все равно начинает новую трассу
+	ctx, outer := tracing.Start(context.Background(), "startup")
+	c.cronTask(ctx)
+	outer.End()
+
+	var run sdktrace.ReadOnlySpan
+	for _, span := range rec.Ended() {
+		if span.Name() == "cron.CollectWeather" {
+			run = span
+		}
+	}
+	if run == nil {
+		t.Fatal("no cron.CollectWeather span")
+	}
+	if run.Parent().IsValid() || run.SpanContext().TraceID() == outer.SpanContext().TraceID() {
+		t.Error("collection run is not a root span")
+	}
+	if run.Status().Code != codes.Error || len(run.Events()) != 1 {
+		t.Errorf("status %+v with %d events, want error with one recorded failure", run.Status(), len(run.Events()))
+	}
+
+	// Обновления городов выполняются в спане запуска
+	for i, parent := range svc.parents {
+		if parent.SpanID() != run.SpanContext().SpanID() {
+			t.Errorf("%s refreshed outside of the run span", svc.cities[i])
+		}
+	}
+}
+
+func TestState(t *testing.T) {
+	scheduler, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(scheduler, &fakeWeatherService{}, time.Hour, []string{"moscow"}, newFakeMetrics())
+
+	if state := c.State(); state.Scheduled || state.Interval != time.Hour {
+		t.Errorf("before Init: %+v, want not scheduled", state)
+	}
+
+	c.Init(context.Background())
+	scheduler.Start()
+
+	state := c.State()
+	if !state.Scheduled || state.NextRun.Before(time.Now()) || !state.LastRun.IsZero() {
+		t.Errorf("after Start: %+v, want next run in the future and no runs yet", state)
+	}
+
+	if err := scheduler.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if state := c.State(); state.Scheduled {
+		t.Errorf("after Shutdown: %+v, want not scheduled", state)
+	}
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Операторы сравнения в правилах оповещений
+const (
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+)
+
+// Alert описывает сработавшее оповещение
+// Оповещение публикуется в канал правила, когда условие начинает выполняться
+type Alert struct {
+	Channel   string    `json:"channel"`    // Канал оповещений, на который подписываются клиенты
+	Rule      string    `json:"rule"`       // Название правила
+	Name      string    `json:"name"`       // Название города
+	Variable  string    `json:"variable"`   // Проверяемая переменная показания
+	Operator  string    `json:"op"`         // Оператор сравнения
+	Threshold float64   `json:"threshold"`  // Пороговое значение
+	Value     float64   `json:"value"`      // Значение переменной в показании
+	ReadingID int64     `json:"reading_id"` // Идентификатор показания, вызвавшего оповещение
+	Timestamp time.Time `json:"timestamp"`  // Время измерения показания
+}
+
+// IsOperator сообщает, что op - поддерживаемый оператор сравнения
+func IsOperator(op string) bool {
+	switch op {
+	case OpLess, OpLessEqual, OpGreater, OpGreaterEqual:
+		return true
+	default:
+		return false
+	}
+}
+
+// Compare применяет оператор сравнения к значению и порогу
+func Compare(value float64, op string, threshold float64) bool {
+	switch op {
+	case OpLess:
+		return value < threshold
+	case OpLessEqual:
+		return value <= threshold
+	case OpGreater:
+		return value > threshold
+	case OpGreaterEqual:
+		return value >= threshold
+	default:
+		return false
+	}
+}
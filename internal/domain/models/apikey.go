@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Области доступа ключей API
+// Области вложены: write включает read, admin включает write и read
+const (
+	ScopeRead  = "read"  // Чтение погоды, истории, статистики и потоков
+	ScopeWrite = "write" // Запись показаний
+	ScopeAdmin = "admin" // Управление ключами и другие административные операции
+)
+
+// Scopes - все допустимые области доступа в порядке расширения
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// APIKey описывает ключ API без секрета
+// В хранилище находится только хэш секрета, сам секрет показывается один раз при создании
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`                   // Описание владельца ключа
+	Prefix     string     `json:"prefix"`                 // Начало секрета, чтобы узнать ключ в списке
+	Scopes     []string   `json:"scopes"`                 // Области доступа
+	PerMinute  int        `json:"per_minute"`             // Квота запросов в минуту, 0 - без ограничения
+	PerDay     int        `json:"per_day"`                // Квота запросов в сутки (UTC), 0 - без ограничения
+	CreatedAt  time.Time  `json:"created_at"`             // Время создания
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // Время последнего запроса с ключом
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`   // Время отзыва, отозванный ключ не принимается
+}
+
+// HasScope сообщает, что ключу доступна область scope: выдана она сама или более широкая
+// Неизвестная область не доступна никому
+func (k *APIKey) HasScope(scope string) bool {
+	want := slices.Index(Scopes, scope)
+	if want < 0 {
+		return false
+	}
+	return slices.ContainsFunc(k.Scopes, func(s string) bool {
+		return slices.Index(Scopes, s) >= want
+	})
+}
+
+// NewAPIKey - параметры создаваемого ключа
+type NewAPIKey struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	PerMinute int      `json:"per_minute"`
+	PerDay    int      `json:"per_day"`
+}
+
+// CreatedAPIKey - созданный ключ вместе с секретом
+type CreatedAPIKey struct {
+	Key    APIKey `json:"key"`
+	Secret string `json:"secret"` // Секрет для заголовка X-API-Key, больше нигде не хранится
+}
+
+// ToResponse преобразует структуру CreatedAPIKey в JSON для HTTP-ответа
+func (c *CreatedAPIKey) ToResponse() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+// KeyUsage - количество запросов с ключом за сутки (UTC)
+type KeyUsage struct {
+	Day      time.Time `json:"day"`
+	Requests int       `json:"requests"`
+}
+
+// KeyCounters - счетчики запросов ключа в текущей минуте и текущих сутках с учетом этого запроса
+type KeyCounters struct {
+	Minute int
+	Day    int
+}
+
+// Quota - состояние квот ключа после запроса для заголовков ответа
+// Лимит 0 означает отсутствие ограничения
+type Quota struct {
+	PerMinute       int
+	PerDay          int
+	RemainingMinute int
+	RemainingDay    int
+	RetryAfter      time.Duration // Время до сброса исчерпанной квоты, 0 - квота не исчерпана
+}
+
+// KeyAccess - результат проверки ключа
+type KeyAccess struct {
+	Key   APIKey
+	Quota Quota
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Источники псевдонимов города
+const (
+	AliasSourceGeocoding = "geocoding" // Название из ответа геокодинга или название из запроса, по которому он выполнен
+	AliasSourceManual    = "manual"    // Добавлен администратором
+)
+
+// CityAlias - псевдоним, по которому находится город
+// Alias хранится нормализованным (см. NormalizeCity), поэтому псевдонимы, различающиеся
+// только регистром или способом записи одних и тех же символов, совпадают
+type CityAlias struct {
+	Alias     string    `json:"alias" db:"alias"`           // Нормализованный псевдоним
+	City      string    `json:"city" db:"name"`             // Ключ города, под которым хранятся показания
+	Source    string    `json:"source" db:"source"`         // Источник: geocoding или manual
+	CreatedAt time.Time `json:"created_at" db:"created_at"` // Время добавления
+}
+
+// NormalizeCity приводит название города к виду, в котором сравниваются псевдонимы:
+// NFKC, свертка регистра Unicode и повторная NFKC (свертка может нарушить нормальную форму),
+// пробелы по краям убираются, внутренние пробельные символы заменяются одним пробелом
+// Свертка регистра, в отличие от перевода в нижний регистр, не зависит от языка
+// "Moscow", "MOSCOW" и "Ｍｏｓｃｏｗ" из полноширинных букв дают одну строку, как и "Йошкар-Ола",
+// записанная с "й" одним символом и буквой "и" с комбинируемым знаком
+func NormalizeCity(name string) string {
+	// Caser хранит состояние, поэтому создается на каждый вызов
+	folded := norm.NFKC.String(cases.Fold().String(norm.NFKC.String(name)))
+	return strings.Join(strings.Fields(folded), " ")
+}
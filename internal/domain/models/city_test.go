@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestNormalizeCity(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "case", in: "MOSCOW", want: "moscow"},
+		{name: "fullwidth letters", in: "Ｍｏｓｃｏｗ", want: "moscow"},
+		{name: "cyrillic case", in: "МОСКВА", want: "москва"},
+		{name: "composed short i", in: "\u0419ошкар-Ола", want: "йошкар-ола"},
+		{name: "decomposed short i", in: "\u0418\u0306ошкар-Ола", want: "йошкар-ола"},
+		{name: "german sharp s", in: "GROSSENHAIN", want: NormalizeCity("Großenhain")},
+		{name: "whitespace", in: "  Nizhny \t Novgorod\n", want: "nizhny novgorod"},
+		{name: "blank", in: " \t ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeCity(tt.in); got != tt.want {
+				t.Errorf("NormalizeCity(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
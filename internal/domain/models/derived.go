@@ -0,0 +1,223 @@
+package models
+
+import (
+	"math"
+	"slices"
+)
+
+// Переменные показания: измеренные и вычисленные по ним
+// Используются в правилах оповещений и в сводной статистике,
+// в базе каждой переменной соответствует колонка reading с тем же названием
+const (
+	VariableTemperature      = "temperature"       // Температура воздуха, °C
+	VariableHumidity         = "humidity"          // Относительная влажность, %
+	VariableWindSpeed        = "wind_speed"        // Скорость ветра на высоте 10 м, км/ч
+	VariableDewPoint         = "dew_point"         // Точка росы, °C
+	VariableHeatIndex        = "heat_index"        // Индекс жары, °C
+	VariableWindChill        = "wind_chill"        // Ветро-холодовой индекс, °C
+	VariableHumidex          = "humidex"           // Хьюмидекс, °C
+	VariableAbsoluteHumidity = "absolute_humidity" // Абсолютная влажность, г/м³
+	VariableFeelsLike        = "feels_like"        // Ощущаемая температура, °C
+)
+
+// Variables - все переменные показания в порядке вывода
+var Variables = []string{
+	VariableTemperature, VariableHumidity, VariableWindSpeed,
+	VariableDewPoint, VariableHeatIndex, VariableWindChill, VariableHumidex, VariableAbsoluteHumidity, VariableFeelsLike,
+}
+
+// IsVariable сообщает, что variable - поддерживаемая переменная показания
+func IsVariable(variable string) bool {
+	return slices.Contains(Variables, variable)
+}
+
+// ReadingValue возвращает значение переменной показания
+// Второе значение false, если переменная не поддерживается, не измерена
+// или условия показания вне области применимости формулы
+func ReadingValue(weather WeatherDTO, variable string) (float64, bool) {
+	switch variable {
+	case VariableTemperature:
+		return weather.Temperature, true
+	case VariableHumidity:
+		return value(weather.Humidity)
+	case VariableWindSpeed:
+		return value(weather.WindSpeed)
+	}
+
+	d := weather.Derived()
+	switch variable {
+	case VariableDewPoint:
+		return value(d.DewPoint)
+	case VariableHeatIndex:
+		return value(d.HeatIndex)
+	case VariableWindChill:
+		return value(d.WindChill)
+	case VariableHumidex:
+		return value(d.Humidex)
+	case VariableAbsoluteHumidity:
+		return value(d.AbsoluteHumidity)
+	case VariableFeelsLike:
+		return value(d.FeelsLike)
+	default:
+		return 0, false
+	}
+}
+
+// Derived содержит величины, вычисленные по температуре, влажности и ветру показания
+// nil - величина не вычисляется: не измерены влажность или ветер, либо условия
+// вне области применимости формулы, где ее результат не имеет смысла
+type Derived struct {
+	DewPoint         *float64 `json:"dew_point,omitempty"`         // Точка росы, °C
+	HeatIndex        *float64 `json:"heat_index,omitempty"`        // Индекс жары, °C
+	WindChill        *float64 `json:"wind_chill,omitempty"`        // Ветро-холодовой индекс, °C
+	Humidex          *float64 `json:"humidex,omitempty"`           // Хьюмидекс, °C
+	AbsoluteHumidity *float64 `json:"absolute_humidity,omitempty"` // Абсолютная влажность, г/м³
+	FeelsLike        *float64 `json:"feels_like,omitempty"`        // Ощущаемая температура, °C
+}
+
+// Derived вычисляет производные величины показания
+func (w WeatherDTO) Derived() Derived {
+	return Derive(w.Temperature, w.Humidity, w.WindSpeed)
+}
+
+// Derive вычисляет производные величины по температуре (°C), относительной
+// влажности (%) и скорости ветра на высоте 10 м (км/ч)
+// Температуры округляются до десятых, абсолютная влажность - до сотых
+//
+// Ощущаемая температура - индекс жары в жару, ветро-холодовой индекс в холод
+// и сама температура в остальных случаях, как у NWS. Она вычисляется, только если
+// известны и влажность, и ветер: иначе неизвестно, какой из случаев применим
+func Derive(temperature float64, humidity, windSpeed *float64) Derived {
+	var d Derived
+
+	rh, hasHumidity := value(humidity)
+	hasHumidity = hasHumidity && rh > 0 && rh <= 100
+	wind, hasWind := value(windSpeed)
+	hasWind = hasWind && wind >= 0
+
+	if hasHumidity {
+		d.DewPoint = rounded(DewPoint(temperature, rh))
+		d.HeatIndex = rounded(HeatIndex(temperature, rh))
+		d.Humidex = rounded(Humidex(temperature, rh))
+		if v, ok := AbsoluteHumidity(temperature, rh); ok {
+			v = math.Round(v*100) / 100
+			d.AbsoluteHumidity = &v
+		}
+	}
+	if hasWind {
+		d.WindChill = rounded(WindChill(temperature, wind))
+	}
+
+	if hasHumidity && hasWind {
+		feelsLike := temperature
+		switch {
+		case d.HeatIndex != nil:
+			feelsLike = *d.HeatIndex
+		case d.WindChill != nil:
+			feelsLike = *d.WindChill
+		}
+		d.FeelsLike = &feelsLike
+	}
+
+	return d
+}
+
+// Коэффициенты формулы Магнуса для давления насыщенного пара над водой (WMO, Sonntag 1990)
+const (
+	magnusE0 = 6.112  // Давление насыщенного пара при 0 °C, гПа
+	magnusB  = 17.62  // Безразмерный коэффициент
+	magnusC  = 243.12 // °C
+)
+
+// Область применимости формулы Магнуса с коэффициентами WMO, °C
+const (
+	magnusMinTemperature = -45
+	magnusMaxTemperature = 60
+)
+
+// DewPoint возвращает точку росы (°C) по формуле Магнуса
+// Применима при температуре от -45 до 60 °C и влажности от 0 (не включая) до 100 %,
+// погрешность в этом диапазоне не больше 0.35 °C
+func DewPoint(temperature, humidity float64) (float64, bool) {
+	if temperature < magnusMinTemperature || temperature > magnusMaxTemperature || humidity <= 0 || humidity > 100 {
+		return 0, false
+	}
+	gamma := math.Log(humidity/100) + magnusB*temperature/(magnusC+temperature)
+	return magnusC * gamma / (magnusB - gamma), true
+}
+
+// AbsoluteHumidity возвращает абсолютную влажность (г/м³): массу водяного пара
+// в кубометре воздуха по парциальному давлению пара из формулы Магнуса и уравнению
+// состояния идеального газа. Применима при температуре от -45 до 60 °C и влажности от 0 до 100 %
+func AbsoluteHumidity(temperature, humidity float64) (float64, bool) {
+	if temperature < magnusMinTemperature || temperature > magnusMaxTemperature || humidity < 0 || humidity > 100 {
+		return 0, false
+	}
+	// Парциальное давление пара, гПа
+	vapor := humidity / 100 * magnusE0 * math.Exp(magnusB*temperature/(magnusC+temperature))
+	// 216.7 = 100 Па/гПа * 1000 г/кг / 461.5 Дж/(кг*К) - газовая постоянная водяного пара
+	return 216.7 * vapor / (273.15 + temperature), true
+}
+
+// HeatIndex возвращает индекс жары (°C) по регрессии Ротфуса (NWS) с поправкой на высокую влажность
+// Применим при температуре от 26.7 °C (80 °F) и влажности от 40 %: регрессия построена
+// по таблицам Стедмана для жары, при более низких значениях NWS индекс не публикует
+func HeatIndex(temperature, humidity float64) (float64, bool) {
+	if temperature < 26.7 || humidity < 40 || humidity > 100 {
+		return 0, false
+	}
+
+	t := temperature*9/5 + 32
+	rh := humidity
+	hi := -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh - 0.00683783*t*t - 0.05481717*rh*rh +
+		0.00122874*t*t*rh + 0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+	if rh > 85 && t <= 87 {
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+	return (hi - 32) * 5 / 9, true
+}
+
+// WindChill возвращает ветро-холодовой индекс (°C) по формуле JAG/TI 2001 (Environment Canada, NWS)
+// для скорости ветра на высоте 10 м в км/ч
+// Применим при температуре не выше 10 °C и ветре сильнее 4.8 км/ч: при слабом ветре
+// и в тепле охлаждение ветром не определено
+func WindChill(temperature, windSpeed float64) (float64, bool) {
+	if temperature > 10 || windSpeed <= 4.8 {
+		return 0, false
+	}
+	v := math.Pow(windSpeed, 0.16)
+	return 13.12 + 0.6215*temperature - 11.37*v + 0.3965*temperature*v, true
+}
+
+// Humidex возвращает хьюмидекс (°C) по формуле Environment Canada через точку росы
+// Применим при температуре от 20 °C: Environment Canada рассчитывает его только для тепла,
+// а в холоде ощущаемую температуру описывает ветро-холодовой индекс
+func Humidex(temperature, humidity float64) (float64, bool) {
+	if temperature < 20 {
+		return 0, false
+	}
+	dewPoint, ok := DewPoint(temperature, humidity)
+	if !ok {
+		return 0, false
+	}
+	// Давление пара по точке росы, гПа
+	vapor := 6.11 * math.Exp(5417.7530*(1/273.16-1/(273.15+dewPoint)))
+	return temperature + 0.5555*(vapor-10), true
+}
+
+// rounded возвращает указатель на значение, округленное до десятых, или nil, если значения нет
+func rounded(v float64, ok bool) *float64 {
+	if !ok {
+		return nil
+	}
+	v = math.Round(v*10) / 10
+	return &v
+}
+
+// value разыменовывает необязательное значение
+func value(v *float64) (float64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	return *v, true
+}
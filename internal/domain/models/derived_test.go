@@ -0,0 +1,171 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDerivedFormulas(t *testing.T) {
+	// Контрольные значения из таблиц NWS, Environment Canada и психрометрических таблиц
+	// Допуск учитывает погрешность самих приближений относительно таблиц
+	tests := []struct {
+		name string
+		fn   func() (float64, bool)
+		want float64
+		tol  float64
+	}{
+		{name: "dew point", fn: func() (float64, bool) { return DewPoint(20, 50) }, want: 9.3, tol: 0.05},
+		{name: "dew point at saturation", fn: func() (float64, bool) { return DewPoint(15, 100) }, want: 15, tol: 1e-9},
+		{name: "absolute humidity", fn: func() (float64, bool) { return AbsoluteHumidity(20, 50) }, want: 8.65, tol: 0.05},
+		{name: "heat index", fn: func() (float64, bool) { return HeatIndex(32.2, 70) }, want: 40.8, tol: 0.5},
+		{name: "heat index high humidity", fn: func() (float64, bool) { return HeatIndex(27.8, 90) }, want: 32.8, tol: 0.7},
+		{name: "wind chill", fn: func() (float64, bool) { return WindChill(-10, 20) }, want: -17.9, tol: 0.05},
+		{name: "humidex", fn: func() (float64, bool) { return Humidex(30, 40.3) }, want: 34, tol: 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.fn()
+			if !ok {
+				t.Fatal("value is outside of the formula range")
+			}
+			if math.Abs(got-tt.want) > tt.tol {
+				t.Errorf("got %.3f, want %g ± %g", got, tt.want, tt.tol)
+			}
+		})
+	}
+}
+
+func TestDerivedFormulaRanges(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func() (float64, bool)
+	}{
+		{name: "dew point below range", fn: func() (float64, bool) { return DewPoint(-50, 50) }},
+		{name: "dew point without humidity", fn: func() (float64, bool) { return DewPoint(20, 0) }},
+		{name: "absolute humidity above range", fn: func() (float64, bool) { return AbsoluteHumidity(65, 50) }},
+		{name: "heat index in cool air", fn: func() (float64, bool) { return HeatIndex(25, 80) }},
+		{name: "heat index in dry air", fn: func() (float64, bool) { return HeatIndex(35, 30) }},
+		{name: "wind chill in warm air", fn: func() (float64, bool) { return WindChill(12, 20) }},
+		{name: "wind chill in calm", fn: func() (float64, bool) { return WindChill(-10, 4) }},
+		{name: "humidex in cold air", fn: func() (float64, bool) { return Humidex(15, 80) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v, ok := tt.fn(); ok {
+				t.Errorf("got %g, want no value outside of the formula range", v)
+			}
+		})
+	}
+}
+
+func TestDerive(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+
+	t.Run("temperature only", func(t *testing.T) {
+		if d := Derive(20, nil, nil); d != (Derived{}) {
+			t.Errorf("got %+v, want no derived values", d)
+		}
+	})
+
+	t.Run("mild weather", func(t *testing.T) {
+		d := Derive(20, f(50), f(10))
+		if d.DewPoint == nil || *d.DewPoint != 9.3 {
+			t.Errorf("dew point = %v, want 9.3", d.DewPoint)
+		}
+		if d.AbsoluteHumidity == nil || *d.AbsoluteHumidity != 8.62 {
+			t.Errorf("absolute humidity = %v, want 8.62", d.AbsoluteHumidity)
+		}
+		if d.HeatIndex != nil || d.WindChill != nil {
+			t.Errorf("got heat index %v and wind chill %v, want none", d.HeatIndex, d.WindChill)
+		}
+		if d.FeelsLike == nil || *d.FeelsLike != 20 {
+			t.Errorf("feels like = %v, want air temperature", d.FeelsLike)
+		}
+	})
+
+	t.Run("cold wind", func(t *testing.T) {
+		d := Derive(-10, f(80), f(20))
+		if d.WindChill == nil || *d.WindChill != -17.9 || d.FeelsLike == nil || *d.FeelsLike != -17.9 {
+			t.Errorf("got wind chill %v and feels like %v, want -17.9", d.WindChill, d.FeelsLike)
+		}
+		if d.Humidex != nil {
+			t.Errorf("humidex = %v, want none in cold air", *d.Humidex)
+		}
+	})
+
+	t.Run("hot and humid", func(t *testing.T) {
+		d := Derive(32.2, f(70), f(5))
+		if d.HeatIndex == nil || d.FeelsLike == nil || *d.FeelsLike != *d.HeatIndex {
+			t.Errorf("got heat index %v and feels like %v, want feels like equal to heat index", d.HeatIndex, d.FeelsLike)
+		}
+		if d.Humidex == nil {
+			t.Error("humidex is missing in hot air")
+		}
+	})
+
+	t.Run("feels like needs humidity and wind", func(t *testing.T) {
+		if d := Derive(-10, nil, f(20)); d.WindChill == nil || d.FeelsLike != nil {
+			t.Errorf("got wind chill %v and feels like %v, want wind chill only", d.WindChill, d.FeelsLike)
+		}
+	})
+
+	t.Run("invalid humidity", func(t *testing.T) {
+		if d := Derive(20, f(120), nil); d != (Derived{}) {
+			t.Errorf("got %+v, want no derived values", d)
+		}
+	})
+}
+
+func TestReadingValue(t *testing.T) {
+	humidity, wind := 50.0, 10.0
+	weather := WeatherDTO{Temperature: 20, Humidity: &humidity, WindSpeed: &wind}
+
+	for variable, want := range map[string]float64{
+		VariableTemperature: 20,
+		VariableHumidity:    50,
+		VariableWindSpeed:   10,
+		VariableDewPoint:    9.3,
+		VariableFeelsLike:   20,
+	} {
+		if got, ok := ReadingValue(weather, variable); !ok || got != want {
+			t.Errorf("%s = %g, %t; want %g", variable, got, ok, want)
+		}
+	}
+
+	for _, variable := range []string{VariableHeatIndex, VariableWindChill, "pressure"} {
+		if got, ok := ReadingValue(weather, variable); ok {
+			t.Errorf("%s = %g, want no value", variable, got)
+		}
+	}
+	if _, ok := ReadingValue(WeatherDTO{Temperature: 20}, VariableHumidity); ok {
+		t.Error("humidity of a reading without it has a value")
+	}
+}
+
+func TestWeatherDerivedJSON(t *testing.T) {
+	humidity, wind := 50.0, 10.0
+	dto := WeatherDTO{Name: "moscow", Temperature: 20, Humidity: &humidity, WindSpeed: &wind}
+
+	var weather Weather
+	dto.ToWeather(&weather)
+	raw, err := weather.ToResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"humidity":50`, `"wind_speed":10`, `"derived":{"dew_point":9.3,"humidex":20.9,"absolute_humidity":8.62,"feels_like":20}`} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("%s does not contain %s", raw, want)
+		}
+	}
+
+	// Старые показания без влажности и ветра сериализуются как раньше
+	var legacy Weather
+	(&WeatherDTO{Name: "moscow", Temperature: 20}).ToWeather(&legacy)
+	if raw, _ := json.Marshal(legacy); strings.Contains(string(raw), "derived") || strings.Contains(string(raw), "humidity") {
+		t.Errorf("legacy reading has new fields: %s", raw)
+	}
+}
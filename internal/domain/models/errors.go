@@ -0,0 +1,61 @@
+package models
+
+import "errors"
+
+var (
+	// ErrCityNotFound возвращается, когда для города нет сохраненных данных
+	ErrCityNotFound = errors.New("No city with same name")
+
+	// ErrNoLocationNearby возвращается, когда в радиусе поиска нет отслеживаемых городов
+	ErrNoLocationNearby = errors.New("no tracked location within the radius")
+
+	// ErrUnauthorized возвращается, когда ключ API не передан, неизвестен или отозван
+	ErrUnauthorized = errors.New("missing or invalid API key")
+
+	// ErrForbidden возвращается, когда ключу не выдана нужная область доступа
+	ErrForbidden = errors.New("API key does not have the required scope")
+
+	// ErrQuotaExceeded возвращается, когда квота запросов ключа исчерпана
+	ErrQuotaExceeded = errors.New("API key quota exceeded")
+
+	// ErrInvalidToken возвращается, когда bearer-токен не передан, не проходит проверку подписи или истек
+	ErrInvalidToken = errors.New("missing or invalid bearer token")
+
+	// ErrPermissionDenied возвращается, когда ролям токена не выдано нужное разрешение
+	ErrPermissionDenied = errors.New("token does not grant the required permission")
+
+	// ErrClientCertRequired возвращается, когда административный маршрут вызван
+	// без клиентского сертификата, подписанного CA клиентов
+	ErrClientCertRequired = errors.New("verified client certificate required")
+
+	// ErrKeyNotFound возвращается, когда ключа API с указанным идентификатором нет
+	ErrKeyNotFound = errors.New("API key not found")
+
+	// ErrAliasTaken возвращается, когда псевдоним уже указывает на другой город
+	ErrAliasTaken = errors.New("alias already belongs to another city")
+
+	// ErrStationNotFound возвращается, когда станция не зарегистрирована
+	ErrStationNotFound = errors.New("station not found")
+
+	// ErrStationExists возвращается при регистрации станции с занятым идентификатором
+	ErrStationExists = errors.New("station already exists")
+
+	// ErrInvalidStationKey возвращается, когда ключ станции не передан, неизвестен или отозван
+	ErrInvalidStationKey = errors.New("missing or invalid station key")
+
+	// ErrWrongStation возвращается, когда ключ выдан другой станции
+	ErrWrongStation = errors.New("station key belongs to another station")
+
+	// ErrIdempotencyMismatch возвращается, когда ключ идемпотентности уже использован с другим запросом
+	ErrIdempotencyMismatch = errors.New("idempotency key was used with a different request")
+
+	// ErrIdempotencyInProgress возвращается, когда запрос с тем же ключом идемпотентности еще обрабатывается
+	ErrIdempotencyInProgress = errors.New("request with this idempotency key is in progress")
+
+	// ErrNoData возвращается, когда в запрошенном диапазоне нет ни одного показания
+	ErrNoData = errors.New("no data for the requested period")
+
+	// ErrInvalidQuery возвращается, когда параметры запроса не прошли валидацию.
+	// Конкретная причина оборачивается через fmt.Errorf("%w: ...")
+	ErrInvalidQuery = errors.New("invalid query")
+)
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// EarthRadiusKm - средний радиус Земли в километрах (IUGG)
+const EarthRadiusKm = 6371.0088
+
+// MaxDistanceKm - половина длины большого круга: дальше по поверхности Земли быть нельзя
+const MaxDistanceKm = math.Pi * EarthRadiusKm
+
+// NearestQuery описывает поиск ближайшего отслеживаемого города по координатам
+type NearestQuery struct {
+	Latitude  float64 // Широта точки в градусах, [-90, 90]
+	Longitude float64 // Долгота точки в градусах, [-180, 180]
+	RadiusKm  float64 // Радиус поиска в километрах
+	Live      bool    // Если в радиусе нет городов, запросить погоду для самих координат во внешнем API
+}
+
+// NearestWeather - ответ на поиск ближайшего города
+type NearestWeather struct {
+	City       string  `json:"city,omitempty"` // Ключ ближайшего города, пустой для live-ответа
+	DistanceKm float64 `json:"distance_km"`    // Расстояние от точки запроса до города по большому кругу
+	Live       bool    `json:"live,omitempty"` // Показание получено из внешнего API для координат запроса и не сохранено
+	Weather    Weather `json:"weather"`        // Последнее показание города
+}
+
+// ToResponse преобразует структуру NearestWeather в JSON для HTTP-ответа
+func (n *NearestWeather) ToResponse() ([]byte, error) {
+	return json.Marshal(n)
+}
+
+// Distance возвращает расстояние между точками по большому кругу в километрах
+// Используется формула гаверсинусов: в отличие от сферической теоремы косинусов
+// она устойчива на малых расстояниях. Погрешность из-за сферической модели Земли
+// не превышает 0.5%, чего достаточно для выбора ближайшего города
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := radians(lat1), radians(lat2)
+	dPhi := phi2 - phi1
+	dLambda := radians(lon2 - lon1)
+
+	h := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(min(h, 1)))
+}
+
+// BoundingBox - прямоугольник в градусах, содержащий круг поиска
+// Если MinLongitude > MaxLongitude, прямоугольник пересекает 180-й меридиан
+// и состоит из долгот [MinLongitude, 180] и [-180, MaxLongitude]
+type BoundingBox struct {
+	MinLatitude, MaxLatitude   float64
+	MinLongitude, MaxLongitude float64
+}
+
+// BoundingBoxAround возвращает прямоугольник, содержащий все точки не дальше radiusKm от центра
+// Прямоугольник отбирает кандидатов по индексу координат, точное расстояние
+// затем считается через Distance. Если круг накрывает полюс, берутся все долготы
+func BoundingBoxAround(lat, lon, radiusKm float64) BoundingBox {
+	angular := radiusKm / EarthRadiusKm // Угловой радиус в радианах
+	dLat := degrees(angular)
+
+	box := BoundingBox{
+		MinLatitude:  lat - dLat,
+		MaxLatitude:  lat + dLat,
+		MinLongitude: -180,
+		MaxLongitude: 180,
+	}
+
+	if box.MinLatitude <= -90 || box.MaxLatitude >= 90 || angular >= math.Pi/2 {
+		box.MinLatitude = max(box.MinLatitude, -90)
+		box.MaxLatitude = min(box.MaxLatitude, 90)
+		return box
+	}
+
+	dLon := degrees(math.Asin(math.Sin(angular) / math.Cos(radians(lat))))
+	box.MinLongitude, box.MaxLongitude = lon-dLon, lon+dLon
+	if box.MinLongitude < -180 {
+		box.MinLongitude += 360
+	}
+	if box.MaxLongitude > 180 {
+		box.MaxLongitude -= 360
+	}
+
+	return box
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
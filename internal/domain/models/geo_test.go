@@ -0,0 +1,83 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64 // Ожидаемое расстояние, км
+		tolerance              float64
+	}{
+		{name: "same point", lat1: 55.75, lon1: 37.62, lat2: 55.75, lon2: 37.62, want: 0, tolerance: 1e-9},
+		{name: "moscow - saint petersburg", lat1: 55.7558, lon1: 37.6173, lat2: 59.9343, lon2: 30.3351, want: 634, tolerance: 3},
+		{name: "moscow - kazan", lat1: 55.7558, lon1: 37.6173, lat2: 55.7963, lon2: 49.1088, want: 719, tolerance: 3},
+		{name: "across antimeridian", lat1: 0, lon1: 179.5, lat2: 0, lon2: -179.5, want: 111.2, tolerance: 0.1},
+		{name: "antipodes", lat1: 10, lon1: 20, lat2: -10, lon2: -160, want: MaxDistanceKm, tolerance: 1e-6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Distance(tt.lat1, tt.lon1, tt.lat2, tt.lon2)
+			if math.Abs(got-tt.want) > tt.tolerance {
+				t.Errorf("got %.3f km, want %.3f ± %g", got, tt.want, tt.tolerance)
+			}
+		})
+	}
+}
+
+// inBox сообщает, что точка попадает в прямоугольник с учетом перехода через 180-й меридиан
+func inBox(box BoundingBox, lat, lon float64) bool {
+	if lat < box.MinLatitude || lat > box.MaxLatitude {
+		return false
+	}
+	if box.MinLongitude <= box.MaxLongitude {
+		return lon >= box.MinLongitude && lon <= box.MaxLongitude
+	}
+	return lon >= box.MinLongitude || lon <= box.MaxLongitude
+}
+
+func TestBoundingBoxContainsCircle(t *testing.T) {
+	centers := []struct {
+		name     string
+		lat, lon float64
+		radiusKm float64
+	}{
+		{name: "moscow", lat: 55.75, lon: 37.62, radiusKm: 50},
+		{name: "equator", lat: 0, lon: 0, radiusKm: 500},
+		{name: "antimeridian", lat: 65, lon: 179.9, radiusKm: 100},
+		{name: "antimeridian west", lat: -40, lon: -179.9, radiusKm: 100},
+		{name: "near north pole", lat: 89.5, lon: 10, radiusKm: 200},
+		{name: "whole globe", lat: 0, lon: 0, radiusKm: MaxDistanceKm},
+	}
+
+	for _, c := range centers {
+		t.Run(c.name, func(t *testing.T) {
+			box := BoundingBoxAround(c.lat, c.lon, c.radiusKm)
+
+			// Точки сетки внутри круга должны попадать в прямоугольник
+			for lat := -90.0; lat <= 90; lat += 0.25 {
+				for lon := -180.0; lon <= 180; lon += 0.25 {
+					if Distance(c.lat, c.lon, lat, lon) <= c.radiusKm && !inBox(box, lat, lon) {
+						t.Fatalf("point (%g, %g) within %g km is outside %+v", lat, lon, c.radiusKm, box)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBoundingBoxIsNarrow(t *testing.T) {
+	box := BoundingBoxAround(55.75, 37.62, 50)
+
+	// Круг радиуса 50 км занимает меньше градуса по широте и долготе
+	if box.MaxLatitude-box.MinLatitude > 1 || box.MaxLongitude-box.MinLongitude > 2 {
+		t.Errorf("box is too wide: %+v", box)
+	}
+	if inBox(box, 59.93, 30.34) {
+		t.Error("saint petersburg is inside the 50 km box around moscow")
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Статусы проверок /healthz и /readyz
+const (
+	HealthOK   = "ok"   // Проверка пройдена
+	HealthFail = "fail" // Проверка не пройдена, реплика не готова принимать трафик
+)
+
+// CollectorState описывает состояние задачи периодического сбора в планировщике
+type CollectorState struct {
+	Scheduled bool          // Задача зарегистрирована и планировщик запущен
+	LastRun   time.Time     // Начало последнего запуска, нулевое до первого запуска
+	NextRun   time.Time     // Время следующего запуска
+	Interval  time.Duration // Интервал между запусками
+}
+
+// HealthCheck - результат одной проверки готовности
+type HealthCheck struct {
+	Status string `json:"status"`          // HealthOK или HealthFail
+	Error  string `json:"error,omitempty"` // Причина неудачи
+}
+
+// SchedulerCheck - результат проверки планировщика сбора
+type SchedulerCheck struct {
+	HealthCheck
+	LastRun *time.Time `json:"last_run,omitempty"` // Начало последнего запуска сбора
+	NextRun *time.Time `json:"next_run,omitempty"` // Время следующего запуска сбора
+}
+
+// LocationFreshness - результат проверки свежести показаний одного города
+type LocationFreshness struct {
+	HealthCheck
+	City       string     `json:"city"`                  // Город из cron.cities
+	FetchedAt  *time.Time `json:"fetched_at,omitempty"`  // Время получения последнего показания
+	AgeSeconds *int64     `json:"age_seconds,omitempty"` // Возраст последнего показания в секундах
+}
+
+// Readiness - сводный результат проверок готовности с разбивкой по проверкам
+type Readiness struct {
+	Status    string              `json:"status"`          // HealthOK, если пройдены все проверки
+	Database  HealthCheck         `json:"database"`        // Соединение с базой
+	Scheduler SchedulerCheck      `json:"scheduler"`       // Планировщик сбора
+	Locations []LocationFreshness `json:"locations"`       // Свежесть показаний собираемых городов
+	MaxAge    int64               `json:"max_age_seconds"` // Допустимый возраст показаний в секундах
+}
+
+// Ready сообщает, пройдены ли все проверки
+func (r Readiness) Ready() bool {
+	return r.Status == HealthOK
+}
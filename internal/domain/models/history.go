@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Допустимые функции агрегации значений внутри одного интервала
+const (
+	AggAvg = "avg" // Среднее значение
+	AggMin = "min" // Минимальное значение
+	AggMax = "max" // Максимальное значение
+)
+
+// HistoryQuery описывает параметры запроса исторического ряда
+// Диапазон [From, To) разбивается на интервалы длиной Step
+type HistoryQuery struct {
+	City   string        // Название города
+	From   time.Time     // Начало диапазона (включительно)
+	To     time.Time     // Конец диапазона (не включительно)
+	Step   time.Duration // Длина интервала агрегации
+	Agg    string        // Функция агрегации: avg, min или max
+	Limit  int           // Максимальное количество точек на странице
+	Cursor string        // Курсор страницы из предыдущего ответа (пустой для первой страницы)
+	// Переменные показания из Variables, которые агрегируются в HistoryPoint.Values
+	// вместе с температурой, например для графика нескольких переменных
+	Variables []string
+}
+
+// HistoryPoint представляет одну точку агрегированного ряда
+type HistoryPoint struct {
+	Timestamp   time.Time `json:"timestamp" db:"bucket"`        // Начало интервала
+	Temperature float64   `json:"temperature" db:"temperature"` // Агрегированная температура
+	Count       int64     `json:"count" db:"count"`             // Количество исходных показаний в интервале
+	// Агрегированные значения переменных HistoryQuery.Variables. Переменной нет,
+	// если в интервале ее значение неизвестно ни в одном показании
+	Values map[string]float64 `json:"values,omitempty" db:"-"`
+}
+
+// History представляет страницу исторического ряда для HTTP-ответа
+type History struct {
+	Name       string         `json:"name"`                  // Название города
+	Step       string         `json:"step"`                  // Длина интервала в формате Go (например, "1h0m0s")
+	Agg        string         `json:"agg"`                   // Функция агрегации
+	Points     []HistoryPoint `json:"points"`                // Точки ряда в порядке возрастания времени
+	NextCursor string         `json:"next_cursor,omitempty"` // Курсор следующей страницы, пустой на последней странице
+}
+
+// ToResponse преобразует структуру History в JSON для HTTP-ответа
+func (h *History) ToResponse() ([]byte, error) {
+	return json.Marshal(h)
+}
+
+// historyCursor - содержимое курсора страницы
+// Кроме начала следующей страницы курсор хранит город, интервал и функцию агрегации
+// запроса, на который он выдан: с другими параметрами страницы не совпадут по границам
+type historyCursor struct {
+	City string        `json:"city"`
+	Step time.Duration `json:"step"`
+	Agg  string        `json:"agg"`
+	Next time.Time     `json:"next"`
+}
+
+// EncodeCursor кодирует начало следующей страницы запроса query в непрозрачный курсор
+func EncodeCursor(query HistoryQuery, next time.Time) string {
+	raw, _ := json.Marshal(historyCursor{
+		City: query.City,
+		Step: query.Step,
+		Agg:  query.Agg,
+		Next: next.UTC(),
+	})
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor восстанавливает начало следующей страницы из курсора, полученного через EncodeCursor
+// Возвращает ErrInvalidQuery, если курсор поврежден или выдан для запроса
+// с другим городом, интервалом или функцией агрегации
+func DecodeCursor(query HistoryQuery, cursor string) (time.Time, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	var c historyCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Next.IsZero() {
+		return time.Time{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	if c.City != query.City || c.Step != query.Step || c.Agg != query.Agg {
+		return time.Time{}, fmt.Errorf("%w: cursor was issued for a different city, step or agg", ErrInvalidQuery)
+	}
+
+	return c.Next, nil
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	query := HistoryQuery{City: "moscow", Step: time.Hour, Agg: AggMax}
+	next := time.Date(2025, 3, 4, 5, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	got, err := DecodeCursor(query, EncodeCursor(query, next))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(next) {
+		t.Errorf("decoded %v, want %v", got, next)
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	query := HistoryQuery{City: "moscow", Step: time.Hour, Agg: AggAvg}
+	next := time.Date(2025, 3, 4, 5, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "!!!"},
+		{name: "not json", cursor: "bm90IGpzb24"},
+		{name: "empty object", cursor: "e30"},
+		{name: "other city", cursor: EncodeCursor(HistoryQuery{City: "kazan", Step: time.Hour, Agg: AggAvg}, next)},
+		{name: "other step", cursor: EncodeCursor(HistoryQuery{City: "moscow", Step: time.Minute, Agg: AggAvg}, next)},
+		{name: "other agg", cursor: EncodeCursor(HistoryQuery{City: "moscow", Step: time.Hour, Agg: AggMin}, next)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(query, tt.cursor); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("err = %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import "slices"
+
+// Разрешения административных маршрутов. Роли из JWT сопоставляются с ними в auth.jwt.roles
+// Разрешение есть у каждой административной операции и только у них: правила оповещений
+// читаются из конфигурации при запуске, а дозагрузки показаний и истории задач в сервисе нет,
+// поэтому разрешения для них появятся вместе с маршрутами
+const (
+	PermissionKeysRead  = "keys:read"  // Просмотр ключей API и их использования
+	PermissionKeysWrite = "keys:write" // Создание и отзыв ключей API
+
+	PermissionLocationsRead  = "locations:read"  // Просмотр псевдонимов городов
+	PermissionLocationsWrite = "locations:write" // Добавление и удаление псевдонимов городов
+
+	PermissionStationsRead  = "stations:read"  // Просмотр метеостанций и их ключей
+	PermissionStationsWrite = "stations:write" // Регистрация станций, выдача и отзыв их ключей
+)
+
+// Permissions - все известные разрешения
+var Permissions = []string{
+	PermissionKeysRead, PermissionKeysWrite,
+	PermissionLocationsRead, PermissionLocationsWrite,
+	PermissionStationsRead, PermissionStationsWrite,
+}
+
+// Principal описывает того, кто выполняет административный запрос: владельца
+// проверенного JWT или ключа API с областью admin
+type Principal struct {
+	Subject     string   // Claim sub токена или название ключа API
+	Roles       []string // Роли из токена
+	Permissions []string // Разрешения, выданные ролям
+}
+
+// HasPermission сообщает, выдано ли разрешение permission
+func (p Principal) HasPermission(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
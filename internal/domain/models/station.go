@@ -0,0 +1,178 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// SourceStation - источник показаний, присланных собственными метеостанциями
+const SourceStation = "station"
+
+// Единицы измерения в показаниях станций. Показания хранятся в °C и км/ч
+const (
+	UnitCelsius    = "C"
+	UnitFahrenheit = "F"
+	UnitKelvin     = "K"
+
+	UnitKilometersPerHour = "km/h"
+	UnitMetersPerSecond   = "m/s"
+	UnitMilesPerHour      = "mph"
+	UnitKnots             = "kn"
+)
+
+// Допустимые значения показаний станций после перевода в единицы хранения
+// Границы немного шире мировых рекордов: значение за ними - неисправность датчика или ошибка единиц
+const (
+	MinStationTemperature = -90.0 // °C
+	MaxStationTemperature = 60.0  // °C
+	MaxStationWindSpeed   = 450.0 // км/ч
+)
+
+// stationIDPattern - допустимый идентификатор станции: он входит в URL и в теги показаний
+var stationIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// IsStationID сообщает, подходит ли id в качестве идентификатора станции
+func IsStationID(id string) bool {
+	return stationIDPattern.MatchString(id)
+}
+
+// Station описывает зарегистрированную метеостанцию
+// Показания станции сохраняются в ряд города City с тегом станции
+type Station struct {
+	ID        string    `json:"id"`                  // Идентификатор, выбранный администратором
+	Name      string    `json:"name"`                // Описание станции
+	City      string    `json:"city"`                // Ключ города, в ряд которого пишутся показания
+	Latitude  *float64  `json:"latitude,omitempty"`  // Широта станции, если известна
+	Longitude *float64  `json:"longitude,omitempty"` // Долгота станции, если известна
+	CreatedAt time.Time `json:"created_at"`          // Время регистрации
+}
+
+// NewStation - параметры регистрируемой станции
+type NewStation struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	City      string   `json:"city"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// StationKey описывает ключ станции без секрета
+// Как и у ключей API, в хранилище находится только хэш секрета
+type StationKey struct {
+	ID         int64      `json:"id"`
+	StationID  string     `json:"station_id"`
+	Prefix     string     `json:"prefix"`                 // Начало секрета, чтобы узнать ключ в списке
+	CreatedAt  time.Time  `json:"created_at"`             // Время создания
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // Время последней отправки показаний
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`   // Время отзыва, отозванный ключ не принимается
+}
+
+// CreatedStationKey - созданный ключ станции вместе с секретом
+type CreatedStationKey struct {
+	Key    StationKey `json:"key"`
+	Secret string     `json:"secret"` // Секрет для заголовка X-Station-Key, больше нигде не хранится
+}
+
+// Observation - показание в том виде, в котором его присылает станция
+// Единицы указываются для каждого показания, по умолчанию °C и км/ч
+type Observation struct {
+	Timestamp       time.Time `json:"timestamp"`                  // Время измерения
+	Temperature     *float64  `json:"temperature"`                // Температура, обязательна
+	TemperatureUnit string    `json:"temperature_unit,omitempty"` // C, F или K
+	Humidity        *float64  `json:"humidity,omitempty"`         // Относительная влажность, %
+	WindSpeed       *float64  `json:"wind_speed,omitempty"`       // Скорость ветра
+	WindSpeedUnit   string    `json:"wind_speed_unit,omitempty"`  // km/h, m/s, mph или kn
+}
+
+// Normalize переводит показание в единицы хранения (°C и км/ч) и проверяет диапазоны значений
+// Время измерения проверяет сервис: допустимое окно зависит от конфигурации
+// Возвращает ошибку ErrInvalidQuery с причиной
+func (o Observation) Normalize() (Observation, error) {
+	if o.Timestamp.IsZero() {
+		return Observation{}, fmt.Errorf("%w: timestamp is required", ErrInvalidQuery)
+	}
+	if o.Temperature == nil {
+		return Observation{}, fmt.Errorf("%w: temperature is required", ErrInvalidQuery)
+	}
+
+	temperature, err := toCelsius(*o.Temperature, o.TemperatureUnit)
+	if err != nil {
+		return Observation{}, err
+	}
+	if temperature < MinStationTemperature || temperature > MaxStationTemperature {
+		return Observation{}, fmt.Errorf("%w: temperature must be between %g and %g °C", ErrInvalidQuery, MinStationTemperature, MaxStationTemperature)
+	}
+
+	if o.Humidity != nil && (*o.Humidity < 0 || *o.Humidity > 100) {
+		return Observation{}, fmt.Errorf("%w: humidity must be between 0 and 100", ErrInvalidQuery)
+	}
+
+	var wind *float64
+	if o.WindSpeed != nil {
+		speed, err := toKilometersPerHour(*o.WindSpeed, o.WindSpeedUnit)
+		if err != nil {
+			return Observation{}, err
+		}
+		if speed < 0 || speed > MaxStationWindSpeed {
+			return Observation{}, fmt.Errorf("%w: wind_speed must be between 0 and %g km/h", ErrInvalidQuery, MaxStationWindSpeed)
+		}
+		wind = &speed
+	}
+
+	return Observation{
+		// Показания хранятся с точностью до секунды, в UTC
+		Timestamp:       o.Timestamp.UTC().Truncate(time.Second),
+		Temperature:     &temperature,
+		TemperatureUnit: UnitCelsius,
+		Humidity:        o.Humidity,
+		WindSpeed:       wind,
+		WindSpeedUnit:   UnitKilometersPerHour,
+	}, nil
+}
+
+// toCelsius переводит температуру в °C, пустая единица - °C
+func toCelsius(value float64, unit string) (float64, error) {
+	switch unit {
+	case "", UnitCelsius:
+		return value, nil
+	case UnitFahrenheit:
+		return (value - 32) * 5 / 9, nil
+	case UnitKelvin:
+		return value - 273.15, nil
+	default:
+		return 0, fmt.Errorf("%w: temperature_unit must be one of C, F, K", ErrInvalidQuery)
+	}
+}
+
+// toKilometersPerHour переводит скорость ветра в км/ч, пустая единица - км/ч
+func toKilometersPerHour(value float64, unit string) (float64, error) {
+	switch unit {
+	case "", UnitKilometersPerHour:
+		return value, nil
+	case UnitMetersPerSecond:
+		return value * 3.6, nil
+	case UnitMilesPerHour:
+		return value * 1.609344, nil
+	case UnitKnots:
+		return value * 1.852, nil
+	default:
+		return 0, fmt.Errorf("%w: wind_speed_unit must be one of km/h, m/s, mph, kn", ErrInvalidQuery)
+	}
+}
+
+// SubmissionResult - результат приема показаний станции
+// Повтор запроса с тем же ключом идемпотентности возвращает сохраненный результат
+type SubmissionResult struct {
+	Station    string  `json:"station"`    // Идентификатор станции
+	City       string  `json:"city"`       // Ключ города, в ряд которого записаны показания
+	Accepted   int     `json:"accepted"`   // Количество новых показаний
+	Duplicates int     `json:"duplicates"` // Количество показаний, которые уже были сохранены
+	Readings   []int64 `json:"readings"`   // Идентификаторы показаний в порядке запроса
+}
+
+// Submission - запрос станции, принятый с ключом идемпотентности
+type Submission struct {
+	RequestHash []byte            // SHA-256 нормализованных показаний запроса
+	Result      *SubmissionResult // Результат, nil - запрос еще обрабатывается
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func float(v float64) *float64 { return &v }
+
+func TestObservationNormalize(t *testing.T) {
+	at := time.Date(2025, 1, 2, 6, 4, 5, 999, time.FixedZone("MSK", 3*60*60))
+
+	tests := []struct {
+		name        string
+		in          Observation
+		temperature float64
+		wind        float64 // -1, если скорость ветра не передана
+	}{
+		{name: "defaults", in: Observation{Timestamp: at, Temperature: float(-5), WindSpeed: float(10)}, temperature: -5, wind: 10},
+		{name: "fahrenheit", in: Observation{Timestamp: at, Temperature: float(14), TemperatureUnit: UnitFahrenheit}, temperature: -10, wind: -1},
+		{name: "kelvin", in: Observation{Timestamp: at, Temperature: float(300), TemperatureUnit: UnitKelvin}, temperature: 26.85, wind: -1},
+		{name: "meters per second", in: Observation{Timestamp: at, Temperature: float(0), WindSpeed: float(10), WindSpeedUnit: UnitMetersPerSecond}, temperature: 0, wind: 36},
+		{name: "miles per hour", in: Observation{Timestamp: at, Temperature: float(0), WindSpeed: float(10), WindSpeedUnit: UnitMilesPerHour}, temperature: 0, wind: 16.09344},
+		{name: "knots", in: Observation{Timestamp: at, Temperature: float(0), WindSpeed: float(10), WindSpeedUnit: UnitKnots}, temperature: 0, wind: 18.52},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.in.Normalize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(*got.Temperature-tt.temperature) > 1e-9 || got.TemperatureUnit != UnitCelsius {
+				t.Errorf("temperature = %g %s, want %g C", *got.Temperature, got.TemperatureUnit, tt.temperature)
+			}
+			if tt.wind < 0 && got.WindSpeed != nil || tt.wind >= 0 && math.Abs(*got.WindSpeed-tt.wind) > 1e-9 {
+				t.Errorf("wind speed = %v, want %g", got.WindSpeed, tt.wind)
+			}
+			if want := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC); !got.Timestamp.Equal(want) || got.Timestamp.Location() != time.UTC {
+				t.Errorf("timestamp = %v, want %v", got.Timestamp, want)
+			}
+		})
+	}
+}
+
+func TestObservationNormalizeErrors(t *testing.T) {
+	at := time.Now()
+
+	tests := []struct {
+		name string
+		in   Observation
+		want string
+	}{
+		{name: "no timestamp", in: Observation{Temperature: float(1)}, want: "timestamp"},
+		{name: "no temperature", in: Observation{Timestamp: at}, want: "temperature is required"},
+		{name: "unknown temperature unit", in: Observation{Timestamp: at, Temperature: float(1), TemperatureUnit: "R"}, want: "temperature_unit"},
+		{name: "hot", in: Observation{Timestamp: at, Temperature: float(70)}, want: "temperature must be between"},
+		// 0 K - это неисправный датчик, а не -273 °C
+		{name: "kelvin zero", in: Observation{Timestamp: at, Temperature: float(0), TemperatureUnit: UnitKelvin}, want: "temperature must be between"},
+		{name: "humidity", in: Observation{Timestamp: at, Temperature: float(1), Humidity: float(101)}, want: "humidity"},
+		{name: "negative wind", in: Observation{Timestamp: at, Temperature: float(1), WindSpeed: float(-1)}, want: "wind_speed must be between"},
+		{name: "unknown wind unit", in: Observation{Timestamp: at, Temperature: float(1), WindSpeed: float(1), WindSpeedUnit: "bft"}, want: "wind_speed_unit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.in.Normalize()
+			if !errors.Is(err, ErrInvalidQuery) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want ErrInvalidQuery with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsStationID(t *testing.T) {
+	for _, id := range []string{"roof-1", "a", "dacha_2", strings.Repeat("a", 64)} {
+		if !IsStationID(id) {
+			t.Errorf("IsStationID(%q) = false", id)
+		}
+	}
+	for _, id := range []string{"", "Roof", "-roof", "roof/1", "крыша", strings.Repeat("a", 65)} {
+		if IsStationID(id) {
+			t.Errorf("IsStationID(%q) = true", id)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Поддерживаемые периоды для сводной статистики
+const (
+	PeriodDay    = "day"    // Последние сутки
+	PeriodWeek   = "week"   // Последние 7 дней
+	PeriodMonth  = "month"  // Последний месяц (to минус один календарный месяц)
+	PeriodCustom = "custom" // Произвольный диапазон [from, to)
+)
+
+// StatsQuery описывает параметры запроса сводной статистики
+type StatsQuery struct {
+	City     string    // Название города
+	Variable string    // Переменная показания (см. Variables), по умолчанию температура
+	Period   string    // Период: day, week, month или custom
+	From     time.Time // Начало диапазона (используется только для custom)
+	To       time.Time // Конец диапазона (для day/week/month - точка, от которой отсчитывается период)
+}
+
+// Stats представляет сводную статистику переменной показания за период
+// Все значения рассчитываются в PostgreSQL по исходным показаниям без агрегации
+// Показания, для которых значение переменной неизвестно, в статистику не входят
+type Stats struct {
+	Name     string    `json:"name"`     // Название города
+	Variable string    `json:"variable"` // Переменная показания
+	Period   string    `json:"period"`   // Запрошенный период
+	From     time.Time `json:"from"`     // Начало диапазона (включительно)
+	To       time.Time `json:"to"`       // Конец диапазона (не включительно)
+	Count    int64     `json:"count"`    // Количество показаний в диапазоне
+
+	Min    float64   `json:"min"`    // Минимальное значение
+	MinAt  time.Time `json:"min_at"` // Время первого показания с минимальным значением
+	Max    float64   `json:"max"`    // Максимальное значение
+	MaxAt  time.Time `json:"max_at"` // Время первого показания с максимальным значением
+	Mean   float64   `json:"mean"`   // Среднее значение
+	Median float64   `json:"median"` // Медиана (50-й перцентиль)
+	P10    float64   `json:"p10"`    // 10-й перцентиль
+	P90    float64   `json:"p90"`    // 90-й перцентиль
+	StdDev float64   `json:"stddev"` // Выборочное стандартное отклонение (0 для одного показания)
+}
+
+// ToResponse преобразует структуру Stats в JSON для HTTP-ответа
+func (s *Stats) ToResponse() ([]byte, error) {
+	return json.Marshal(s)
+}
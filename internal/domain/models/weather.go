@@ -5,11 +5,37 @@ import (
 	"time"
 )
 
+// SourceOpenMeteo - источник показаний, полученных из Open-Meteo API
+const SourceOpenMeteo = "open-meteo"
+
+// SourceMQTT - источник показаний датчиков, полученных через брокер MQTT
+const SourceMQTT = "mqtt"
+
 // Weather представляет основную доменную модель погоды
 // Используется в бизнес-логике приложения и для HTTP-ответов
 type Weather struct {
-	Name        string  `json:"name" db:"name"`               // Название города
-	Temperature float64 `json:"temperature" db:"temperature"` // Температура в градусах
+	ID          int64     `json:"id,omitempty" db:"id"`         // Идентификатор показания
+	Name        string    `json:"name" db:"name"`               // Название города
+	Temperature float64   `json:"temperature" db:"temperature"` // Температура в градусах
+	Humidity    *float64  `json:"humidity,omitempty"`           // Относительная влажность, %
+	WindSpeed   *float64  `json:"wind_speed,omitempty"`         // Скорость ветра на высоте 10 м, км/ч
+	Derived     Derived   `json:"derived,omitzero"`             // Величины, вычисленные по температуре, влажности и ветру
+	ObservedAt  time.Time `json:"observed_at"`                  // Время измерения по данным источника
+	FetchedAt   time.Time `json:"fetched_at,omitzero"`          // Время получения данных сервисом
+	AgeSeconds  int64     `json:"age_seconds"`                  // Возраст данных на момент ответа в секундах
+	Source      string    `json:"source,omitempty"`             // Источник данных (например, open-meteo)
+	Station     string    `json:"station,omitempty"`            // Идентификатор станции, приславшей показание
+	Sensor      string    `json:"sensor,omitempty"`             // Тема MQTT датчика, приславшего показание
+	Location    *Location `json:"location,omitempty"`           // Местоположение города, если известно
+	Stale       bool      `json:"stale,omitempty"`              // Данные старше допустимого порога свежести
+}
+
+// Location описывает местоположение города по данным геокодинга
+type Location struct {
+	Name      string  `json:"name" db:"display_name"`   // Название города по данным геокодинга
+	Country   string  `json:"country" db:"country"`     // Название страны
+	Latitude  float64 `json:"latitude" db:"latitude"`   // Географическая широта
+	Longitude float64 `json:"longitude" db:"longitude"` // Географическая долгота
 }
 
 // ToResponse преобразует структуру Weather в JSON для HTTP-ответа
@@ -31,16 +57,69 @@ func (w *Weather) ToResponse() ([]byte, error) {
 // WeatherDTO (Data Transfer Object) представляет модель данных для передачи между слоями
 // Содержит дополнительные поля, необходимые для работы с хранилищем, но не для клиента
 type WeatherDTO struct {
-	Name        string    `json:"name" db:"name"`               // Название города
-	Timestamp   time.Time `json:"timestamp" db:"timestamp"`     // Временная метка измерения (из БД)
-	Temperature float64   `json:"temperature" db:"temperature"` // Температура
+	ID          int64      `json:"id" db:"id"`                   // Идентификатор показания
+	Name        string     `json:"name" db:"name"`               // Название города
+	Timestamp   time.Time  `json:"timestamp" db:"timestamp"`     // Временная метка измерения (из БД)
+	Temperature float64    `json:"temperature" db:"temperature"` // Температура
+	Humidity    *float64   `json:"humidity" db:"humidity"`       // Относительная влажность, % (NULL, если источник ее не передал)
+	WindSpeed   *float64   `json:"wind_speed" db:"wind_speed"`   // Скорость ветра на высоте 10 м, км/ч (NULL, если неизвестна)
+	FetchedAt   *time.Time `json:"fetched_at" db:"fetched_at"`   // Время получения данных (NULL для старых записей)
+	Source      *string    `json:"source" db:"source"`           // Источник данных (NULL для старых записей)
+	StationID   *string    `json:"station_id" db:"station_id"`   // Станция, приславшая показание (NULL для внешних API)
+	Sensor      *string    `json:"sensor" db:"sensor"`           // Тема MQTT датчика, приславшего показание (NULL для других источников)
+	Location    *Location  `json:"location" db:"-"`              // Местоположение города, если известно
 }
 
 // ToWeather преобразует WeatherDTO в доменную модель Weather
 // Выполняет маппинг полей между DTO и доменной моделью
 // Метод получает указатель на Weather для заполнения его полей
+// Возраст и признак устаревания зависят от момента ответа и рассчитываются сервисом
 func (w *WeatherDTO) ToWeather(weather *Weather) {
+	weather.ID = w.ID
 	weather.Name = w.Name
 	weather.Temperature = w.Temperature
-	// Поле Timestamp не копируется, так как оно не нужно в доменной модели для клиента
+	weather.Humidity = w.Humidity
+	weather.WindSpeed = w.WindSpeed
+	weather.Derived = w.Derived()
+	weather.ObservedAt = w.Timestamp
+	weather.Location = w.Location
+
+	if w.FetchedAt != nil {
+		weather.FetchedAt = *w.FetchedAt
+	}
+	if w.Source != nil {
+		weather.Source = *w.Source
+	}
+	if w.StationID != nil {
+		weather.Station = *w.StationID
+	}
+	if w.Sensor != nil {
+		weather.Sensor = *w.Sensor
+	}
+}
+
+// ReceivedAt возвращает время получения показания сервисом
+// У старых записей без fetched_at известно только время измерения, оно и возвращается
+func (w WeatherDTO) ReceivedAt() time.Time {
+	if w.FetchedAt != nil {
+		return *w.FetchedAt
+	}
+	return w.Timestamp
+}
+
+// CityLocation описывает город, прошедший геокодинг:
+// ключ, под которым хранятся показания, и его местоположение
+type CityLocation struct {
+	City      string    `json:"city" db:"name"`             // Ключ города
+	Location  Location  `json:"location"`                   // Местоположение по данным геокодинга
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"` // Время последнего геокодинга
+}
+
+// CityWeather - результат пакетного запроса погоды для одного города
+// Заполнено ровно одно из полей Weather и Err: ошибка одного города
+// не прерывает обработку остальных
+type CityWeather struct {
+	City    string   // Город из запроса
+	Weather *Weather // Последнее показание
+	Err     error    // Причина, по которой показание получить не удалось
 }
@@ -0,0 +1,162 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// subscriberBuffer - размер буфера канала подписчика
+// Если подписчик не успевает забирать события и буфер переполнен,
+// подписка закрывается, чтобы медленный клиент не тормозил остальных
+const subscriberBuffer = 64
+
+// Типы событий брокера
+const (
+	TypeReading = "reading" // Новое показание
+	TypeAlert   = "alert"   // Сработавшее оповещение
+)
+
+// Event - событие, доставляемое подписчикам
+// Заполнено ровно одно из полей Reading и Alert в зависимости от Type
+type Event struct {
+	Type    string             // Тип события: reading или alert
+	Reading *models.WeatherDTO // Показание для событий reading
+	Alert   *models.Alert      // Оповещение для событий alert
+}
+
+// ReadingTopic возвращает тему показаний города
+func ReadingTopic(city string) string {
+	return TypeReading + ":" + city
+}
+
+// AlertTopic возвращает тему канала оповещений
+func AlertTopic(channel string) string {
+	return TypeAlert + ":" + channel
+}
+
+// Broker раздает события подписчикам внутри процесса
+// Подписчик может быть подписан на несколько тем и менять их во время работы,
+// события одной темы приходят в порядке публикации
+type Broker struct {
+	mu          sync.Mutex                            // Защищает карту подписчиков
+	subscribers map[string]map[*Subscription]struct{} // Подписчики по темам
+}
+
+// Subscription представляет подписку на набор тем
+// Канал C закрывается при отписке или при переполнении буфера
+type Subscription struct {
+	C      <-chan Event        // Канал событий по всем темам подписки
+	ch     chan Event          // Тот же канал, доступный брокеру для записи
+	topics map[string]struct{} // Темы подписки, изменяются под broker.mu
+	closed bool                // Подписка отменена, канал закрыт
+	broker *Broker             // Брокер для изменения подписки
+}
+
+// NewBroker создает брокер без подписчиков
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe оформляет подписку на темы (может быть пустой и дополняться через Add)
+// Вызывающий обязан вызвать Unsubscribe, когда подписка больше не нужна
+func (b *Broker) Subscribe(topics ...string) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, topics: make(map[string]struct{}), broker: b}
+	sub.Add(topics...)
+
+	return sub
+}
+
+// Add добавляет темы в подписку
+// Возвращает false, если подписка уже закрыта
+func (s *Subscription) Add(topics ...string) bool {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	for _, topic := range topics {
+		if b.subscribers[topic] == nil {
+			b.subscribers[topic] = make(map[*Subscription]struct{})
+		}
+		b.subscribers[topic][s] = struct{}{}
+		s.topics[topic] = struct{}{}
+	}
+
+	return true
+}
+
+// Remove убирает темы из подписки, канал при этом остается открытым
+func (s *Subscription) Remove(topics ...string) {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, topic := range topics {
+		b.detach(s, topic)
+	}
+}
+
+// Unsubscribe отменяет подписку на все темы и закрывает ее канал
+// Повторный вызов безопасен
+func (s *Subscription) Unsubscribe() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	s.broker.close(s)
+}
+
+// PublishReading отправляет показание подписчикам темы его города
+func (b *Broker) PublishReading(weather models.WeatherDTO) {
+	b.publish(ReadingTopic(weather.Name), Event{Type: TypeReading, Reading: &weather})
+}
+
+// PublishAlert отправляет оповещение подписчикам его канала
+func (b *Broker) PublishAlert(alert models.Alert) {
+	b.publish(AlertTopic(alert.Channel), Event{Type: TypeAlert, Alert: &alert})
+}
+
+// publish отправляет событие всем подписчикам темы
+// Не блокируется: подписчики с переполненным буфером отключаются
+func (b *Broker) publish(topic string, event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers[topic] {
+		select {
+		case sub.ch <- event:
+		default:
+			b.close(sub)
+		}
+	}
+}
+
+// detach отвязывает подписку от темы, вызывается под b.mu
+func (b *Broker) detach(sub *Subscription, topic string) {
+	delete(sub.topics, topic)
+
+	subs := b.subscribers[topic]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subscribers, topic)
+	}
+}
+
+// close отвязывает подписку от всех тем и закрывает ее канал, вызывается под b.mu
+func (b *Broker) close(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+
+	for topic := range sub.topics {
+		b.detach(sub, topic)
+	}
+	sub.closed = true
+	close(sub.ch)
+}
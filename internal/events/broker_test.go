@@ -0,0 +1,133 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// receive забирает все события, уже находящиеся в канале подписки
+func receive(sub *Subscription) []Event {
+	var events []Event
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestBrokerRoutesByTopic(t *testing.T) {
+	b := NewBroker()
+	moscow := b.Subscribe(ReadingTopic("moscow"))
+	defer moscow.Unsubscribe()
+	frost := b.Subscribe(AlertTopic("frost"))
+	defer frost.Unsubscribe()
+
+	b.PublishReading(models.WeatherDTO{ID: 1, Name: "moscow"})
+	b.PublishReading(models.WeatherDTO{ID: 2, Name: "kazan"})
+	b.PublishAlert(models.Alert{Channel: "frost", Rule: "moscow-frost"})
+
+	got := receive(moscow)
+	if len(got) != 1 || got[0].Type != TypeReading || got[0].Reading.ID != 1 {
+		t.Errorf("moscow subscriber got %+v, want reading 1 only", got)
+	}
+
+	got = receive(frost)
+	if len(got) != 1 || got[0].Type != TypeAlert || got[0].Alert.Rule != "moscow-frost" {
+		t.Errorf("frost subscriber got %+v, want one alert", got)
+	}
+}
+
+func TestSubscriptionAddRemove(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe()
+	defer sub.Unsubscribe()
+
+	if !sub.Add(ReadingTopic("moscow"), ReadingTopic("kazan")) {
+		t.Fatal("Add on an open subscription returned false")
+	}
+	sub.Remove(ReadingTopic("kazan"))
+
+	b.PublishReading(models.WeatherDTO{ID: 1, Name: "moscow"})
+	b.PublishReading(models.WeatherDTO{ID: 2, Name: "kazan"})
+
+	got := receive(sub)
+	if len(got) != 1 || got[0].Reading.ID != 1 {
+		t.Errorf("got %+v, want reading 1 only", got)
+	}
+	if len(b.subscribers) != 1 {
+		t.Errorf("broker keeps %d topics, want 1 after Remove", len(b.subscribers))
+	}
+}
+
+func TestBrokerPreservesOrder(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe(ReadingTopic("moscow"))
+	defer sub.Unsubscribe()
+
+	for id := int64(1); id <= 10; id++ {
+		b.PublishReading(models.WeatherDTO{ID: id, Name: "moscow"})
+	}
+
+	for i, event := range receive(sub) {
+		if event.Reading.ID != int64(i+1) {
+			t.Fatalf("event %d has id %d, want %d", i, event.Reading.ID, i+1)
+		}
+	}
+}
+
+func TestBrokerClosesSlowSubscriber(t *testing.T) {
+	b := NewBroker()
+	slow := b.Subscribe(ReadingTopic("moscow"))
+	fast := b.Subscribe(ReadingTopic("moscow"))
+	defer fast.Unsubscribe()
+
+	// Быстрый подписчик забирает события, медленный - нет
+	for id := int64(1); id <= subscriberBuffer+1; id++ {
+		b.PublishReading(models.WeatherDTO{ID: id, Name: "moscow"})
+		receive(fast)
+	}
+
+	got := receive(slow)
+	if len(got) != subscriberBuffer {
+		t.Errorf("slow subscriber got %d events before closing, want %d", len(got), subscriberBuffer)
+	}
+	if _, ok := <-slow.C; ok {
+		t.Error("slow subscriber channel is still open")
+	}
+	if slow.Add(ReadingTopic("kazan")) {
+		t.Error("Add on a closed subscription returned true")
+	}
+
+	// Отписка после закрытия брокером безопасна
+	slow.Unsubscribe()
+
+	b.PublishReading(models.WeatherDTO{ID: 100, Name: "moscow"})
+	if got := receive(fast); len(got) != 1 {
+		t.Errorf("fast subscriber got %d events, want 1", len(got))
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe(ReadingTopic("moscow"), AlertTopic("frost"))
+
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+
+	if _, ok := <-sub.C; ok {
+		t.Error("channel is open after Unsubscribe")
+	}
+	if len(b.subscribers) != 0 {
+		t.Errorf("broker keeps %d topics after Unsubscribe", len(b.subscribers))
+	}
+
+	// Публикация без подписчиков не блокируется и не паникует
+	b.PublishReading(models.WeatherDTO{ID: 1, Name: "moscow"})
+}
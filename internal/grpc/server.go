@@ -0,0 +1,92 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	weatherv1 "github.com/olezhek28/wether-service/pkg/api/weather/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Server — структура, описывающая gRPC-сервер.
+// Работает на отдельном порту и обслуживает WeatherService, проверку здоровья и reflection.
+type Server struct {
+	context context.Context // Контекст для управления жизненным циклом сервера
+	host    string          // Хост (обычно "0.0.0.0" или "localhost")
+	port    int             // Порт, на котором будет запущен сервер
+	server  *grpc.Server    // Сервер с зарегистрированными сервисами
+	health  *health.Server  // Статус сервисов для grpc.health.v1.Health
+}
+
+// NewServer — конструктор для создания нового gRPC-сервера.
+// Регистрирует реализацию weather.v1.WeatherService поверх сервиса погоды,
+// стандартный сервис grpc.health.v1.Health и reflection для grpcurl и подобных клиентов.
+func NewServer(
+	context context.Context,
+	port int,
+	host string,
+	weatherService WeatherService,
+	streamConfig config.StreamConfig,
+) *Server {
+	server := grpc.NewServer()
+
+	weatherv1.RegisterWeatherServiceServer(server, newWeatherServer(weatherService, streamConfig))
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(weatherv1.WeatherService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+
+	return &Server{
+		context: context,
+		host:    host,
+		port:    port,
+		server:  server,
+		health:  healthServer,
+	}
+}
+
+// MustRun — запускает gRPC-сервер и завершает приложение с фатальной ошибкой, если запуск невозможен.
+func (s *Server) MustRun() {
+	addr := fmt.Sprintf("%s:%d", s.host, s.port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := s.serve(listener); err != nil {
+		panic(err)
+	}
+}
+
+// serve обслуживает соединения, принятые listener, до остановки сервера
+func (s *Server) serve(listener net.Listener) error {
+	return s.server.Serve(listener)
+}
+
+// Stop останавливает сервер, дожидаясь завершения активных вызовов до отмены ctx
+// Сначала все сервисы переводятся в NOT_SERVING, чтобы балансировщики
+// перестали направлять новые вызовы, пока завершаются текущие
+// Потоки WatchReadings бесконечны, поэтому по истечении ctx оставшиеся
+// соединения закрываются принудительно
+func (s *Server) Stop(ctx context.Context) {
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.server.Stop()
+	}
+}
@@ -0,0 +1,300 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/olezhek28/wether-service/internal/events"
+	weatherv1 "github.com/olezhek28/wether-service/pkg/api/weather/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Значения по умолчанию для параметров исторического запроса, те же, что у HTTP API
+const (
+	defaultHistoryRange = 24 * time.Hour // Диапазон, если не указан from
+	defaultHistoryStep  = time.Hour      // Интервал агрегации, если не указан step
+	defaultHistoryLimit = 500            // Размер страницы, если не указан limit
+	defaultHistoryAgg   = models.AggAvg  // Функция агрегации, если не указана agg
+)
+
+// WeatherService определяет контракт для сервиса погоды
+// Интерфейс описывает методы, которые используются обработчиками gRPC
+type WeatherService interface {
+	GetWeather(ctx context.Context, city string) (models.Weather, error)
+	GetHistory(ctx context.Context, query models.HistoryQuery) (models.History, error)
+	WatchWeather(ctx context.Context, city string, afterID int64, resumeLimit int) (*events.Subscription, []models.WeatherDTO, error)
+	SubscribeEvents() *events.Subscription
+	ResolveCity(ctx context.Context, city string) (string, error)
+	ListLocations(ctx context.Context) ([]models.CityLocation, error)
+}
+
+// weatherServer реализует weather.v1.WeatherService поверх сервиса погоды
+type weatherServer struct {
+	weatherv1.UnimplementedWeatherServiceServer
+
+	weatherService WeatherService      // Сервис для работы с бизнес-логикой погоды
+	streamConfig   config.StreamConfig // Параметры потоковой выдачи (лимит досылки пропущенных показаний)
+}
+
+// newWeatherServer создает реализацию gRPC-сервиса погоды
+func newWeatherServer(weatherService WeatherService, streamConfig config.StreamConfig) *weatherServer {
+	return &weatherServer{
+		weatherService: weatherService,
+		streamConfig:   streamConfig,
+	}
+}
+
+// GetCurrent возвращает текущее показание города, как GET /api/v1/{city}
+func (s *weatherServer) GetCurrent(ctx context.Context, req *weatherv1.GetCurrentRequest) (*weatherv1.GetCurrentResponse, error) {
+	if req.GetCity() == "" {
+		return nil, status.Error(codes.InvalidArgument, "city is required")
+	}
+
+	weather, err := s.weatherService.GetWeather(ctx, req.GetCity())
+	if err != nil {
+		return nil, toStatus(err, "Error getting weather")
+	}
+
+	return &weatherv1.GetCurrentResponse{Reading: toReading(weather)}, nil
+}
+
+// GetHistory возвращает страницу исторического ряда, как GET /api/v1/{city}/history
+// Незаполненные параметры принимают те же значения по умолчанию, что и в HTTP API
+func (s *weatherServer) GetHistory(ctx context.Context, req *weatherv1.GetHistoryRequest) (*weatherv1.GetHistoryResponse, error) {
+	if req.GetCity() == "" {
+		return nil, status.Error(codes.InvalidArgument, "city is required")
+	}
+
+	query := models.HistoryQuery{
+		City:   req.GetCity(),
+		To:     time.Now(),
+		Step:   defaultHistoryStep,
+		Agg:    defaultHistoryAgg,
+		Limit:  defaultHistoryLimit,
+		Cursor: req.GetCursor(),
+	}
+	if req.To != nil {
+		query.To = req.To.AsTime()
+	}
+	query.From = query.To.Add(-defaultHistoryRange)
+	if req.From != nil {
+		query.From = req.From.AsTime()
+	}
+	if req.Step != nil {
+		query.Step = req.Step.AsDuration()
+	}
+	if req.GetAgg() != "" {
+		query.Agg = req.GetAgg()
+	}
+	if req.GetLimit() != 0 {
+		query.Limit = int(req.GetLimit())
+	}
+
+	history, err := s.weatherService.GetHistory(ctx, query)
+	if err != nil {
+		return nil, toStatus(err, "Error getting weather history")
+	}
+
+	resp := &weatherv1.GetHistoryResponse{
+		City:       history.Name,
+		Step:       durationpb.New(query.Step),
+		Agg:        history.Agg,
+		Points:     make([]*weatherv1.HistoryPoint, 0, len(history.Points)),
+		NextCursor: history.NextCursor,
+	}
+	for _, point := range history.Points {
+		resp.Points = append(resp.Points, &weatherv1.HistoryPoint{
+			Timestamp:   timestamppb.New(point.Timestamp),
+			Temperature: point.Temperature,
+			Count:       point.Count,
+		})
+	}
+
+	return resp, nil
+}
+
+// WatchReadings передает новые показания перечисленных городов до отмены вызова клиентом
+// С after_id (только для одного города) сначала досылаются пропущенные показания,
+// как при переподключении SSE с Last-Event-ID
+// Если клиент не успевает читать, брокер закрывает подписку и вызов завершается
+// с кодом RESOURCE_EXHAUSTED - клиенту следует переподключиться с after_id
+func (s *weatherServer) WatchReadings(req *weatherv1.WatchReadingsRequest, stream weatherv1.WeatherService_WatchReadingsServer) error {
+	ctx := stream.Context()
+	cities := req.GetCities()
+
+	if len(cities) == 0 {
+		return status.Error(codes.InvalidArgument, "at least one city is required")
+	}
+	for _, city := range cities {
+		if city == "" {
+			return status.Error(codes.InvalidArgument, "city must not be empty")
+		}
+	}
+	if req.GetAfterId() < 0 {
+		return status.Error(codes.InvalidArgument, "after_id must be a reading id")
+	}
+	if req.GetAfterId() > 0 && len(cities) > 1 {
+		return status.Error(codes.InvalidArgument, "after_id is supported for a single city only")
+	}
+
+	// Показания приходят с ключами городов, поэтому подписка и учет id ведутся по ключам
+	keys := make([]string, 0, len(cities))
+	for _, city := range cities {
+		key, err := s.weatherService.ResolveCity(ctx, city)
+		if err != nil {
+			return toStatus(err, "Error resolving city")
+		}
+		keys = append(keys, key)
+	}
+
+	var (
+		sub     *events.Subscription
+		backlog []models.WeatherDTO
+		err     error
+	)
+	if len(cities) == 1 {
+		sub, backlog, err = s.weatherService.WatchWeather(ctx, keys[0], req.GetAfterId(), s.streamConfig.ResumeLimit)
+		if err != nil {
+			return toStatus(err, "Error subscribing to weather")
+		}
+	} else {
+		sub = s.weatherService.SubscribeEvents()
+		topics := make([]string, 0, len(keys))
+		for _, key := range keys {
+			topics = append(topics, events.ReadingTopic(key))
+		}
+		sub.Add(topics...)
+	}
+	defer sub.Unsubscribe()
+
+	// Показания одного города фиксируются в порядке id, а разных городов - нет,
+	// поэтому последний отправленный id хранится для каждого города отдельно
+	lastIDs := make(map[string]int64, len(cities))
+	lastIDs[keys[0]] = req.GetAfterId()
+	send := func(dto models.WeatherDTO) error {
+		// Показание могло прийти и из backlog, и из подписки
+		if dto.ID <= lastIDs[dto.Name] {
+			return nil
+		}
+		if err := stream.Send(readingFromDTO(dto)); err != nil {
+			return err
+		}
+		lastIDs[dto.Name] = dto.ID
+		return nil
+	}
+
+	for _, dto := range backlog {
+		if err := send(dto); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-sub.C:
+			if !ok {
+				slog.Warn("closing slow grpc stream", "cities", cities)
+				return status.Error(codes.ResourceExhausted, "client is too slow, reconnect with after_id")
+			}
+			if event.Reading == nil {
+				continue
+			}
+			if err := send(*event.Reading); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ListLocations возвращает города, прошедшие геокодинг
+func (s *weatherServer) ListLocations(ctx context.Context, _ *weatherv1.ListLocationsRequest) (*weatherv1.ListLocationsResponse, error) {
+	locations, err := s.weatherService.ListLocations(ctx)
+	if err != nil {
+		return nil, toStatus(err, "Error listing locations")
+	}
+
+	resp := &weatherv1.ListLocationsResponse{
+		Locations: make([]*weatherv1.CityLocation, 0, len(locations)),
+	}
+	for _, l := range locations {
+		resp.Locations = append(resp.Locations, &weatherv1.CityLocation{
+			City:      l.City,
+			Location:  toLocation(&l.Location),
+			UpdatedAt: timestamppb.New(l.UpdatedAt),
+		})
+	}
+
+	return resp, nil
+}
+
+// toStatus преобразует ошибку сервиса в статус gRPC
+// Коды соответствуют статусам HTTP API: не найдено - NOT_FOUND,
+// некорректный запрос - INVALID_ARGUMENT, прочие ошибки - INTERNAL
+// с общим сообщением, а подробности пишутся в лог
+func toStatus(err error, message string) error {
+	switch {
+	case errors.Is(err, models.ErrCityNotFound), errors.Is(err, models.ErrNoData):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, models.ErrInvalidQuery):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		slog.Error(message, "error", err)
+		return status.Error(codes.Internal, message)
+	}
+}
+
+// toReading преобразует доменную модель Weather в сообщение Reading
+func toReading(weather models.Weather) *weatherv1.Reading {
+	reading := &weatherv1.Reading{
+		Id:          weather.ID,
+		City:        weather.Name,
+		Temperature: weather.Temperature,
+		ObservedAt:  timestamppb.New(weather.ObservedAt),
+		AgeSeconds:  weather.AgeSeconds,
+		Source:      weather.Source,
+		Location:    toLocation(weather.Location),
+		Stale:       weather.Stale,
+	}
+	if !weather.FetchedAt.IsZero() {
+		reading.FetchedAt = timestamppb.New(weather.FetchedAt)
+	}
+
+	return reading
+}
+
+// readingFromDTO преобразует показание из потока событий в сообщение Reading
+// Возраст считается на момент отправки, как в потоках SSE и WebSocket
+func readingFromDTO(dto models.WeatherDTO) *weatherv1.Reading {
+	var weather models.Weather
+	dto.ToWeather(&weather)
+	weather.AgeSeconds = int64(time.Since(dto.Timestamp) / time.Second)
+
+	return toReading(weather)
+}
+
+// toLocation преобразует местоположение в сообщение Location, nil - если оно неизвестно
+func toLocation(location *models.Location) *weatherv1.Location {
+	if location == nil {
+		return nil
+	}
+
+	return &weatherv1.Location{
+		Name:      location.Name,
+		Country:   location.Country,
+		Latitude:  location.Latitude,
+		Longitude: location.Longitude,
+	}
+}
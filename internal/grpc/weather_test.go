@@ -0,0 +1,394 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/olezhek28/wether-service/internal/events"
+	weatherv1 "github.com/olezhek28/wether-service/pkg/api/weather/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeWeatherService подменяет сервис погоды в тестах gRPC
+type fakeWeatherService struct {
+	broker    *events.Broker
+	weather   map[string]models.Weather
+	history   models.History
+	query     models.HistoryQuery // Последний запрос истории
+	backlog   []models.WeatherDTO
+	locations []models.CityLocation
+	err       error
+}
+
+func (f *fakeWeatherService) GetWeather(_ context.Context, city string) (models.Weather, error) {
+	if f.err != nil {
+		return models.Weather{}, f.err
+	}
+	weather, ok := f.weather[city]
+	if !ok {
+		return models.Weather{}, models.ErrCityNotFound
+	}
+	return weather, nil
+}
+
+func (f *fakeWeatherService) GetHistory(_ context.Context, query models.HistoryQuery) (models.History, error) {
+	f.query = query
+	return f.history, f.err
+}
+
+func (f *fakeWeatherService) WatchWeather(_ context.Context, city string, _ int64, _ int) (*events.Subscription, []models.WeatherDTO, error) {
+	return f.broker.Subscribe(events.ReadingTopic(city)), f.backlog, f.err
+}
+
+func (f *fakeWeatherService) SubscribeEvents() *events.Subscription {
+	return f.broker.Subscribe()
+}
+
+// ResolveCity возвращает нормализованное название: в тестах сервера псевдонимов нет
+func (f *fakeWeatherService) ResolveCity(_ context.Context, city string) (string, error) {
+	if key := models.NormalizeCity(city); key != "" {
+		return key, nil
+	}
+	return "", models.ErrInvalidQuery
+}
+
+func (f *fakeWeatherService) ListLocations(_ context.Context) ([]models.CityLocation, error) {
+	return f.locations, f.err
+}
+
+// newTestClient запускает сервер поверх bufconn и возвращает клиентское соединение
+func newTestClient(t *testing.T, svc *fakeWeatherService) *grpc.ClientConn {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer(context.Background(), 0, "", svc, config.StreamConfig{ResumeLimit: 100})
+	go server.serve(listener)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Stop(ctx)
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// testContext возвращает контекст с таймаутом, чтобы зависший вызов не блокировал тесты
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestGetCurrent(t *testing.T) {
+	observed := time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)
+	svc := &fakeWeatherService{
+		broker: events.NewBroker(),
+		weather: map[string]models.Weather{
+			"moscow": {
+				ID: 7, Name: "moscow", Temperature: -3.5, ObservedAt: observed, AgeSeconds: 60,
+				Source: models.SourceOpenMeteo, Stale: true,
+				Location: &models.Location{Name: "Moscow", Country: "Russia", Latitude: 55.75, Longitude: 37.62},
+			},
+		},
+	}
+	client := weatherv1.NewWeatherServiceClient(newTestClient(t, svc))
+	ctx := testContext(t)
+
+	resp, err := client.GetCurrent(ctx, &weatherv1.GetCurrentRequest{City: "moscow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reading := resp.GetReading()
+	if reading.GetId() != 7 || reading.GetCity() != "moscow" || reading.GetTemperature() != -3.5 {
+		t.Errorf("unexpected reading: %v", reading)
+	}
+	if !reading.GetObservedAt().AsTime().Equal(observed) {
+		t.Errorf("observed_at = %v, want %v", reading.GetObservedAt().AsTime(), observed)
+	}
+	if reading.GetFetchedAt() != nil {
+		t.Errorf("fetched_at must be unset when unknown, got %v", reading.GetFetchedAt())
+	}
+	if !reading.GetStale() || reading.GetLocation().GetCountry() != "Russia" {
+		t.Errorf("unexpected stale flag or location: %v", reading)
+	}
+
+	tests := []struct {
+		name string
+		city string
+		err  error
+		code codes.Code
+	}{
+		{name: "empty city", city: "", code: codes.InvalidArgument},
+		{name: "unknown city", city: "atlantis", code: codes.NotFound},
+		{name: "storage error", city: "moscow", err: errors.New("connection refused"), code: codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc.err = tt.err
+			defer func() { svc.err = nil }()
+
+			_, err := client.GetCurrent(ctx, &weatherv1.GetCurrentRequest{City: tt.city})
+			if got := status.Code(err); got != tt.code {
+				t.Errorf("code = %v, want %v (%v)", got, tt.code, err)
+			}
+		})
+	}
+}
+
+func TestGetHistory(t *testing.T) {
+	bucket := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	svc := &fakeWeatherService{
+		broker: events.NewBroker(),
+		history: models.History{
+			Name:       "moscow",
+			Agg:        models.AggMax,
+			Points:     []models.HistoryPoint{{Timestamp: bucket, Temperature: 1.5, Count: 4}},
+			NextCursor: "next",
+		},
+	}
+	client := weatherv1.NewWeatherServiceClient(newTestClient(t, svc))
+	ctx := testContext(t)
+
+	resp, err := client.GetHistory(ctx, &weatherv1.GetHistoryRequest{City: "moscow", Agg: models.AggMax})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Незаполненные параметры принимают значения по умолчанию
+	q := svc.query
+	if q.Step != defaultHistoryStep || q.Limit != defaultHistoryLimit || q.Agg != models.AggMax {
+		t.Errorf("unexpected defaults: %+v", q)
+	}
+	if q.To.Sub(q.From) != defaultHistoryRange {
+		t.Errorf("range = %v, want %v", q.To.Sub(q.From), defaultHistoryRange)
+	}
+
+	if resp.GetNextCursor() != "next" || len(resp.GetPoints()) != 1 {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+	if p := resp.GetPoints()[0]; !p.GetTimestamp().AsTime().Equal(bucket) || p.GetCount() != 4 {
+		t.Errorf("unexpected point: %v", p)
+	}
+
+	svc.err = models.ErrInvalidQuery
+	_, err = client.GetHistory(ctx, &weatherv1.GetHistoryRequest{City: "moscow"})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("code = %v, want %v", got, codes.InvalidArgument)
+	}
+}
+
+func TestWatchReadings(t *testing.T) {
+	broker := events.NewBroker()
+	svc := &fakeWeatherService{
+		broker: broker,
+		// Показание 6 придет повторно из подписки и должно быть пропущено
+		backlog: []models.WeatherDTO{{ID: 6, Name: "moscow"}},
+	}
+	client := weatherv1.NewWeatherServiceClient(newTestClient(t, svc))
+	ctx := testContext(t)
+
+	stream, err := client.WatchReadings(ctx, &weatherv1.WatchReadingsRequest{Cities: []string{"moscow"}, AfterId: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reading, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reading.GetId() != 6 {
+		t.Fatalf("backlog reading id = %d, want 6", reading.GetId())
+	}
+
+	broker.PublishReading(models.WeatherDTO{ID: 6, Name: "moscow"})
+	broker.PublishReading(models.WeatherDTO{ID: 8, Name: "moscow"})
+	reading, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reading.GetId() != 8 {
+		t.Errorf("live reading id = %d, want 8", reading.GetId())
+	}
+}
+
+func TestWatchReadingsSeveralCities(t *testing.T) {
+	broker := events.NewBroker()
+	svc := &fakeWeatherService{broker: broker}
+	client := weatherv1.NewWeatherServiceClient(newTestClient(t, svc))
+	ctx := testContext(t)
+
+	stream, err := client.WatchReadings(ctx, &weatherv1.WatchReadingsRequest{Cities: []string{"moscow", "Kazan"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Подписка оформляется в обработчике асинхронно относительно клиента,
+	// поэтому показания публикуются, пока клиент не получит первое из них
+	received := make(chan *weatherv1.Reading, 2)
+	go func() {
+		for {
+			reading, err := stream.Recv()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- reading
+		}
+	}()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	var first *weatherv1.Reading
+	for id := int64(1); first == nil; id++ {
+		select {
+		case <-ticker.C:
+			broker.PublishReading(models.WeatherDTO{ID: id, Name: "kazan"})
+		case first = <-received:
+		case <-ctx.Done():
+			t.Fatal("no readings received")
+		}
+	}
+	if first.GetCity() != "kazan" {
+		t.Errorf("city = %q, want kazan", first.GetCity())
+	}
+}
+
+func TestWatchReadingsInvalidArgument(t *testing.T) {
+	svc := &fakeWeatherService{broker: events.NewBroker()}
+	client := weatherv1.NewWeatherServiceClient(newTestClient(t, svc))
+	ctx := testContext(t)
+
+	tests := []struct {
+		name string
+		req  *weatherv1.WatchReadingsRequest
+	}{
+		{name: "no cities", req: &weatherv1.WatchReadingsRequest{}},
+		{name: "empty city", req: &weatherv1.WatchReadingsRequest{Cities: []string{""}}},
+		{name: "negative after_id", req: &weatherv1.WatchReadingsRequest{Cities: []string{"moscow"}, AfterId: -1}},
+		{name: "after_id with several cities", req: &weatherv1.WatchReadingsRequest{Cities: []string{"moscow", "kazan"}, AfterId: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := client.WatchReadings(ctx, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = stream.Recv()
+			if got := status.Code(err); got != codes.InvalidArgument {
+				t.Errorf("code = %v, want %v (%v)", got, codes.InvalidArgument, err)
+			}
+		})
+	}
+}
+
+func TestListLocations(t *testing.T) {
+	svc := &fakeWeatherService{
+		broker: events.NewBroker(),
+		locations: []models.CityLocation{
+			{City: "moscow", Location: models.Location{Name: "Moscow", Country: "Russia"}, UpdatedAt: time.Unix(100, 0)},
+		},
+	}
+	client := weatherv1.NewWeatherServiceClient(newTestClient(t, svc))
+
+	resp, err := client.ListLocations(testContext(t), &weatherv1.ListLocationsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetLocations()) != 1 {
+		t.Fatalf("got %d locations, want 1", len(resp.GetLocations()))
+	}
+	if l := resp.GetLocations()[0]; l.GetCity() != "moscow" || l.GetLocation().GetName() != "Moscow" || l.GetUpdatedAt().GetSeconds() != 100 {
+		t.Errorf("unexpected location: %v", l)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	conn := newTestClient(t, &fakeWeatherService{broker: events.NewBroker()})
+
+	resp, err := healthpb.NewHealthClient(conn).Check(testContext(t), &healthpb.HealthCheckRequest{
+		Service: weatherv1.WeatherService_ServiceDesc.ServiceName,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status = %v, want SERVING", resp.GetStatus())
+	}
+}
+
+func TestWatchReadingsOrdersPerCity(t *testing.T) {
+	broker := events.NewBroker()
+	svc := &fakeWeatherService{broker: broker}
+	client := weatherv1.NewWeatherServiceClient(newTestClient(t, svc))
+	ctx := testContext(t)
+
+	stream, err := client.WatchReadings(ctx, &weatherv1.WatchReadingsRequest{Cities: []string{"moscow", "kazan"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan *weatherv1.Reading, 16)
+	go func() {
+		for {
+			reading, err := stream.Recv()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- reading
+		}
+	}()
+
+	// Дожидаемся оформления подписки по первому доставленному показанию
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	var lastMoscow int64
+	for lastMoscow == 0 {
+		select {
+		case <-ticker.C:
+			broker.PublishReading(models.WeatherDTO{ID: 100, Name: "moscow"})
+		case r := <-received:
+			lastMoscow = r.GetId()
+		case <-ctx.Done():
+			t.Fatal("no readings received")
+		}
+	}
+
+	// Показание другого города с меньшим id не должно теряться
+	broker.PublishReading(models.WeatherDTO{ID: 50, Name: "kazan"})
+	for {
+		select {
+		case r := <-received:
+			if r.GetCity() == "kazan" {
+				if r.GetId() != 50 {
+					t.Errorf("kazan reading id = %d, want 50", r.GetId())
+				}
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("reading of another city with a lower id was dropped")
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// aliasesResponse - ответ со списком псевдонимов города
+type aliasesResponse struct {
+	City    string             `json:"city"`
+	Aliases []models.CityAlias `json:"aliases"`
+}
+
+// aliasRequest - тело запроса на добавление псевдонима
+type aliasRequest struct {
+	Alias string `json:"alias"`
+}
+
+// getAliases обрабатывает GET /api/v1/admin/locations/{city}/aliases - псевдонимы города
+// Город можно указать любым его псевдонимом
+func (h *Handlers) getAliases(w http.ResponseWriter, r *http.Request) {
+	aliases, err := h.weatherService.ListAliases(r.Context(), chi.URLParam(r, "city"))
+	if err != nil {
+		writeAliasError(w, err, "Error fetching aliases")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, aliasesResponse{City: aliases[0].City, Aliases: aliases})
+}
+
+// postAlias обрабатывает POST /api/v1/admin/locations/{city}/aliases - добавление псевдонима
+// Псевдоним, который уже принадлежит другому городу, - 409 Conflict
+func (h *Handlers) postAlias(w http.ResponseWriter, r *http.Request) {
+	var req aliasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "body must be a JSON object")
+		return
+	}
+
+	alias, err := h.weatherService.AddAlias(r.Context(), chi.URLParam(r, "city"), req.Alias)
+	if err != nil {
+		writeAliasError(w, err, "Error adding alias")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, alias)
+}
+
+// deleteAlias обрабатывает DELETE /api/v1/admin/locations/{city}/aliases/{alias} - удаление псевдонима
+func (h *Handlers) deleteAlias(w http.ResponseWriter, r *http.Request) {
+	err := h.weatherService.DeleteAlias(r.Context(), chi.URLParam(r, "city"), chi.URLParam(r, "alias"))
+	if err != nil {
+		writeAliasError(w, err, "Error deleting alias")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeAliasError записывает ответ с ошибкой операции над псевдонимами
+// message используется для внутренних ошибок, чтобы не раскрывать их детали
+func writeAliasError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, models.ErrInvalidQuery):
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+	case errors.Is(err, models.ErrCityNotFound):
+		writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+	case errors.Is(err, models.ErrAliasTaken):
+		writeError(w, http.StatusConflict, codeConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, codeInternal, message)
+	}
+}
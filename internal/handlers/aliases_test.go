@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// newAliasRouter создает маршрутизатор с административным ключом "admin" поверх сервиса svc
+func newAliasRouter(t *testing.T, svc *fakeWeatherService) http.Handler {
+	t.Helper()
+
+	keys := newFakeKeyService()
+	keys.keys["admin"] = models.APIKey{ID: 1, Name: "ops", Scopes: []string{models.ScopeAdmin}}
+	return newAuthRouter(t, svc, keys, testConfig())
+}
+
+func TestListAliases(t *testing.T) {
+	svc := newFakeWeatherService()
+	svc.aliases = []models.CityAlias{
+		{Alias: "moscow", City: "moscow", Source: models.AliasSourceGeocoding, CreatedAt: time.Now()},
+		{Alias: "москва", City: "moscow", Source: models.AliasSourceGeocoding, CreatedAt: time.Now()},
+	}
+
+	rec := serve(newAliasRouter(t, svc), newKeyRequest(http.MethodGet, "/api/v1/admin/locations/Москва/aliases", "admin", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	var body aliasesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.City != "moscow" || len(body.Aliases) != 2 {
+		t.Errorf("unexpected body: %s", rec.Body)
+	}
+}
+
+func TestAddAlias(t *testing.T) {
+	svc := newFakeWeatherService()
+
+	rec := serve(newAliasRouter(t, svc), newKeyRequest(http.MethodPost, "/api/v1/admin/locations/moscow/aliases", "admin", `{"alias": "Мск"}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if svc.aliasCity != "moscow" || svc.alias != "Мск" {
+		t.Errorf("added %q to %q", svc.alias, svc.aliasCity)
+	}
+
+	var alias models.CityAlias
+	if err := json.Unmarshal(rec.Body.Bytes(), &alias); err != nil {
+		t.Fatal(err)
+	}
+	if alias.Alias != "мск" || alias.Source != models.AliasSourceManual {
+		t.Errorf("unexpected body: %s", rec.Body)
+	}
+}
+
+func TestAliasErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		err    error
+		status int
+	}{
+		{name: "taken", method: http.MethodPost, path: "/api/v1/admin/locations/moscow/aliases", body: `{"alias": "paris"}`, err: models.ErrAliasTaken, status: http.StatusConflict},
+		{name: "unknown city", method: http.MethodGet, path: "/api/v1/admin/locations/omsk/aliases", err: models.ErrCityNotFound, status: http.StatusNotFound},
+		{name: "empty alias", method: http.MethodPost, path: "/api/v1/admin/locations/moscow/aliases", body: `{"alias": ""}`, status: http.StatusBadRequest},
+		{name: "city key", method: http.MethodDelete, path: "/api/v1/admin/locations/moscow/aliases/moscow", err: models.ErrInvalidQuery, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newFakeWeatherService()
+			svc.err = tt.err
+
+			rec := serve(newAliasRouter(t, svc), newKeyRequest(tt.method, tt.path, "admin", tt.body))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d, body %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestDeleteAlias(t *testing.T) {
+	svc := newFakeWeatherService()
+
+	rec := serve(newAliasRouter(t, svc), newKeyRequest(http.MethodDelete, "/api/v1/admin/locations/moscow/aliases/%D0%BC%D1%81%D0%BA", "admin", ""))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if svc.aliasCity != "moscow" || svc.alias != "мск" {
+		t.Errorf("deleted %q of %q", svc.alias, svc.aliasCity)
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// headerAPIKey - заголовок с секретом ключа API
+const headerAPIKey = "X-API-Key"
+
+// bearerPrefix - схема заголовка Authorization с JWT
+const bearerPrefix = "Bearer "
+
+// principalKey - ключ контекста запроса, под которым хранится models.Principal
+type principalKey struct{}
+
+// Заголовки квот ключа API, выставляются только для ограниченных квот
+const (
+	headerLimitMinute     = "X-RateLimit-Limit-Minute"
+	headerRemainingMinute = "X-RateLimit-Remaining-Minute"
+	headerLimitDay        = "X-RateLimit-Limit-Day"
+	headerRemainingDay    = "X-RateLimit-Remaining-Day"
+)
+
+// requireScope - middleware, пропускающее запрос только с ключом API, которому выдана область scope
+// Каждый пропущенный запрос учитывается в квотах ключа, ответ содержит заголовки
+// с остатком квот. Ключ для чтения и записи нужен только при включенном auth.enabled,
+// административные маршруты закрыты ключом всегда
+func (h *Handlers) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !h.config.Auth.Enabled && scope != models.ScopeAdmin {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			access, err := h.keyService.Authenticate(r.Context(), r.Header.Get(headerAPIKey), scope)
+			writeQuotaHeaders(w, access.Quota)
+
+			switch {
+			case err == nil:
+				next.ServeHTTP(w, r)
+			case errors.Is(err, models.ErrUnauthorized):
+				writeError(w, http.StatusUnauthorized, codeUnauthorized, err.Error())
+			case errors.Is(err, models.ErrForbidden):
+				writeError(w, http.StatusForbidden, codeForbidden, err.Error())
+			case errors.Is(err, models.ErrQuotaExceeded):
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(access.Quota.RetryAfter.Seconds()))))
+				writeError(w, http.StatusTooManyRequests, codeTooManyRequests, err.Error())
+			default:
+				writeError(w, http.StatusInternalServerError, codeInternal, "Error checking API key")
+			}
+		})
+	}
+}
+
+// authenticateAdmin - middleware административных маршрутов: проверяет, кто выполняет запрос,
+// и сохраняет models.Principal в контексте для requirePermission
+// Если JWKS настроен, нужен JWT в заголовке Authorization: Bearer. Иначе нужен ключ API
+// с областью admin, которому доступны все административные разрешения
+func (h *Handlers) authenticateAdmin(next http.Handler) http.Handler {
+	if h.tokenVerifier == nil {
+		return h.requireScope(models.ScopeAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := models.Principal{Subject: "api-key", Permissions: models.Permissions}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+		}))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), bearerPrefix)
+
+		principal, err := h.tokenVerifier.Verify(r.Context(), strings.TrimSpace(token))
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidToken) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				writeError(w, http.StatusUnauthorized, codeUnauthorized, err.Error())
+				return
+			}
+			writeError(w, http.StatusInternalServerError, codeInternal, "Error verifying token")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	})
+}
+
+// requireClientCert - middleware административных маршрутов при заданном tls.client_ca_file:
+// пропускает запрос, только если клиент предъявил сертификат, подписанный CA клиентов
+// Цепочку проверяет TLS-рукопожатие, здесь проверяется, что проверенная цепочка есть
+// Сертификат дополняет ключ API или JWT, а не заменяет их
+func (h *Handlers) requireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			writeError(w, http.StatusForbidden, codeForbidden, models.ErrClientCertRequired.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requirePermission - middleware, пропускающее запрос, только если authenticateAdmin
+// сохранил в контексте субъекта с разрешением permission
+func (h *Handlers) requirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := r.Context().Value(principalKey{}).(models.Principal)
+			if !principal.HasPermission(permission) {
+				writeError(w, http.StatusForbidden, codeForbidden, models.ErrPermissionDenied.Error())
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeQuotaHeaders записывает лимиты и остаток квот ключа
+func writeQuotaHeaders(w http.ResponseWriter, quota models.Quota) {
+	if quota.PerMinute > 0 {
+		w.Header().Set(headerLimitMinute, strconv.Itoa(quota.PerMinute))
+		w.Header().Set(headerRemainingMinute, strconv.Itoa(quota.RemainingMinute))
+	}
+	if quota.PerDay > 0 {
+		w.Header().Set(headerLimitDay, strconv.Itoa(quota.PerDay))
+		w.Header().Set(headerRemainingDay, strconv.Itoa(quota.RemainingDay))
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// newKeyRequest создает запрос с ключом API
+func newKeyRequest(method, target, secret string, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if secret != "" {
+		req.Header.Set(headerAPIKey, secret)
+	}
+	return req
+}
+
+func TestRequireScope(t *testing.T) {
+	keys := newFakeKeyService()
+	keys.keys["reader"] = models.APIKey{ID: 1, Scopes: []string{models.ScopeRead}}
+	keys.keys["admin"] = models.APIKey{ID: 2, Scopes: []string{models.ScopeAdmin}}
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		target   string
+		secret   string
+		wantCode int
+		wantErr  string
+	}{
+		{name: "disabled read is open", target: "/api/v1/moscow", wantCode: http.StatusOK},
+		{name: "disabled admin needs key", target: "/api/v1/admin/keys", wantCode: http.StatusUnauthorized, wantErr: codeUnauthorized},
+		{name: "disabled admin with key", target: "/api/v1/admin/keys", secret: "admin", wantCode: http.StatusOK},
+		{name: "missing key", enabled: true, target: "/api/v1/moscow", wantCode: http.StatusUnauthorized, wantErr: codeUnauthorized},
+		{name: "unknown key", enabled: true, target: "/api/v1/moscow", secret: "guess", wantCode: http.StatusUnauthorized, wantErr: codeUnauthorized},
+		{name: "read key", enabled: true, target: "/api/v1/moscow", secret: "reader", wantCode: http.StatusOK},
+		{name: "admin key can read", enabled: true, target: "/api/v1/moscow", secret: "admin", wantCode: http.StatusOK},
+		{name: "read key cannot administer", enabled: true, target: "/api/v1/admin/keys", secret: "reader", wantCode: http.StatusForbidden, wantErr: codeForbidden},
+		{name: "spec is public", enabled: true, target: "/api/v1/openapi.json", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Auth.Enabled = tt.enabled
+
+			rec := serve(newAuthRouter(t, newFakeWeatherService(), keys, cfg), newKeyRequest(http.MethodGet, tt.target, tt.secret, ""))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantErr != "" {
+				var body errorResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Code != tt.wantErr {
+					t.Errorf("body = %s, want error %s", rec.Body, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestRequireScopeQuotaHeaders(t *testing.T) {
+	keys := newFakeKeyService()
+	keys.keys["reader"] = models.APIKey{ID: 1, Scopes: []string{models.ScopeRead}}
+	keys.quota = models.Quota{PerMinute: 60, RemainingMinute: 59, PerDay: 1000, RemainingDay: 10}
+	cfg := testConfig()
+	cfg.Auth.Enabled = true
+
+	rec := serve(newAuthRouter(t, newFakeWeatherService(), keys, cfg), newKeyRequest(http.MethodGet, "/api/v1/moscow", "reader", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	for header, want := range map[string]string{
+		headerLimitMinute:     "60",
+		headerRemainingMinute: "59",
+		headerLimitDay:        "1000",
+		headerRemainingDay:    "10",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestRequireScopeQuotaExceeded(t *testing.T) {
+	keys := newFakeKeyService()
+	keys.keys["reader"] = models.APIKey{ID: 1, Scopes: []string{models.ScopeRead}}
+	keys.quota = models.Quota{PerMinute: 60, RetryAfter: 1500 * time.Millisecond}
+	keys.quotaErr = models.ErrQuotaExceeded
+	cfg := testConfig()
+	cfg.Auth.Enabled = true
+
+	rec := serve(newAuthRouter(t, newFakeWeatherService(), keys, cfg), newKeyRequest(http.MethodGet, "/api/v1/moscow", "reader", ""))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	// Секунды округляются вверх, чтобы клиент не повторил запрос до сброса квоты
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	if got := rec.Header().Get(headerRemainingMinute); got != "0" {
+		t.Errorf("%s = %q, want 0", headerRemainingMinute, got)
+	}
+}
+
+func TestRequireScopeStorageFailure(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth.Enabled = true
+
+	rec := serve(newAuthRouter(t, newFakeWeatherService(), failingKeys{}, cfg), newKeyRequest(http.MethodGet, "/api/v1/moscow", "reader", ""))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
+
+// failingKeys - сервис ключей, хранилище которого недоступно
+type failingKeys struct {
+	*fakeKeyService
+}
+
+func (failingKeys) Authenticate(context.Context, string, string) (models.KeyAccess, error) {
+	return models.KeyAccess{}, errors.New("connection refused")
+}
+
+func TestAdminBearerToken(t *testing.T) {
+	keys := newFakeKeyService()
+	keys.keys["admin"] = models.APIKey{ID: 1, Name: "ops", Scopes: []string{models.ScopeAdmin}}
+	tokens := &fakeTokenVerifier{tokens: map[string]models.Principal{
+		"admin-token":   {Subject: "alice", Permissions: []string{models.PermissionKeysRead, models.PermissionKeysWrite}},
+		"auditor-token": {Subject: "bob", Permissions: []string{models.PermissionKeysRead}},
+		"no-roles":      {Subject: "eve"},
+	}}
+	router := newTokenRouter(t, newFakeWeatherService(), keys, tokens, testConfig())
+
+	tests := []struct {
+		name          string
+		method        string
+		target        string
+		authorization string
+		apiKey        string
+		body          string
+		want          int
+	}{
+		{name: "read with read permission", method: http.MethodGet, target: "/api/v1/admin/keys", authorization: "Bearer auditor-token", want: http.StatusOK},
+		{name: "usage with read permission", method: http.MethodGet, target: "/api/v1/admin/keys/1/usage", authorization: "Bearer auditor-token", want: http.StatusOK},
+		{name: "write without write permission", method: http.MethodPost, target: "/api/v1/admin/keys", authorization: "Bearer auditor-token", body: `{"name": "x", "scopes": ["read"]}`, want: http.StatusForbidden},
+		{name: "revoke without write permission", method: http.MethodDelete, target: "/api/v1/admin/keys/1", authorization: "Bearer auditor-token", want: http.StatusForbidden},
+		{name: "revoke with write permission", method: http.MethodDelete, target: "/api/v1/admin/keys/1", authorization: "Bearer admin-token", want: http.StatusNoContent},
+		{name: "create with write permission", method: http.MethodPost, target: "/api/v1/admin/keys", authorization: "Bearer admin-token", body: `{"name": "x", "scopes": ["read"]}`, want: http.StatusCreated},
+		{name: "token without roles", method: http.MethodGet, target: "/api/v1/admin/keys", authorization: "Bearer no-roles", want: http.StatusForbidden},
+		{name: "missing token", method: http.MethodGet, target: "/api/v1/admin/keys", want: http.StatusUnauthorized},
+		{name: "invalid token", method: http.MethodGet, target: "/api/v1/admin/keys", authorization: "Bearer forged", want: http.StatusUnauthorized},
+		{name: "other scheme", method: http.MethodGet, target: "/api/v1/admin/keys", authorization: "Basic admin-token", want: http.StatusUnauthorized},
+		{name: "admin API key is not enough", method: http.MethodGet, target: "/api/v1/admin/keys", apiKey: "admin", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newKeyRequest(tt.method, tt.target, tt.apiKey, tt.body)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			rec := serve(router, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusUnauthorized && !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "Bearer") {
+				t.Errorf("WWW-Authenticate = %q, want Bearer challenge", rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAdminBearerTokenVerifierError(t *testing.T) {
+	tokens := &fakeTokenVerifier{err: errors.New("jwks unavailable")}
+	router := newTokenRouter(t, newFakeWeatherService(), newFakeKeyService(), tokens, testConfig())
+
+	req := newKeyRequest(http.MethodGet, "/api/v1/admin/keys", "", "")
+	req.Header.Set("Authorization", "Bearer admin-token")
+	if rec := serve(router, req); rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
+
+func TestReadRoutesIgnoreBearerToken(t *testing.T) {
+	svc := newFakeWeatherService()
+	svc.weather = models.Weather{Name: "moscow", Temperature: 5, ObservedAt: time.Now(), FetchedAt: time.Now()}
+	router := newTokenRouter(t, svc, newFakeKeyService(), &fakeTokenVerifier{}, testConfig())
+
+	// Без auth.enabled маршруты чтения открыты, JWT проверяется только на административных
+	if rec := serve(router, newKeyRequest(http.MethodGet, "/api/v1/moscow", "", "")); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200, body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAdminClientCert(t *testing.T) {
+	keys := newFakeKeyService()
+	keys.keys["admin"] = models.APIKey{ID: 1, Name: "ops", Scopes: []string{models.ScopeAdmin}}
+	keys.keys["reader"] = models.APIKey{ID: 2, Name: "app", Scopes: []string{models.ScopeRead}}
+	svc := newFakeWeatherService()
+	svc.weather = models.Weather{Name: "moscow", ObservedAt: time.Now(), FetchedAt: time.Now()}
+
+	cfg := testConfig()
+	cfg.Auth.Enabled = true
+	cfg.TLS.ClientCAFile = "clients.pem"
+	router := newAuthRouter(t, svc, keys, cfg)
+
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "ops"}}}}}
+	tests := []struct {
+		name   string
+		target string
+		apiKey string
+		tls    *tls.ConnectionState
+		want   int
+	}{
+		{name: "admin with client cert", target: "/api/v1/admin/keys", apiKey: "admin", tls: verified, want: http.StatusOK},
+		{name: "admin without client cert", target: "/api/v1/admin/keys", apiKey: "admin", tls: &tls.ConnectionState{}, want: http.StatusForbidden},
+		{name: "admin over plain http", target: "/api/v1/admin/keys", apiKey: "admin", want: http.StatusForbidden},
+		{name: "client cert does not replace API key", target: "/api/v1/admin/keys", tls: verified, want: http.StatusUnauthorized},
+		{name: "read route without client cert", target: "/api/v1/moscow", apiKey: "reader", tls: &tls.ConnectionState{}, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newKeyRequest(http.MethodGet, tt.target, tt.apiKey, "")
+			req.TLS = tt.tls
+
+			rec := serve(router, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
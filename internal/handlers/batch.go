@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// batchRequest - тело POST /api/v1/weather для длинных списков городов
+type batchRequest struct {
+	Cities []string `json:"cities"`
+}
+
+// batchResponse - ответ пакетного запроса: результаты в порядке городов запроса
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// batchResult - результат для одного города
+// Заполнено ровно одно из полей Weather и Error, формат ошибки совпадает со схемой Error
+type batchResult struct {
+	City    string          `json:"city"`
+	Weather *models.Weather `json:"weather,omitempty"`
+	Error   *errorBody      `json:"error,omitempty"`
+}
+
+// getWeatherQuery обрабатывает GET /api/v1/weather
+// С параметром cities это пакетный запрос по списку городов,
+// с параметрами lat и lon - поиск ближайшего отслеживаемого города
+func (h *Handlers) getWeatherQuery(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	byCoordinates := values.Has("lat") || values.Has("lon")
+
+	switch {
+	case values.Has("cities") && byCoordinates:
+		writeError(w, http.StatusBadRequest, codeBadRequest, "cities cannot be combined with lat and lon")
+	case values.Has("cities"):
+		h.writeWeatherBatch(w, r, splitCities(values.Get("cities")))
+	case byCoordinates:
+		h.getNearestWeather(w, r)
+	default:
+		writeError(w, http.StatusBadRequest, codeBadRequest, "either cities or lat and lon are required")
+	}
+}
+
+// postWeatherBatch обрабатывает POST /api/v1/weather с телом {"cities": [...]}
+// Используется для списков, которые не помещаются в строку запроса
+func (h *Handlers) postWeatherBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "body must be a JSON object with a cities array")
+		return
+	}
+
+	h.writeWeatherBatch(w, r, req.Cities)
+}
+
+// writeWeatherBatch получает последние показания городов и записывает ответ
+// Ошибки отдельных городов возвращаются в их результатах со статусом ответа 200
+func (h *Handlers) writeWeatherBatch(w http.ResponseWriter, r *http.Request, cities []string) {
+	results, err := h.weatherService.GetWeatherBatch(r.Context(), cities)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidQuery) {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error fetching weather")
+		return
+	}
+
+	resp := batchResponse{Results: make([]batchResult, len(results))}
+	for i, result := range results {
+		resp.Results[i] = batchResult{City: result.City, Weather: result.Weather}
+
+		switch {
+		case result.Err == nil:
+		case errors.Is(result.Err, models.ErrCityNotFound):
+			resp.Results[i].Error = &errorBody{Code: codeNotFound, Message: result.Err.Error()}
+		default:
+			resp.Results[i].Error = &errorBody{Code: codeInternal, Message: "Error fetching weather"}
+		}
+	}
+
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error encoding weather")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(raw)
+}
+
+// splitCities разбирает список городов через запятую, пробелы вокруг названий отбрасываются
+func splitCities(raw string) []string {
+	var cities []string
+	for city := range strings.SplitSeq(raw, ",") {
+		if city = strings.TrimSpace(city); city != "" {
+			cities = append(cities, city)
+		}
+	}
+	return cities
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+func TestWeatherBatch(t *testing.T) {
+	svc := newFakeWeatherService()
+	svc.batch = []models.CityWeather{
+		{City: "moscow", Weather: &models.Weather{Name: "moscow", Temperature: -3}},
+		{City: "atlantis", Err: models.ErrCityNotFound},
+		{City: "omsk", Err: errors.New("upstream is down")},
+	}
+	router := newTestRouter(t, svc, testConfig())
+
+	requests := map[string]*http.Request{
+		"get": httptest.NewRequest(http.MethodGet, "/api/v1/weather?cities=moscow,+atlantis,omsk,", nil),
+		"post": func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/weather", strings.NewReader(`{"cities": ["moscow", "atlantis", "omsk"]}`))
+			req.Header.Set("Content-Type", "application/json")
+			return req
+		}(),
+	}
+
+	for name, req := range requests {
+		t.Run(name, func(t *testing.T) {
+			rec := serve(router, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+			if want := []string{"moscow", "atlantis", "omsk"}; !slices.Equal(svc.batchCities, want) {
+				t.Errorf("cities = %q, want %q", svc.batchCities, want)
+			}
+
+			var body batchResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Results) != 3 {
+				t.Fatalf("got %d results", len(body.Results))
+			}
+			if r := body.Results[0]; r.Weather == nil || r.Weather.Temperature != -3 || r.Error != nil {
+				t.Errorf("moscow = %+v", r)
+			}
+			if r := body.Results[1]; r.Weather != nil || r.Error == nil || r.Error.Code != codeNotFound {
+				t.Errorf("atlantis = %+v, want not_found", r)
+			}
+			if r := body.Results[2]; r.Error == nil || r.Error.Code != codeInternal || strings.Contains(r.Error.Message, "upstream") {
+				t.Errorf("omsk = %+v, want internal error without details", r)
+			}
+		})
+	}
+}
+
+func TestWeatherBatchBadRequest(t *testing.T) {
+	tests := map[string]func() *http.Request{
+		"no cities": func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/api/v1/weather", nil)
+		},
+		"empty body list": func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/weather", strings.NewReader(`{"cities": []}`))
+			req.Header.Set("Content-Type", "application/json")
+			return req
+		},
+		"not json": func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/weather", strings.NewReader(`cities=moscow`))
+			req.Header.Set("Content-Type", "application/json")
+			return req
+		},
+	}
+
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := serve(newTestRouter(t, newFakeWeatherService(), testConfig()), req())
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400, body %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestWeatherBatchServiceErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: models.ErrInvalidQuery, want: http.StatusBadRequest},
+		{err: errors.New("connection refused"), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		svc := newFakeWeatherService()
+		svc.err = tt.err
+
+		rec := serve(newTestRouter(t, svc, testConfig()), httptest.NewRequest(http.MethodGet, "/api/v1/weather?cities=moscow", nil))
+		if rec.Code != tt.want {
+			t.Errorf("%v: status = %d, want %d", tt.err, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// writeCacheHeaders выставляет ETag, Last-Modified и Cache-Control для показания
+// и сообщает, что у клиента уже есть актуальная версия (ответ 304 Not Modified)
+//
+// ETag слабый: тело ответа содержит возраст данных, который меняется со временем,
+// но само показание при этом остается тем же. Остальные поля ответа (показание и его значения,
+// признак stale, местоположение, время получения, источник) входят в ETag: клиент с сохраненной
+// копией без "stale": true должен получить новую версию, когда данные устарели
+// max-age рассчитывается как время до следующего ожидаемого сбора данных
+// При auth.enabled ответ помечается private: общий кэш (CDN, прокси) отдал бы ответ,
+// закрытый ключом, клиенту без ключа и в обход квот
+func (h *Handlers) writeCacheHeaders(w http.ResponseWriter, r *http.Request, weather models.Weather) bool {
+	etag := weatherETag(weather)
+	lastModified := h.lastModified(weather)
+
+	visibility := "public"
+	if h.config.Auth.Enabled {
+		visibility = "private"
+		w.Header().Add("Vary", headerAPIKey)
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, h.maxAge(weather)))
+
+	// If-None-Match имеет приоритет над If-Modified-Since (RFC 9110, 13.2.2)
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		return err == nil && !lastModified.After(since)
+	}
+
+	return false
+}
+
+// weatherETag вычисляет слабый ETag по всем полям ответа, кроме возраста данных
+// На одно время измерения бывает несколько показаний (Open-Meteo, станции и датчики), поэтому
+// в ETag входят идентификатор показания, станция, датчик и сами значения; производные величины
+// вычисляются из значений и отдельно не учитываются
+func weatherETag(weather models.Weather) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d|%s|%d|%d|%s|%s|%s|%t", weather.ID, weather.Name, weather.ObservedAt.UnixNano(), weather.FetchedAt.UnixNano(),
+		weather.Source, weather.Station, weather.Sensor, weather.Stale)
+	fmt.Fprintf(hash, "|%g|%s|%s", weather.Temperature, optionalValue(weather.Humidity), optionalValue(weather.WindSpeed))
+	if l := weather.Location; l != nil {
+		fmt.Fprintf(hash, "|%s|%s|%g|%g", l.Name, l.Country, l.Latitude, l.Longitude)
+	}
+
+	return fmt.Sprintf(`W/"%x-%x"`, weather.ObservedAt.UnixNano(), hash.Sum64())
+}
+
+// optionalValue форматирует необязательное значение показания для ETag
+func optionalValue(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprint(*v)
+}
+
+// lastModified возвращает время последнего изменения ответа: получение показания
+// или, если данные уже помечены stale, момент, когда они устарели
+// Местоположение обновляется при каждом получении данных, поэтому отдельно не учитывается
+func (h *Handlers) lastModified(weather models.Weather) time.Time {
+	modified := weather.ObservedAt
+	if weather.FetchedAt.After(modified) {
+		modified = weather.FetchedAt
+	}
+
+	if staleAt := weather.ObservedAt.Add(h.config.Weather.StaleAfter); weather.Stale && staleAt.After(modified) {
+		modified = staleAt
+	}
+
+	return modified.UTC().Truncate(time.Second)
+}
+
+// maxAge возвращает срок свежести ответа в секундах
+// Следующее показание ожидается через интервал сбора после последнего получения данных
+// (или после времени измерения, если время получения неизвестно)
+// Срок не превышает время до того, как данные будут помечены stale
+func (h *Handlers) maxAge(weather models.Weather) int64 {
+	ref := weather.FetchedAt
+	if ref.IsZero() {
+		ref = weather.ObservedAt
+	}
+
+	interval := h.config.Cron.Interval
+	remaining := min(time.Until(ref.Add(interval)), interval)
+
+	if staleAfter := h.config.Weather.StaleAfter; staleAfter > 0 && !weather.Stale {
+		remaining = min(remaining, time.Until(weather.ObservedAt.Add(staleAfter)))
+	}
+
+	if remaining < 0 {
+		return 0
+	}
+
+	return int64(remaining / time.Second)
+}
+
+// etagMatches проверяет заголовок If-None-Match по правилам слабого сравнения
+// Заголовок может содержать список тегов через запятую или "*"
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
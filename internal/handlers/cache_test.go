@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// cachedWeather возвращает показание, полученное fetchedAgo назад
+func cachedWeather(fetchedAgo time.Duration) models.Weather {
+	fetchedAt := time.Now().Add(-fetchedAgo)
+	return models.Weather{
+		ID:          1,
+		Name:        "moscow",
+		Temperature: 3,
+		ObservedAt:  fetchedAt.Add(-5 * time.Minute),
+		FetchedAt:   fetchedAt,
+		Source:      models.SourceOpenMeteo,
+		Location:    &models.Location{Name: "Moscow", Country: "Russia", Latitude: 55.75, Longitude: 37.62},
+	}
+}
+
+// getCity выполняет GET /api/v1/moscow с заголовками условного запроса
+func getCity(t *testing.T, weather models.Weather, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	svc := newFakeWeatherService()
+	svc.weather = weather
+	cfg := testConfig()
+	cfg.Weather.StaleAfter = time.Hour
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/moscow", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return serve(newTestRouter(t, svc, cfg), req)
+}
+
+func TestGetCityIfNoneMatch(t *testing.T) {
+	weather := cachedWeather(time.Minute)
+	first := getCity(t, weather, nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, ETag = %q", first.Code, etag)
+	}
+
+	stale := weather
+	stale.Stale = true
+	moved := weather
+	moved.Location = &models.Location{Name: "Moscow", Country: "Russia", Latitude: 55.7, Longitude: 37.6}
+	aged := weather
+	aged.AgeSeconds += 600
+	// Показание станции на то же время измерения и получения
+	station := weather
+	station.ID, station.Station, station.Source = 2, "backyard", models.SourceStation
+	warmer := weather
+	warmer.Temperature = 4
+	humid := weather
+	humid.Humidity = new(float64)
+
+	tests := []struct {
+		name    string
+		weather models.Weather
+		header  string
+		want    int
+	}{
+		{name: "same reading", weather: weather, header: etag, want: http.StatusNotModified},
+		{name: "age changed only", weather: aged, header: etag, want: http.StatusNotModified},
+		{name: "strong form of the tag", weather: weather, header: etag[len("W/"):], want: http.StatusNotModified},
+		{name: "tag in a list", weather: weather, header: `"other", ` + etag, want: http.StatusNotModified},
+		{name: "wildcard", weather: weather, header: "*", want: http.StatusNotModified},
+		{name: "became stale", weather: stale, header: etag, want: http.StatusOK},
+		{name: "location changed", weather: moved, header: etag, want: http.StatusOK},
+		{name: "other reading at the same time", weather: station, header: etag, want: http.StatusOK},
+		{name: "temperature changed", weather: warmer, header: etag, want: http.StatusOK},
+		{name: "humidity added", weather: humid, header: etag, want: http.StatusOK},
+		{name: "other tag", weather: weather, header: `W/"other"`, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := getCity(t, tt.weather, map[string]string{"If-None-Match": tt.header})
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Code == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("304 response has a body: %q", rec.Body)
+			}
+		})
+	}
+}
+
+func TestGetCityIfModifiedSince(t *testing.T) {
+	weather := cachedWeather(time.Minute)
+	lastModified := getCity(t, weather, nil).Header().Get("Last-Modified")
+
+	stale := weather
+	stale.ObservedAt = time.Now().Add(-2 * time.Hour)
+	stale.FetchedAt = stale.ObservedAt
+	stale.Stale = true
+	// Копия, сохраненная клиентом до того, как данные устарели
+	beforeStale := stale.ObservedAt.Add(time.Minute).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name    string
+		weather models.Weather
+		since   string
+		want    int
+	}{
+		{name: "not modified", weather: weather, since: lastModified, want: http.StatusNotModified},
+		{name: "modified", weather: weather, since: weather.ObservedAt.Add(-time.Hour).UTC().Format(http.TimeFormat), want: http.StatusOK},
+		{name: "became stale", weather: stale, since: beforeStale, want: http.StatusOK},
+		{name: "malformed date", weather: weather, since: "yesterday", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := getCity(t, tt.weather, map[string]string{"If-Modified-Since": tt.since})
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	// If-None-Match имеет приоритет: несовпадающий тег отменяет If-Modified-Since
+	rec := getCity(t, weather, map[string]string{"If-None-Match": `W/"other"`, "If-Modified-Since": lastModified})
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 when If-None-Match does not match", rec.Code)
+	}
+}
+
+func TestCacheControlWithAuth(t *testing.T) {
+	keys := newFakeKeyService()
+	keys.keys["reader"] = models.APIKey{ID: 1, Scopes: []string{models.ScopeRead}}
+
+	tests := []struct {
+		name        string
+		enabled     bool
+		wantControl string
+		wantVary    string
+	}{
+		{name: "open api", wantControl: "public, max-age=", wantVary: ""},
+		{name: "auth enabled", enabled: true, wantControl: "private, max-age=", wantVary: headerAPIKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newFakeWeatherService()
+			svc.weather = cachedWeather(time.Minute)
+			cfg := testConfig()
+			cfg.Auth.Enabled = tt.enabled
+
+			rec := serve(newAuthRouter(t, svc, keys, cfg), newKeyRequest(http.MethodGet, "/api/v1/moscow", "reader", ""))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("Cache-Control"); !strings.HasPrefix(got, tt.wantControl) {
+				t.Errorf("Cache-Control = %q, want %q...", got, tt.wantControl)
+			}
+			if got := rec.Header().Get("Vary"); got != tt.wantVary {
+				t.Errorf("Vary = %q, want %q", got, tt.wantVary)
+			}
+		})
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	h := &Handlers{config: testConfig()}
+	h.config.Cron.Interval = 10 * time.Minute
+	h.config.Weather.StaleAfter = time.Hour
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		weather models.Weather
+		want    int64
+	}{
+		{
+			name:    "time until next collection",
+			weather: models.Weather{ObservedAt: now.Add(-5 * time.Minute), FetchedAt: now.Add(-2 * time.Minute)},
+			want:    8 * 60,
+		},
+		{
+			name:    "observed time when fetch time is unknown",
+			weather: models.Weather{ObservedAt: now.Add(-3 * time.Minute)},
+			want:    7 * 60,
+		},
+		{
+			name:    "collection overdue",
+			weather: models.Weather{ObservedAt: now.Add(-30 * time.Minute), FetchedAt: now.Add(-20 * time.Minute)},
+			want:    0,
+		},
+		{
+			name:    "capped by interval for clock skew",
+			weather: models.Weather{ObservedAt: now, FetchedAt: now.Add(time.Hour)},
+			want:    10 * 60,
+		},
+		{
+			name:    "capped by time until stale",
+			weather: models.Weather{ObservedAt: now.Add(-58 * time.Minute), FetchedAt: now},
+			want:    2 * 60,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Допуск в секунду на время выполнения теста
+			if got := h.maxAge(tt.weather); got != tt.want && got != tt.want-1 {
+				t.Errorf("maxAge = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
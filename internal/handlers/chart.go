@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/olezhek28/wether-service/internal/charts"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// Системы единиц графиков
+const (
+	unitsMetric   = "metric"   // °C, км/ч
+	unitsImperial = "imperial" // °F, mph
+)
+
+// Параметры графиков по умолчанию и допустимые размеры
+const (
+	defaultChartRange  = 24 * time.Hour
+	defaultChartWidth  = 800
+	defaultPanelHeight = 300 // Высота панели одной переменной, если высота не задана
+	minChartWidth      = 200
+	maxChartWidth      = 2000
+	minChartHeight     = 120
+	maxChartHeight     = 2000
+	// Пикселей ширины на точку: на более длинных диапазонах показания усредняются
+	// по интервалам, чтобы точек было не больше, чем различимо на графике
+	chartPixelsPerPoint = 4
+)
+
+// chartSteps - интервалы усреднения графиков в порядке возрастания
+// Для диапазонов, которым не хватает самого длинного, интервал округляется до суток
+var chartSteps = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour,
+	3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// chartThemes - темы графиков по названиям
+var chartThemes = map[string]charts.Theme{
+	"light": charts.ThemeLight,
+	"dark":  charts.ThemeDark,
+}
+
+// chartUnit - единица переменной в системе единиц и перевод в нее из хранимой
+type chartUnit struct {
+	symbol  string
+	convert func(float64) float64
+}
+
+// chartVariable описывает переменную показания, которую можно вывести на график
+type chartVariable struct {
+	label string               // Подпись панели
+	units map[string]chartUnit // Единицы по системам
+}
+
+// Единицы переменных графиков по системам
+var (
+	temperatureUnits = map[string]chartUnit{
+		unitsMetric:   {symbol: "°C", convert: func(v float64) float64 { return v }},
+		unitsImperial: {symbol: "°F", convert: func(v float64) float64 { return v*9/5 + 32 }},
+	}
+	percentUnits = map[string]chartUnit{
+		unitsMetric:   {symbol: "%", convert: func(v float64) float64 { return v }},
+		unitsImperial: {symbol: "%", convert: func(v float64) float64 { return v }},
+	}
+	speedUnits = map[string]chartUnit{
+		unitsMetric:   {symbol: "км/ч", convert: func(v float64) float64 { return v }},
+		unitsImperial: {symbol: "mph", convert: func(v float64) float64 { return v / 1.609344 }},
+	}
+	densityUnits = map[string]chartUnit{
+		unitsMetric:   {symbol: "г/м³", convert: func(v float64) float64 { return v }},
+		unitsImperial: {symbol: "г/м³", convert: func(v float64) float64 { return v }},
+	}
+)
+
+// chartVariables - переменные графиков по названиям из параметра variables
+// Совпадают с переменными показания models.Variables
+var chartVariables = map[string]chartVariable{
+	models.VariableTemperature:      {label: "Температура", units: temperatureUnits},
+	models.VariableHumidity:         {label: "Влажность", units: percentUnits},
+	models.VariableWindSpeed:        {label: "Скорость ветра", units: speedUnits},
+	models.VariableDewPoint:         {label: "Точка росы", units: temperatureUnits},
+	models.VariableHeatIndex:        {label: "Индекс жары", units: temperatureUnits},
+	models.VariableWindChill:        {label: "Ветро-холодовой индекс", units: temperatureUnits},
+	models.VariableHumidex:          {label: "Хьюмидекс", units: temperatureUnits},
+	models.VariableAbsoluteHumidity: {label: "Абсолютная влажность", units: densityUnits},
+	models.VariableFeelsLike:        {label: "Ощущается как", units: temperatureUnits},
+}
+
+// historyValue возвращает значение переменной в точке ряда в хранимых единицах
+// Второе значение false, если в интервале значение переменной неизвестно
+func historyValue(p models.HistoryPoint, variable string) (float64, bool) {
+	if variable == models.VariableTemperature {
+		return p.Temperature, true
+	}
+	v, ok := p.Values[variable]
+	return v, ok
+}
+
+// chartQuery - разобранные параметры запроса графика
+type chartQuery struct {
+	history   models.HistoryQuery
+	variables []string
+	units     string
+	theme     charts.Theme
+	width     int
+	height    int
+}
+
+// chartRenderers - форматы графиков: тип содержимого и функция отрисовки
+var chartRenderers = map[string]struct {
+	contentType string
+	render      func(io.Writer, []charts.Series, charts.Options) error
+}{
+	"svg": {contentType: "image/svg+xml", render: charts.SVG},
+	"png": {contentType: "image/png", render: charts.PNG},
+}
+
+// getChartSVG обрабатывает GET /api/v1/{city}/chart.svg - график переменных в формате SVG
+func (h *Handlers) getChartSVG(w http.ResponseWriter, r *http.Request) {
+	h.writeChart(w, r, "svg")
+}
+
+// getChartPNG обрабатывает GET /api/v1/{city}/chart.png - график переменных в формате PNG
+func (h *Handlers) getChartPNG(w http.ResponseWriter, r *http.Request) {
+	h.writeChart(w, r, "png")
+}
+
+// writeChart строит график по сохраненным показаниям города
+// Параметры: from, to (RFC 3339, по умолчанию последние сутки), variables (через запятую),
+// width, height, theme (light, dark) и units (metric, imperial)
+// Показания усредняются по интервалам, длина которых растет с диапазоном, поэтому
+// число точек ограничено шириной графика, а не количеством показаний
+func (h *Handlers) writeChart(w http.ResponseWriter, r *http.Request, format string) {
+	ctx := r.Context()
+	renderer := chartRenderers[format]
+
+	query, err := parseChartQuery(chi.URLParam(r, "city"), r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	history, err := h.weatherService.GetHistory(ctx, query.history)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidQuery) {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error fetching weather history")
+		return
+	}
+
+	series := make([]charts.Series, len(query.variables))
+	for i, name := range query.variables {
+		variable := chartVariables[name]
+		unit := variable.units[query.units]
+
+		// Интервалы, где значение переменной неизвестно (например, у старых показаний нет влажности),
+		// на график не попадают
+		series[i] = charts.Series{Name: variable.label, Unit: unit.symbol, Points: make([]charts.Point, 0, len(history.Points))}
+		for _, p := range history.Points {
+			if v, ok := historyValue(p, name); ok {
+				series[i].Points = append(series[i].Points, charts.Point{Time: p.Timestamp, Value: unit.convert(v)})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	err = renderer.render(&buf, series, charts.Options{
+		Width:  query.width,
+		Height: query.height,
+		Title:  query.history.City,
+		From:   query.history.From,
+		To:     query.history.To,
+		Gap:    h.chartGap(query.history.Step),
+		Theme:  query.theme,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error rendering chart")
+		return
+	}
+
+	w.Header().Set("Content-Type", renderer.contentType)
+	w.Write(buf.Bytes())
+}
+
+// chartGap возвращает промежуток, дальше которого соседние точки графика не соединяются
+// Пустой интервал между собранными показаниями - не пропуск, поэтому промежуток
+// не меньше двух интервалов сбора
+func (h *Handlers) chartGap(step time.Duration) time.Duration {
+	return 2 * max(step, h.config.Cron.Interval)
+}
+
+// parseChartQuery разбирает параметры строки запроса графика и подставляет значения по умолчанию
+func parseChartQuery(city string, values url.Values) (chartQuery, error) {
+	query := chartQuery{
+		history: models.HistoryQuery{
+			City: city,
+			To:   time.Now().UTC(),
+			Agg:  models.AggAvg,
+		},
+		variables: []string{models.VariableTemperature},
+		units:     unitsMetric,
+		theme:     charts.ThemeLight,
+		width:     defaultChartWidth,
+	}
+
+	if raw := values.Get("to"); raw != "" {
+		to, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return chartQuery{}, fmt.Errorf("%w: to must be an RFC 3339 timestamp", models.ErrInvalidQuery)
+		}
+		query.history.To = to
+	}
+
+	query.history.From = query.history.To.Add(-defaultChartRange)
+	if raw := values.Get("from"); raw != "" {
+		from, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return chartQuery{}, fmt.Errorf("%w: from must be an RFC 3339 timestamp", models.ErrInvalidQuery)
+		}
+		query.history.From = from
+	}
+	if !query.history.From.Before(query.history.To) {
+		return chartQuery{}, fmt.Errorf("%w: from must be before to", models.ErrInvalidQuery)
+	}
+
+	if raw := values.Get("variables"); raw != "" {
+		query.variables = nil
+		for name := range strings.SplitSeq(raw, ",") {
+			name = strings.TrimSpace(name)
+			if _, ok := chartVariables[name]; !ok {
+				return chartQuery{}, fmt.Errorf("%w: unsupported variable %q", models.ErrInvalidQuery, name)
+			}
+			if !slices.Contains(query.variables, name) {
+				query.variables = append(query.variables, name)
+			}
+		}
+	}
+
+	if raw := values.Get("units"); raw != "" {
+		if raw != unitsMetric && raw != unitsImperial {
+			return chartQuery{}, fmt.Errorf("%w: units must be one of metric, imperial", models.ErrInvalidQuery)
+		}
+		query.units = raw
+	}
+
+	if raw := values.Get("theme"); raw != "" {
+		theme, ok := chartThemes[raw]
+		if !ok {
+			return chartQuery{}, fmt.Errorf("%w: theme must be one of light, dark", models.ErrInvalidQuery)
+		}
+		query.theme = theme
+	}
+
+	var err error
+	if query.width, err = parseChartSize(values, "width", defaultChartWidth, minChartWidth, maxChartWidth); err != nil {
+		return chartQuery{}, err
+	}
+	defaultHeight := min(defaultPanelHeight*len(query.variables), maxChartHeight)
+	if query.height, err = parseChartSize(values, "height", defaultHeight, minChartHeight, maxChartHeight); err != nil {
+		return chartQuery{}, err
+	}
+
+	// Температура входит в каждую точку ряда, остальные переменные агрегируются отдельно
+	for _, name := range query.variables {
+		if name != models.VariableTemperature {
+			query.history.Variables = append(query.history.Variables, name)
+		}
+	}
+
+	// Интервалы выровнены от начала эпохи, поэтому начало диапазона выравнивается по интервалу:
+	// иначе первая точка оказалась бы левее оси
+	query.history.Step = chartStep(query.history.To.Sub(query.history.From), query.width)
+	query.history.From = query.history.From.Truncate(query.history.Step)
+	query.history.Limit = int(query.history.To.Sub(query.history.From)/query.history.Step) + 2
+
+	return query, nil
+}
+
+// parseChartSize разбирает размер графика в пикселях из параметра name
+func parseChartSize(values url.Values, name string, def, lo, hi int) (int, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	size, err := strconv.Atoi(raw)
+	if err != nil || size < lo || size > hi {
+		return 0, fmt.Errorf("%w: %s must be an integer between %d and %d", models.ErrInvalidQuery, name, lo, hi)
+	}
+	return size, nil
+}
+
+// chartStep возвращает интервал усреднения, при котором на график шириной width
+// приходится не больше одной точки на chartPixelsPerPoint пикселей
+func chartStep(span time.Duration, width int) time.Duration {
+	raw := span / time.Duration(max(width/chartPixelsPerPoint, 1))
+	for _, step := range chartSteps {
+		if step >= raw {
+			return step
+		}
+	}
+	day := 24 * time.Hour
+	return (raw + day - 1) / day * day
+}
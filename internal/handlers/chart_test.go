@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// chartHistory возвращает сервис с рядом температур с интервалом сбора за последний час
+func chartHistory(temperatures ...float64) *fakeWeatherService {
+	svc := newFakeWeatherService()
+	start := time.Now().UTC().Add(-time.Hour).Truncate(15 * time.Minute)
+	for i, v := range temperatures {
+		svc.history.Points = append(svc.history.Points, models.HistoryPoint{Timestamp: start.Add(time.Duration(i) * 15 * time.Minute), Temperature: v, Count: 1})
+	}
+	return svc
+}
+
+func TestGetChartSVG(t *testing.T) {
+	svc := chartHistory(-5, 0, 5)
+
+	rec := serve(newTestRouter(t, svc, testConfig()), httptest.NewRequest(http.MethodGet, "/api/v1/moscow/chart.svg?units=imperial", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/svg+xml" {
+		t.Errorf("Content-Type = %q, want image/svg+xml", ct)
+	}
+
+	// -5..5 °C - это 23..41 °F
+	body := rec.Body.String()
+	for _, want := range []string{`width="800" height="300"`, ">moscow<", ">Температура<", ">40°F<", "<polyline"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("chart has no %q", want)
+		}
+	}
+
+	// Сутки на 800 пикселях - не больше 200 точек, то есть интервалы по 15 минут
+	q := svc.historyQuery
+	if q.Step != 15*time.Minute || q.Agg != models.AggAvg || q.City != "moscow" {
+		t.Errorf("history query = %+v", q)
+	}
+	if !q.From.Equal(q.From.Truncate(q.Step)) || q.To.Sub(q.From) < 24*time.Hour {
+		t.Errorf("range %s - %s is not a day aligned to the step", q.From, q.To)
+	}
+}
+
+func TestGetChartPNG(t *testing.T) {
+	svc := chartHistory(1, 2, 3)
+	url := "/api/v1/moscow/chart.png?from=2026-01-01T00:00:00Z&to=2026-03-01T00:00:00Z&width=400&height=200&theme=dark&variables=temperature"
+
+	rec := serve(newTestRouter(t, svc, testConfig()), httptest.NewRequest(http.MethodGet, url, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", ct)
+	}
+
+	img, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 400 || size.Y != 200 {
+		t.Errorf("size = %v, want 400x200", size)
+	}
+
+	// 59 суток на 100 точек - интервалы больше суток округляются до целых суток
+	if step := svc.historyQuery.Step; step != 24*time.Hour {
+		t.Errorf("step = %s, want 24h", step)
+	}
+}
+
+func TestGetChartVariables(t *testing.T) {
+	svc := chartHistory(10, 12, 14)
+	// Влажность известна только в двух интервалах, ветер - во всех
+	for i := range svc.history.Points {
+		svc.history.Points[i].Values = map[string]float64{models.VariableWindSpeed: 36}
+		if i > 0 {
+			svc.history.Points[i].Values[models.VariableHumidity] = 60
+		}
+	}
+
+	rec := serve(newTestRouter(t, svc, testConfig()), httptest.NewRequest(http.MethodGet, "/api/v1/moscow/chart.svg?variables=humidity,temperature,wind_speed&units=imperial", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	// Температура входит в каждую точку ряда, остальные переменные запрашиваются отдельно
+	if got := strings.Join(svc.historyQuery.Variables, ","); got != "humidity,wind_speed" {
+		t.Errorf("history variables = %q, want humidity,wind_speed", got)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{`height="900"`, ">Влажность<", ">Температура<", ">Скорость ветра<", "mph<"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("chart has no %q", want)
+		}
+	}
+	if n := strings.Count(body, "<polyline"); n != 3 {
+		t.Errorf("chart has %d lines, want one per variable", n)
+	}
+}
+
+func TestGetChartInvalid(t *testing.T) {
+	r := newTestRouter(t, chartHistory(1), testConfig())
+
+	for _, query := range []string{
+		"variables=pressure",
+		"width=50",
+		"height=5000",
+		"theme=solarized",
+		"units=kelvin",
+		"from=yesterday",
+		"from=2026-01-02T00:00:00Z&to=2026-01-01T00:00:00Z",
+	} {
+		rec := serve(r, httptest.NewRequest(http.MethodGet, "/api/v1/moscow/chart.png?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestChartStep(t *testing.T) {
+	tests := []struct {
+		span  time.Duration
+		width int
+		want  time.Duration
+	}{
+		{time.Hour, 800, time.Minute},
+		{24 * time.Hour, 800, 15 * time.Minute},
+		{24 * time.Hour, 200, 30 * time.Minute},
+		{7 * 24 * time.Hour, 800, time.Hour},
+		{365 * 24 * time.Hour, 800, 2 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := chartStep(tt.span, tt.width); got != tt.want {
+			t.Errorf("chartStep(%s, %d) = %s, want %s", tt.span, tt.width, got, tt.want)
+		}
+	}
+}
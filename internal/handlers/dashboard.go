@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/olezhek28/wether-service/internal/charts"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/olezhek28/wether-service/internal/events"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+)
+
+// dashboardFS содержит шаблоны веб-панели: страницу и карточку города
+// Стили и скрипт встроены в страницу, поэтому панель не требует отдельной сборки фронтенда
+//
+//go:embed dashboard/*.html
+var dashboardFS embed.FS
+
+var dashboardTemplates = template.Must(template.ParseFS(dashboardFS, "dashboard/*.html"))
+
+// Размер графика в карточке. Страница масштабирует его по ширине карточки
+const (
+	dashboardChartWidth  = 640
+	dashboardChartHeight = 240
+)
+
+// dashboardRange - период графиков панели и интервал усреднения для него
+// Интервал подобран так, чтобы на графике было 50-60 точек
+type dashboardRange struct {
+	Name string
+	span time.Duration
+	step time.Duration
+}
+
+// dashboardRanges - периоды, доступные в панели; первый используется по умолчанию
+var dashboardRanges = []dashboardRange{
+	{Name: "24h", span: 24 * time.Hour, step: 30 * time.Minute},
+	{Name: "7d", span: 7 * 24 * time.Hour, step: 3 * time.Hour},
+	{Name: "30d", span: 30 * 24 * time.Hour, step: 12 * time.Hour},
+}
+
+// dashboardPage - данные страницы панели
+type dashboardPage struct {
+	Ranges []dashboardRange
+	Range  string
+	Cards  []dashboardCard
+}
+
+// dashboardCard - данные карточки города
+// City - название из конфигурации, по нему строятся адреса карточки и графика,
+// Key - ключ города, с которым приходят события новых показаний
+// Weather равно nil, если показание получить не удалось
+type dashboardCard struct {
+	City    string
+	Key     string
+	Range   string
+	Weather *models.Weather
+}
+
+// Title возвращает название города по данным геокодинга или ключ города
+func (c dashboardCard) Title() string {
+	if c.Weather != nil && c.Weather.Location != nil && c.Weather.Location.Name != "" {
+		return c.Weather.Location.Name
+	}
+	return c.City
+}
+
+// CardURL возвращает адрес фрагмента карточки, по которому она обновляется
+func (c dashboardCard) CardURL() string {
+	return "/dashboard/" + url.PathEscape(c.City) + "/card?range=" + url.QueryEscape(c.Range)
+}
+
+// ChartURL возвращает адрес графика. Идентификатор показания в адресе
+// сбрасывает кэш браузера, когда в ряду появляется новая точка
+func (c dashboardCard) ChartURL() string {
+	query := url.Values{"range": {c.Range}}
+	if c.Weather != nil {
+		query.Set("v", fmt.Sprint(c.Weather.ID))
+	}
+	return "/dashboard/" + url.PathEscape(c.City) + "/chart.svg?" + query.Encode()
+}
+
+// initDashboard регистрирует маршруты веб-панели
+// Панель показывает только отслеживаемые города (cron.cities) и только сохраненные показания,
+// поэтому ее запросы не обращаются к внешним API. При auth.enabled, если dashboard.public
+// не задан, браузер входит в панель ключом API с областью read и получает cookie сессии
+// (см. requireDashboardSession); клиенты API могут передавать X-API-Key, как в остальном API
+func (h *Handlers) initDashboard() {
+	protected := h.config.Auth.Enabled && !h.config.Dashboard.Public
+	if protected {
+		h.r.Post("/dashboard/login", h.postDashboardLogin)
+	}
+
+	h.r.Group(func(r chi.Router) {
+		if protected {
+			r.Use(h.requireDashboardSession)
+		}
+
+		r.Get("/dashboard", h.getDashboard)
+		r.Get("/dashboard/events", h.getDashboardEvents)
+		r.Get("/dashboard/{city}/card", h.getDashboardCard)
+		r.Get("/dashboard/{city}/chart.svg", h.getDashboardChart)
+	})
+}
+
+// getDashboard обрабатывает GET /dashboard?range= - страницу с карточками всех отслеживаемых городов
+// Ошибка получения показаний не мешает показать страницу: карточки остаются без текущих данных,
+// как и карточки городов, по которым сбор еще не сохранил показаний
+func (h *Handlers) getDashboard(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rng, ok := parseDashboardRange(r)
+	if !ok {
+		http.Error(w, "unknown range", http.StatusBadRequest)
+		return
+	}
+
+	page := dashboardPage{Ranges: dashboardRanges, Range: rng.Name}
+
+	results, err := h.weatherService.GetStoredWeather(ctx, h.config.Cron.Cities)
+	if err != nil {
+		pkg.FromContext(ctx).Error("dashboard weather failed", "error", err)
+	}
+	byCity := make(map[string]*models.Weather, len(results))
+	for _, res := range results {
+		byCity[res.City] = res.Weather
+	}
+	for _, city := range h.config.Cron.Cities {
+		page.Cards = append(page.Cards, dashboardCard{
+			City:    city,
+			Key:     h.dashboardKey(r, city),
+			Range:   rng.Name,
+			Weather: byCity[city],
+		})
+	}
+
+	h.renderDashboard(w, r, "dashboard.html", page)
+}
+
+// getDashboardCard обрабатывает GET /dashboard/{city}/card?range= - фрагмент карточки города
+// Страница запрашивает его при новом показании города
+func (h *Handlers) getDashboardCard(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	city, ok := h.dashboardCity(w, r)
+	if !ok {
+		return
+	}
+	rng, ok := parseDashboardRange(r)
+	if !ok {
+		http.Error(w, "unknown range", http.StatusBadRequest)
+		return
+	}
+
+	card := dashboardCard{City: city, Key: h.dashboardKey(r, city), Range: rng.Name}
+	results, err := h.weatherService.GetStoredWeather(ctx, []string{city})
+	switch {
+	case err != nil:
+		pkg.FromContext(ctx).Error("dashboard weather failed", "city", city, "error", err)
+	case len(results) == 1:
+		card.Weather = results[0].Weather
+	}
+
+	h.renderDashboard(w, r, "card", card)
+}
+
+// getDashboardChart обрабатывает GET /dashboard/{city}/chart.svg?range= - график средней
+// температуры города за период. Интервалы без показаний остаются разрывами линии
+func (h *Handlers) getDashboardChart(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	city, ok := h.dashboardCity(w, r)
+	if !ok {
+		return
+	}
+	rng, ok := parseDashboardRange(r)
+	if !ok {
+		http.Error(w, "unknown range", http.StatusBadRequest)
+		return
+	}
+
+	// Начало выравнивается по интервалу, чтобы точки не сдвигались при каждом обновлении
+	to := time.Now().UTC()
+	from := to.Add(-rng.span).Truncate(rng.step)
+	history, err := h.weatherService.GetHistory(ctx, models.HistoryQuery{
+		City:  city,
+		From:  from,
+		To:    to,
+		Step:  rng.step,
+		Agg:   models.AggAvg,
+		Limit: int(rng.span/rng.step) + 2,
+	})
+	if err != nil {
+		http.Error(w, "Error fetching weather history", http.StatusInternalServerError)
+		return
+	}
+
+	series := charts.Series{Unit: "°C", Points: make([]charts.Point, len(history.Points))}
+	for i, p := range history.Points {
+		series.Points[i] = charts.Point{Time: p.Timestamp, Value: p.Temperature}
+	}
+
+	var buf bytes.Buffer
+	err = charts.SVG(&buf, []charts.Series{series}, charts.Options{
+		Width:  dashboardChartWidth,
+		Height: dashboardChartHeight,
+		From:   from,
+		To:     to,
+		Gap:    h.chartGap(rng.step),
+	})
+	if err != nil {
+		http.Error(w, "Error rendering chart", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+}
+
+// getDashboardEvents обрабатывает GET /dashboard/events - поток SSE с ключами
+// отслеживаемых городов, для которых появилось новое показание
+// Сами показания страница получает фрагментом карточки, поэтому событие не несет данных
+func (h *Handlers) getDashboardEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := h.weatherService.SubscribeEvents()
+	defer sub.Unsubscribe()
+
+	topics := make([]string, len(h.config.Cron.Cities))
+	for i, city := range h.config.Cron.Cities {
+		topics[i] = events.ReadingTopic(h.dashboardKey(r, city))
+	}
+	sub.Add(topics...)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.config.Stream.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// Брокер закрыл подписку из-за переполнения буфера, EventSource переподключится сам
+				pkg.FromContext(ctx).Warn("closing slow dashboard stream")
+				return
+			}
+			if event.Type != events.TypeReading {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: reading\ndata: %s\n\n", event.Reading.Name); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// dashboardCity возвращает город из пути, если он отслеживается
+// Для остальных городов отвечает 404: панель не запрашивает погоду произвольных городов
+func (h *Handlers) dashboardCity(w http.ResponseWriter, r *http.Request) (string, bool) {
+	city := chi.URLParam(r, "city")
+	if !slices.Contains(h.config.Cron.Cities, city) {
+		http.NotFound(w, r)
+		return "", false
+	}
+	return city, true
+}
+
+// dashboardKey возвращает ключ отслеживаемого города, под которым публикуются его показания
+// Если ключ определить не удалось, используется нормализованное название: так же
+// город хранится, пока он не геокодирован
+func (h *Handlers) dashboardKey(r *http.Request, city string) string {
+	key, err := h.weatherService.ResolveCity(r.Context(), city)
+	if err != nil {
+		pkg.FromContext(r.Context()).Error("dashboard city resolution failed", "city", city, "error", err)
+		return models.NormalizeCity(city)
+	}
+	return key
+}
+
+// renderDashboard выполняет шаблон панели в буфер, чтобы ошибка шаблона
+// не оставила клиенту половину страницы
+func (h *Handlers) renderDashboard(w http.ResponseWriter, r *http.Request, name string, data any) {
+	var buf bytes.Buffer
+	if err := dashboardTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		pkg.FromContext(r.Context()).Error("dashboard template failed", "template", name, "error", err)
+		http.Error(w, "Error rendering dashboard", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+}
+
+// parseDashboardRange возвращает период из параметра range, по умолчанию первый из dashboardRanges
+func parseDashboardRange(r *http.Request) (dashboardRange, bool) {
+	name := r.URL.Query().Get("range")
+	if name == "" {
+		return dashboardRanges[0], true
+	}
+	i := slices.IndexFunc(dashboardRanges, func(rng dashboardRange) bool { return rng.Name == name })
+	if i < 0 {
+		return dashboardRange{}, false
+	}
+	return dashboardRanges[i], true
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+)
+
+// dashboardCookie - cookie сессии веб-панели
+const dashboardCookie = "dashboard_session"
+
+// dashboardLogin - данные формы входа в панель
+type dashboardLogin struct {
+	Error string
+}
+
+// newDashboardSecret возвращает ключ подписи сессий панели из конфигурации
+// или случайный ключ, если он не задан
+func newDashboardSecret(cfg config.DashboardConfig) []byte {
+	if cfg.SessionSecret != "" {
+		return []byte(cfg.SessionSecret)
+	}
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return secret
+}
+
+// requireDashboardSession - middleware панели при auth.enabled
+// Браузер не может добавить X-API-Key к <img>, fetch фрагментов и EventSource, поэтому
+// панель пропускает запросы с действующей cookie сессии, выданной postDashboardLogin,
+// не расходуя квоты ключа. Запрос без сессии проверяется как запрос API по X-API-Key,
+// а страница панели без сессии и ключа показывает форму входа
+func (h *Handlers) requireDashboardSession(next http.Handler) http.Handler {
+	withKey := h.requireScope(models.ScopeRead)(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case h.validDashboardSession(r, time.Now()):
+			next.ServeHTTP(w, r)
+		case r.URL.Path == "/dashboard" && r.Header.Get(headerAPIKey) == "":
+			h.renderDashboardLogin(w, r, http.StatusUnauthorized, "")
+		default:
+			withKey.ServeHTTP(w, r)
+		}
+	})
+}
+
+// postDashboardLogin обрабатывает POST /dashboard/login - вход в панель ключом API с областью read
+// Ключ проверяется и учитывается в квотах один раз, после чего браузер получает cookie сессии
+// на dashboard.session_ttl. Сессия не хранится на сервере, поэтому отзыв ключа не завершает
+// уже выданные сессии: они действуют до истечения срока
+func (h *Handlers) postDashboardLogin(w http.ResponseWriter, r *http.Request) {
+	access, err := h.keyService.Authenticate(r.Context(), r.PostFormValue("key"), models.ScopeRead)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrUnauthorized):
+		h.renderDashboardLogin(w, r, http.StatusUnauthorized, "Неизвестный или отозванный ключ")
+		return
+	case errors.Is(err, models.ErrForbidden):
+		h.renderDashboardLogin(w, r, http.StatusForbidden, "У ключа нет области read")
+		return
+	case errors.Is(err, models.ErrQuotaExceeded):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(access.Quota.RetryAfter.Seconds()))))
+		h.renderDashboardLogin(w, r, http.StatusTooManyRequests, "Квота ключа исчерпана, повторите позже")
+		return
+	default:
+		pkg.FromContext(r.Context()).Error("dashboard login failed", "error", err)
+		h.renderDashboardLogin(w, r, http.StatusInternalServerError, "Не удалось проверить ключ")
+		return
+	}
+
+	expires := time.Now().Add(h.config.Dashboard.SessionTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     dashboardCookie,
+		Value:    h.signDashboardSession(access.Key.ID, expires),
+		Path:     "/dashboard",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// signDashboardSession возвращает значение cookie сессии ключа keyID, действующей до expires:
+// идентификатор ключа и срок действия с подписью HMAC-SHA256, поэтому подделать
+// или продлить сессию без ключа подписи нельзя
+func (h *Handlers) signDashboardSession(keyID int64, expires time.Time) string {
+	payload := fmt.Sprintf("%d.%d", keyID, expires.Unix())
+	return payload + "." + base64.RawURLEncoding.EncodeToString(h.dashboardMAC(payload))
+}
+
+// validDashboardSession сообщает, что запрос предъявил cookie сессии с верной подписью,
+// срок действия которой не истек к моменту now
+func (h *Handlers) validDashboardSession(r *http.Request, now time.Time) bool {
+	cookie, err := r.Cookie(dashboardCookie)
+	if err != nil {
+		return false
+	}
+
+	i := strings.LastIndexByte(cookie.Value, '.')
+	if i < 0 {
+		return false
+	}
+	payload := cookie.Value[:i]
+	mac, err := base64.RawURLEncoding.DecodeString(cookie.Value[i+1:])
+	if err != nil || !hmac.Equal(mac, h.dashboardMAC(payload)) {
+		return false
+	}
+
+	_, rawExpires, _ := strings.Cut(payload, ".")
+	expires, err := strconv.ParseInt(rawExpires, 10, 64)
+	return err == nil && now.Unix() < expires
+}
+
+// dashboardMAC возвращает подпись HMAC-SHA256 содержимого сессии
+func (h *Handlers) dashboardMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, h.dashboardSecret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// renderDashboardLogin отвечает формой входа в панель со статусом status
+func (h *Handlers) renderDashboardLogin(w http.ResponseWriter, r *http.Request, status int, message string) {
+	var buf bytes.Buffer
+	if err := dashboardTemplates.ExecuteTemplate(&buf, "login.html", dashboardLogin{Error: message}); err != nil {
+		pkg.FromContext(r.Context()).Error("dashboard template failed", "template", "login.html", "error", err)
+		http.Error(w, "Error rendering dashboard", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
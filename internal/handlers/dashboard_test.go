@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// dashboardConfig возвращает конфигурацию с включенной панелью для городов moscow и kazan
+func dashboardConfig() *config.Config {
+	cfg := testConfig()
+	cfg.Cron.Cities = []string{"moscow", "kazan"}
+	cfg.Dashboard.Enabled = true
+	cfg.Dashboard.SessionTTL = time.Hour
+	return cfg
+}
+
+func TestDashboardDisabled(t *testing.T) {
+	rec := serve(newTestRouter(t, newFakeWeatherService(), testConfig()), httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestGetDashboard(t *testing.T) {
+	svc := newFakeWeatherService()
+	svc.batch = []models.CityWeather{
+		{City: "moscow", Weather: &models.Weather{ID: 42, Name: "moscow", Temperature: -3.25, Location: &models.Location{Name: "Москва"}}},
+		{City: "kazan", Err: models.ErrCityNotFound},
+	}
+
+	rec := serve(newTestRouter(t, svc, dashboardConfig()), httptest.NewRequest(http.MethodGet, "/dashboard?range=7d", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", ct)
+	}
+	if strings.Join(svc.batchCities, ",") != "moscow,kazan" {
+		t.Errorf("batch cities = %v, want tracked cities", svc.batchCities)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"Москва",
+		"-3.2&nbsp;°C",
+		`src="/dashboard/moscow/chart.svg?range=7d&amp;v=42"`,
+		`data-src="/dashboard/kazan/card?range=7d"`,
+		"нет текущих данных",
+		`class="active">7d<`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page has no %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestGetDashboardUnknownRange(t *testing.T) {
+	rec := serve(newTestRouter(t, newFakeWeatherService(), dashboardConfig()), httptest.NewRequest(http.MethodGet, "/dashboard?range=1y", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestGetDashboardCard(t *testing.T) {
+	svc := newFakeWeatherService()
+	svc.weather = models.Weather{ID: 7, Name: "kazan", Temperature: 12, Stale: true}
+	r := newTestRouter(t, svc, dashboardConfig())
+
+	rec := serve(r, httptest.NewRequest(http.MethodGet, "/dashboard/kazan/card", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, `<section class="card" data-city="kazan"`) || !strings.Contains(body, "устарело") {
+		t.Errorf("card:\n%s", body)
+	}
+
+	// Неотслеживаемые города панель не показывает
+	rec = serve(r, httptest.NewRequest(http.MethodGet, "/dashboard/paris/card", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("untracked city: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestGetDashboardChart(t *testing.T) {
+	svc := newFakeWeatherService()
+	now := time.Now().UTC()
+	svc.history = models.History{Points: []models.HistoryPoint{
+		{Timestamp: now.Add(-3 * time.Hour), Temperature: 1},
+		{Timestamp: now.Add(-150 * time.Minute), Temperature: 2},
+	}}
+
+	rec := serve(newTestRouter(t, svc, dashboardConfig()), httptest.NewRequest(http.MethodGet, "/dashboard/moscow/chart.svg?range=24h", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/svg+xml" {
+		t.Errorf("Content-Type = %q, want image/svg+xml", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "<polyline") {
+		t.Errorf("chart has no line:\n%s", rec.Body)
+	}
+
+	q := svc.historyQuery
+	if q.City != "moscow" || q.Step != 30*time.Minute || q.Agg != models.AggAvg {
+		t.Errorf("history query = %+v", q)
+	}
+	if span := q.To.Sub(q.From); span < 24*time.Hour || span > 24*time.Hour+30*time.Minute {
+		t.Errorf("history span = %s, want 24h aligned to the step", span)
+	}
+	if q.Limit < int(q.To.Sub(q.From)/q.Step) {
+		t.Errorf("limit %d does not cover the range", q.Limit)
+	}
+}
+
+func TestGetDashboardEvents(t *testing.T) {
+	svc := newFakeWeatherService()
+	srv := httptest.NewServer(newTestRouter(t, svc, dashboardConfig()))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/dashboard/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// Подписка оформлена до отправки заголовков, поэтому показания после ответа не теряются
+	svc.broker.PublishReading(models.WeatherDTO{ID: 1, Name: "paris"})
+	svc.broker.PublishReading(models.WeatherDTO{ID: 2, Name: "kazan"})
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			if data != "kazan" {
+				t.Errorf("event for %q, want kazan", data)
+			}
+			return
+		}
+	}
+	t.Fatalf("stream ended without events: %v", scanner.Err())
+}
+
+func TestDashboardUsesCityKeys(t *testing.T) {
+	svc := newFakeWeatherService()
+	svc.keys = map[string]string{"Москва": "moscow"}
+	cfg := dashboardConfig()
+	cfg.Cron.Cities = []string{"Москва"}
+	srv := httptest.NewServer(newTestRouter(t, svc, cfg))
+	defer srv.Close()
+
+	// Карточка города, заданного псевдонимом, помечена ключом, с которым приходят события
+	resp, err := http.Get(srv.URL + "/dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(page), `data-city="moscow"`) {
+		t.Errorf("card is not marked with the city key:\n%s", page)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/dashboard/events", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	svc.broker.PublishReading(models.WeatherDTO{ID: 1, Name: "moscow"})
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			if data != "moscow" {
+				t.Errorf("event for %q, want moscow", data)
+			}
+			return
+		}
+	}
+	t.Fatalf("stream ended without events for the city key: %v", scanner.Err())
+}
+
+func TestDashboardAuth(t *testing.T) {
+	keys := newFakeKeyService()
+	keys.keys["reader"] = models.APIKey{ID: 1, Scopes: []string{models.ScopeRead}}
+
+	tests := []struct {
+		name     string
+		public   bool
+		secret   string
+		wantCode int
+	}{
+		{name: "key required", wantCode: http.StatusUnauthorized},
+		{name: "read key", secret: "reader", wantCode: http.StatusOK},
+		{name: "public", public: true, wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := dashboardConfig()
+			cfg.Auth.Enabled = true
+			cfg.Dashboard.Public = tt.public
+			r := newAuthRouter(t, newFakeWeatherService(), keys, cfg)
+
+			for _, target := range []string{"/dashboard", "/dashboard/moscow/card", "/dashboard/moscow/chart.svg"} {
+				rec := serve(r, newKeyRequest(http.MethodGet, target, tt.secret, ""))
+				if rec.Code != tt.wantCode {
+					t.Errorf("%s: status = %d, want %d", target, rec.Code, tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+// Браузер открывает панель так же, как страница: без X-API-Key, только с cookie,
+// а фрагменты, графики и поток событий запрашивает по ссылкам со страницы
+func TestDashboardBrowserSession(t *testing.T) {
+	keys := newFakeKeyService()
+	keys.keys["reader"] = models.APIKey{ID: 1, Scopes: []string{models.ScopeRead}}
+	keys.keys["scopeless"] = models.APIKey{ID: 2}
+	svc := newFakeWeatherService()
+	svc.weather = models.Weather{ID: 7, Name: "moscow", Temperature: 1}
+
+	cfg := dashboardConfig()
+	cfg.Auth.Enabled = true
+	srv := httptest.NewServer(newAuthRouter(t, svc, keys, cfg))
+	defer srv.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	browser := &http.Client{Jar: jar}
+
+	get := func(target string) (int, string) {
+		t.Helper()
+		resp, err := browser.Get(srv.URL + target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// Без сессии страница предлагает войти, а остальные адреса панели закрыты
+	if code, body := get("/dashboard"); code != http.StatusUnauthorized || !strings.Contains(body, `action="/dashboard/login"`) {
+		t.Fatalf("page without session: status %d:\n%s", code, body)
+	}
+	if code, _ := get("/dashboard/moscow/card"); code != http.StatusUnauthorized {
+		t.Errorf("card without session: status %d, want 401", code)
+	}
+
+	// Ключ без области read не открывает панель
+	resp, err := browser.PostForm(srv.URL+"/dashboard/login", url.Values{"key": {"scopeless"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("login without the read scope: status %d, want 403", resp.StatusCode)
+	}
+
+	resp, err = browser.PostForm(srv.URL+"/dashboard/login", url.Values{"key": {"reader"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), `data-city="moscow"`) {
+		t.Fatalf("after login: status %d:\n%s", resp.StatusCode, page)
+	}
+	loggedIn := keys.authenticated.Load()
+
+	// Карточки, графики и поток событий открываются по cookie и квоты не расходуют
+	for _, target := range []string{"/dashboard", "/dashboard/moscow/card", "/dashboard/moscow/chart.svg"} {
+		if code, body := get(target); code != http.StatusOK {
+			t.Errorf("%s: status %d:\n%s", target, code, body)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/dashboard/events", nil)
+	resp, err = browser.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("events: status %d, want 200", resp.StatusCode)
+	}
+
+	if n := keys.authenticated.Load(); n != loggedIn {
+		t.Errorf("key checked %d more times after login, want none", n-loggedIn)
+	}
+}
+
+func TestDashboardSessionSignature(t *testing.T) {
+	h := New(chi.NewRouter(), newFakeWeatherService(), newFakeKeyService(), newFakeStationService(), nil, &fakeHealthChecker{}, newFakeMetrics(), dashboardConfig())
+	now := time.Now()
+
+	session := func(value string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+		req.AddCookie(&http.Cookie{Name: dashboardCookie, Value: value})
+		return req
+	}
+
+	valid := h.signDashboardSession(1, now.Add(time.Hour))
+	if !h.validDashboardSession(session(valid), now) {
+		t.Error("valid session rejected")
+	}
+	if h.validDashboardSession(session(valid), now.Add(2*time.Hour)) {
+		t.Error("expired session accepted")
+	}
+
+	// Продление срока без ключа подписи
+	_, mac, _ := strings.Cut(strings.TrimPrefix(valid, "1."), ".")
+	if forged := fmt.Sprintf("1.%d.%s", now.Add(48*time.Hour).Unix(), mac); h.validDashboardSession(session(forged), now) {
+		t.Error("session with a forged expiry accepted")
+	}
+
+	// Сессия, подписанная другим ключом, например другой репликой без общего dashboard.session_secret
+	other := New(chi.NewRouter(), newFakeWeatherService(), newFakeKeyService(), newFakeStationService(), nil, &fakeHealthChecker{}, newFakeMetrics(), dashboardConfig())
+	if h.validDashboardSession(session(other.signDashboardSession(1, now.Add(time.Hour))), now) {
+		t.Error("session signed with another secret accepted")
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Коды ошибок в теле ответа, одинаковые для всех обработчиков API
+const (
+	codeBadRequest    = "bad_request"    // Некорректные параметры запроса
+	codeNotFound      = "not_found"      // Запрошенные данные не найдены
+	codeConflict      = "conflict"       // Запрос противоречит текущему состоянию данных
+	codeUnprocessable = "unprocessable"  // Ключ идемпотентности уже использован с другим запросом
+	codeNotAcceptable = "not_acceptable" // Ни один из форматов в Accept не поддерживается
+	codeInternal      = "internal"       // Внутренняя ошибка сервиса
+
+	codeUnauthorized    = "unauthorized"      // Ключ API не передан, неизвестен или отозван
+	codeForbidden       = "forbidden"         // Ключу API не выдана нужная область доступа
+	codeTooManyRequests = "too_many_requests" // Квота запросов ключа API исчерпана
+)
+
+// errorResponse описывает тело ответа с ошибкой: {"error": {"code": ..., "message": ...}}
+// Формат совпадает со схемой Error в openapi.json
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+// errorBody содержит машиночитаемый код и описание ошибки
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeError записывает ответ с ошибкой в едином JSON-формате
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	raw, err := json.Marshal(errorResponse{Error: errorBody{Code: code, Message: message}})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(raw)
+}
+
+// writeJSON записывает ответ с телом v в формате JSON
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error encoding response")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(raw)
+}
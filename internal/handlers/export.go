@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// Форматы ответа, которые поддерживают выгружаемые маршруты (history, stats)
+const (
+	mediaJSON   = "application/json"
+	mediaCSV    = "text/csv"
+	mediaNDJSON = "application/x-ndjson"
+)
+
+// flushEvery - через сколько записанных строк данные принудительно отправляются клиенту
+const flushEvery = 500
+
+// exportFormats - поддерживаемые форматы в порядке предпочтения при равном q
+var exportFormats = []string{mediaJSON, mediaCSV, mediaNDJSON}
+
+// negotiate выбирает формат ответа по заголовку Accept
+// Учитывает веса q и маски (*/*, text/*); без заголовка возвращает JSON
+// Вес формата берется из самого специфичного подходящего диапазона (RFC 9110, 12.5.1):
+// "application/json;q=0, */*" исключает JSON, хотя */* под него тоже подходит
+// Возвращает false, если ни один поддерживаемый формат не подходит
+func negotiate(r *http.Request) (string, bool) {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return mediaJSON, true
+	}
+
+	ranges := parseAccept(accept)
+
+	best, bestQ := "", 0.0
+	for _, format := range exportFormats {
+		q, specificity := 0.0, -1
+		for _, rng := range ranges {
+			if s := mediaSpecificity(rng.mediaType, format); s > specificity {
+				q, specificity = rng.q, s
+			}
+		}
+
+		// При равном весе остается формат, который стоит раньше в exportFormats
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+
+	return best, best != ""
+}
+
+// acceptRange - один диапазон из заголовка Accept с его весом
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept разбирает заголовок Accept, пропуская некорректные диапазоны
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+
+	return ranges
+}
+
+// mediaSpecificity сообщает, насколько точно диапазон из Accept подходит под формат:
+// 2 - точное совпадение, 1 - маска типа (text/*), 0 - */*, -1 - не подходит
+func mediaSpecificity(mediaRange, format string) int {
+	switch {
+	case mediaRange == format:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	}
+
+	prefix, ok := strings.CutSuffix(mediaRange, "/*")
+	if ok && strings.HasPrefix(format, prefix+"/") {
+		return 1
+	}
+	return -1
+}
+
+// historyWriter записывает точки исторического ряда в поток ответа
+// Реализации для CSV и NDJSON сбрасывают буфер в сеть по мере записи,
+// поэтому клиент начинает получать данные до окончания чтения из базы
+type historyWriter interface {
+	Write(point models.HistoryPoint) error
+	Close() error
+}
+
+// newHistoryWriter создает historyWriter для выбранного формата и пишет заголовки ответа
+func newHistoryWriter(w http.ResponseWriter, format string, city string) historyWriter {
+	w.Header().Set("Content-Type", format)
+
+	if format == mediaCSV {
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name", "timestamp", "temperature", "count"})
+		return &csvHistoryWriter{w: cw, flusher: flusherOf(w), city: city}
+	}
+
+	return &ndjsonHistoryWriter{enc: json.NewEncoder(w), flusher: flusherOf(w)}
+}
+
+// csvHistoryWriter пишет точки ряда строками CSV: name,timestamp,temperature,count
+type csvHistoryWriter struct {
+	w       *csv.Writer
+	flusher http.Flusher
+	city    string
+	rows    int
+}
+
+func (c *csvHistoryWriter) Write(point models.HistoryPoint) error {
+	err := c.w.Write([]string{
+		c.city,
+		point.Timestamp.UTC().Format(time.RFC3339),
+		formatFloat(point.Temperature),
+		strconv.FormatInt(point.Count, 10),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Сбрасываем данные клиенту пачками, чтобы не держать весь ответ в буфере
+	c.rows++
+	if c.rows%flushEvery == 0 {
+		return c.flush()
+	}
+	return nil
+}
+
+func (c *csvHistoryWriter) Close() error {
+	return c.flush()
+}
+
+func (c *csvHistoryWriter) flush() error {
+	c.w.Flush()
+	if c.flusher != nil {
+		c.flusher.Flush()
+	}
+	return c.w.Error()
+}
+
+// ndjsonHistoryWriter пишет каждую точку ряда отдельной JSON-строкой
+type ndjsonHistoryWriter struct {
+	enc     *json.Encoder
+	flusher http.Flusher
+	rows    int
+}
+
+func (n *ndjsonHistoryWriter) Write(point models.HistoryPoint) error {
+	if err := n.enc.Encode(point); err != nil {
+		return err
+	}
+
+	n.rows++
+	if n.rows%flushEvery == 0 && n.flusher != nil {
+		n.flusher.Flush()
+	}
+	return nil
+}
+
+func (n *ndjsonHistoryWriter) Close() error {
+	if n.flusher != nil {
+		n.flusher.Flush()
+	}
+	return nil
+}
+
+// flusherOf возвращает http.Flusher, если ResponseWriter его поддерживает
+func flusherOf(w http.ResponseWriter) http.Flusher {
+	f, _ := w.(http.Flusher)
+	return f
+}
+
+// writeStatsExport записывает сводную статистику в формате CSV (заголовок и одна строка) или NDJSON
+func writeStatsExport(w io.Writer, format string, stats models.Stats) error {
+	if format == mediaNDJSON {
+		return json.NewEncoder(w).Encode(stats)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"name", "variable", "period", "from", "to", "count", "min", "min_at", "max", "max_at", "mean", "median", "p10", "p90", "stddev"})
+	cw.Write([]string{
+		stats.Name,
+		stats.Variable,
+		stats.Period,
+		stats.From.UTC().Format(time.RFC3339),
+		stats.To.UTC().Format(time.RFC3339),
+		strconv.FormatInt(stats.Count, 10),
+		formatFloat(stats.Min),
+		stats.MinAt.UTC().Format(time.RFC3339),
+		formatFloat(stats.Max),
+		stats.MaxAt.UTC().Format(time.RFC3339),
+		formatFloat(stats.Mean),
+		formatFloat(stats.Median),
+		formatFloat(stats.P10),
+		formatFloat(stats.P90),
+		formatFloat(stats.StdDev),
+	})
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// formatFloat форматирует число в кратчайшем десятичном виде без экспоненты
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{accept: "", want: mediaJSON, ok: true},
+		{accept: "*/*", want: mediaJSON, ok: true},
+		{accept: "text/csv", want: mediaCSV, ok: true},
+		{accept: "text/*", want: mediaCSV, ok: true},
+		{accept: "application/x-ndjson", want: mediaNDJSON, ok: true},
+		{accept: "application/json;q=0.5, text/csv", want: mediaCSV, ok: true},
+		{accept: "text/csv;q=0.5, application/x-ndjson;q=0.9", want: mediaNDJSON, ok: true},
+		// Точный диапазон с q=0 исключает формат, хотя под него подходит и маска
+		{accept: "application/json;q=0, */*", want: mediaCSV, ok: true},
+		{accept: "application/json;q=0, text/csv;q=0, */*;q=0.1", want: mediaNDJSON, ok: true},
+		// Маска типа специфичнее */*
+		{accept: "text/*;q=0, */*", want: mediaJSON, ok: true},
+		{accept: "application/xml", ok: false},
+		{accept: "*/*;q=0", ok: false},
+		{accept: "text/csv;q=abc", ok: false},
+		{accept: "not a media type", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			got, ok := negotiate(r)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("negotiate(%q) = %q, %v; want %q, %v", tt.accept, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+// historyPoints возвращает две точки ряда для тестов выгрузки
+func historyPoints() []models.HistoryPoint {
+	bucket := time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)
+	return []models.HistoryPoint{
+		{Timestamp: bucket, Temperature: -1.5, Count: 4},
+		{Timestamp: bucket.Add(time.Hour), Temperature: 2, Count: 6},
+	}
+}
+
+func TestExportHistory(t *testing.T) {
+	tests := []struct {
+		name      string
+		accept    string
+		points    []models.HistoryPoint
+		streamErr error
+		err       error
+		wantCode  int
+		wantType  string
+		wantBody  string
+		wantAbort bool // Обработчик обрывает соединение через http.ErrAbortHandler
+	}{
+		{
+			name: "csv", accept: mediaCSV, points: historyPoints(),
+			wantCode: http.StatusOK, wantType: mediaCSV,
+			wantBody: "name,timestamp,temperature,count\n" +
+				"moscow,2025-01-02T03:00:00Z,-1.5,4\n" +
+				"moscow,2025-01-02T04:00:00Z,2,6\n",
+		},
+		{
+			name: "ndjson", accept: mediaNDJSON, points: historyPoints(),
+			wantCode: http.StatusOK, wantType: mediaNDJSON,
+			wantBody: `{"timestamp":"2025-01-02T03:00:00Z","temperature":-1.5,"count":4}` + "\n" +
+				`{"timestamp":"2025-01-02T04:00:00Z","temperature":2,"count":6}` + "\n",
+		},
+		{
+			name: "empty range csv", accept: mediaCSV,
+			wantCode: http.StatusOK, wantType: mediaCSV,
+			wantBody: "name,timestamp,temperature,count\n",
+		},
+		{
+			name: "empty range ndjson", accept: mediaNDJSON,
+			wantCode: http.StatusOK, wantType: mediaNDJSON,
+			wantBody: "",
+		},
+		{
+			// Ошибка после первой строки обрывает соединение, записанное отправляется
+			name: "error mid-stream", wantAbort: true, accept: mediaCSV, points: historyPoints()[:1], streamErr: errors.New("connection lost"),
+			wantCode: http.StatusOK, wantType: mediaCSV,
+			wantBody: "name,timestamp,temperature,count\n" +
+				"moscow,2025-01-02T03:00:00Z,-1.5,4\n",
+		},
+		{
+			name: "invalid query", accept: mediaCSV, err: models.ErrInvalidQuery,
+			wantCode: http.StatusBadRequest, wantType: mediaJSON,
+		},
+		{
+			name: "error before first row", accept: mediaNDJSON, err: errors.New("connection refused"),
+			wantCode: http.StatusInternalServerError, wantType: mediaJSON,
+		},
+		{
+			name: "not acceptable", accept: "application/xml",
+			wantCode: http.StatusNotAcceptable, wantType: mediaJSON,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newFakeWeatherService()
+			svc.points, svc.streamErr, svc.err = tt.points, tt.streamErr, tt.err
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/moscow/history", nil)
+			req.Header.Set("Accept", tt.accept)
+			rec, aborted := serveAbortable(newTestRouter(t, svc, testConfig()), req)
+			if aborted != tt.wantAbort {
+				t.Errorf("aborted = %v, want %v", aborted, tt.wantAbort)
+			}
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.wantType)
+			}
+			if tt.wantCode == http.StatusOK && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+// serveAbortable выполняет запрос и сообщает, оборвал ли обработчик соединение
+func serveAbortable(r http.Handler, req *http.Request) (rec *httptest.ResponseRecorder, aborted bool) {
+	rec = httptest.NewRecorder()
+	defer func() {
+		if v := recover(); v != nil {
+			if v != http.ErrAbortHandler {
+				panic(v)
+			}
+			aborted = true
+		}
+	}()
+
+	r.ServeHTTP(rec, req)
+	return rec, false
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/olezhek28/wether-service/internal/events"
+)
+
+// fakeWeatherService подменяет сервис погоды в тестах обработчиков
+// Каждый метод возвращает заранее заданный результат и запоминает параметры вызова
+type fakeWeatherService struct {
+	broker *events.Broker
+
+	weather      models.Weather
+	batch        []models.CityWeather // Результат GetWeatherBatch
+	batchCities  []string             // Города последнего пакетного запроса или чтения сохраненных показаний
+	nearest      models.NearestWeather
+	nearestQuery models.NearestQuery
+	history      models.History
+	historyQuery models.HistoryQuery
+	points       []models.HistoryPoint // Ряд для StreamHistory
+	streamErr    error                 // Ошибка StreamHistory после отправки points
+	stats        models.Stats
+	statsQuery   models.StatsQuery
+	backlog      []models.WeatherDTO
+	aliases      []models.CityAlias // Результат ListAliases
+	aliasCity    string             // Город и псевдоним последнего AddAlias или DeleteAlias
+	alias        string
+	keys         map[string]string // Ключи городов по названиям для ResolveCity
+	err          error             // Ошибка, возвращаемая методами до выполнения
+
+	subscriptions chan *events.Subscription // Подписки, выданные SubscribeEvents
+}
+
+func newFakeWeatherService() *fakeWeatherService {
+	return &fakeWeatherService{
+		broker:        events.NewBroker(),
+		subscriptions: make(chan *events.Subscription, 8),
+	}
+}
+
+func (f *fakeWeatherService) AddWeather(context.Context, models.WeatherDTO) error {
+	return f.err
+}
+
+func (f *fakeWeatherService) GetWeather(_ context.Context, city string) (models.Weather, error) {
+	if f.err != nil {
+		return models.Weather{}, f.err
+	}
+	return f.weather, nil
+}
+
+func (f *fakeWeatherService) GetWeatherBatch(_ context.Context, cities []string) ([]models.CityWeather, error) {
+	f.batchCities = cities
+	return f.batch, f.err
+}
+
+// GetStoredWeather возвращает batch, а если он не задан - weather для каждого города
+func (f *fakeWeatherService) GetStoredWeather(_ context.Context, cities []string) ([]models.CityWeather, error) {
+	f.batchCities = cities
+	if f.err != nil || f.batch != nil {
+		return f.batch, f.err
+	}
+
+	results := make([]models.CityWeather, len(cities))
+	for i, city := range cities {
+		weather := f.weather
+		results[i] = models.CityWeather{City: city, Weather: &weather}
+	}
+	return results, nil
+}
+
+func (f *fakeWeatherService) GetNearestWeather(_ context.Context, query models.NearestQuery) (models.NearestWeather, error) {
+	f.nearestQuery = query
+	return f.nearest, f.err
+}
+
+func (f *fakeWeatherService) GetHistory(_ context.Context, query models.HistoryQuery) (models.History, error) {
+	f.historyQuery = query
+	return f.history, f.err
+}
+
+func (f *fakeWeatherService) StreamHistory(_ context.Context, query models.HistoryQuery, fn func(models.HistoryPoint) error) error {
+	f.historyQuery = query
+	if f.err != nil {
+		return f.err
+	}
+	for _, p := range f.points {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return f.streamErr
+}
+
+func (f *fakeWeatherService) WatchWeather(_ context.Context, city string, _ int64, _ int) (*events.Subscription, []models.WeatherDTO, error) {
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	return f.broker.Subscribe(events.ReadingTopic(city)), f.backlog, nil
+}
+
+func (f *fakeWeatherService) SubscribeEvents() *events.Subscription {
+	sub := f.broker.Subscribe()
+	f.subscriptions <- sub
+	return sub
+}
+
+func (f *fakeWeatherService) GetStats(_ context.Context, query models.StatsQuery) (models.Stats, error) {
+	f.statsQuery = query
+	return f.stats, f.err
+}
+
+// ResolveCity возвращает ключ из keys, а для остальных городов - нормализованное название
+func (f *fakeWeatherService) ResolveCity(_ context.Context, city string) (string, error) {
+	if key, ok := f.keys[city]; ok {
+		return key, nil
+	}
+	if key := models.NormalizeCity(city); key != "" {
+		return key, nil
+	}
+	return "", models.ErrInvalidQuery
+}
+
+func (f *fakeWeatherService) ListAliases(context.Context, string) ([]models.CityAlias, error) {
+	return f.aliases, f.err
+}
+
+func (f *fakeWeatherService) AddAlias(_ context.Context, city, alias string) (models.CityAlias, error) {
+	f.aliasCity, f.alias = city, alias
+	if f.err != nil {
+		return models.CityAlias{}, f.err
+	}
+	return models.CityAlias{Alias: models.NormalizeCity(alias), City: models.NormalizeCity(city), Source: models.AliasSourceManual, CreatedAt: time.Now()}, nil
+}
+
+func (f *fakeWeatherService) DeleteAlias(_ context.Context, city, alias string) error {
+	f.aliasCity, f.alias = city, alias
+	return f.err
+}
+
+// fakeKeyService подменяет сервис ключей API
+// Authenticate пропускает секрет из keys, если у ключа есть нужная область, и возвращает заданную квоту
+type fakeKeyService struct {
+	keys     map[string]models.APIKey // Ключи по секретам
+	quota    models.Quota
+	quotaErr error // Ошибка квоты для пропущенных по области запросов
+	err      error // Ошибка остальных методов
+
+	authenticated atomic.Int64 // Количество вызовов Authenticate, то есть запросов, учтенных в квотах
+
+	created models.NewAPIKey // Параметры последнего созданного ключа
+	revoked int64
+	days    int
+	usage   []models.KeyUsage
+}
+
+func newFakeKeyService() *fakeKeyService {
+	return &fakeKeyService{keys: make(map[string]models.APIKey)}
+}
+
+func (f *fakeKeyService) Authenticate(_ context.Context, secret string, scope string) (models.KeyAccess, error) {
+	f.authenticated.Add(1)
+	key, ok := f.keys[secret]
+	if !ok {
+		return models.KeyAccess{}, models.ErrUnauthorized
+	}
+	if !key.HasScope(scope) {
+		return models.KeyAccess{Key: key}, models.ErrForbidden
+	}
+	return models.KeyAccess{Key: key, Quota: f.quota}, f.quotaErr
+}
+
+func (f *fakeKeyService) CreateKey(_ context.Context, params models.NewAPIKey) (models.CreatedAPIKey, error) {
+	f.created = params
+	return models.CreatedAPIKey{Key: models.APIKey{ID: 7, Name: params.Name, Scopes: params.Scopes}, Secret: "wsk_secret"}, f.err
+}
+
+func (f *fakeKeyService) ListKeys(context.Context) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	for _, key := range f.keys {
+		keys = append(keys, key)
+	}
+	return keys, f.err
+}
+
+func (f *fakeKeyService) RevokeKey(_ context.Context, id int64) error {
+	f.revoked = id
+	return f.err
+}
+
+func (f *fakeKeyService) GetKeyUsage(_ context.Context, _ int64, days int) ([]models.KeyUsage, error) {
+	f.days = days
+	return f.usage, f.err
+}
+
+// fakeStationService подменяет сервис метеостанций
+// Authenticate пропускает секрет из secrets только для станции, которой он выдан
+type fakeStationService struct {
+	stations map[string]models.Station // Станции по идентификаторам
+	secrets  map[string]string         // Идентификаторы станций по секретам
+	result   models.SubmissionResult   // Результат Submit
+	replayed bool
+	err      error // Ошибка методов, кроме Authenticate
+
+	idempotencyKey string               // Ключ идемпотентности последнего Submit
+	observations   []models.Observation // Показания последнего Submit
+	registered     models.NewStation    // Параметры последней зарегистрированной станции
+	revoked        int64
+}
+
+func newFakeStationService() *fakeStationService {
+	return &fakeStationService{
+		stations: make(map[string]models.Station),
+		secrets:  make(map[string]string),
+	}
+}
+
+func (f *fakeStationService) Authenticate(_ context.Context, stationID, secret string) (models.Station, error) {
+	id, ok := f.secrets[secret]
+	if !ok {
+		return models.Station{}, models.ErrInvalidStationKey
+	}
+	if id != stationID {
+		return models.Station{}, models.ErrWrongStation
+	}
+	return f.stations[id], nil
+}
+
+func (f *fakeStationService) Submit(_ context.Context, _ models.Station, idempotencyKey string, observations []models.Observation) (models.SubmissionResult, bool, error) {
+	f.idempotencyKey = idempotencyKey
+	f.observations = observations
+	return f.result, f.replayed, f.err
+}
+
+func (f *fakeStationService) RegisterStation(_ context.Context, params models.NewStation) (models.Station, error) {
+	f.registered = params
+	return models.Station{ID: params.ID, Name: params.Name, City: params.City, CreatedAt: time.Now()}, f.err
+}
+
+func (f *fakeStationService) ListStations(context.Context) ([]models.Station, error) {
+	var stations []models.Station
+	for _, s := range f.stations {
+		stations = append(stations, s)
+	}
+	return stations, f.err
+}
+
+func (f *fakeStationService) CreateStationKey(_ context.Context, stationID string) (models.CreatedStationKey, error) {
+	return models.CreatedStationKey{Key: models.StationKey{ID: 3, StationID: stationID, Prefix: "wss_secret"}, Secret: "wss_secret"}, f.err
+}
+
+func (f *fakeStationService) ListStationKeys(_ context.Context, stationID string) ([]models.StationKey, error) {
+	return []models.StationKey{{ID: 3, StationID: stationID, Prefix: "wss_secret"}}, f.err
+}
+
+func (f *fakeStationService) RevokeStationKey(_ context.Context, _ string, id int64) error {
+	f.revoked = id
+	return f.err
+}
+
+// fakeTokenVerifier подменяет проверку JWT: пропускает токены из tokens
+type fakeTokenVerifier struct {
+	tokens map[string]models.Principal // Субъекты по токенам
+	err    error                       // Ошибка для всех токенов, например недоступность JWKS
+}
+
+func (f *fakeTokenVerifier) Verify(_ context.Context, token string) (models.Principal, error) {
+	if f.err != nil {
+		return models.Principal{}, f.err
+	}
+	principal, ok := f.tokens[token]
+	if !ok {
+		return models.Principal{}, models.ErrInvalidToken
+	}
+	return principal, nil
+}
+
+// fakeHealthChecker подменяет проверки готовности и возвращает заданный результат
+type fakeHealthChecker struct {
+	readiness models.Readiness
+}
+
+func (f *fakeHealthChecker) Readiness(context.Context) models.Readiness {
+	return f.readiness
+}
+
+// observedRequest - запрос, учтенный в метриках
+type observedRequest struct {
+	route  string
+	method string
+	status int
+}
+
+// fakeMetrics запоминает учтенные запросы
+type fakeMetrics struct {
+	mu       sync.Mutex
+	requests []observedRequest
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{}
+}
+
+func (f *fakeMetrics) ObserveRequest(route, method string, status int, _ time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, observedRequest{route: route, method: method, status: status})
+}
+
+func (f *fakeMetrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("# metrics\n"))
+	})
+}
+
+// testConfig возвращает конфигурацию со значениями по умолчанию для тестов обработчиков
+func testConfig() *config.Config {
+	return &config.Config{
+		Cron:   config.CronConfig{Interval: 10 * time.Minute},
+		Stream: config.StreamConfig{Heartbeat: time.Minute, ResumeLimit: 100},
+	}
+}
+
+// newTestRouter создает маршрутизатор со всеми маршрутами API поверх svc без ключей API
+func newTestRouter(t *testing.T, svc WeatherService, cfg *config.Config) *chi.Mux {
+	t.Helper()
+
+	return newAuthRouter(t, svc, newFakeKeyService(), cfg)
+}
+
+// newAuthRouter создает маршрутизатор со всеми маршрутами API поверх svc и сервиса ключей keys
+func newAuthRouter(t *testing.T, svc WeatherService, keys KeyService, cfg *config.Config) *chi.Mux {
+	t.Helper()
+
+	return newTokenRouter(t, svc, keys, nil, cfg)
+}
+
+// newTokenRouter создает маршрутизатор, административные маршруты которого проверяют JWT через tokens
+func newTokenRouter(t *testing.T, svc WeatherService, keys KeyService, tokens TokenVerifier, cfg *config.Config) *chi.Mux {
+	t.Helper()
+
+	r := chi.NewRouter()
+	New(r, svc, keys, newFakeStationService(), tokens, &fakeHealthChecker{}, newFakeMetrics(), cfg).Init()
+	return r
+}
+
+// serve выполняет запрос к маршрутизатору и возвращает записанный ответ
+func serve(r http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
@@ -2,49 +2,223 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
+	"github.com/olezhek28/wether-service/internal/config"
 	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/olezhek28/wether-service/internal/events"
 )
 
 // WeatherService определяет контракт для сервиса погоды
 // Интерфейс описывает методы, которые используются обработчиками HTTP
 type WeatherService interface {
-	AddWeather(ctx context.Context, name string, temperature float64, timestamp time.Time) error
+	AddWeather(ctx context.Context, weather models.WeatherDTO) error
 	GetWeather(ctx context.Context, city string) (models.Weather, error)
+	GetWeatherBatch(ctx context.Context, cities []string) ([]models.CityWeather, error)
+	GetStoredWeather(ctx context.Context, cities []string) ([]models.CityWeather, error)
+	GetNearestWeather(ctx context.Context, query models.NearestQuery) (models.NearestWeather, error)
+	GetHistory(ctx context.Context, query models.HistoryQuery) (models.History, error)
+	StreamHistory(ctx context.Context, query models.HistoryQuery, fn func(models.HistoryPoint) error) error
+	WatchWeather(ctx context.Context, city string, afterID int64, resumeLimit int) (*events.Subscription, []models.WeatherDTO, error)
+	SubscribeEvents() *events.Subscription
+	GetStats(ctx context.Context, query models.StatsQuery) (models.Stats, error)
+	ResolveCity(ctx context.Context, city string) (string, error)
+	ListAliases(ctx context.Context, city string) ([]models.CityAlias, error)
+	AddAlias(ctx context.Context, city, alias string) (models.CityAlias, error)
+	DeleteAlias(ctx context.Context, city, alias string) error
+}
+
+// KeyService определяет контракт для проверки и управления ключами API
+type KeyService interface {
+	Authenticate(ctx context.Context, secret string, scope string) (models.KeyAccess, error)
+	CreateKey(ctx context.Context, params models.NewAPIKey) (models.CreatedAPIKey, error)
+	ListKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeKey(ctx context.Context, id int64) error
+	GetKeyUsage(ctx context.Context, id int64, days int) ([]models.KeyUsage, error)
+}
+
+// StationService определяет контракт для управления метеостанциями и приема их показаний
+type StationService interface {
+	Authenticate(ctx context.Context, stationID, secret string) (models.Station, error)
+	Submit(ctx context.Context, station models.Station, idempotencyKey string, observations []models.Observation) (models.SubmissionResult, bool, error)
+	RegisterStation(ctx context.Context, params models.NewStation) (models.Station, error)
+	ListStations(ctx context.Context) ([]models.Station, error)
+	CreateStationKey(ctx context.Context, stationID string) (models.CreatedStationKey, error)
+	ListStationKeys(ctx context.Context, stationID string) ([]models.StationKey, error)
+	RevokeStationKey(ctx context.Context, stationID string, id int64) error
+}
+
+// TokenVerifier определяет контракт проверки bearer-токенов административных маршрутов
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (models.Principal, error)
+}
+
+// HealthChecker определяет контракт проверок готовности реплики
+type HealthChecker interface {
+	Readiness(ctx context.Context) models.Readiness
+}
+
+// Metrics определяет контракт метрик HTTP и обработчика /metrics
+type Metrics interface {
+	ObserveRequest(route, method string, status int, duration time.Duration)
+	Handler() http.Handler
 }
 
 // Handlers представляет слой обработчиков HTTP-запросов
 // Содержит зависимости и маршрутизатор для обработки запросов
 type Handlers struct {
 	weatherService WeatherService // Сервис для работы с бизнес-логикой погоды
+	keyService     KeyService     // Сервис ключей API
+	stationService StationService // Сервис метеостанций
+	tokenVerifier  TokenVerifier  // Проверка JWT административных маршрутов, nil - маршруты защищены ключом API
+	healthChecker  HealthChecker  // Проверки готовности для /readyz
+	metrics        Metrics        // Метрики Prometheus
 	r              *chi.Mux       // Маршрутизатор Chi для управления HTTP-маршрутами
+	spec           *openapi3.T    // Спецификация OpenAPI для валидации запросов
+	config         *config.Config // Конфигурация сервиса (интервал сбора, параметры потоков)
+	// Ключ подписи cookie сессий веб-панели
+	dashboardSecret []byte
 }
 
 // New создает новый экземпляр обработчиков с внедренными зависимостями
-// Принимает маршрутизатор и сервис погоды для инициализации
+// Принимает маршрутизатор, сервис погоды, сервис ключей API, сервис метеостанций, проверку JWT
+// (nil, если JWKS не настроен), проверки готовности, метрики и конфигурацию для инициализации
 func New(
 	r *chi.Mux,
 	weatherService WeatherService,
+	keyService KeyService,
+	stationService StationService,
+	tokenVerifier TokenVerifier,
+	healthChecker HealthChecker,
+	metrics Metrics,
+	config *config.Config,
 ) *Handlers {
 	return &Handlers{
 		r:              r,
 		weatherService: weatherService,
+		keyService:     keyService,
+		stationService: stationService,
+		tokenVerifier:  tokenVerifier,
+		healthChecker:  healthChecker,
+		metrics:        metrics,
+		spec:           loadSpec(),
+		config:         config,
+
+		dashboardSecret: newDashboardSecret(config.Dashboard),
 	}
 }
 
 // Init инициализирует маршруты и middleware для обработчиков
 // Настраивает обработку HTTP-запросов и добавляет промежуточное ПО
 func (h *Handlers) Init() {
-	// Добавляем middleware для логирования всех запросов
-	h.r.Use(middleware.Logger)
+	// Спаны OpenTelemetry с продолжением трассы из traceparent, кроме служебных маршрутов
+	h.r.Use(h.traceRequests)
+
+	// Идентификатор запроса, логгер запроса в контексте и access-лог всех запросов
+	// Выполняется после начала спана, чтобы записи лога содержали trace_id
+	h.r.Use(h.logRequests)
+
+	// Метрики HTTP по шаблонам маршрутов, включая служебные маршруты
+	h.r.Use(h.observeRequests)
+
+	// Проверки живости и готовности для Kubernetes и внешнего мониторинга и метрики Prometheus
+	// Служебные маршруты в корне не требуют ключа API и не описаны в спецификации API
+	h.r.Get("/healthz", h.getHealthz)
+	h.r.Get("/readyz", h.getReadyz)
+	h.r.Method(http.MethodGet, "/metrics", h.metrics.Handler())
+
+	// Веб-панель с текущей погодой и графиками отслеживаемых городов
+	// При auth.enabled требует вход ключом API с областью read, если не открыта явно (dashboard.public)
+	if h.config.Dashboard.Enabled {
+		h.initDashboard()
+	}
+
+	// Все маршруты API версионируются префиксом /api/v1, чтобы служебные
+	// маршруты в корне (например, /health) не пересекались с названиями городов
+	h.r.Route(apiPrefix, func(r chi.Router) {
+		// Спецификация OpenAPI, которой соответствуют маршруты ниже
+		r.Get("/openapi.json", h.getOpenAPI)
 
-	// Регистрируем обработчик для GET запросов по пути /{city}
-	// {city} - параметр маршрута, который будет извлекаться из URL
-	h.r.Get("/{city}", h.getCity)
+		// Запросы к остальным маршрутам требуют ключ API с нужной областью доступа
+		// и проверяются по спецификации до вызова обработчика
+		r.Group(func(r chi.Router) {
+			r.Use(h.requireScope(models.ScopeRead))
+			r.Use(h.validateRequest)
+
+			// Регистрируем обработчик для GET запросов по пути /{city}
+			// {city} - параметр маршрута, который будет извлекаться из URL
+			r.Get("/{city}", h.getCity)
+
+			// Последние показания нескольких городов одним запросом или ближайшего к координатам города
+			// Статический сегмент /weather имеет приоритет над /{city}
+			r.Get("/weather", h.getWeatherQuery)
+			r.Post("/weather", h.postWeatherBatch)
+
+			// Исторический ряд с агрегацией по интервалам и курсорной пагинацией
+			r.Get("/{city}/history", h.getHistory)
+
+			// Сводная статистика температуры за период
+			r.Get("/{city}/stats", h.getStats)
+
+			// Графики переменных за период в виде изображений для чатов и вики
+			r.Get("/{city}/chart.svg", h.getChartSVG)
+			r.Get("/{city}/chart.png", h.getChartPNG)
+
+			// Поток новых показаний в формате Server-Sent Events
+			r.Get("/{city}/stream", h.getStream)
+
+			// Подписка на показания и оповещения нескольких городов через WebSocket
+			// Статический сегмент /ws имеет приоритет над /{city}
+			r.Get("/ws", h.getWS)
+		})
+
+		// Прием показаний собственных метеостанций. Станция предъявляет свой ключ
+		// в X-Station-Key вместо ключа API, поэтому маршрут в отдельной группе
+		r.Group(func(r chi.Router) {
+			r.Use(h.authenticateStation)
+			r.Use(h.validateRequest)
+
+			r.Post("/stations/{id}/observations", h.postObservations)
+		})
+
+		// Управление ключами API, псевдонимами городов и метеостанциями
+		// Маршруты объявлены полными путями в группе, а не через r.Route: middleware
+		// вложенного маршрутизатора выполняется до сопоставления маршрута, и validateRequest
+		// не смог бы найти операцию по неполному шаблону
+		// Каждому маршруту нужно свое разрешение: JWT выдает их по ролям, ключ с областью admin - все
+		// При заданном CA клиентов административные маршруты дополнительно требуют клиентский сертификат
+		r.Group(func(r chi.Router) {
+			if h.config.TLS.ClientCAFile != "" {
+				r.Use(h.requireClientCert)
+			}
+			r.Use(h.authenticateAdmin)
+			r.Use(h.validateRequest)
+
+			r.With(h.requirePermission(models.PermissionKeysRead)).Get("/admin/keys", h.getKeys)
+			r.With(h.requirePermission(models.PermissionKeysWrite)).Post("/admin/keys", h.postKey)
+			r.With(h.requirePermission(models.PermissionKeysWrite)).Delete("/admin/keys/{id}", h.deleteKey)
+			r.With(h.requirePermission(models.PermissionKeysRead)).Get("/admin/keys/{id}/usage", h.getKeyUsage)
+
+			// Псевдонимы городов
+			r.With(h.requirePermission(models.PermissionLocationsRead)).Get("/admin/locations/{city}/aliases", h.getAliases)
+			r.With(h.requirePermission(models.PermissionLocationsWrite)).Post("/admin/locations/{city}/aliases", h.postAlias)
+			r.With(h.requirePermission(models.PermissionLocationsWrite)).Delete("/admin/locations/{city}/aliases/{alias}", h.deleteAlias)
+
+			// Метеостанции и их ключи
+			r.With(h.requirePermission(models.PermissionStationsRead)).Get("/admin/stations", h.getStations)
+			r.With(h.requirePermission(models.PermissionStationsWrite)).Post("/admin/stations", h.postStation)
+			r.With(h.requirePermission(models.PermissionStationsRead)).Get("/admin/stations/{id}/keys", h.getStationKeys)
+			r.With(h.requirePermission(models.PermissionStationsWrite)).Post("/admin/stations/{id}/keys", h.postStationKey)
+			r.With(h.requirePermission(models.PermissionStationsWrite)).Delete("/admin/stations/{id}/keys/{key_id}", h.deleteStationKey)
+		})
+	})
+
+	// Проверяем, что спецификация описывает все зарегистрированные маршруты
+	h.checkRoutes()
 }
 
 // getCity обрабатывает GET запрос для получения погоды по городу
@@ -61,22 +235,39 @@ func (h *Handlers) getCity(w http.ResponseWriter, r *http.Request) {
 	// Делегируем бизнес-логику сервисному слою
 	weather, err := h.weatherService.GetWeather(ctx, city)
 	if err != nil {
-		// В случае ошибки возвращаем статус 500 Internal Server Error
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Error fetching weather"))
+		// Город не найден ни в хранилище, ни через геокодинг - 404 Not Found
+		if errors.Is(err, models.ErrCityNotFound) {
+			writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+			return
+		}
+		// Название города пустое после нормализации - 400 Bad Request
+		if errors.Is(err, models.ErrInvalidQuery) {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		// В остальных случаях возвращаем статус 500 Internal Server Error
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error fetching weather")
 		return // Важно: прекращаем выполнение после ошибки
 	}
 
+	// Заголовки кэширования зависят только от времени показания, поэтому
+	// условный запрос можно обработать до сериализации тела
+	if h.writeCacheHeaders(w, r, weather) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	// Преобразуем доменную модель в формат для HTTP-ответа
 	// Метод ToResponse вероятно сериализует данные в JSON или другой формат
 	raw, err := weather.ToResponse()
 	if err != nil {
 		// Если преобразование не удалось, возвращаем ошибку сервера
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error encoding weather")
 		return
 	}
 
 	// Записываем сырые данные в тело ответа
 	// По умолчанию статус 200 OK
+	w.Header().Set("Content-Type", "application/json")
 	w.Write(raw)
 }
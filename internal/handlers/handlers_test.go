@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+func TestGetCityStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{name: "not found by geocoding", err: models.ErrCityNotFound, wantCode: http.StatusNotFound, wantBody: codeNotFound},
+		{name: "upstream failure", err: errors.New("upstream is down"), wantCode: http.StatusInternalServerError, wantBody: codeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newFakeWeatherService()
+			svc.err = tt.err
+
+			rec := serve(newTestRouter(t, svc, testConfig()), httptest.NewRequest(http.MethodGet, "/api/v1/atlantis", nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+
+			var body errorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error.Code != tt.wantBody {
+				t.Errorf("error code = %q, want %q", body.Error.Code, tt.wantBody)
+			}
+		})
+	}
+}
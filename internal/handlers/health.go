@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// getHealthz отвечает на проверку живости: процесс запущен и обслуживает HTTP
+// Зависимости не проверяются, чтобы недоступная база не приводила к перезапуску реплики
+func (h *Handlers) getHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, models.HealthCheck{Status: models.HealthOK})
+}
+
+// getReadyz отвечает на проверку готовности с разбивкой по проверкам
+// Если хотя бы одна проверка не пройдена, ответ 503, чтобы балансировщик не направлял трафик на реплику
+func (h *Handlers) getReadyz(w http.ResponseWriter, r *http.Request) {
+	readiness := h.healthChecker.Readiness(r.Context())
+
+	// Результат проверки должен быть актуальным, кэширование прокси его исказило бы
+	w.Header().Set("Cache-Control", "no-store")
+
+	status := http.StatusOK
+	if !readiness.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, readiness)
+}
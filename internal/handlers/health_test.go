@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// newHealthRouter создает маршрутизатор с включенной проверкой ключей и заданным результатом /readyz
+func newHealthRouter(t *testing.T, readiness models.Readiness) http.Handler {
+	t.Helper()
+
+	cfg := testConfig()
+	cfg.Auth.Enabled = true
+
+	r := chi.NewRouter()
+	New(r, newFakeWeatherService(), newFakeKeyService(), newFakeStationService(), nil, &fakeHealthChecker{readiness: readiness}, newFakeMetrics(), cfg).Init()
+	return r
+}
+
+func TestHealthz(t *testing.T) {
+	rec := serve(newHealthRouter(t, models.Readiness{Status: models.HealthFail}), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	// Живость не зависит от готовности и не требует ключа API
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200, body %s", rec.Code, rec.Body)
+	}
+	var body models.HealthCheck
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Status != models.HealthOK {
+		t.Errorf("body = %s, want status ok", rec.Body)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	ok := models.HealthCheck{Status: models.HealthOK}
+	stale := models.HealthCheck{Status: models.HealthFail, Error: "latest reading is older than 30m0s"}
+
+	tests := []struct {
+		name      string
+		readiness models.Readiness
+		want      int
+	}{
+		{
+			name: "ready",
+			readiness: models.Readiness{
+				Status:    models.HealthOK,
+				Database:  ok,
+				Scheduler: models.SchedulerCheck{HealthCheck: ok},
+				Locations: []models.LocationFreshness{{HealthCheck: ok, City: "moscow"}},
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "stale location",
+			readiness: models.Readiness{
+				Status:    models.HealthFail,
+				Database:  ok,
+				Scheduler: models.SchedulerCheck{HealthCheck: ok},
+				Locations: []models.LocationFreshness{{HealthCheck: stale, City: "moscow"}},
+			},
+			want: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(newHealthRouter(t, tt.readiness), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+			if rec.Header().Get("Cache-Control") != "no-store" {
+				t.Error("readiness must not be cached")
+			}
+
+			var body struct {
+				Status    string `json:"status"`
+				Database  struct{ Status string }
+				Locations []struct {
+					City   string
+					Status string
+					Error  string
+				}
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Status != tt.readiness.Status || body.Database.Status != models.HealthOK {
+				t.Errorf("unexpected body: %s", rec.Body)
+			}
+			if len(body.Locations) != 1 || body.Locations[0].City != "moscow" || body.Locations[0].Error != tt.readiness.Locations[0].Error {
+				t.Errorf("unexpected locations: %s", rec.Body)
+			}
+		})
+	}
+}
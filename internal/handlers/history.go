@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+)
+
+// Значения по умолчанию для параметров исторического запроса
+const (
+	defaultHistoryRange = 24 * time.Hour // Диапазон, если не указан from
+	defaultHistoryStep  = time.Hour      // Интервал агрегации, если не указан step
+	defaultHistoryLimit = 500            // Размер страницы, если не указан limit
+)
+
+// getHistory обрабатывает GET /api/v1/{city}/history?from=&to=&step=&agg=&limit=&cursor=
+// Время from и to передается в формате RFC 3339, step - в формате Go duration (15m, 1h)
+// Формат ответа выбирается по заголовку Accept: JSON (страница с курсором),
+// CSV или NDJSON (весь диапазон потоком, без limit)
+func (h *Handlers) getHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format, ok := negotiate(r)
+	if !ok {
+		writeError(w, http.StatusNotAcceptable, codeNotAcceptable, "supported formats: application/json, text/csv, application/x-ndjson")
+		return
+	}
+
+	query, err := parseHistoryQuery(chi.URLParam(r, "city"), r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	if format != mediaJSON {
+		h.exportHistory(w, r, format, query)
+		return
+	}
+
+	history, err := h.weatherService.GetHistory(ctx, query)
+	if err != nil {
+		// Ошибки валидации сервиса - это ошибки клиента
+		if errors.Is(err, models.ErrInvalidQuery) {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error fetching weather history")
+		return
+	}
+
+	raw, err := history.ToResponse()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error encoding weather history")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(raw)
+}
+
+// exportHistory выгружает исторический ряд потоком в формате CSV или NDJSON
+// Статус ответа отправляется вместе с первой строкой, поэтому ошибку валидации
+// еще можно вернуть как 400, а ошибка посреди выгрузки обрывает соединение
+func (h *Handlers) exportHistory(w http.ResponseWriter, r *http.Request, format string, query models.HistoryQuery) {
+	var out historyWriter
+
+	err := h.weatherService.StreamHistory(r.Context(), query, func(point models.HistoryPoint) error {
+		if out == nil {
+			out = newHistoryWriter(w, format, query.City)
+		}
+		return out.Write(point)
+	})
+
+	switch {
+	case err == nil:
+		// Пустой диапазон - отдаем только заголовок (для CSV) или пустое тело (для NDJSON)
+		if out == nil {
+			out = newHistoryWriter(w, format, query.City)
+		}
+		out.Close()
+	case out != nil:
+		// Часть данных уже записана, изменить статус нельзя. Отправляем записанное и
+		// обрываем соединение без завершающего чанка: иначе клиент принял бы
+		// усеченную выгрузку за полную
+		pkg.FromContext(r.Context()).Error("history export interrupted", "city", query.City, "error", err)
+		out.Close()
+		panic(http.ErrAbortHandler)
+	case errors.Is(err, models.ErrInvalidQuery):
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error fetching weather history")
+	}
+}
+
+// parseHistoryQuery разбирает параметры строки запроса и подставляет значения по умолчанию
+func parseHistoryQuery(city string, values url.Values) (models.HistoryQuery, error) {
+	query := models.HistoryQuery{
+		City:   city,
+		To:     time.Now().UTC(),
+		Step:   defaultHistoryStep,
+		Agg:    models.AggAvg,
+		Limit:  defaultHistoryLimit,
+		Cursor: values.Get("cursor"),
+	}
+
+	if raw := values.Get("to"); raw != "" {
+		to, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return models.HistoryQuery{}, fmt.Errorf("%w: to must be an RFC 3339 timestamp", models.ErrInvalidQuery)
+		}
+		query.To = to
+	}
+
+	query.From = query.To.Add(-defaultHistoryRange)
+	if raw := values.Get("from"); raw != "" {
+		from, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return models.HistoryQuery{}, fmt.Errorf("%w: from must be an RFC 3339 timestamp", models.ErrInvalidQuery)
+		}
+		query.From = from
+	}
+
+	if raw := values.Get("step"); raw != "" {
+		step, err := time.ParseDuration(raw)
+		if err != nil {
+			return models.HistoryQuery{}, fmt.Errorf("%w: step must be a duration like 15m or 1h", models.ErrInvalidQuery)
+		}
+		query.Step = step
+	}
+
+	if raw := values.Get("agg"); raw != "" {
+		if raw != models.AggAvg && raw != models.AggMin && raw != models.AggMax {
+			return models.HistoryQuery{}, fmt.Errorf("%w: agg must be one of avg, min, max", models.ErrInvalidQuery)
+		}
+		query.Agg = raw
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			return models.HistoryQuery{}, fmt.Errorf("%w: limit must be an integer", models.ErrInvalidQuery)
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
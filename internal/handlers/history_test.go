@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+func TestParseHistoryQuery(t *testing.T) {
+	to := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	from := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		values  url.Values
+		want    models.HistoryQuery
+		wantErr bool
+	}{
+		{
+			name:   "defaults relative to to",
+			values: url.Values{"to": {"2025-01-02T00:00:00Z"}},
+			want:   models.HistoryQuery{City: "moscow", From: to.Add(-defaultHistoryRange), To: to, Step: defaultHistoryStep, Agg: models.AggAvg, Limit: defaultHistoryLimit},
+		},
+		{
+			name: "all parameters",
+			values: url.Values{
+				"from": {"2025-01-01T12:00:00Z"}, "to": {"2025-01-02T00:00:00Z"},
+				"step": {"15m"}, "agg": {"max"}, "limit": {"10"}, "cursor": {"abc"},
+			},
+			want: models.HistoryQuery{City: "moscow", From: from, To: to, Step: 15 * time.Minute, Agg: models.AggMax, Limit: 10, Cursor: "abc"},
+		},
+		{name: "malformed from", values: url.Values{"from": {"yesterday"}}, wantErr: true},
+		{name: "malformed to", values: url.Values{"to": {"2025-01-02"}}, wantErr: true},
+		{name: "malformed step", values: url.Values{"step": {"hourly"}}, wantErr: true},
+		{name: "unknown agg", values: url.Values{"agg": {"sum"}}, wantErr: true},
+		{name: "malformed limit", values: url.Values{"limit": {"ten"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHistoryQuery("moscow", tt.values)
+			if tt.wantErr {
+				if !errors.Is(err, models.ErrInvalidQuery) {
+					t.Errorf("err = %v, want ErrInvalidQuery", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHistoryQueryDefaultTo(t *testing.T) {
+	before := time.Now()
+	got, err := parseHistoryQuery("moscow", url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.To.Before(before) || time.Since(got.To) > time.Minute {
+		t.Errorf("to = %v, want current time", got.To)
+	}
+	if got.To.Sub(got.From) != defaultHistoryRange {
+		t.Errorf("range = %v, want %v", got.To.Sub(got.From), defaultHistoryRange)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// defaultUsageDays - глубина истории использования ключа по умолчанию
+const defaultUsageDays = 30
+
+// keysResponse - ответ со списком ключей
+type keysResponse struct {
+	Keys []models.APIKey `json:"keys"`
+}
+
+// usageResponse - ответ с суточным использованием ключа
+type usageResponse struct {
+	KeyID int64             `json:"key_id"`
+	Usage []models.KeyUsage `json:"usage"`
+}
+
+// getKeys обрабатывает GET /api/v1/admin/keys - список ключей без секретов
+func (h *Handlers) getKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.keyService.ListKeys(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error fetching API keys")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, keysResponse{Keys: append([]models.APIKey{}, keys...)})
+}
+
+// postKey обрабатывает POST /api/v1/admin/keys - создание ключа
+// Секрет возвращается только в этом ответе
+func (h *Handlers) postKey(w http.ResponseWriter, r *http.Request) {
+	var params models.NewAPIKey
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "body must be a JSON object")
+		return
+	}
+
+	created, err := h.keyService.CreateKey(r.Context(), params)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidQuery) {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error creating API key")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// deleteKey обрабатывает DELETE /api/v1/admin/keys/{id} - отзыв ключа
+func (h *Handlers) deleteKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "id must be an integer")
+		return
+	}
+
+	if err := h.keyService.RevokeKey(r.Context(), id); err != nil {
+		if errors.Is(err, models.ErrKeyNotFound) {
+			writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error revoking API key")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getKeyUsage обрабатывает GET /api/v1/admin/keys/{id}/usage?days=30 - количество запросов по суткам
+func (h *Handlers) getKeyUsage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "id must be an integer")
+		return
+	}
+
+	days := defaultUsageDays
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		if days, err = strconv.Atoi(raw); err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "days must be an integer")
+			return
+		}
+	}
+
+	usage, err := h.keyService.GetKeyUsage(r.Context(), id, days)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidQuery):
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		case errors.Is(err, models.ErrKeyNotFound):
+			writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, codeInternal, "Error fetching API key usage")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, usageResponse{KeyID: id, Usage: append([]models.KeyUsage{}, usage...)})
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// newAdminRouter создает маршрутизатор, в котором секрет "admin" дает доступ к административным маршрутам
+func newAdminRouter(t *testing.T, keys *fakeKeyService) http.Handler {
+	t.Helper()
+
+	keys.keys["admin"] = models.APIKey{ID: 1, Name: "ops", Scopes: []string{models.ScopeAdmin}}
+	return newAuthRouter(t, newFakeWeatherService(), keys, testConfig())
+}
+
+func TestCreateKey(t *testing.T) {
+	keys := newFakeKeyService()
+	router := newAdminRouter(t, keys)
+
+	rec := serve(router, newKeyRequest(http.MethodPost, "/api/v1/admin/keys", "admin", `{"name": "dashboard", "scopes": ["read"], "per_minute": 60}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Error("response with a secret must not be cached")
+	}
+
+	want := models.NewAPIKey{Name: "dashboard", Scopes: []string{"read"}, PerMinute: 60}
+	if keys.created.Name != want.Name || !slices.Equal(keys.created.Scopes, want.Scopes) || keys.created.PerMinute != want.PerMinute {
+		t.Errorf("created %+v, want %+v", keys.created, want)
+	}
+
+	var body models.CreatedAPIKey
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Secret != "wsk_secret" || body.Key.ID != 7 {
+		t.Errorf("unexpected body: %s", rec.Body)
+	}
+}
+
+func TestCreateKeyValidation(t *testing.T) {
+	tests := map[string]string{
+		"no scopes":      `{"name": "dashboard", "scopes": []}`,
+		"unknown scope":  `{"name": "dashboard", "scopes": ["root"]}`,
+		"negative quota": `{"name": "dashboard", "scopes": ["read"], "per_day": -1}`,
+		"no name":        `{"scopes": ["read"]}`,
+	}
+
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := serve(newAdminRouter(t, newFakeKeyService()), newKeyRequest(http.MethodPost, "/api/v1/admin/keys", "admin", body))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400, body %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestListKeys(t *testing.T) {
+	rec := serve(newAdminRouter(t, newFakeKeyService()), newKeyRequest(http.MethodGet, "/api/v1/admin/keys", "admin", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	var body keysResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Keys) != 1 || body.Keys[0].Name != "ops" {
+		t.Errorf("unexpected keys: %s", rec.Body)
+	}
+}
+
+func TestRevokeKey(t *testing.T) {
+	keys := newFakeKeyService()
+	router := newAdminRouter(t, keys)
+
+	rec := serve(router, newKeyRequest(http.MethodDelete, "/api/v1/admin/keys/42", "admin", ""))
+	if rec.Code != http.StatusNoContent || keys.revoked != 42 {
+		t.Errorf("status = %d, revoked %d, want 204 and 42", rec.Code, keys.revoked)
+	}
+
+	keys.err = models.ErrKeyNotFound
+	if rec := serve(router, newKeyRequest(http.MethodDelete, "/api/v1/admin/keys/43", "admin", "")); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown key: status = %d, want 404", rec.Code)
+	}
+
+	keys.err = nil
+	if rec := serve(router, newKeyRequest(http.MethodDelete, "/api/v1/admin/keys/abc", "admin", "")); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad id: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestGetKeyUsage(t *testing.T) {
+	keys := newFakeKeyService()
+	keys.usage = []models.KeyUsage{{Requests: 5}}
+	router := newAdminRouter(t, keys)
+
+	rec := serve(router, newKeyRequest(http.MethodGet, "/api/v1/admin/keys/3/usage", "admin", ""))
+	if rec.Code != http.StatusOK || keys.days != defaultUsageDays {
+		t.Fatalf("status = %d, days = %d", rec.Code, keys.days)
+	}
+
+	var body usageResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.KeyID != 3 || len(body.Usage) != 1 || body.Usage[0].Requests != 5 {
+		t.Errorf("unexpected body: %s", rec.Body)
+	}
+
+	if rec := serve(router, newKeyRequest(http.MethodGet, "/api/v1/admin/keys/3/usage?days=0", "admin", "")); rec.Code != http.StatusBadRequest {
+		t.Errorf("days=0: status = %d, want 400", rec.Code)
+	}
+}
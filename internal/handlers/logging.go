@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader - заголовок с идентификатором запроса
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину идентификатора, принятого от клиента
+const maxRequestIDLength = 128
+
+// logRequests - middleware, которое присваивает запросу идентификатор и пишет access-лог
+// Идентификатор берется из X-Request-ID клиента или прокси, иначе генерируется,
+// и возвращается в ответе. Логгер с request_id и trace_id кладется в контекст запроса,
+// поэтому записи обработчиков, сервисов, хранилища и клиентов внешних API связаны
+// с записью access-лога
+// Служебные маршруты пишутся на уровне debug, чтобы частые проверки не засоряли лог,
+// ответы 5xx - на уровне error
+func (h *Handlers) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = rand.Text()
+		}
+		w.Header().Set(requestIDHeader, id)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("http.request.id", id))
+
+		log := pkg.WithTrace(r.Context(), slog.Default().With("request_id", id))
+		ctx := pkg.WithLogger(r.Context(), log)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case untracedPaths[r.URL.Path]:
+			level = slog.LevelDebug
+		}
+
+		log.LogAttrs(ctx, level, "http request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", routePattern(r)),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	})
+}
+
+// validRequestID сообщает, можно ли использовать идентификатор клиента как есть
+// Допускаются только печатные ASCII-символы без пробелов, чтобы идентификатор
+// не ломал записи текстового лога
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
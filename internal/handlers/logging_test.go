@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+)
+
+// loggingWeatherService пишет запись через логгер из контекста, как сервисный слой
+type loggingWeatherService struct {
+	*fakeWeatherService
+}
+
+func (l loggingWeatherService) GetWeather(ctx context.Context, city string) (models.Weather, error) {
+	pkg.FromContext(ctx).Info("service call", "city", city)
+	return l.fakeWeatherService.GetWeather(ctx, city)
+}
+
+// captureLogs направляет логгер по умолчанию в буфер на время теста
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+// logRecords разбирает записи JSON-лога
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogRequests(t *testing.T) {
+	buf := captureLogs(t)
+
+	svc := newFakeWeatherService()
+	svc.weather = models.Weather{Name: "moscow", ObservedAt: time.Now(), FetchedAt: time.Now()}
+	r := newTestRouter(t, loggingWeatherService{svc}, testConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/moscow", nil)
+	req.Header.Set(requestIDHeader, "req-42")
+	rec := serve(r, req)
+	if got := rec.Header().Get(requestIDHeader); got != "req-42" {
+		t.Errorf("X-Request-ID = %q, want the incoming id", got)
+	}
+
+	records := logRecords(t, buf)
+	if len(records) != 2 {
+		t.Fatalf("got %d log records, want service call and access log:\n%s", len(records), buf)
+	}
+
+	// Запись сервиса и access-лог связаны идентификатором запроса
+	call, access := records[0], records[1]
+	if call["msg"] != "service call" || call["request_id"] != "req-42" {
+		t.Errorf("service record = %v, want request_id req-42", call)
+	}
+	if access["msg"] != "http request" || access["request_id"] != "req-42" || access["level"] != "INFO" {
+		t.Errorf("access record = %v", access)
+	}
+	if access["route"] != "/api/v1/{city}" || access["path"] != "/api/v1/moscow" || access["status"] != float64(http.StatusOK) {
+		t.Errorf("access record = %v, want route, path and status 200", access)
+	}
+}
+
+func TestLogRequestsGeneratesID(t *testing.T) {
+	tests := map[string]string{
+		"missing":  "",
+		"too long": strings.Repeat("a", maxRequestIDLength+1),
+		"newline":  "id\nlevel=ERROR",
+	}
+	for name, incoming := range tests {
+		t.Run(name, func(t *testing.T) {
+			buf := captureLogs(t)
+			r := newTestRouter(t, newFakeWeatherService(), testConfig())
+
+			req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+			if incoming != "" {
+				req.Header.Set(requestIDHeader, incoming)
+			}
+			id := serve(r, req).Header().Get(requestIDHeader)
+			if id == "" || id == incoming {
+				t.Fatalf("X-Request-ID = %q, want a generated id", id)
+			}
+
+			// Проверки готовности пишутся на уровне debug
+			records := logRecords(t, buf)
+			if len(records) != 1 || records[0]["request_id"] != id || records[0]["level"] != "DEBUG" {
+				t.Errorf("records = %v, want one debug record with id %s", records, id)
+			}
+		})
+	}
+}
+
+func TestLogRequestsServerError(t *testing.T) {
+	buf := captureLogs(t)
+
+	svc := newFakeWeatherService()
+	svc.err = context.DeadlineExceeded
+	serve(newTestRouter(t, svc, testConfig()), httptest.NewRequest(http.MethodGet, "/api/v1/moscow", nil))
+
+	records := logRecords(t, buf)
+	if last := records[len(records)-1]; last["level"] != "ERROR" || last["status"] != float64(http.StatusInternalServerError) {
+		t.Errorf("access record = %v, want error level for 500", last)
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// unmatchedRoute - значение метки route для запросов, не совпавших ни с одним маршрутом
+// Путь таких запросов в метку не попадает, чтобы сканеры не раздували число рядов
+const unmatchedRoute = "unmatched"
+
+// observeRequests - middleware, учитывающее каждый запрос в метриках HTTP
+// Шаблон маршрута известен только после маршрутизации, поэтому читается после обработки
+// Обертка ответа сохраняет http.Flusher и http.Hijacker, нужные SSE и WebSocket
+func (h *Handlers) observeRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := routePattern(r)
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		// Обработчик, не записавший ни заголовков, ни тела, отвечает 200
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		h.metrics.ObserveRequest(route, r.Method, status, time.Since(start))
+	})
+}
+
+// routePattern возвращает шаблон маршрута chi, с которым совпал обработанный запрос,
+// или пустую строку, если запрос не совпал ни с одним маршрутом
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+func TestObserveRequests(t *testing.T) {
+	svc := newFakeWeatherService()
+	svc.weather = models.Weather{Name: "moscow", ObservedAt: time.Now(), FetchedAt: time.Now()}
+	metrics := newFakeMetrics()
+
+	r := chi.NewRouter()
+	New(r, svc, newFakeKeyService(), newFakeStationService(), nil, &fakeHealthChecker{}, metrics, testConfig()).Init()
+
+	for _, target := range []string{"/api/v1/moscow", "/api/v1/paris/stats?period=bogus", "/wp-login.php", "/metrics"} {
+		serve(r, httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	// Город не попадает в метку: учитывается шаблон маршрута, неизвестные пути - как unmatched
+	want := []observedRequest{
+		{route: "/api/v1/{city}", method: http.MethodGet, status: http.StatusOK},
+		{route: "/api/v1/{city}/stats", method: http.MethodGet, status: http.StatusBadRequest},
+		{route: unmatchedRoute, method: http.MethodGet, status: http.StatusNotFound},
+		{route: "/metrics", method: http.MethodGet, status: http.StatusOK},
+	}
+	if !slices.Equal(metrics.requests, want) {
+		t.Errorf("observed %+v, want %+v", metrics.requests, want)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth.Enabled = true
+
+	// /metrics не требует ключа API, как и проверки готовности
+	rec := serve(newTestRouter(t, newFakeWeatherService(), cfg), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "# metrics\n" {
+		t.Errorf("status = %d, body %q", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// defaultRadiusKm - радиус поиска ближайшего города по умолчанию
+const defaultRadiusKm = 50
+
+// Значения параметра fallback
+const (
+	fallbackNone = "none" // Без городов в радиусе ответ 404
+	fallbackLive = "live" // Без городов в радиусе погода запрашивается для самих координат
+)
+
+// getNearestWeather обрабатывает GET /api/v1/weather?lat=&lon=&radius_km=&fallback=none|live
+// Возвращает последнее показание ближайшего отслеживаемого города и расстояние до него
+func (h *Handlers) getNearestWeather(w http.ResponseWriter, r *http.Request) {
+	query, err := parseNearestQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	nearest, err := h.weatherService.GetNearestWeather(r.Context(), query)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidQuery):
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		case errors.Is(err, models.ErrNoLocationNearby), errors.Is(err, models.ErrCityNotFound):
+			writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, codeInternal, "Error fetching weather")
+		}
+		return
+	}
+
+	raw, err := nearest.ToResponse()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error encoding weather")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(raw)
+}
+
+// parseNearestQuery разбирает параметры поиска ближайшего города
+// lat и lon обязательны, radius_km по умолчанию равен defaultRadiusKm
+// Диапазоны значений проверяет сервис
+func parseNearestQuery(values url.Values) (models.NearestQuery, error) {
+	query := models.NearestQuery{RadiusKm: defaultRadiusKm}
+
+	for _, p := range []struct {
+		name     string
+		dst      *float64
+		required bool
+	}{
+		{name: "lat", dst: &query.Latitude, required: true},
+		{name: "lon", dst: &query.Longitude, required: true},
+		{name: "radius_km", dst: &query.RadiusKm},
+	} {
+		raw := values.Get(p.name)
+		if raw == "" {
+			if p.required {
+				return models.NearestQuery{}, fmt.Errorf("%w: %s is required", models.ErrInvalidQuery, p.name)
+			}
+			continue
+		}
+
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return models.NearestQuery{}, fmt.Errorf("%w: %s must be a number", models.ErrInvalidQuery, p.name)
+		}
+		*p.dst = v
+	}
+
+	switch values.Get("fallback") {
+	case "", fallbackNone:
+	case fallbackLive:
+		query.Live = true
+	default:
+		return models.NearestQuery{}, fmt.Errorf("%w: fallback must be none or live", models.ErrInvalidQuery)
+	}
+
+	return query, nil
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+func TestGetNearestWeather(t *testing.T) {
+	svc := newFakeWeatherService()
+	svc.nearest = models.NearestWeather{City: "moscow", DistanceKm: 1.5, Weather: models.Weather{Name: "moscow", Temperature: -2}}
+
+	rec := serve(newTestRouter(t, svc, testConfig()), httptest.NewRequest(http.MethodGet, "/api/v1/weather?lat=55.75&lon=37.62&radius_km=10&fallback=live", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	want := models.NearestQuery{Latitude: 55.75, Longitude: 37.62, RadiusKm: 10, Live: true}
+	if svc.nearestQuery != want {
+		t.Errorf("query = %+v, want %+v", svc.nearestQuery, want)
+	}
+
+	var body models.NearestWeather
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.City != "moscow" || body.DistanceKm != 1.5 || body.Weather.Temperature != -2 {
+		t.Errorf("unexpected body: %s", rec.Body)
+	}
+}
+
+func TestGetNearestWeatherDefaults(t *testing.T) {
+	svc := newFakeWeatherService()
+
+	serve(newTestRouter(t, svc, testConfig()), httptest.NewRequest(http.MethodGet, "/api/v1/weather?lat=1&lon=2", nil))
+
+	want := models.NearestQuery{Latitude: 1, Longitude: 2, RadiusKm: defaultRadiusKm}
+	if svc.nearestQuery != want {
+		t.Errorf("query = %+v, want %+v", svc.nearestQuery, want)
+	}
+}
+
+func TestGetNearestWeatherStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		err   error
+		want  int
+	}{
+		{name: "lon missing", query: "lat=1", want: http.StatusBadRequest},
+		{name: "lat out of range", query: "lat=91&lon=0", want: http.StatusBadRequest},
+		{name: "not a number", query: "lat=north&lon=0", want: http.StatusBadRequest},
+		{name: "unknown fallback", query: "lat=1&lon=2&fallback=cache", want: http.StatusBadRequest},
+		{name: "combined with cities", query: "lat=1&lon=2&cities=moscow", want: http.StatusBadRequest},
+		{name: "no parameters", query: "", want: http.StatusBadRequest},
+		{name: "nothing nearby", query: "lat=1&lon=2", err: models.ErrNoLocationNearby, want: http.StatusNotFound},
+		{name: "service rejects", query: "lat=1&lon=2", err: models.ErrInvalidQuery, want: http.StatusBadRequest},
+		{name: "storage failure", query: "lat=1&lon=2", err: errors.New("connection refused"), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newFakeWeatherService()
+			svc.err = tt.err
+
+			rec := serve(newTestRouter(t, svc, testConfig()), httptest.NewRequest(http.MethodGet, "/api/v1/weather?"+tt.query, nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
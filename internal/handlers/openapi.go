@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/go-chi/chi/v5"
+)
+
+// apiPrefix - префикс всех маршрутов API, совпадает с servers[0].url в openapi.json
+const apiPrefix = "/api/v1"
+
+// openAPISpec - спецификация OpenAPI 3, встроенная в бинарник
+// Отдается клиентам как есть и используется для валидации запросов
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// loadSpec разбирает и проверяет встроенную спецификацию
+// Ошибка в спецификации - ошибка сборки, поэтому функция паникует
+func loadSpec() *openapi3.T {
+	spec, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	if err != nil {
+		panic(fmt.Errorf("load openapi spec: %w", err))
+	}
+
+	if err := spec.Validate(openapi3.NewLoader().Context); err != nil {
+		panic(fmt.Errorf("validate openapi spec: %w", err))
+	}
+
+	return spec
+}
+
+// getOpenAPI отдает спецификацию OpenAPI в формате JSON
+func (h *Handlers) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// validateRequest - middleware, проверяющее запрос по спецификации OpenAPI
+// Подключается внутри группы маршрутов, поэтому к моменту вызова chi уже
+// сопоставил маршрут и его шаблон можно использовать для поиска операции в спецификации
+func (h *Handlers) validateRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, ok := h.findRoute(r)
+		if !ok {
+			writeError(w, http.StatusInternalServerError, codeInternal, "route is not described in the API specification")
+			return
+		}
+
+		options := &openapi3filter.Options{
+			// Аутентификация проверяется отдельными middleware
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		}
+		options.WithCustomSchemaErrorFunc(schemaErrorMessage)
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		}
+
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// schemaErrorMessage оставляет в ошибке валидации только причину
+// По умолчанию сообщение содержит дамп схемы и значения, который не нужен клиенту
+// Настройка действует только на проверки этого middleware, а не на весь процесс
+func schemaErrorMessage(err *openapi3.SchemaError) string {
+	return err.Reason
+}
+
+// findRoute находит операцию спецификации по шаблону маршрута chi
+// Шаблоны chi и пути OpenAPI используют одинаковый синтаксис параметров ({city})
+func (h *Handlers) findRoute(r *http.Request) (*routers.Route, map[string]string, bool) {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return nil, nil, false
+	}
+
+	path := strings.TrimPrefix(rctx.RoutePattern(), apiPrefix)
+
+	pathItem := h.spec.Paths.Find(path)
+	if pathItem == nil {
+		return nil, nil, false
+	}
+
+	operation := pathItem.GetOperation(r.Method)
+	if operation == nil {
+		return nil, nil, false
+	}
+
+	pathParams := make(map[string]string, len(rctx.URLParams.Keys))
+	for i, key := range rctx.URLParams.Keys {
+		pathParams[key] = rctx.URLParams.Values[i]
+	}
+
+	route := &routers.Route{
+		Spec:      h.spec,
+		Path:      path,
+		PathItem:  pathItem,
+		Method:    r.Method,
+		Operation: operation,
+	}
+
+	return route, pathParams, true
+}
+
+// checkRoutes проверяет, что каждый маршрут API описан в спецификации
+// Вызывается при инициализации, чтобы спецификация не расходилась с обработчиками
+func (h *Handlers) checkRoutes() {
+	err := chi.Walk(h.r, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if !strings.HasPrefix(route, apiPrefix+"/") {
+			return nil
+		}
+
+		pathItem := h.spec.Paths.Find(strings.TrimPrefix(route, apiPrefix))
+		if pathItem == nil || pathItem.GetOperation(method) == nil {
+			return fmt.Errorf("route %s %s is not described in openapi.json", method, route)
+		}
+
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
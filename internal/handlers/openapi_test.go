@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestValidateRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   string // Фрагмент сообщения об ошибке
+	}{
+		{name: "unknown agg", target: "/api/v1/moscow/history?agg=sum", want: "agg"},
+		{name: "limit above maximum", target: "/api/v1/moscow/history?limit=100000", want: "limit"},
+		{name: "limit not a number", target: "/api/v1/moscow/history?limit=ten", want: "limit"},
+		{name: "malformed step", target: "/api/v1/moscow/history?step=hourly", want: "step"},
+		{name: "unknown period", target: "/api/v1/moscow/stats?period=year", want: "period"},
+		{name: "unknown stats variable", target: "/api/v1/moscow/stats?variable=pressure", want: "variable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newFakeWeatherService()
+			rec := serve(newTestRouter(t, svc, testConfig()), httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+
+			var body errorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error.Code != codeBadRequest || !strings.Contains(body.Error.Message, tt.want) {
+				t.Errorf("error = %+v, want code %q mentioning %q", body.Error, codeBadRequest, tt.want)
+			}
+			// Сообщение не должно содержать дамп схемы
+			if strings.Contains(body.Error.Message, "Schema:") {
+				t.Errorf("error message contains schema details: %q", body.Error.Message)
+			}
+		})
+	}
+}
+
+func TestGetOpenAPI(t *testing.T) {
+	rec := serve(newTestRouter(t, newFakeWeatherService(), testConfig()), httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), openAPISpec) {
+		t.Error("served document differs from the embedded spec")
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil || doc.OpenAPI == "" {
+		t.Errorf("served document is not an OpenAPI spec: %v", err)
+	}
+}
+
+func TestCheckRoutesPanicsOnUndocumentedRoute(t *testing.T) {
+	r := chi.NewRouter()
+	h := New(r, newFakeWeatherService(), newFakeKeyService(), newFakeStationService(), nil, &fakeHealthChecker{}, newFakeMetrics(), testConfig())
+	r.Get(apiPrefix+"/undocumented", func(http.ResponseWriter, *http.Request) {})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("checkRoutes did not panic on a route missing from the spec")
+		}
+	}()
+	h.checkRoutes()
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// Заголовки запросов станций
+const (
+	headerStationKey     = "X-Station-Key"       // Секрет ключа станции
+	headerIdempotencyKey = "Idempotency-Key"     // Ключ идемпотентности запроса с показаниями
+	headerReplayed       = "Idempotent-Replayed" // Ответ на повтор запроса с тем же ключом идемпотентности
+)
+
+// stationKey - ключ контекста запроса, под которым хранится станция, прошедшая проверку ключа
+type stationKey struct{}
+
+// stationsResponse - ответ со списком станций
+type stationsResponse struct {
+	Stations []models.Station `json:"stations"`
+}
+
+// stationKeysResponse - ответ со списком ключей станции
+type stationKeysResponse struct {
+	Station string              `json:"station"`
+	Keys    []models.StationKey `json:"keys"`
+}
+
+// observationsRequest - тело запроса с показаниями: одно показание или {"observations": [...]}
+// Поле Observations отличает пакет от одного показания, формы описаны в спецификации через oneOf
+type observationsRequest struct {
+	Observations []models.Observation `json:"observations"`
+	models.Observation
+}
+
+// authenticateStation - middleware приема показаний: пропускает запрос, только если
+// ключ из заголовка X-Station-Key выдан станции из пути, и сохраняет станцию в контексте
+// Ключ станции нужен всегда, независимо от auth.enabled: без него показания мог бы
+// прислать кто угодно
+func (h *Handlers) authenticateStation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		station, err := h.stationService.Authenticate(r.Context(), chi.URLParam(r, "id"), r.Header.Get(headerStationKey))
+		switch {
+		case err == nil:
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), stationKey{}, station)))
+		case errors.Is(err, models.ErrInvalidStationKey):
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, err.Error())
+		case errors.Is(err, models.ErrWrongStation):
+			writeError(w, http.StatusForbidden, codeForbidden, err.Error())
+		case errors.Is(err, models.ErrStationNotFound):
+			writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, codeInternal, "Error checking station key")
+		}
+	})
+}
+
+// postObservations обрабатывает POST /api/v1/stations/{id}/observations - прием показаний станции
+// Показания проверяются целиком: одно некорректное показание отклоняет весь запрос
+// Повтор запроса с тем же Idempotency-Key возвращает сохраненный результат с заголовком Idempotent-Replayed
+func (h *Handlers) postObservations(w http.ResponseWriter, r *http.Request) {
+	station, _ := r.Context().Value(stationKey{}).(models.Station)
+
+	var req observationsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "body must be an observation or a JSON object with an observations array")
+		return
+	}
+	observations := req.Observations
+	if observations == nil {
+		observations = []models.Observation{req.Observation}
+	}
+
+	result, replayed, err := h.stationService.Submit(r.Context(), station, r.Header.Get(headerIdempotencyKey), observations)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidQuery):
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		case errors.Is(err, models.ErrIdempotencyInProgress):
+			writeError(w, http.StatusConflict, codeConflict, err.Error())
+		case errors.Is(err, models.ErrIdempotencyMismatch):
+			writeError(w, http.StatusUnprocessableEntity, codeUnprocessable, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, codeInternal, "Error saving observations")
+		}
+		return
+	}
+
+	if replayed {
+		w.Header().Set(headerReplayed, "true")
+	}
+	writeJSON(w, http.StatusCreated, result)
+}
+
+// getStations обрабатывает GET /api/v1/admin/stations - список станций
+func (h *Handlers) getStations(w http.ResponseWriter, r *http.Request) {
+	stations, err := h.stationService.ListStations(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error fetching stations")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stationsResponse{Stations: append([]models.Station{}, stations...)})
+}
+
+// postStation обрабатывает POST /api/v1/admin/stations - регистрация станции
+// Занятый идентификатор - 409 Conflict
+func (h *Handlers) postStation(w http.ResponseWriter, r *http.Request) {
+	var params models.NewStation
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "body must be a JSON object")
+		return
+	}
+
+	station, err := h.stationService.RegisterStation(r.Context(), params)
+	if err != nil {
+		writeStationError(w, err, "Error registering station")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, station)
+}
+
+// getStationKeys обрабатывает GET /api/v1/admin/stations/{id}/keys - ключи станции без секретов
+func (h *Handlers) getStationKeys(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	keys, err := h.stationService.ListStationKeys(r.Context(), id)
+	if err != nil {
+		writeStationError(w, err, "Error fetching station keys")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stationKeysResponse{Station: id, Keys: append([]models.StationKey{}, keys...)})
+}
+
+// postStationKey обрабатывает POST /api/v1/admin/stations/{id}/keys - выдача ключа станции
+// Секрет возвращается только в этом ответе
+func (h *Handlers) postStationKey(w http.ResponseWriter, r *http.Request) {
+	created, err := h.stationService.CreateStationKey(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeStationError(w, err, "Error creating station key")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// deleteStationKey обрабатывает DELETE /api/v1/admin/stations/{id}/keys/{key_id} - отзыв ключа станции
+func (h *Handlers) deleteStationKey(w http.ResponseWriter, r *http.Request) {
+	keyID, err := strconv.ParseInt(chi.URLParam(r, "key_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "key_id must be an integer")
+		return
+	}
+
+	if err := h.stationService.RevokeStationKey(r.Context(), chi.URLParam(r, "id"), keyID); err != nil {
+		writeStationError(w, err, "Error revoking station key")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeStationError записывает ответ с ошибкой операции над станциями
+// message используется для внутренних ошибок, чтобы не раскрывать их детали
+func writeStationError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, models.ErrInvalidQuery):
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+	case errors.Is(err, models.ErrStationNotFound), errors.Is(err, models.ErrKeyNotFound):
+		writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+	case errors.Is(err, models.ErrStationExists):
+		writeError(w, http.StatusConflict, codeConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, codeInternal, message)
+	}
+}
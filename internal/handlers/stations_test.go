@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// newStationRouter создает маршрутизатор с административным ключом "admin" поверх сервиса станций stations
+// Станции roof-1 и roof-2 принимают показания с секретами "roof-1-secret" и "roof-2-secret"
+func newStationRouter(t *testing.T, stations *fakeStationService) http.Handler {
+	t.Helper()
+
+	for _, id := range []string{"roof-1", "roof-2"} {
+		stations.stations[id] = models.Station{ID: id, Name: "Roof", City: "moscow"}
+		stations.secrets[id+"-secret"] = id
+	}
+
+	keys := newFakeKeyService()
+	keys.keys["admin"] = models.APIKey{ID: 1, Name: "ops", Scopes: []string{models.ScopeAdmin}}
+
+	r := chi.NewRouter()
+	New(r, newFakeWeatherService(), keys, stations, nil, &fakeHealthChecker{}, newFakeMetrics(), testConfig()).Init()
+	return r
+}
+
+// newObservationRequest создает запрос с показаниями станции id и ключом станции secret
+func newObservationRequest(id, secret, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/stations/"+id+"/observations", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(headerStationKey, secret)
+	}
+	return req
+}
+
+func TestPostObservations(t *testing.T) {
+	stations := newFakeStationService()
+	stations.result = models.SubmissionResult{Station: "roof-1", City: "moscow", Accepted: 2, Readings: []int64{5, 6}}
+	body := `{"observations": [
+		{"timestamp": "2025-01-02T03:04:05Z", "temperature": 14, "temperature_unit": "F"},
+		{"timestamp": "2025-01-02T03:05:05Z", "temperature": -10, "wind_speed": 3, "wind_speed_unit": "m/s"}
+	]}`
+
+	req := newObservationRequest("roof-1", "roof-1-secret", body)
+	req.Header.Set(headerIdempotencyKey, "req-1")
+	rec := serve(newStationRouter(t, stations), req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get(headerReplayed) != "" {
+		t.Errorf("%s set on the first request", headerReplayed)
+	}
+	if len(stations.observations) != 2 || stations.observations[0].TemperatureUnit != models.UnitFahrenheit || stations.idempotencyKey != "req-1" {
+		t.Errorf("submitted %+v with key %q", stations.observations, stations.idempotencyKey)
+	}
+
+	var result models.SubmissionResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Accepted != 2 || len(result.Readings) != 2 {
+		t.Errorf("unexpected body: %s", rec.Body)
+	}
+}
+
+func TestPostSingleObservation(t *testing.T) {
+	stations := newFakeStationService()
+	stations.replayed = true
+
+	rec := serve(newStationRouter(t, stations), newObservationRequest("roof-1", "roof-1-secret", `{"timestamp": "2025-01-02T03:04:05Z", "temperature": 1.5, "humidity": 40}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get(headerReplayed) != "true" {
+		t.Errorf("%s = %q, want true for a replayed result", headerReplayed, rec.Header().Get(headerReplayed))
+	}
+	if len(stations.observations) != 1 || *stations.observations[0].Temperature != 1.5 || *stations.observations[0].Humidity != 40 {
+		t.Errorf("submitted %+v", stations.observations)
+	}
+}
+
+func TestPostObservationsErrors(t *testing.T) {
+	valid := `{"timestamp": "2025-01-02T03:04:05Z", "temperature": 1}`
+
+	tests := []struct {
+		name   string
+		id     string
+		secret string
+		body   string
+		err    error
+		status int
+	}{
+		{name: "no key", id: "roof-1", body: valid, status: http.StatusUnauthorized},
+		{name: "unknown key", id: "roof-1", secret: "wss_unknown", body: valid, status: http.StatusUnauthorized},
+		{name: "key of another station", id: "roof-2", secret: "roof-1-secret", body: valid, status: http.StatusForbidden},
+		{name: "no temperature", id: "roof-1", secret: "roof-1-secret", body: `{"timestamp": "2025-01-02T03:04:05Z"}`, status: http.StatusBadRequest},
+		{name: "unknown unit", id: "roof-1", secret: "roof-1-secret", body: `{"timestamp": "2025-01-02T03:04:05Z", "temperature": 1, "temperature_unit": "R"}`, status: http.StatusBadRequest},
+		{name: "empty batch", id: "roof-1", secret: "roof-1-secret", body: `{"observations": []}`, status: http.StatusBadRequest},
+		{name: "rejected by service", id: "roof-1", secret: "roof-1-secret", body: valid, err: models.ErrInvalidQuery, status: http.StatusBadRequest},
+		{name: "in progress", id: "roof-1", secret: "roof-1-secret", body: valid, err: models.ErrIdempotencyInProgress, status: http.StatusConflict},
+		{name: "idempotency mismatch", id: "roof-1", secret: "roof-1-secret", body: valid, err: models.ErrIdempotencyMismatch, status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stations := newFakeStationService()
+			stations.err = tt.err
+
+			rec := serve(newStationRouter(t, stations), newObservationRequest(tt.id, tt.secret, tt.body))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d, body %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+// Ключ API не заменяет ключ станции, даже с областью admin
+func TestPostObservationsRequiresStationKey(t *testing.T) {
+	req := newObservationRequest("roof-1", "", `{"timestamp": "2025-01-02T03:04:05Z", "temperature": 1}`)
+	req.Header.Set(headerAPIKey, "admin")
+
+	rec := serve(newStationRouter(t, newFakeStationService()), req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", rec.Code)
+	}
+}
+
+func TestRegisterStationHandler(t *testing.T) {
+	stations := newFakeStationService()
+
+	rec := serve(newStationRouter(t, stations), newKeyRequest(http.MethodPost, "/api/v1/admin/stations", "admin", `{"id": "roof-3", "name": "Roof", "city": "Москва"}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if stations.registered.ID != "roof-3" || stations.registered.City != "Москва" {
+		t.Errorf("registered %+v", stations.registered)
+	}
+
+	// Идентификатор проверяется по шаблону спецификации до вызова сервиса
+	rec = serve(newStationRouter(t, stations), newKeyRequest(http.MethodPost, "/api/v1/admin/stations", "admin", `{"id": "Roof 3", "name": "Roof", "city": "moscow"}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid id: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestStationAdminErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		err    error
+		status int
+	}{
+		{name: "taken id", method: http.MethodPost, path: "/api/v1/admin/stations", body: `{"id": "roof-1", "name": "Roof", "city": "moscow"}`, err: models.ErrStationExists, status: http.StatusConflict},
+		{name: "unknown station keys", method: http.MethodGet, path: "/api/v1/admin/stations/omsk-1/keys", err: models.ErrStationNotFound, status: http.StatusNotFound},
+		{name: "key for unknown station", method: http.MethodPost, path: "/api/v1/admin/stations/omsk-1/keys", err: models.ErrStationNotFound, status: http.StatusNotFound},
+		{name: "unknown key", method: http.MethodDelete, path: "/api/v1/admin/stations/roof-1/keys/9", err: models.ErrKeyNotFound, status: http.StatusNotFound},
+		{name: "key id", method: http.MethodDelete, path: "/api/v1/admin/stations/roof-1/keys/abc", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stations := newFakeStationService()
+			stations.err = tt.err
+
+			rec := serve(newStationRouter(t, stations), newKeyRequest(tt.method, tt.path, "admin", tt.body))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d, body %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestStationKeys(t *testing.T) {
+	stations := newFakeStationService()
+	router := newStationRouter(t, stations)
+
+	rec := serve(router, newKeyRequest(http.MethodPost, "/api/v1/admin/stations/roof-1/keys", "admin", ""))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q, the secret must not be cached", rec.Header().Get("Cache-Control"))
+	}
+
+	var created models.CreatedStationKey
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Secret == "" || created.Key.StationID != "roof-1" {
+		t.Errorf("unexpected body: %s", rec.Body)
+	}
+
+	rec = serve(router, newKeyRequest(http.MethodGet, "/api/v1/admin/stations/roof-1/keys", "admin", ""))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), `"secret"`) {
+		t.Errorf("list: status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(router, newKeyRequest(http.MethodDelete, "/api/v1/admin/stations/roof-1/keys/3", "admin", ""))
+	if rec.Code != http.StatusNoContent || stations.revoked != 3 {
+		t.Errorf("revoke: status = %d, revoked %d", rec.Code, stations.revoked)
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+)
+
+// getStats обрабатывает GET /api/v1/{city}/stats?period=day|week|month|custom&from=&to=&variable=
+// variable - переменная показания, по умолчанию temperature. Для period=custom параметр from обязателен, для остальных периодов недопустим,
+// to по умолчанию равен текущему времени
+// Формат ответа выбирается по заголовку Accept: JSON, CSV или NDJSON
+func (h *Handlers) getStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format, ok := negotiate(r)
+	if !ok {
+		writeError(w, http.StatusNotAcceptable, codeNotAcceptable, "supported formats: application/json, text/csv, application/x-ndjson")
+		return
+	}
+
+	query, err := parseStatsQuery(chi.URLParam(r, "city"), r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	stats, err := h.weatherService.GetStats(ctx, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidQuery):
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		case errors.Is(err, models.ErrNoData):
+			writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, codeInternal, "Error fetching weather stats")
+		}
+		return
+	}
+
+	if format != mediaJSON {
+		w.Header().Set("Content-Type", format)
+		if err := writeStatsExport(w, format, stats); err != nil {
+			pkg.FromContext(r.Context()).Error("stats export failed", "city", query.City, "error", err)
+		}
+		return
+	}
+
+	raw, err := stats.ToResponse()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error encoding weather stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(raw)
+}
+
+// parseStatsQuery разбирает параметры строки запроса статистики
+// Период по умолчанию - day, переменная - temperature
+// Значения периода и переменной проверяет сервис
+func parseStatsQuery(city string, values url.Values) (models.StatsQuery, error) {
+	query := models.StatsQuery{
+		City:     city,
+		Variable: models.VariableTemperature,
+		Period:   models.PeriodDay,
+		To:       time.Now().UTC(),
+	}
+
+	if raw := values.Get("period"); raw != "" {
+		query.Period = raw
+	}
+	if raw := values.Get("variable"); raw != "" {
+		query.Variable = raw
+	}
+
+	if raw := values.Get("to"); raw != "" {
+		to, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return models.StatsQuery{}, fmt.Errorf("%w: to must be an RFC 3339 timestamp", models.ErrInvalidQuery)
+		}
+		query.To = to
+	}
+
+	if raw := values.Get("from"); raw != "" {
+		from, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return models.StatsQuery{}, fmt.Errorf("%w: from must be an RFC 3339 timestamp", models.ErrInvalidQuery)
+		}
+		query.From = from
+	}
+
+	return query, nil
+}
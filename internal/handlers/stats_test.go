@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+func TestGetStatsStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		err      error
+		wantCode int
+		wantBody string // Код ошибки в теле ответа
+	}{
+		{name: "ok", target: "/api/v1/moscow/stats?period=week", wantCode: http.StatusOK},
+		{name: "no data", target: "/api/v1/moscow/stats", err: models.ErrNoData, wantCode: http.StatusNotFound, wantBody: codeNotFound},
+		{name: "rejected by service", target: "/api/v1/moscow/stats?period=day&from=2025-01-01T00:00:00Z", err: models.ErrInvalidQuery, wantCode: http.StatusBadRequest, wantBody: codeBadRequest},
+		{name: "malformed to", target: "/api/v1/moscow/stats?to=tomorrow", wantCode: http.StatusBadRequest, wantBody: codeBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newFakeWeatherService()
+			svc.err = tt.err
+			r := newTestRouter(t, svc, testConfig())
+
+			rec := serve(r, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantBody == "" {
+				return
+			}
+
+			var body errorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error.Code != tt.wantBody {
+				t.Errorf("error code = %q, want %q", body.Error.Code, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestGetStatsVariable(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{target: "/api/v1/moscow/stats", want: models.VariableTemperature},
+		{target: "/api/v1/moscow/stats?variable=humidex", want: models.VariableHumidex},
+	}
+
+	for _, tt := range tests {
+		svc := newFakeWeatherService()
+		rec := serve(newTestRouter(t, svc, testConfig()), httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200: %s", tt.target, rec.Code, rec.Body)
+		}
+		if svc.statsQuery.Variable != tt.want {
+			t.Errorf("%s: variable = %q, want %q", tt.target, svc.statsQuery.Variable, tt.want)
+		}
+	}
+}
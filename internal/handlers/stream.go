@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+)
+
+// getStream обрабатывает GET /api/v1/{city}/stream - поток новых показаний в формате SSE
+// Каждое событие имеет тип reading, id показания и JSON в формате ответа GET /{city}
+// Клиент, переподключившийся с заголовком Last-Event-ID, сначала получает пропущенные показания
+// Если клиент не успевает читать события, поток закрывается, и клиент переподключается
+func (h *Handlers) getStream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	city := chi.URLParam(r, "city")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, codeInternal, "streaming is not supported")
+		return
+	}
+
+	var lastID int64
+	if raw := r.Header.Get("Last-Event-ID"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id < 0 {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Last-Event-ID must be a reading id")
+			return
+		}
+		lastID = id
+	}
+
+	sub, backlog, err := h.weatherService.WatchWeather(ctx, city, lastID, h.config.Stream.ResumeLimit)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidQuery) {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error subscribing to weather")
+		return
+	}
+	defer sub.Unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Отключает буферизацию ответа в nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(dto models.WeatherDTO) error {
+		// Показание могло прийти и из backlog, и из подписки
+		if dto.ID <= lastID {
+			return nil
+		}
+		if err := writeReadingEvent(w, dto); err != nil {
+			return err
+		}
+		lastID = dto.ID
+		flusher.Flush()
+		return nil
+	}
+
+	for _, dto := range backlog {
+		if err := send(dto); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.config.Stream.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// Брокер закрыл подписку из-за переполнения буфера
+				pkg.FromContext(ctx).Warn("closing slow event stream", "city", city)
+				return
+			}
+			if err := send(*event.Reading); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			// Строка-комментарий не является событием и игнорируется клиентом
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeReadingEvent записывает показание как событие SSE
+func writeReadingEvent(w http.ResponseWriter, dto models.WeatherDTO) error {
+	var weather models.Weather
+	dto.ToWeather(&weather)
+	weather.AgeSeconds = int64(time.Since(dto.Timestamp) / time.Second)
+
+	raw, err := json.Marshal(weather)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: reading\ndata: %s\n\n", dto.ID, raw)
+	return err
+}
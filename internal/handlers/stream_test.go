@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// readSSEIDs читает из потока SSE id событий reading, пока не наберет n
+func readSSEIDs(t *testing.T, scanner *bufio.Scanner, n int) []string {
+	t.Helper()
+
+	var ids []string
+	for len(ids) < n && scanner.Scan() {
+		if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < n {
+		t.Fatalf("stream ended after %d events, want %d: %v", len(ids), n, scanner.Err())
+	}
+	return ids
+}
+
+func TestGetStreamMergesBacklogAndLive(t *testing.T) {
+	svc := newFakeWeatherService()
+	svc.backlog = []models.WeatherDTO{
+		{ID: 6, Name: "moscow", Timestamp: time.Now()},
+		{ID: 7, Name: "moscow", Timestamp: time.Now()},
+	}
+	srv := httptest.NewServer(newTestRouter(t, svc, testConfig()))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/moscow/stream", nil)
+	req.Header.Set("Last-Event-ID", "5")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	if ids := readSSEIDs(t, scanner, 2); ids[0] != "6" || ids[1] != "7" {
+		t.Fatalf("backlog ids = %v, want [6 7]", ids)
+	}
+
+	// Показание 7 уже пришло из backlog, а 4 старше Last-Event-ID - оба пропускаются
+	svc.broker.PublishReading(models.WeatherDTO{ID: 7, Name: "moscow"})
+	svc.broker.PublishReading(models.WeatherDTO{ID: 4, Name: "moscow"})
+	svc.broker.PublishReading(models.WeatherDTO{ID: 8, Name: "moscow"})
+	svc.broker.PublishReading(models.WeatherDTO{ID: 9, Name: "kazan"})
+	svc.broker.PublishReading(models.WeatherDTO{ID: 10, Name: "moscow"})
+
+	if ids := readSSEIDs(t, scanner, 2); ids[0] != "8" || ids[1] != "10" {
+		t.Errorf("live ids = %v, want [8 10]", ids)
+	}
+}
+
+func TestGetStreamInvalidLastEventID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/moscow/stream", nil)
+	req.Header.Set("Last-Event-ID", "latest")
+
+	rec := serve(newTestRouter(t, newFakeWeatherService(), testConfig()), req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
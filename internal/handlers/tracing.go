@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// untracedPaths - служебные маршруты, которые опрашиваются часто и не записываются спанами
+var untracedPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// traceRequests - middleware, записывающее каждый запрос серверным спаном
+// Контекст трассировки читается из заголовка traceparent, поэтому спаны сервиса,
+// запросов к базе и внешним API продолжают трассу вызывающего клиента
+// Шаблон маршрута известен только после маршрутизации, поэтому имя спана
+// "<method> <route>" и атрибут http.route задаются после обработки запроса
+// Запрос без совпавшего маршрута называется только по методу
+func (h *Handlers) traceRequests(next http.Handler) http.Handler {
+	routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if route := routePattern(r); route != "" {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(spanName(r))
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+	})
+
+	// otelhttp тоже переименовывает спан после обработки, если chi заполнил r.Pattern,
+	// поэтому форматтер возвращает то же имя
+	return otelhttp.NewHandler(routed, "",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return spanName(r)
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !untracedPaths[r.URL.Path]
+		}),
+	)
+}
+
+// spanName возвращает имя серверного спана по методу и шаблону маршрута запроса
+func spanName(r *http.Request) string {
+	if route := routePattern(r); route != "" {
+		return r.Method + " " + route
+	}
+	return r.Method
+}
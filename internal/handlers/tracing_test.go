@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceRequests(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	svc := newFakeWeatherService()
+	svc.weather = models.Weather{Name: "moscow", ObservedAt: time.Now(), FetchedAt: time.Now()}
+	r := newTestRouter(t, svc, testConfig())
+
+	const (
+		traceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentSpanID = "00f067aa0ba902b7"
+	)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/moscow", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentSpanID+"-01")
+	if rec := serve(r, req); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	serve(r, httptest.NewRequest(http.MethodGet, "/wp-login.php", nil))
+	serve(r, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	serve(r, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2 (probes and /metrics are not traced)", len(spans))
+	}
+
+	// Спан продолжает трассу клиента и назван по шаблону маршрута, а не по городу
+	city := spans[0]
+	if city.Name() != "GET /api/v1/{city}" || city.SpanKind() != trace.SpanKindServer {
+		t.Errorf("span %q of kind %v, want server span GET /api/v1/{city}", city.Name(), city.SpanKind())
+	}
+	if city.SpanContext().TraceID().String() != traceID || city.Parent().SpanID().String() != parentSpanID || !city.Parent().IsRemote() {
+		t.Errorf("span is not a child of the incoming traceparent: parent %v", city.Parent())
+	}
+	var route string
+	for _, kv := range city.Attributes() {
+		if kv.Key == "http.route" {
+			route = kv.Value.AsString()
+		}
+	}
+	if route != "/api/v1/{city}" {
+		t.Errorf("http.route = %q", route)
+	}
+
+	// Без совпавшего маршрута путь в имя спана не попадает
+	if unmatched := spans[1]; unmatched.Name() != http.MethodGet || unmatched.Parent().IsValid() {
+		t.Errorf("unmatched span %q with parent %v, want root span GET", unmatched.Name(), unmatched.Parent())
+	}
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/olezhek28/wether-service/internal/events"
+)
+
+// Типы сообщений протокола WebSocket
+// subscribe и unsubscribe отправляет клиент, сервер подтверждает их сообщением того же типа
+// со списком всех текущих подписок; reading, alert и error отправляет сервер
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+	wsReading     = "reading"
+	wsAlert       = "alert"
+	wsError       = "error"
+)
+
+// Коды ошибок в сообщениях error
+const (
+	wsCodeBadMessage   = "bad_message"   // Сообщение не удалось разобрать
+	wsCodeLimit        = "limit"         // Превышено количество подписок
+	wsCodeSlowConsumer = "slow_consumer" // Клиент не успевает читать события, соединение закрывается
+	wsCodeInternal     = "internal"      // Внутренняя ошибка сервиса, подписка не изменена
+)
+
+// Ограничения соединения WebSocket
+const (
+	wsMaxMessageSize   = 64 << 10         // Максимальный размер сообщения клиента
+	wsMaxSubscriptions = 256              // Максимум городов и каналов на одно соединение
+	wsWriteTimeout     = 10 * time.Second // Время на отправку одного сообщения клиенту
+	wsControlBuffer    = 16               // Буфер подтверждений и ошибок
+)
+
+// wsMessage - сообщение протокола в обе стороны
+// Для каждого типа заполняются только относящиеся к нему поля
+type wsMessage struct {
+	Type      string          `json:"type"`
+	Locations []string        `json:"locations,omitempty"` // subscribe/unsubscribe: города, в подтверждении - их ключи
+	Alerts    []string        `json:"alerts,omitempty"`    // subscribe/unsubscribe: каналы оповещений
+	Location  string          `json:"location,omitempty"`  // reading: ключ города показания
+	Reading   *models.Weather `json:"reading,omitempty"`   // reading: показание
+	Alert     *models.Alert   `json:"alert,omitempty"`     // alert: оповещение
+	Code      string          `json:"code,omitempty"`      // error: код ошибки
+	Message   string          `json:"message,omitempty"`   // error: описание ошибки
+}
+
+// wsConn хранит состояние одного соединения WebSocket
+// Читает сообщения клиента в отдельной горутине, а все записи выполняет
+// единственная горутина writeLoop, как того требует gorilla/websocket
+type wsConn struct {
+	conn      *websocket.Conn
+	sub       *events.Subscription
+	resolve   func(city string) (string, error) // Ключ города по названию из сообщения
+	control   chan wsMessage                    // Подтверждения и ошибки для отправки клиенту
+	locations map[string]struct{}               // Текущие подписки на города по ключам
+	alerts    map[string]struct{}               // Текущие подписки на каналы оповещений
+	heartbeat time.Duration                     // Интервал ping-кадров
+}
+
+// getWS обрабатывает GET /api/v1/ws - подписку на показания и оповещения через WebSocket
+// Клиент управляет подписками сообщениями subscribe/unsubscribe во время работы соединения
+// Если клиент не успевает читать события, он получает ошибку slow_consumer
+// и соединение закрывается с кодом 1013 (try again later)
+func (h *Handlers) getWS(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: h.checkOrigin}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade уже записал ответ с ошибкой
+		return
+	}
+	defer conn.Close()
+
+	c := &wsConn{
+		conn: conn,
+		sub:  h.weatherService.SubscribeEvents(),
+		resolve: func(city string) (string, error) {
+			return h.weatherService.ResolveCity(r.Context(), city)
+		},
+		control:   make(chan wsMessage, wsControlBuffer),
+		locations: make(map[string]struct{}),
+		alerts:    make(map[string]struct{}),
+		heartbeat: h.config.Stream.Heartbeat,
+	}
+	defer c.sub.Unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.readLoop()
+	}()
+
+	c.writeLoop(done)
+}
+
+// checkOrigin разрешает соединения без Origin (не из браузера), с того же хоста
+// и с источников из stream.allowed_origins ("*" разрешает любые)
+func (h *Handlers) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	allowed := h.config.Stream.AllowedOrigins
+	if slices.Contains(allowed, "*") || slices.Contains(allowed, origin) {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// readLoop читает сообщения клиента до закрытия соединения
+func (c *wsConn) readLoop() {
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(2 * c.heartbeat))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(2 * c.heartbeat))
+	})
+
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				c.reply(wsMessage{Type: wsError, Code: wsCodeBadMessage, Message: "message must be a JSON object"})
+				continue
+			}
+			return
+		}
+
+		c.handle(msg)
+	}
+}
+
+// handle применяет сообщение клиента к подписке и отправляет подтверждение
+// Города подписываются по ключам: "Москва" и "Moscow" - одна подписка
+func (c *wsConn) handle(msg wsMessage) {
+	switch msg.Type {
+	case wsSubscribe, wsUnsubscribe:
+	default:
+		c.reply(wsMessage{Type: wsError, Code: wsCodeBadMessage, Message: "type must be subscribe or unsubscribe"})
+		return
+	}
+
+	// Все названия разрешаются до изменения подписки, чтобы ошибка не применяла сообщение частично
+	keys := make([]string, 0, len(msg.Locations))
+	for _, city := range msg.Locations {
+		key, err := c.resolve(city)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidQuery) {
+				c.reply(wsMessage{Type: wsError, Code: wsCodeBadMessage, Message: err.Error()})
+			} else {
+				c.reply(wsMessage{Type: wsError, Code: wsCodeInternal, Message: "failed to resolve city"})
+			}
+			return
+		}
+		keys = append(keys, key)
+	}
+
+	switch msg.Type {
+	case wsSubscribe:
+		if len(c.locations)+len(c.alerts)+len(keys)+len(msg.Alerts) > wsMaxSubscriptions {
+			c.reply(wsMessage{Type: wsError, Code: wsCodeLimit, Message: "too many subscriptions"})
+			return
+		}
+		for _, key := range keys {
+			c.locations[key] = struct{}{}
+			c.sub.Add(events.ReadingTopic(key))
+		}
+		for _, channel := range msg.Alerts {
+			c.alerts[channel] = struct{}{}
+			c.sub.Add(events.AlertTopic(channel))
+		}
+	case wsUnsubscribe:
+		for _, key := range keys {
+			delete(c.locations, key)
+			c.sub.Remove(events.ReadingTopic(key))
+		}
+		for _, channel := range msg.Alerts {
+			delete(c.alerts, channel)
+			c.sub.Remove(events.AlertTopic(channel))
+		}
+	}
+
+	c.reply(wsMessage{
+		Type:      msg.Type,
+		Locations: slices.Sorted(maps.Keys(c.locations)),
+		Alerts:    slices.Sorted(maps.Keys(c.alerts)),
+	})
+}
+
+// reply ставит сообщение в очередь на отправку
+// Если очередь переполнена, клиент не читает ответы, и соединение закрывается
+func (c *wsConn) reply(msg wsMessage) {
+	select {
+	case c.control <- msg:
+	default:
+		c.conn.Close()
+	}
+}
+
+// writeLoop отправляет клиенту события подписки, ответы и ping-кадры
+// Завершается при закрытии соединения клиентом или при переполнении подписки
+func (c *wsConn) writeLoop(done <-chan struct{}) {
+	ping := time.NewTicker(c.heartbeat)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case msg := <-c.control:
+			if err := c.write(msg); err != nil {
+				return
+			}
+		case event, ok := <-c.sub.C:
+			if !ok {
+				// Брокер закрыл подписку: клиент не успевает читать события
+				c.write(wsMessage{Type: wsError, Code: wsCodeSlowConsumer, Message: "client is too slow, reconnect and resubscribe"})
+				c.conn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, wsCodeSlowConsumer),
+					time.Now().Add(wsWriteTimeout),
+				)
+				return
+			}
+			if err := c.write(eventMessage(event)); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// write отправляет сообщение с ограничением по времени
+// Клиент, который не принимает данные, не блокирует горутину дольше wsWriteTimeout
+func (c *wsConn) write(msg wsMessage) error {
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteJSON(msg)
+}
+
+// eventMessage преобразует событие брокера в сообщение протокола
+func eventMessage(event events.Event) wsMessage {
+	if event.Type == events.TypeAlert {
+		return wsMessage{Type: wsAlert, Alert: event.Alert}
+	}
+
+	var weather models.Weather
+	event.Reading.ToWeather(&weather)
+	weather.AgeSeconds = int64(time.Since(weather.ObservedAt) / time.Second)
+
+	return wsMessage{Type: wsReading, Location: event.Reading.Name, Reading: &weather}
+}
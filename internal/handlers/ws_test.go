@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/olezhek28/wether-service/internal/events"
+)
+
+// dialWS поднимает тестовый сервер и подключается к /api/v1/ws
+// Возвращает соединение и подписку, которую обработчик получил от сервиса
+func dialWS(t *testing.T, svc *fakeWeatherService) (*websocket.Conn, *events.Subscription) {
+	t.Helper()
+
+	srv := httptest.NewServer(newTestRouter(t, svc, testConfig()))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/v1/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	select {
+	case sub := <-svc.subscriptions:
+		return conn, sub
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not subscribe")
+		return nil, nil
+	}
+}
+
+// exchange отправляет сообщение и возвращает ответ сервера
+func exchange(t *testing.T, conn *websocket.Conn, msg wsMessage) wsMessage {
+	t.Helper()
+
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	}
+	return readWS(t, conn)
+}
+
+// readWS читает одно сообщение с ограничением по времени
+func readWS(t *testing.T, conn *websocket.Conn) wsMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var reply wsMessage
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func TestWSSubscribeUnsubscribe(t *testing.T) {
+	conn, _ := dialWS(t, newFakeWeatherService())
+
+	// Названия разрешаются в ключи: "Moscow" и "moscow" - одна подписка
+	reply := exchange(t, conn, wsMessage{Type: wsSubscribe, Locations: []string{"Moscow", "moscow", "kazan"}, Alerts: []string{"frost"}})
+	if reply.Type != wsSubscribe || !slices.Equal(reply.Locations, []string{"kazan", "moscow"}) || !slices.Equal(reply.Alerts, []string{"frost"}) {
+		t.Fatalf("unexpected subscribe ack: %+v", reply)
+	}
+
+	reply = exchange(t, conn, wsMessage{Type: wsUnsubscribe, Locations: []string{"kazan"}, Alerts: []string{"frost"}})
+	if reply.Type != wsUnsubscribe || !slices.Equal(reply.Locations, []string{"moscow"}) || len(reply.Alerts) != 0 {
+		t.Fatalf("unexpected unsubscribe ack: %+v", reply)
+	}
+}
+
+func TestWSInvalidCity(t *testing.T) {
+	conn, _ := dialWS(t, newFakeWeatherService())
+
+	reply := exchange(t, conn, wsMessage{Type: wsSubscribe, Locations: []string{"moscow", " "}})
+	if reply.Type != wsError || reply.Code != wsCodeBadMessage {
+		t.Fatalf("got %+v, want bad_message error", reply)
+	}
+
+	// Сообщение с ошибкой не применяется частично
+	if reply := exchange(t, conn, wsMessage{Type: wsSubscribe}); len(reply.Locations) != 0 {
+		t.Errorf("locations = %v, want none", reply.Locations)
+	}
+}
+
+func TestWSDeliversSubscribedEvents(t *testing.T) {
+	svc := newFakeWeatherService()
+	conn, _ := dialWS(t, svc)
+
+	exchange(t, conn, wsMessage{Type: wsSubscribe, Locations: []string{"moscow"}, Alerts: []string{"frost"}})
+
+	// Показание другого города не должно дойти до клиента
+	svc.broker.PublishReading(models.WeatherDTO{ID: 1, Name: "kazan", Temperature: 3, Timestamp: time.Now()})
+	svc.broker.PublishReading(models.WeatherDTO{ID: 2, Name: "moscow", Temperature: -1, Timestamp: time.Now()})
+	svc.broker.PublishAlert(models.Alert{Channel: "frost", Rule: "moscow-frost", Name: "moscow", Value: -1, ReadingID: 2})
+
+	reading := readWS(t, conn)
+	if reading.Type != wsReading || reading.Location != "moscow" || reading.Reading == nil || reading.Reading.Temperature != -1 {
+		t.Fatalf("unexpected reading: %+v", reading)
+	}
+
+	alert := readWS(t, conn)
+	if alert.Type != wsAlert || alert.Alert == nil || alert.Alert.Rule != "moscow-frost" || alert.Alert.ReadingID != 2 {
+		t.Fatalf("unexpected alert: %+v", alert)
+	}
+}
+
+func TestWSSubscriptionLimit(t *testing.T) {
+	conn, _ := dialWS(t, newFakeWeatherService())
+
+	locations := make([]string, wsMaxSubscriptions)
+	for i := range locations {
+		locations[i] = "city" + string(rune('a'+i%26)) + strings.Repeat("x", i/26)
+	}
+	if reply := exchange(t, conn, wsMessage{Type: wsSubscribe, Locations: locations}); reply.Type != wsSubscribe {
+		t.Fatalf("subscription within limit rejected: %+v", reply)
+	}
+
+	reply := exchange(t, conn, wsMessage{Type: wsSubscribe, Alerts: []string{"frost"}})
+	if reply.Type != wsError || reply.Code != wsCodeLimit {
+		t.Fatalf("got %+v, want limit error", reply)
+	}
+}
+
+func TestWSBadMessage(t *testing.T) {
+	conn, _ := dialWS(t, newFakeWeatherService())
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	if reply := readWS(t, conn); reply.Type != wsError || reply.Code != wsCodeBadMessage {
+		t.Fatalf("got %+v, want bad_message error", reply)
+	}
+
+	// После ошибки соединение продолжает работать
+	if reply := exchange(t, conn, wsMessage{Type: "ping"}); reply.Type != wsError || reply.Code != wsCodeBadMessage {
+		t.Fatalf("got %+v, want bad_message error", reply)
+	}
+	if reply := exchange(t, conn, wsMessage{Type: wsSubscribe, Locations: []string{"moscow"}}); reply.Type != wsSubscribe {
+		t.Fatalf("got %+v, want subscribe ack", reply)
+	}
+}
+
+func TestWSSlowConsumerCloses(t *testing.T) {
+	conn, sub := dialWS(t, newFakeWeatherService())
+
+	// Брокер закрывает подписку, когда буфер подписчика переполнен
+	sub.Unsubscribe()
+
+	reply := readWS(t, conn)
+	if reply.Type != wsError || reply.Code != wsCodeSlowConsumer {
+		t.Fatalf("got %+v, want slow_consumer error", reply)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseTryAgainLater {
+		t.Fatalf("got %v, want close 1013", err)
+	}
+}
@@ -2,42 +2,98 @@ package http
 
 import (
 	"context"
-	"fmt"
+	"crypto/tls"
+	"net"
 	"net/http"
+	"strconv"
+
+	"github.com/olezhek28/wether-service/internal/config"
 )
 
 // Server — структура, описывающая HTTP-сервер.
-// Включает контекст для graceful shutdown, порт, хост и экземпляр http.Handler.
+// Включает контекст для graceful shutdown, порт, хост, экземпляр http.Handler
+// и, если задан сертификат, параметры TLS.
 type Server struct {
-	context  context.Context // Контекст для управления жизненным циклом сервера
-	host     string          // Хост (обычно "0.0.0.0" или "localhost")
-	port     int             // Порт, на котором будет запущен сервер
-	handlers http.Handler    // Интерфейс для работы хэндлеров
+	context  context.Context  // Контекст для управления жизненным циклом сервера
+	host     string           // Хост (обычно "0.0.0.0" или "localhost")
+	port     int              // Порт, на котором будет запущен сервер
+	handlers http.Handler     // Интерфейс для работы хэндлеров
+	tls      *tls.Config      // Конфигурация TLS, nil - обычный HTTP
+	certs    *certReloader    // Перечитываемый сертификат сервера при включенном TLS
+	config   config.TLSConfig // Параметры TLS, в том числе интервал проверки файлов
 }
 
 // NewServer — конструктор для создания нового сервера.
-// Принимает контекст, порт, хост и экземпляр http.Handler, возвращает *Server.
+// Принимает контекст, порт, хост, экземпляр http.Handler и параметры TLS, возвращает *Server.
+// При включенном TLS сразу загружает сертификат и CA клиентов и вызывает панику,
+// если их не удалось прочитать, чтобы ошибка конфигурации обнаружилась при запуске.
 func NewServer(
 	context context.Context,
 	port int,
 	host string,
 	handlers http.Handler,
+	tlsConfig config.TLSConfig,
 ) *Server {
-	return &Server{
+	s := &Server{
 		context:  context,
+		host:     host,
 		port:     port,
 		handlers: handlers,
+		config:   tlsConfig,
 	}
+
+	if tlsConfig.Enabled() {
+		certs, err := newCertReloader(tlsConfig.CertFile, tlsConfig.KeyFile)
+		if err != nil {
+			panic(err)
+		}
+		s.certs = certs
+
+		s.tls, err = newTLSConfig(tlsConfig, certs)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return s
 }
 
 // MustRun — запускает HTTP-сервер и завершает приложение с фатальной ошибкой, если запуск невозможен.
 // Формирует строку адреса (хост:порт) и запускает сервер.
 func (s *Server) MustRun() {
-	addr := fmt.Sprintf("%s:%d", s.host, s.port)
-	err := http.ListenAndServe(addr, s.handlers)
+	listener, err := net.Listen("tcp", s.addr())
 	if err != nil {
 		panic(err)
 	}
+
+	if err := s.serve(listener); err != nil {
+		panic(err)
+	}
+}
+
+// addr возвращает адрес, на котором сервер принимает соединения
+// Пустой хост означает все интерфейсы
+func (s *Server) addr() string {
+	return net.JoinHostPort(s.host, strconv.Itoa(s.port))
+}
+
+// serve обслуживает соединения, принятые listener
+// При включенном TLS соединения принимаются через TLS, а файлы сертификата
+// проверяются на изменения до отмены контекста сервера
+func (s *Server) serve(listener net.Listener) error {
+	srv := &http.Server{
+		Handler:   s.handlers,
+		TLSConfig: s.tls,
+	}
+
+	if s.tls == nil {
+		return srv.Serve(listener)
+	}
+
+	go s.certs.watch(s.context, s.config.ReloadInterval)
+
+	// Сертификат отдается через TLSConfig.GetCertificate, поэтому пути к файлам не передаются
+	return srv.ServeTLS(listener, "", "")
 }
 
 // TODO: Сделать Gracefull Shutdown
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/olezhek28/wether-service/internal/config"
+)
+
+func TestServerListenAddress(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "127.0.0.1", want: "127.0.0.1:8080"},
+		{host: "::1", want: "[::1]:8080"},
+		{host: "", want: ":8080"}, // Все интерфейсы
+	}
+	for _, tt := range tests {
+		s := NewServer(context.Background(), 8080, tt.host, nil, config.TLSConfig{})
+		if got := s.addr(); got != tt.want {
+			t.Errorf("host %q: addr = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+
+	// Сервер с заданным хостом слушает только его, а не все интерфейсы
+	s := NewServer(context.Background(), 0, "127.0.0.1", nil, config.TLSConfig{})
+	listener, err := net.Listen("tcp", s.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if ip := listener.Addr().(*net.TCPAddr).IP; !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("listening on %s, want 127.0.0.1", ip)
+	}
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+)
+
+// tlsVersions сопоставляет версии TLS из конфигурации константам crypto/tls
+var tlsVersions = map[string]uint16{
+	config.TLSVersion12: tls.VersionTLS12,
+	config.TLSVersion13: tls.VersionTLS13,
+}
+
+// certReloader отдает текущий сертификат сервера и перечитывает его,
+// когда файлы сертификата или ключа меняются на диске
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	version fileVersion // Версия файлов, из которых загружен cert
+}
+
+// fileVersion описывает состояние пары файлов для обнаружения изменений
+// Сравниваются время изменения и размер: после замены секрета через символическую
+// ссылку (как в Kubernetes) os.Stat видит новый файл
+type fileVersion struct {
+	certModTime, keyModTime time.Time
+	certSize, keySize       int64
+}
+
+// newCertReloader загружает сертификат и ключ. Ошибка возвращается, если файлы
+// не читаются или ключ не соответствует сертификату
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate возвращает текущий сертификат для tls.Config.GetCertificate
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// watch проверяет файлы каждые interval до отмены ctx
+// Если новые файлы не загружаются (например, записан только сертификат, а ключ еще
+// старый), сервер продолжает работать с прежним сертификатом до следующей проверки
+func (c *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := c.reload()
+			switch {
+			case err != nil:
+				slog.Error("failed to reload TLS certificate, serving the previous one", "cert_file", c.certFile, "error", err)
+			case reloaded:
+				slog.Info("TLS certificate reloaded", "cert_file", c.certFile)
+			}
+		}
+	}
+}
+
+// reload загружает сертификат и ключ, если файлы изменились с прошлой загрузки
+// Возвращает true, если сертификат заменен
+func (c *certReloader) reload() (bool, error) {
+	version, err := c.stat()
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.RLock()
+	unchanged := c.cert != nil && version == c.version
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	c.cert, c.version = &cert, version
+	c.mu.Unlock()
+	return true, nil
+}
+
+// stat возвращает текущую версию файлов сертификата и ключа
+func (c *certReloader) stat() (fileVersion, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{
+		certModTime: certInfo.ModTime(),
+		keyModTime:  keyInfo.ModTime(),
+		certSize:    certInfo.Size(),
+		keySize:     keyInfo.Size(),
+	}, nil
+}
+
+// newTLSConfig создает конфигурацию TLS сервера с перечитываемым сертификатом
+// Если задан CA клиентов, клиентский сертификат запрашивается у всех, но проверяется
+// только присланный: без сертификата доступны маршруты, которые его не требуют,
+// а административные маршруты проверяют r.TLS.VerifiedChains
+func newTLSConfig(config config.TLSConfig, certs *certReloader) (*tls.Config, error) {
+	version, ok := tlsVersions[config.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS version %q", config.MinVersion)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     version,
+		GetCertificate: certs.GetCertificate,
+	}
+
+	if config.ClientCAFile != "" {
+		pem, err := os.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + config.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,226 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+)
+
+// testCert - сертификат с ключом, выпущенный тестовым CA или самоподписанный
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// issue выпускает сертификат с CommonName name. Если parent равен nil, сертификат - CA
+func issue(t *testing.T, name string, serial int64, parent *testCert) testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCert{cert: cert, key: key, der: der}
+}
+
+// write записывает сертификат и ключ в PEM и сдвигает время изменения на at
+func (c testCert) write(t *testing.T, certFile, keyFile string, at time.Time) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: c.der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	}
+	for name, block := range files {
+		if err := os.WriteFile(name, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// serial возвращает серийный номер текущего сертификата
+func serial(t *testing.T, certs *certReloader) int64 {
+	t.Helper()
+
+	cert, err := certs.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Hour)
+
+	ca := issue(t, "ca", 1, nil)
+	issue(t, "server", 10, &ca).write(t, certFile, keyFile, start)
+
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := serial(t, certs); got != 10 {
+		t.Fatalf("serial = %d, want 10", got)
+	}
+
+	// Файлы не менялись - сертификат не перечитывается
+	if reloaded, err := certs.reload(); reloaded || err != nil {
+		t.Errorf("reload of unchanged files = %t, %v", reloaded, err)
+	}
+
+	issue(t, "server", 11, &ca).write(t, certFile, keyFile, start.Add(time.Minute))
+	if reloaded, err := certs.reload(); !reloaded || err != nil {
+		t.Fatalf("reload of rotated files = %t, %v", reloaded, err)
+	}
+	if got := serial(t, certs); got != 11 {
+		t.Errorf("serial after rotation = %d, want 11", got)
+	}
+
+	// Ключ не соответствует сертификату (записан только новый сертификат) - остается прежний
+	mismatched := issue(t, "server", 12, &ca)
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mismatched.der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := certs.reload(); err == nil {
+		t.Error("expected error for a certificate that does not match the key")
+	}
+	if got := serial(t, certs); got != 11 {
+		t.Errorf("serial after failed reload = %d, want 11", got)
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+
+	ca := issue(t, "ca", 1, nil)
+	issue(t, "server", 10, &ca).write(t, certFile, keyFile, time.Now())
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Обработчик сообщает, проверен ли клиентский сертификат
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%d", len(r.TLS.VerifiedChains))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewServer(ctx, 0, "127.0.0.1", handler, config.TLSConfig{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		MinVersion:     config.TLSVersion13,
+		ReloadInterval: time.Minute,
+		ClientCAFile:   caFile,
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.serve(listener)
+	url := "https://" + listener.Addr().String() + "/"
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := issue(t, "ops", 20, &ca)
+
+	tests := []struct {
+		name    string
+		tls     *tls.Config
+		want    string
+		wantErr bool
+	}{
+		{name: "tls 1.3 without client cert", tls: &tls.Config{RootCAs: roots}, want: "0"},
+		{name: "tls 1.3 with client cert", tls: &tls.Config{
+			RootCAs:      roots,
+			Certificates: []tls.Certificate{{Certificate: [][]byte{client.der}, PrivateKey: client.key}},
+		}, want: "1"},
+		{name: "tls 1.2 below minimum", tls: &tls.Config{RootCAs: roots, MaxVersion: tls.VersionTLS12}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: tt.tls}}
+			defer c.CloseIdleConnections()
+
+			res, err := c.Get(url)
+			if tt.wantErr {
+				if err == nil {
+					res.Body.Close()
+					t.Fatal("expected handshake error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(body); got != tt.want {
+				t.Errorf("verified chains = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
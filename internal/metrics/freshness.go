@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// freshnessTimeout ограничивает чтение последних показаний при сборе метрик,
+// чтобы медленная база не задерживала ответ /metrics дольше таймаута Prometheus
+const freshnessTimeout = 2 * time.Second
+
+// LatestReader определяет контракт чтения последних показаний нескольких городов
+type LatestReader interface {
+	ReadWeatherByCities(ctx context.Context, cities []string) ([]models.WeatherDTO, error)
+}
+
+// CityResolver определяет контракт получения ключа города, под которым хранятся показания
+type CityResolver interface {
+	ResolveCity(ctx context.Context, city string) (string, error)
+}
+
+// freshnessCollector отдает возраст последнего показания каждого города на момент запроса /metrics
+// Возраст считается по базе, а не по показаниям, сохраненным этой репликой,
+// поэтому все реплики отдают одинаковое значение
+type freshnessCollector struct {
+	readings LatestReader
+	resolver CityResolver
+	cities   []string
+	age      *prometheus.Desc
+}
+
+// RegisterFreshness регистрирует метрику latest_reading_age_seconds для городов cities
+// Города из конфигурации приводятся к ключам через resolver при каждом сборе,
+// и ряд помечается ключом города. Город без показаний в метрике отсутствует
+func (m *Metrics) RegisterFreshness(readings LatestReader, resolver CityResolver, cities []string) {
+	m.registry.MustRegister(&freshnessCollector{
+		readings: readings,
+		resolver: resolver,
+		cities:   cities,
+		age: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "latest_reading_age_seconds"),
+			"Seconds since the latest reading of a collected city was fetched.",
+			[]string{"city"}, nil,
+		),
+	})
+}
+
+// Describe отправляет описание метрики
+func (c *freshnessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.age
+}
+
+// Collect читает последние показания и отправляет их возраст
+// При ошибке чтения метрика пропускается: отсутствие ряда заметнее устаревшего значения
+func (c *freshnessCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), freshnessTimeout)
+	defer cancel()
+
+	// Показания хранятся под ключом города, а не под названием из конфигурации
+	keys := make([]string, 0, len(c.cities))
+	for _, city := range c.cities {
+		key, err := c.resolver.ResolveCity(ctx, city)
+		if err != nil {
+			slog.Error("metrics: failed to resolve city", "city", city, "error", err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	readings, err := c.readings.ReadWeatherByCities(ctx, keys)
+	if err != nil {
+		slog.Error("metrics: failed to read latest readings", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for _, r := range readings {
+		ch <- prometheus.MustNewConstMetric(c.age, prometheus.GaugeValue, now.Sub(r.ReceivedAt()).Seconds(), r.Name)
+	}
+}
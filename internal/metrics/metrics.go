@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace - общий префикс имен метрик сервиса
+const namespace = "weather"
+
+// Результаты запуска сбора в метке result
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// Metrics содержит метрики сервиса в собственном реестре
+// Отдельный реестр вместо глобального prometheus.DefaultRegisterer позволяет
+// создавать независимые экземпляры в тестах
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests       *prometheus.CounterVec
+	httpDuration       *prometheus.HistogramVec
+	upstreamDuration   *prometheus.HistogramVec
+	upstreamErrors     *prometheus.CounterVec
+	collectionRuns     *prometheus.CounterVec
+	collectionDuration *prometheus.HistogramVec
+	dbDuration         *prometheus.HistogramVec
+	mqttMessages       *prometheus.CounterVec
+}
+
+// New создает и регистрирует метрики сервиса, а также метрики Go runtime и процесса
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by chi route pattern, method and status code.",
+		}, []string{"route", "method", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by chi route pattern and method. Streaming routes last for the whole connection.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "Latency of requests to external APIs by client.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"client"}),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_errors_total",
+			Help:      "Failed requests to external APIs by client: transport errors and non-2xx responses.",
+		}, []string{"client"}),
+		collectionRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "collection_runs_total",
+			Help:      "Scheduled collection runs by city and result (success, failure).",
+		}, []string{"city", "result"}),
+		collectionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "collection_duration_seconds",
+			Help:      "Duration of scheduled collection runs by city.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"city"}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Database query latency by SQL command (select, insert, ...).",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation"}),
+		mqttMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mqtt_messages_total",
+			Help:      "MQTT sensor messages by result: saved, invalid, out_of_window (timestamp outside mqtt.max_past/max_future), failed.",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.upstreamDuration,
+		m.upstreamErrors,
+		m.collectionRuns,
+		m.collectionDuration,
+		m.dbDuration,
+		m.mqttMessages,
+	)
+
+	return m
+}
+
+// Handler возвращает обработчик /metrics в формате Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRequest учитывает обработанный HTTP-запрос
+// route - шаблон маршрута chi, а не путь запроса, чтобы названия городов не раздували число рядов
+func (m *Metrics) ObserveRequest(route, method string, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// ObserveCollection учитывает запуск сбора показаний города по расписанию
+func (m *Metrics) ObserveCollection(city string, duration time.Duration, err error) {
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+	m.collectionRuns.WithLabelValues(city, result).Inc()
+	m.collectionDuration.WithLabelValues(city).Observe(duration.Seconds())
+}
+
+// ObserveMQTTMessage учитывает сообщение датчика MQTT с результатом обработки result
+func (m *Metrics) ObserveMQTTMessage(result string) {
+	m.mqttMessages.WithLabelValues(result).Inc()
+}
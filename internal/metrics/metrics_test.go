@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// scrape возвращает ответ /metrics
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	return rec.Body.String()
+}
+
+func TestObserveRequest(t *testing.T) {
+	m := New()
+	m.ObserveRequest("/api/v1/{city}", http.MethodGet, http.StatusOK, 20*time.Millisecond)
+	m.ObserveRequest("/api/v1/{city}", http.MethodGet, http.StatusOK, 30*time.Millisecond)
+	m.ObserveRequest("/api/v1/{city}", http.MethodGet, http.StatusNotFound, time.Millisecond)
+
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("/api/v1/{city}", http.MethodGet, "200")); got != 2 {
+		t.Errorf("200 requests = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("/api/v1/{city}", http.MethodGet, "404")); got != 1 {
+		t.Errorf("404 requests = %v, want 1", got)
+	}
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`weather_http_request_duration_seconds_count{method="GET",route="/api/v1/{city}"} 3`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q", want)
+		}
+	}
+}
+
+func TestObserveMQTTMessage(t *testing.T) {
+	m := New()
+	m.ObserveMQTTMessage("saved")
+	m.ObserveMQTTMessage("out_of_window")
+	m.ObserveMQTTMessage("out_of_window")
+
+	if !strings.Contains(scrape(t, m), `weather_mqtt_messages_total{result="out_of_window"} 2`) {
+		t.Error("metrics do not count out of window messages")
+	}
+}
+
+func TestObserveCollection(t *testing.T) {
+	m := New()
+	m.ObserveCollection("moscow", time.Second, nil)
+	m.ObserveCollection("moscow", time.Second, errors.New("upstream unavailable"))
+	m.ObserveCollection("paris", time.Second, nil)
+
+	tests := []struct {
+		city, result string
+		want         float64
+	}{
+		{"moscow", resultSuccess, 1},
+		{"moscow", resultFailure, 1},
+		{"paris", resultSuccess, 1},
+		{"paris", resultFailure, 0},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(m.collectionRuns.WithLabelValues(tt.city, tt.result)); got != tt.want {
+			t.Errorf("%s %s = %v, want %v", tt.city, tt.result, got, tt.want)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	m := New()
+	client := &http.Client{Transport: m.Transport(ClientOpenMeteo, srv.Client().Transport)}
+
+	for _, path := range []string{"/ok", "/ok", "/fail"} {
+		res, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}
+
+	// Сбой транспорта тоже считается ошибкой
+	srv.Close()
+	if _, err := client.Get(srv.URL + "/ok"); err == nil {
+		t.Fatal("expected transport error after the server is closed")
+	}
+
+	if got := testutil.ToFloat64(m.upstreamErrors.WithLabelValues(ClientOpenMeteo)); got != 2 {
+		t.Errorf("errors = %v, want 2 (502 and transport error)", got)
+	}
+	if got := testutil.ToFloat64(m.upstreamErrors.WithLabelValues(ClientGeocoding)); got != 0 {
+		t.Errorf("geocoding errors = %v, want 0", got)
+	}
+	if !strings.Contains(scrape(t, m), `weather_upstream_request_duration_seconds_count{client="open_meteo"} 4`) {
+		t.Error("upstream latency is not observed for every request")
+	}
+}
+
+func TestSQLOperation(t *testing.T) {
+	tests := map[string]string{
+		"select 1":                          "select",
+		"\n  SELECT name\nfrom location":    "select",
+		"insert into reading (name) values": "insert",
+		"with usage as (update api_key)":    "with",
+		"vacuum reading":                    "other",
+		"":                                  "other",
+	}
+	for sql, want := range tests {
+		if got := sqlOperation(sql); got != want {
+			t.Errorf("sqlOperation(%q) = %q, want %q", sql, got, want)
+		}
+	}
+}
+
+func TestQueryTracer(t *testing.T) {
+	m := New()
+	tracer := m.QueryTracer()
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "select 1"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("canceled")})
+
+	// Без начала запроса в контексте время неизвестно, и запрос не учитывается
+	tracer.TraceQueryEnd(context.Background(), nil, pgx.TraceQueryEndData{})
+
+	if !strings.Contains(scrape(t, m), `weather_db_query_duration_seconds_count{operation="select"} 1`) {
+		t.Error("query latency is not observed once by operation")
+	}
+}
+
+// fakeLatestReader возвращает заданные последние показания
+type fakeLatestReader struct {
+	readings []models.WeatherDTO
+	err      error
+}
+
+func (f fakeLatestReader) ReadWeatherByCities(context.Context, []string) ([]models.WeatherDTO, error) {
+	return f.readings, f.err
+}
+
+// fakeResolver приводит названия городов к ключам по таблице псевдонимов
+// Название, которого нет в таблице, само является ключом
+type fakeResolver map[string]string
+
+func (f fakeResolver) ResolveCity(_ context.Context, city string) (string, error) {
+	if key, ok := f[city]; ok {
+		return key, nil
+	}
+	return city, nil
+}
+
+// keyedLatestReader возвращает показания только запрошенных ключей
+type keyedLatestReader []models.WeatherDTO
+
+func (f keyedLatestReader) ReadWeatherByCities(_ context.Context, cities []string) ([]models.WeatherDTO, error) {
+	var readings []models.WeatherDTO
+	for _, r := range f {
+		if slices.Contains(cities, r.Name) {
+			readings = append(readings, r)
+		}
+	}
+	return readings, nil
+}
+
+func TestFreshness(t *testing.T) {
+	fetchedAt := time.Now().Add(-90 * time.Second)
+	m := New()
+	m.RegisterFreshness(fakeLatestReader{readings: []models.WeatherDTO{
+		{Name: "moscow", Timestamp: time.Now().Add(-time.Hour), FetchedAt: &fetchedAt},
+	}}, fakeResolver{}, []string{"moscow", "paris"})
+
+	body := scrape(t, m)
+	var line string
+	for l := range strings.Lines(body) {
+		if strings.HasPrefix(l, `weather_latest_reading_age_seconds{city="moscow"}`) {
+			line = l
+		}
+	}
+	if line == "" {
+		t.Fatalf("no age for moscow in:\n%s", body)
+	}
+	if strings.Contains(body, `weather_latest_reading_age_seconds{city="paris"}`) {
+		t.Error("city without readings must not have an age")
+	}
+
+	age, err := strconv.ParseFloat(strings.Fields(line)[1], 64)
+	if err != nil || age < 89 || age > 95 {
+		t.Errorf("age line %q, want about 90 seconds from fetched_at", line)
+	}
+}
+
+func TestFreshnessReadError(t *testing.T) {
+	m := New()
+	m.RegisterFreshness(fakeLatestReader{err: errors.New("timeout")}, fakeResolver{}, []string{"moscow"})
+
+	// Ошибка базы не ломает ответ /metrics, ряд просто отсутствует
+	if strings.Contains(scrape(t, m), "weather_latest_reading_age_seconds{") {
+		t.Error("age must be omitted when readings cannot be read")
+	}
+}
+
+func TestFreshnessResolvesAliases(t *testing.T) {
+	fetchedAt := time.Now().Add(-time.Minute)
+	m := New()
+	m.RegisterFreshness(keyedLatestReader{
+		{Name: "moscow", Timestamp: time.Now().Add(-time.Hour), FetchedAt: &fetchedAt},
+	}, fakeResolver{"Москва": "moscow"}, []string{"Москва"})
+
+	body := scrape(t, m)
+	if !strings.Contains(body, `weather_latest_reading_age_seconds{city="moscow"}`) {
+		t.Errorf("no age for the key of a city configured by alias in:\n%s", body)
+	}
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// sqlOperations - SQL-команды, которые попадают в метку operation как есть
+// Остальные учитываются как other, чтобы текст запросов не раздувал число рядов
+var sqlOperations = map[string]bool{
+	"select": true,
+	"insert": true,
+	"update": true,
+	"delete": true,
+	"with":   true,
+	"listen": true,
+	"begin":  true,
+	"commit": true,
+}
+
+// queryStartKey - ключ контекста, под которым хранится начало запроса
+type queryStartKey struct{}
+
+// queryStart - начало запроса и его SQL-команда
+type queryStart struct {
+	at        time.Time
+	operation string
+}
+
+// queryTracer учитывает время запросов pgx
+type queryTracer struct {
+	metrics *Metrics
+}
+
+// QueryTracer возвращает трассировщик запросов pgx для pgx.ConnConfig.Tracer,
+// который учитывает время каждого запроса в метрике db_query_duration_seconds
+func (m *Metrics) QueryTracer() pgx.QueryTracer {
+	return &queryTracer{metrics: m}
+}
+
+// TraceQueryStart запоминает начало запроса в контексте
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{at: time.Now(), operation: sqlOperation(data.SQL)})
+}
+
+// TraceQueryEnd учитывает время запроса, в том числе завершившегося ошибкой
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	t.metrics.dbDuration.WithLabelValues(start.operation).Observe(time.Since(start.at).Seconds())
+}
+
+// sqlOperation возвращает первое слово запроса в нижнем регистре или other
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "other"
+	}
+	word := strings.ToLower(fields[0])
+	if sqlOperations[word] {
+		return word
+	}
+	return "other"
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+)
+
+// Названия клиентов внешних API в метке client
+const (
+	ClientGeocoding = "geocoding"
+	ClientOpenMeteo = "open_meteo"
+	ClientJWKS      = "jwks"
+)
+
+// instrumentedTransport учитывает время и ошибки запросов клиента внешнего API
+type instrumentedTransport struct {
+	next    http.RoundTripper
+	client  string
+	metrics *Metrics
+}
+
+// Transport оборачивает next так, что запросы учитываются в метриках внешних API с меткой client
+// Если next равен nil, используется http.DefaultTransport
+func (m *Metrics) Transport(client string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &instrumentedTransport{next: next, client: client, metrics: m}
+}
+
+// RoundTrip выполняет запрос и учитывает его. Ошибкой считается сбой транспорта
+// (в том числе таймаут) и ответ со статусом вне 2xx
+// Время считается до получения заголовков ответа, чтение тела остается клиенту
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	t.metrics.upstreamDuration.WithLabelValues(t.client).Observe(time.Since(start).Seconds())
+
+	if err != nil || res.StatusCode < 200 || res.StatusCode > 299 {
+		t.metrics.upstreamErrors.WithLabelValues(t.client).Inc()
+	}
+	return res, err
+}
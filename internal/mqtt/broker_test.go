@@ -0,0 +1,189 @@
+package mqtt
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// testBroker - минимальный брокер MQTT 3.1.1 внутри процесса теста, чтобы тестам подписчика
+// не нужен был внешний брокер. Принимает любые подключения, выдает подписки с QoS 0
+// и доставляет опубликованные тестом сообщения подписчикам подходящих фильтров
+type testBroker struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	clients map[*brokerClient]struct{}
+
+	subscribed chan string // Фильтры оформленных подписок
+}
+
+// brokerClient - подключение клиента к тестовому брокеру
+type brokerClient struct {
+	conn    net.Conn
+	writeMu sync.Mutex // Пакеты пишут обработчик подключения и publish
+	filters []string   // Фильтры подписок, защищены testBroker.mu
+}
+
+// newTestBroker запускает брокер на свободном локальном порту до завершения теста
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &testBroker{
+		listener:   listener,
+		clients:    make(map[*brokerClient]struct{}),
+		subscribed: make(chan string, 16),
+	}
+	go b.accept()
+
+	t.Cleanup(func() {
+		listener.Close()
+		b.dropClients()
+	})
+	return b
+}
+
+// url возвращает адрес брокера для конфигурации подписчика
+func (b *testBroker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+// accept принимает подключения до закрытия брокера
+func (b *testBroker) accept() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &brokerClient{conn: conn}
+		b.mu.Lock()
+		b.clients[c] = struct{}{}
+		b.mu.Unlock()
+
+		go b.serve(c)
+	}
+}
+
+// serve обрабатывает пакеты клиента до разрыва соединения
+func (b *testBroker) serve(c *brokerClient) {
+	defer func() {
+		c.conn.Close()
+		b.mu.Lock()
+		delete(b.clients, c)
+		b.mu.Unlock()
+	}()
+
+	for {
+		packet, err := packets.ReadPacket(c.conn)
+		if err != nil {
+			return
+		}
+
+		switch p := packet.(type) {
+		case *packets.ConnectPacket:
+			ack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+			ack.ReturnCode = packets.Accepted
+			c.write(ack)
+		case *packets.SubscribePacket:
+			b.mu.Lock()
+			c.filters = append(c.filters, p.Topics...)
+			b.mu.Unlock()
+
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = make([]byte, len(p.Topics)) // QoS 0 для всех фильтров
+			c.write(ack)
+
+			for _, topic := range p.Topics {
+				b.subscribed <- topic
+			}
+		case *packets.PingreqPacket:
+			c.write(packets.NewControlPacket(packets.Pingresp))
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+// write отправляет пакет клиенту. Ошибка записи означает разрыв, его обнаружит serve
+func (c *brokerClient) write(packet packets.ControlPacket) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	packet.Write(c.conn)
+}
+
+// publish доставляет сообщение с QoS 0 всем клиентам, подписанным на тему topic
+func (b *testBroker) publish(topic, payload string) {
+	b.mu.Lock()
+	var targets []*brokerClient
+	for c := range b.clients {
+		for _, filter := range c.filters {
+			if matchTopic(filter, topic) {
+				targets = append(targets, c)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	for _, c := range targets {
+		p := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+		p.TopicName = topic
+		p.Payload = []byte(payload)
+		c.write(p)
+	}
+}
+
+// dropClients разрывает все подключения, как при перезапуске брокера или сбое сети
+func (b *testBroker) dropClients() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for c := range b.clients {
+		c.conn.Close()
+	}
+}
+
+// waitSubscribed ждет подписки на фильтр filter
+func (b *testBroker) waitSubscribed(t *testing.T, filter string) {
+	t.Helper()
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case topic := <-b.subscribed:
+			if topic == filter {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no subscription to %s", filter)
+		}
+	}
+}
+
+// matchTopic сообщает, совпадает ли тема topic с фильтром filter с подстановками + и #
+func matchTopic(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
@@ -0,0 +1,267 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// cityLevel - уровень шаблона темы, задающий город показания
+const cityLevel = "{city}"
+
+// Пути к значениям сообщения по умолчанию
+const (
+	defaultTemperatureField = "temperature"
+	defaultHumidityField    = "humidity"
+	defaultWindSpeedField   = "wind_speed"
+	defaultTimestampField   = "timestamp"
+)
+
+// subscription - подписка из конфигурации, подготовленная к разбору сообщений
+type subscription struct {
+	filter    string            // Фильтр темы для брокера: уровень {city} заменен на +
+	cityLevel int               // Номер уровня темы с городом, -1 - тема не задает город
+	city      string            // Город показаний, если его не задают тема и сообщение
+	fields    config.MQTTFields // Пути к значениям с подставленными путями по умолчанию
+
+	temperatureUnit string
+	windSpeedUnit   string
+	timestampFormat string
+}
+
+// compile проверяет подписку из конфигурации и готовит ее к разбору сообщений
+// Ошибки шаблона темы и единиц обнаруживаются при запуске, а не на первом сообщении
+func compile(cfg config.MQTTSubscription) (subscription, error) {
+	s := subscription{
+		cityLevel:       -1,
+		city:            cfg.City,
+		fields:          cfg.Fields,
+		temperatureUnit: cfg.TemperatureUnit,
+		windSpeedUnit:   cfg.WindSpeedUnit,
+		timestampFormat: cfg.TimestampFormat,
+	}
+
+	levels := strings.Split(cfg.Topic, "/")
+	for i, level := range levels {
+		switch {
+		case level == cityLevel:
+			if s.cityLevel >= 0 {
+				return subscription{}, fmt.Errorf("mqtt topic %q: %s may appear only once", cfg.Topic, cityLevel)
+			}
+			s.cityLevel = i
+			levels[i] = "+"
+		case level == "#":
+			if i != len(levels)-1 {
+				return subscription{}, fmt.Errorf("mqtt topic %q: # must be the last level", cfg.Topic)
+			}
+		case level != "+" && strings.ContainsAny(level, "+#{}"):
+			return subscription{}, fmt.Errorf("mqtt topic %q: wildcards must occupy a whole level", cfg.Topic)
+		}
+	}
+	s.filter = strings.Join(levels, "/")
+
+	if s.cityLevel < 0 && s.city == "" && s.fields.City == "" {
+		return subscription{}, fmt.Errorf("mqtt topic %q: city is not set, use a %s level, city or fields.city", cfg.Topic, cityLevel)
+	}
+
+	switch s.temperatureUnit {
+	case "", models.UnitCelsius, models.UnitFahrenheit, models.UnitKelvin:
+	default:
+		return subscription{}, fmt.Errorf("mqtt topic %q: temperature_unit must be one of C, F, K", cfg.Topic)
+	}
+	switch s.windSpeedUnit {
+	case "", models.UnitKilometersPerHour, models.UnitMetersPerSecond, models.UnitMilesPerHour, models.UnitKnots:
+	default:
+		return subscription{}, fmt.Errorf("mqtt topic %q: wind_speed_unit must be one of km/h, m/s, mph, kn", cfg.Topic)
+	}
+	if s.timestampFormat == "" {
+		s.timestampFormat = config.MQTTTimestampRFC3339
+	}
+
+	if s.fields.Temperature == "" {
+		s.fields.Temperature = defaultTemperatureField
+	}
+	if s.fields.Humidity == "" {
+		s.fields.Humidity = defaultHumidityField
+	}
+	if s.fields.WindSpeed == "" {
+		s.fields.WindSpeed = defaultWindSpeedField
+	}
+	if s.fields.Timestamp == "" {
+		s.fields.Timestamp = defaultTimestampField
+	}
+
+	return s, nil
+}
+
+// parse разбирает сообщение темы topic и возвращает название города и показание
+// в единицах хранения. Сообщение без времени измерения датировано моментом получения received
+func (s subscription) parse(topic string, payload []byte, received time.Time) (string, models.Observation, error) {
+	var body map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil || body == nil {
+		return "", models.Observation{}, errors.New("payload must be a JSON object")
+	}
+
+	observation := models.Observation{
+		Timestamp:       received,
+		TemperatureUnit: s.temperatureUnit,
+		WindSpeedUnit:   s.windSpeedUnit,
+	}
+
+	var err error
+	if observation.Temperature, err = number(body, s.fields.Temperature); err != nil {
+		return "", models.Observation{}, err
+	}
+	if observation.Humidity, err = number(body, s.fields.Humidity); err != nil {
+		return "", models.Observation{}, err
+	}
+	if observation.WindSpeed, err = number(body, s.fields.WindSpeed); err != nil {
+		return "", models.Observation{}, err
+	}
+	if value, ok := lookup(body, s.fields.Timestamp); ok {
+		if observation.Timestamp, err = timestamp(value, s.timestampFormat); err != nil {
+			return "", models.Observation{}, fmt.Errorf("field %s: %w", s.fields.Timestamp, err)
+		}
+	}
+
+	city, err := s.cityOf(topic, body)
+	if err != nil {
+		return "", models.Observation{}, err
+	}
+
+	observation, err = observation.Normalize()
+	if err != nil {
+		return "", models.Observation{}, err
+	}
+	return city, observation, nil
+}
+
+// errOutOfWindow - время измерения вне допустимого окна
+// Обычно это неверный формат времени в подписке: секунды, прочитанные как миллисекунды,
+// датируют показание 1970 годом, а миллисекунды как секунды - далеким будущим
+var errOutOfWindow = errors.New("timestamp is out of the accepted window")
+
+// checkTimestamp проверяет, что время измерения observed не раньше now - maxPast
+// и не позже now + maxFuture, как у показаний станций
+func checkTimestamp(observed, now time.Time, maxPast, maxFuture time.Duration) error {
+	from, to := now.Add(-maxPast), now.Add(maxFuture)
+	if observed.Before(from) || observed.After(to) {
+		return fmt.Errorf("%w: %s is not between %s and %s", errOutOfWindow,
+			observed.UTC().Format(time.RFC3339), from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// cityOf возвращает город показания: из поля сообщения, уровня темы или подписки
+func (s subscription) cityOf(topic string, body map[string]any) (string, error) {
+	if s.fields.City != "" {
+		if value, ok := lookup(body, s.fields.City); ok {
+			city, isString := value.(string)
+			if !isString {
+				return "", fmt.Errorf("field %s must be a string", s.fields.City)
+			}
+			if city = strings.TrimSpace(city); city != "" {
+				return city, nil
+			}
+		}
+	}
+
+	if s.cityLevel >= 0 {
+		// Брокер доставляет только темы, совпавшие с фильтром, поэтому уровень есть
+		if levels := strings.Split(topic, "/"); s.cityLevel < len(levels) && levels[s.cityLevel] != "" {
+			return levels[s.cityLevel], nil
+		}
+	}
+
+	if s.city == "" {
+		return "", errors.New("message does not name a city")
+	}
+	return s.city, nil
+}
+
+// lookup возвращает значение по пути через точку, например data.temp
+// Отсутствующее поле и null не различаются
+func lookup(body map[string]any, path string) (any, bool) {
+	var value any = body
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, value != nil
+}
+
+// number возвращает числовое значение по пути или nil, если его нет
+// Датчики часто присылают числа строками, поэтому строка с числом тоже принимается
+func number(body map[string]any, path string) (*float64, error) {
+	value, ok := lookup(body, path)
+	if !ok {
+		return nil, nil
+	}
+
+	v, err := toFloat(value)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", path, err)
+	}
+	return &v, nil
+}
+
+// toFloat преобразует число JSON или строку с числом в float64
+func toFloat(value any) (float64, error) {
+	var (
+		v   float64
+		err error
+	)
+	switch value := value.(type) {
+	case json.Number:
+		v, err = value.Float64()
+	case string:
+		v, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+	default:
+		return 0, errors.New("must be a number")
+	}
+	// NaN прошел бы проверки диапазонов в Normalize
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errors.New("must be a number")
+	}
+	return v, nil
+}
+
+// timestamp разбирает время измерения в формате format
+func timestamp(value any, format string) (time.Time, error) {
+	if format == config.MQTTTimestampRFC3339 {
+		s, ok := value.(string)
+		if !ok {
+			return time.Time{}, errors.New("must be an RFC 3339 string")
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return time.Time{}, errors.New("must be an RFC 3339 string")
+		}
+		return t, nil
+	}
+
+	v, err := toFloat(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if format == config.MQTTTimestampUnixMilli {
+		return time.UnixMilli(int64(v)), nil
+	}
+	seconds := math.Floor(v)
+	return time.Unix(int64(seconds), int64((v-seconds)*1e9)), nil
+}
@@ -0,0 +1,230 @@
+package mqtt
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		topic     string
+		filter    string
+		cityLevel int
+	}{
+		{topic: "sensors/{city}/+/state", filter: "sensors/+/+/state", cityLevel: 1},
+		{topic: "{city}/#", filter: "+/#", cityLevel: 0},
+		{topic: "home/balcony", filter: "home/balcony", cityLevel: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			s, err := compile(config.MQTTSubscription{Topic: tt.topic, City: "moscow"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.filter != tt.filter || s.cityLevel != tt.cityLevel {
+				t.Errorf("filter = %q, city level %d, want %q, %d", s.filter, s.cityLevel, tt.filter, tt.cityLevel)
+			}
+			if s.fields.Temperature != defaultTemperatureField || s.timestampFormat != config.MQTTTimestampRFC3339 {
+				t.Errorf("defaults not applied: %+v", s)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.MQTTSubscription
+		want string
+	}{
+		{name: "two cities", cfg: config.MQTTSubscription{Topic: "{city}/{city}"}, want: "only once"},
+		{name: "hash in the middle", cfg: config.MQTTSubscription{Topic: "sensors/#/{city}"}, want: "last level"},
+		{name: "partial wildcard", cfg: config.MQTTSubscription{Topic: "sensors/room+/{city}"}, want: "whole level"},
+		{name: "misspelled city", cfg: config.MQTTSubscription{Topic: "sensors/{City}", City: "moscow"}, want: "whole level"},
+		{name: "no city", cfg: config.MQTTSubscription{Topic: "sensors/+"}, want: "city is not set"},
+		{name: "temperature unit", cfg: config.MQTTSubscription{Topic: "{city}", TemperatureUnit: "R"}, want: "temperature_unit"},
+		{name: "wind speed unit", cfg: config.MQTTSubscription{Topic: "{city}", WindSpeedUnit: "bft"}, want: "wind_speed_unit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compile(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	received := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		cfg         config.MQTTSubscription
+		topic       string
+		payload     string
+		city        string
+		temperature float64
+		humidity    float64 // -1, если влажность не передана
+		wind        float64 // -1, если скорость ветра не передана
+		timestamp   time.Time
+	}{
+		{
+			name:    "defaults",
+			cfg:     config.MQTTSubscription{Topic: "sensors/{city}/+"},
+			topic:   "sensors/Moscow/balcony",
+			payload: `{"temperature": -5.5, "humidity": 80, "wind_speed": 12, "timestamp": "2025-01-02T06:00:00+03:00"}`,
+			city:    "Moscow", temperature: -5.5, humidity: 80, wind: 12,
+			timestamp: time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "nested fields and units",
+			cfg: config.MQTTSubscription{
+				Topic:           "sensors/{city}/+",
+				Fields:          config.MQTTFields{Temperature: "data.temp", WindSpeed: "data.wind"},
+				TemperatureUnit: "F",
+				WindSpeedUnit:   "m/s",
+			},
+			topic:   "sensors/omsk/roof",
+			payload: `{"data": {"temp": 14, "wind": 10}}`,
+			city:    "omsk", temperature: -10, humidity: -1, wind: 36,
+			timestamp: received,
+		},
+		{
+			name:    "numeric strings",
+			cfg:     config.MQTTSubscription{Topic: "{city}", TimestampFormat: config.MQTTTimestampUnix},
+			topic:   "kazan",
+			payload: `{"temperature": " 21.5 ", "humidity": "40", "timestamp": "1735787045"}`,
+			city:    "kazan", temperature: 21.5, humidity: 40, wind: -1,
+			timestamp: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name:    "unix milliseconds",
+			cfg:     config.MQTTSubscription{Topic: "{city}", TimestampFormat: config.MQTTTimestampUnixMilli},
+			topic:   "kazan",
+			payload: `{"temperature": 1, "timestamp": 1735787045999}`,
+			city:    "kazan", temperature: 1, humidity: -1, wind: -1,
+			timestamp: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name:    "city from payload",
+			cfg:     config.MQTTSubscription{Topic: "sensors/{city}", Fields: config.MQTTFields{City: "location.city"}},
+			topic:   "sensors/hall",
+			payload: `{"temperature": 1, "location": {"city": "Самара"}}`,
+			city:    "Самара", temperature: 1, humidity: -1, wind: -1,
+			timestamp: received,
+		},
+		{
+			name:    "city from topic without city field",
+			cfg:     config.MQTTSubscription{Topic: "sensors/{city}", Fields: config.MQTTFields{City: "city"}},
+			topic:   "sensors/tver",
+			payload: `{"temperature": 1, "city": ""}`,
+			city:    "tver", temperature: 1, humidity: -1, wind: -1,
+			timestamp: received,
+		},
+		{
+			name:    "fixed city",
+			cfg:     config.MQTTSubscription{Topic: "home/+", City: "moscow"},
+			topic:   "home/kitchen",
+			payload: `{"temperature": 1, "humidity": null}`,
+			city:    "moscow", temperature: 1, humidity: -1, wind: -1,
+			timestamp: received,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := compile(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			city, got, err := s.parse(tt.topic, []byte(tt.payload), received)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if city != tt.city {
+				t.Errorf("city = %q, want %q", city, tt.city)
+			}
+			if math.Abs(*got.Temperature-tt.temperature) > 1e-9 {
+				t.Errorf("temperature = %g, want %g", *got.Temperature, tt.temperature)
+			}
+			if tt.humidity < 0 && got.Humidity != nil || tt.humidity >= 0 && (got.Humidity == nil || *got.Humidity != tt.humidity) {
+				t.Errorf("humidity = %v, want %g", got.Humidity, tt.humidity)
+			}
+			if tt.wind < 0 && got.WindSpeed != nil || tt.wind >= 0 && (got.WindSpeed == nil || math.Abs(*got.WindSpeed-tt.wind) > 1e-9) {
+				t.Errorf("wind speed = %v, want %g", got.WindSpeed, tt.wind)
+			}
+			if !got.Timestamp.Equal(tt.timestamp) {
+				t.Errorf("timestamp = %v, want %v", got.Timestamp, tt.timestamp)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.MQTTSubscription
+		payload string
+		want    string
+	}{
+		{name: "not json", payload: `21.5`, want: "JSON object"},
+		{name: "array", payload: `[{"temperature": 1}]`, want: "JSON object"},
+		{name: "no temperature", payload: `{"temp": 1}`, want: "temperature is required"},
+		{name: "temperature is not a number", payload: `{"temperature": "warm"}`, want: "must be a number"},
+		{name: "temperature is nan", payload: `{"temperature": "NaN"}`, want: "must be a number"},
+		{name: "temperature out of range", payload: `{"temperature": 300}`, want: "temperature must be between"},
+		{name: "humidity is an object", payload: `{"temperature": 1, "humidity": {}}`, want: "humidity"},
+		{name: "unix timestamp string", payload: `{"temperature": 1, "timestamp": "1735787045"}`, want: "RFC 3339"},
+		{name: "city is a number", cfg: config.MQTTSubscription{Fields: config.MQTTFields{City: "city"}}, payload: `{"temperature": 1, "city": 5}`, want: "string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Topic = "sensors/{city}"
+			s, err := compile(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, _, err = s.parse("sensors/moscow", []byte(tt.payload), time.Now())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTimestamp(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		observed time.Time
+		wantErr  bool
+	}{
+		{name: "now", observed: now},
+		{name: "oldest accepted", observed: now.Add(-time.Hour)},
+		{name: "latest accepted", observed: now.Add(time.Minute)},
+		{name: "too old", observed: now.Add(-time.Hour - time.Second), wantErr: true},
+		{name: "unix read as milliseconds", observed: time.UnixMilli(now.Unix()), wantErr: true},
+		{name: "milliseconds read as unix", observed: time.Unix(now.UnixMilli(), 0), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTimestamp(tt.observed, now, time.Hour, time.Minute)
+			if errors.Is(err, errOutOfWindow) != tt.wantErr {
+				t.Errorf("err = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+	"github.com/olezhek28/wether-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WeatherService определяет контракт сохранения показаний датчиков
+// Используется для внедрения зависимости в подписчика MQTT
+type WeatherService interface {
+	ResolveCity(ctx context.Context, city string) (string, error)
+	AddWeather(ctx context.Context, weather models.WeatherDTO) error
+}
+
+// Metrics определяет контракт учета обработанных сообщений
+type Metrics interface {
+	ObserveMQTTMessage(result string)
+}
+
+// Результаты обработки сообщения для метрик
+const (
+	resultSaved       = "saved"         // Показание сохранено
+	resultInvalid     = "invalid"       // Сообщение не удалось разобрать
+	resultOutOfWindow = "out_of_window" // Время измерения вне окна max_past/max_future
+	resultFailed      = "failed"        // Ошибка сохранения
+)
+
+// handleTimeout ограничивает сохранение показания из одного сообщения
+const handleTimeout = 10 * time.Second
+
+// disconnectQuiesce - сколько миллисекунд Stop ждет завершения отправки пакетов клиента
+const disconnectQuiesce = 250
+
+// subscribeFailed - код SUBACK, которым брокер отклоняет подписку
+const subscribeFailed = 0x80
+
+// Subscriber подписывается на темы датчиков и сохраняет показания из их сообщений
+// Клиент переподключается к брокеру автоматически, а подписки оформляются заново
+// при каждом подключении: сессия не сохраняется, поэтому брокер их не помнит
+type Subscriber struct {
+	client        paho.Client
+	service       WeatherService
+	metrics       Metrics
+	subscriptions []subscription
+	qos           byte
+	maxPast       time.Duration
+	maxFuture     time.Duration
+}
+
+// New создает подписчика по конфигурации MQTT
+// Вызывает панику при некорректной подписке, так как конфигурация проверяется при запуске
+func New(cfg config.MQTTConfig, service WeatherService, metrics Metrics) *Subscriber {
+	s := &Subscriber{
+		service:   service,
+		metrics:   metrics,
+		qos:       byte(cfg.QoS),
+		maxPast:   cfg.MaxPast,
+		maxFuture: cfg.MaxFuture,
+	}
+	for _, sc := range cfg.Subscriptions {
+		sub, err := compile(sc)
+		if err != nil {
+			panic(err)
+		}
+		s.subscriptions = append(s.subscriptions, sub)
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID(cfg.ClientID)).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetConnectTimeout(cfg.ConnectTimeout).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(cfg.MaxReconnectInterval).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(cfg.MaxReconnectInterval).
+		SetCleanSession(true).
+		// Сообщения обрабатываются параллельно: медленная запись в базу не задерживает чтение
+		SetOrderMatters(false).
+		SetOnConnectHandler(s.subscribe).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			slog.Warn("mqtt connection lost, reconnecting", "broker", cfg.Broker, "error", err)
+		})
+	s.client = paho.NewClient(opts)
+
+	return s
+}
+
+// Start подключается к брокеру в фоне
+// Недоступный брокер не мешает запуску сервиса: подключение повторяется до успеха или Stop
+func (s *Subscriber) Start() {
+	s.client.Connect()
+}
+
+// Stop отключается от брокера и прекращает переподключения
+func (s *Subscriber) Stop() {
+	s.client.Disconnect(disconnectQuiesce)
+}
+
+// subscribe оформляет подписки после каждого подключения к брокеру
+// Вызывается клиентом в отдельной горутине, поэтому может ждать ответа брокера
+func (s *Subscriber) subscribe(client paho.Client) {
+	slog.Info("mqtt connected", "subscriptions", len(s.subscriptions))
+
+	for _, sub := range s.subscriptions {
+		token := client.Subscribe(sub.filter, s.qos, func(_ paho.Client, msg paho.Message) {
+			s.handle(sub, msg)
+		})
+		token.Wait()
+
+		err := token.Error()
+		if st, ok := token.(*paho.SubscribeToken); ok && err == nil && st.Result()[sub.filter] == subscribeFailed {
+			err = errors.New("broker rejected the subscription")
+		}
+		if err != nil {
+			slog.Error("mqtt subscription failed", "topic", sub.filter, "error", err)
+		}
+	}
+}
+
+// handle сохраняет показание из сообщения msg подписки sub
+// Каждое сообщение - корневой спан трассировки. Некорректное сообщение и показание
+// со временем измерения вне окна пропускаются: датчик пришлет следующее, а повтор
+// того же сообщения ничего не исправит
+func (s *Subscriber) handle(sub subscription, msg paho.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), handleTimeout)
+	defer cancel()
+
+	var err error
+	ctx, span := tracing.Start(ctx, "mqtt.HandleMessage",
+		trace.WithNewRoot(),
+		trace.WithAttributes(attribute.String("messaging.destination.name", msg.Topic())),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	// Записи сервиса и хранилища за время обработки связаны с трассой сообщения
+	log := pkg.WithTrace(ctx, pkg.FromContext(ctx).With("topic", msg.Topic()))
+	ctx = pkg.WithLogger(ctx, log)
+
+	now := time.Now()
+	city, observation, err := sub.parse(msg.Topic(), msg.Payload(), now)
+	if err != nil {
+		log.Warn("mqtt message skipped", "error", err)
+		s.metrics.ObserveMQTTMessage(resultInvalid)
+		return
+	}
+
+	// Показание из 1970 года стало бы самым старым в истории, а из будущего - текущим
+	// на месяцы вперед, поэтому время измерения проверяется до сохранения
+	if err = checkTimestamp(observation.Timestamp, now, s.maxPast, s.maxFuture); err != nil {
+		log.Warn("mqtt reading rejected", "city", city, "error", err)
+		s.metrics.ObserveMQTTMessage(resultOutOfWindow)
+		return
+	}
+
+	if err = s.save(ctx, msg.Topic(), city, observation); err != nil {
+		log.Error("failed to save mqtt reading", "city", city, "error", err)
+		s.metrics.ObserveMQTTMessage(resultFailed)
+		return
+	}
+	s.metrics.ObserveMQTTMessage(resultSaved)
+}
+
+// save сохраняет нормализованное показание датчика с темой topic в ряд города city
+// Тема определяет датчик: показания разных датчиков и Open-Meteo на одно время
+// сохраняются отдельно, а повтор показания того же датчика с тем же временем измерения
+// не создает новой записи, поэтому одно сообщение, полученное несколькими репликами,
+// сохраняется один раз
+func (s *Subscriber) save(ctx context.Context, topic, city string, observation models.Observation) error {
+	key, err := s.service.ResolveCity(ctx, city)
+	if err != nil {
+		return err
+	}
+
+	source := models.SourceMQTT
+	fetchedAt := time.Now().UTC()
+	return s.service.AddWeather(ctx, models.WeatherDTO{
+		Name:        key,
+		Timestamp:   observation.Timestamp,
+		Temperature: *observation.Temperature,
+		Humidity:    observation.Humidity,
+		WindSpeed:   observation.WindSpeed,
+		FetchedAt:   &fetchedAt,
+		Source:      &source,
+		Sensor:      &topic,
+	})
+}
+
+// clientID возвращает идентификатор клиента: префикс из конфигурации и случайный суффикс
+func clientID(prefix string) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%x", prefix, suffix)
+}
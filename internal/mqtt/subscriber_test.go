@@ -0,0 +1,205 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// fakeWeatherService передает сохраненные показания в канал saved
+// Город разрешается нормализацией названия, псевдонимов в тестах нет
+type fakeWeatherService struct {
+	saved chan models.WeatherDTO
+}
+
+func newFakeWeatherService() *fakeWeatherService {
+	return &fakeWeatherService{saved: make(chan models.WeatherDTO, 16)}
+}
+
+func (f *fakeWeatherService) ResolveCity(_ context.Context, city string) (string, error) {
+	if key := models.NormalizeCity(city); key != "" {
+		return key, nil
+	}
+	return "", models.ErrInvalidQuery
+}
+
+func (f *fakeWeatherService) AddWeather(_ context.Context, weather models.WeatherDTO) error {
+	f.saved <- weather
+	return nil
+}
+
+// receive ждет следующее сохраненное показание
+func (f *fakeWeatherService) receive(t *testing.T) models.WeatherDTO {
+	t.Helper()
+
+	select {
+	case weather := <-f.saved:
+		return weather
+	case <-time.After(10 * time.Second):
+		t.Fatal("no reading saved")
+		return models.WeatherDTO{}
+	}
+}
+
+// fakeMetrics передает результаты обработки сообщений в канал results
+type fakeMetrics struct {
+	results chan string
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{results: make(chan string, 16)}
+}
+
+func (f *fakeMetrics) ObserveMQTTMessage(result string) {
+	f.results <- result
+}
+
+// newTestSubscriber запускает подписчика на брокер b до завершения теста
+func newTestSubscriber(t *testing.T, b *testBroker, service WeatherService, subscriptions ...config.MQTTSubscription) *fakeMetrics {
+	t.Helper()
+
+	metrics := newFakeMetrics()
+
+	s := New(config.MQTTConfig{
+		Broker:               b.url(),
+		ClientID:             "weather-service-test",
+		QoS:                  1,
+		ConnectTimeout:       time.Second,
+		MaxReconnectInterval: 100 * time.Millisecond,
+		MaxFuture:            5 * time.Minute,
+		MaxPast:              168 * time.Hour,
+		Subscriptions:        subscriptions,
+	}, service, metrics)
+	s.Start()
+	t.Cleanup(s.Stop)
+	return metrics
+}
+
+func TestSubscriberSavesReadings(t *testing.T) {
+	broker := newTestBroker(t)
+	service := newFakeWeatherService()
+	newTestSubscriber(t, broker, service, config.MQTTSubscription{
+		Topic:           "sensors/{city}/+",
+		Fields:          config.MQTTFields{Temperature: "data.temp"},
+		TemperatureUnit: models.UnitFahrenheit,
+	})
+	broker.waitSubscribed(t, "sensors/+/+")
+
+	// Некорректное сообщение пропускается и не мешает следующим
+	observed := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	broker.publish("sensors/Moscow/balcony", `{"data": {"temp": "warm"}}`)
+	broker.publish("sensors/Moscow/balcony", `{"data": {"temp": 14}, "humidity": 40, "timestamp": "`+observed.Format(time.RFC3339)+`"}`)
+
+	got := service.receive(t)
+	if got.Name != "moscow" || got.Temperature != -10 || got.Humidity == nil || *got.Humidity != 40 {
+		t.Errorf("saved %+v", got)
+	}
+	if !got.Timestamp.Equal(observed) {
+		t.Errorf("timestamp = %v", got.Timestamp)
+	}
+	if got.Source == nil || *got.Source != models.SourceMQTT || got.FetchedAt == nil || got.StationID != nil {
+		t.Errorf("source = %v, fetched at %v, station %v", got.Source, got.FetchedAt, got.StationID)
+	}
+	if got.Sensor == nil || *got.Sensor != "sensors/Moscow/balcony" {
+		t.Errorf("sensor = %v, want the message topic", got.Sensor)
+	}
+}
+
+// Показания двух датчиков города на одно время сохраняются с разными датчиками,
+// поэтому хранилище не считает второе повтором первого
+func TestSubscriberSeparatesSensors(t *testing.T) {
+	broker := newTestBroker(t)
+	service := newFakeWeatherService()
+	newTestSubscriber(t, broker, service, config.MQTTSubscription{Topic: "sensors/{city}/+"})
+	broker.waitSubscribed(t, "sensors/+/+")
+
+	observed := time.Now().UTC().Format(time.RFC3339)
+	broker.publish("sensors/moscow/balcony", `{"temperature": 1, "timestamp": "`+observed+`"}`)
+	broker.publish("sensors/moscow/roof", `{"temperature": 2, "timestamp": "`+observed+`"}`)
+
+	sensors := map[string]float64{}
+	for range 2 {
+		got := service.receive(t)
+		if got.Sensor == nil {
+			t.Fatalf("saved %+v without a sensor", got)
+		}
+		sensors[*got.Sensor] = got.Temperature
+	}
+	if sensors["sensors/moscow/balcony"] != 1 || sensors["sensors/moscow/roof"] != 2 {
+		t.Errorf("sensors = %v", sensors)
+	}
+}
+
+// Показание с временем измерения вне окна, например секунды при timestamp_format: unix_ms,
+// не сохраняется и учитывается в метриках
+func TestSubscriberRejectsOutOfWindow(t *testing.T) {
+	broker := newTestBroker(t)
+	service := newFakeWeatherService()
+	metrics := newTestSubscriber(t, broker, service, config.MQTTSubscription{
+		Topic:           "sensors/{city}/+",
+		TimestampFormat: config.MQTTTimestampUnixMilli,
+	})
+	broker.waitSubscribed(t, "sensors/+/+")
+
+	now := time.Now()
+	broker.publish("sensors/moscow/balcony", fmt.Sprintf(`{"temperature": 1, "timestamp": %d}`, now.Unix()))
+	if got := receiveResult(t, metrics); got != resultOutOfWindow {
+		t.Errorf("result = %q, want %q", got, resultOutOfWindow)
+	}
+
+	broker.publish("sensors/moscow/balcony", fmt.Sprintf(`{"temperature": 2, "timestamp": %d}`, now.UnixMilli()))
+	if got := receiveResult(t, metrics); got != resultSaved {
+		t.Errorf("result = %q, want %q", got, resultSaved)
+	}
+	if got := service.receive(t); got.Temperature != 2 {
+		t.Errorf("saved %+v, want only the reading inside the window", got)
+	}
+}
+
+// receiveResult ждет результат обработки следующего сообщения
+func receiveResult(t *testing.T, metrics *fakeMetrics) string {
+	t.Helper()
+
+	select {
+	case result := <-metrics.results:
+		return result
+	case <-time.After(10 * time.Second):
+		t.Fatal("no message handled")
+		return ""
+	}
+}
+
+// После разрыва соединения подписчик переподключается и снова подписывается на темы
+func TestSubscriberReconnects(t *testing.T) {
+	broker := newTestBroker(t)
+	service := newFakeWeatherService()
+	newTestSubscriber(t, broker, service, config.MQTTSubscription{Topic: "home/#", City: "moscow"})
+	broker.waitSubscribed(t, "home/#")
+
+	broker.publish("home/kitchen", `{"temperature": 21}`)
+	if got := service.receive(t); got.Temperature != 21 {
+		t.Errorf("before reconnect: saved %+v", got)
+	}
+
+	broker.dropClients()
+	broker.waitSubscribed(t, "home/#")
+
+	broker.publish("home/kitchen", `{"temperature": 22}`)
+	if got := service.receive(t); got.Temperature != 22 || got.Name != "moscow" {
+		t.Errorf("after reconnect: saved %+v", got)
+	}
+}
+
+func TestNewPanicsOnInvalidSubscription(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("New did not panic")
+		}
+	}()
+
+	New(config.MQTTConfig{Broker: "tcp://localhost:1883", Subscriptions: []config.MQTTSubscription{{Topic: "sensors/+"}}}, newFakeWeatherService(), newFakeMetrics())
+}
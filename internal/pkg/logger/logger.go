@@ -1,12 +1,58 @@
 package pkg
 
 import (
+	"context"
 	"log/slog"
 	"os"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func SetUpLogger() *slog.Logger {
-	var log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+// loggerKey - ключ контекста, под которым хранится логгер запроса или задачи
+type loggerKey struct{}
+
+// SetUpLogger создает логгер с уровнем и форматом из конфигурации и делает его логгером
+// по умолчанию для slog и стандартного пакета log
+// Вызывает панику при неизвестном уровне, так как конфигурация проверяется при загрузке
+func SetUpLogger(cfg config.LogConfig) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		panic(err)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewJSONHandler(os.Stdout, opts)
+	if cfg.Format == config.LogFormatText {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+
+	log := slog.New(handler)
 	slog.SetDefault(log)
 	return log
 }
+
+// WithLogger возвращает контекст, несущий логгер log
+// Сервисы, хранилище и клиенты внешних API получают его через FromContext,
+// поэтому их записи содержат атрибуты запроса или задачи, например request_id
+func WithLogger(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// FromContext возвращает логгер из контекста или логгер по умолчанию
+func FromContext(ctx context.Context) *slog.Logger {
+	if log, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return log
+	}
+	return slog.Default()
+}
+
+// WithTrace добавляет к логгеру идентификатор трассы активного спана ctx,
+// чтобы записи лога можно было сопоставить с трассой. Без спана возвращает log как есть
+func WithTrace(ctx context.Context, log *slog.Logger) *slog.Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return log
+	}
+	return log.With("trace_id", sc.TraceID().String())
+}
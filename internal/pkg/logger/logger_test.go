@@ -0,0 +1,53 @@
+package pkg
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetUpLogger(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	log := SetUpLogger(config.LogConfig{Level: "warn", Format: config.LogFormatText})
+	if slog.Default() != log {
+		t.Error("logger is not the default")
+	}
+	if log.Enabled(context.Background(), slog.LevelInfo) || !log.Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("level warn is not applied")
+	}
+	if _, ok := log.Handler().(*slog.TextHandler); !ok {
+		t.Errorf("handler %T, want text", log.Handler())
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("context without logger must return the default logger")
+	}
+
+	log := slog.Default().With("request_id", "req-42")
+	if FromContext(WithLogger(context.Background(), log)) != log {
+		t.Error("context logger is not returned")
+	}
+}
+
+func TestWithTrace(t *testing.T) {
+	log := slog.Default()
+	if WithTrace(context.Background(), log) != log {
+		t.Error("logger without span must be returned as is")
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	if WithTrace(ctx, log) == log {
+		t.Error("trace_id is not added for an active span")
+	}
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+)
+
+// AlertReader определяет контракт чтения показаний, по которым определяется фронт условий оповещений
+type AlertReader interface {
+	ReadWeatherByCity(ctx context.Context, city string) (models.WeatherDTO, error)
+	ReadPreviousWeather(ctx context.Context, weather models.WeatherDTO) (models.WeatherDTO, error)
+}
+
+// AliasReader определяет контракт получения ключей городов по нормализованным псевдонимам
+type AliasReader interface {
+	ReadCitiesByAliases(ctx context.Context, aliases []string) (map[string]string, error)
+}
+
+// alertEvaluator проверяет правила оповещений для каждого сохраненного показания
+// Оповещение отправляется по фронту: когда условие правила начинает выполняться
+// Пока условие остается истинным, повторные оповещения не отправляются
+//
+// Состояние условия не хранится в процессе, а вычисляется по предыдущему сохраненному
+// показанию того же источника: Open-Meteo, станции или датчика. Источники одного города
+// расходятся на доли градуса, и сравнение с показанием другого источника давало бы
+// фронт на каждом чередовании. Показание, измеренное раньше последнего сохраненного
+// показания города (пакет станции после потери связи), не описывает текущую погоду
+// и оповещений не вызывает. Правила проверяет только реплика, которая создала показание
+// (повторно полученные показания не сохраняются), поэтому пересечение порога
+// рассылается один раз независимо от количества реплик, а перезапуск сервиса
+// не приводит к повторной рассылке уже активных оповещений
+//
+// Город правила может быть записан любым псевдонимом, а показания приходят с ключом города,
+// поэтому города правил приводятся к ключам через таблицу псевдонимов при проверке
+type alertEvaluator struct {
+	notifier AlertNotifier      // Рассылка оповещений всем репликам
+	readings AlertReader        // Чтение последнего и предыдущего показаний
+	aliases  AliasReader        // Чтение ключей городов по псевдонимам
+	rules    []config.AlertRule // Правила оповещений из конфигурации
+
+	mu     sync.Mutex
+	keys   map[string]string   // Ключи городов правил, найденные среди псевдонимов
+	warned map[string]struct{} // Города правил, о которых уже предупредили, что их нет среди псевдонимов
+}
+
+// newAlertEvaluator проверяет правила и создает alertEvaluator
+// Некорректное правило - ошибка конфигурации, поэтому функция паникует
+// Город правила нормализуется, а ключ города определяется при первой проверке (см. ruleKeys)
+// Правила копируются: срез конфигурации вызывающего остается без изменений
+func newAlertEvaluator(notifier AlertNotifier, readings AlertReader, aliases AliasReader, rules []config.AlertRule) *alertEvaluator {
+	rules = slices.Clone(rules)
+	for i := range rules {
+		rules[i].City = models.NormalizeCity(rules[i].City)
+		if rules[i].Variable == "" {
+			rules[i].Variable = models.VariableTemperature
+		}
+		if err := validateAlertRule(rules[i]); err != nil {
+			panic(fmt.Errorf("alert rule %q: %w", rules[i].Name, err))
+		}
+	}
+
+	return &alertEvaluator{
+		notifier: notifier,
+		readings: readings,
+		aliases:  aliases,
+		rules:    rules,
+		keys:     make(map[string]string),
+		warned:   make(map[string]struct{}),
+	}
+}
+
+// ruleKeys возвращает ключи городов правил по нормализованным названиям из правил
+// Найденный ключ запоминается: ключ геокодированного города не меняется. Город, которого
+// еще нет среди псевдонимов, сравнивается по своему названию (как в ResolveCity) и ищется
+// снова при следующей проверке: он может появиться после первого геокодинга
+// Ошибка чтения псевдонимов только логируется, такие города тоже сравниваются по названию
+func (a *alertEvaluator) ruleKeys(ctx context.Context) map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var unresolved []string
+	for _, rule := range a.rules {
+		if _, ok := a.keys[rule.City]; !ok && !slices.Contains(unresolved, rule.City) {
+			unresolved = append(unresolved, rule.City)
+		}
+	}
+
+	keys := maps.Clone(a.keys)
+	if len(unresolved) == 0 {
+		return keys
+	}
+
+	found, err := a.aliases.ReadCitiesByAliases(ctx, unresolved)
+	if err != nil {
+		pkg.FromContext(ctx).Error("failed to resolve alert rule cities", "error", err)
+	}
+	for _, city := range unresolved {
+		if key, ok := found[city]; ok {
+			a.keys[city], keys[city] = key, key
+			continue
+		}
+
+		keys[city] = city
+		if _, ok := a.warned[city]; !ok && err == nil {
+			a.warned[city] = struct{}{}
+			pkg.FromContext(ctx).Warn("alert rule city is not a known alias yet, matching by name", "city", city)
+		}
+	}
+
+	return keys
+}
+
+// evaluate проверяет правила города показания и рассылает сработавшие оповещения
+// Ошибки чтения и рассылки только логируются: показание к этому моменту уже сохранено
+func (a *alertEvaluator) evaluate(ctx context.Context, weather models.WeatherDTO) {
+	var (
+		prev       *models.WeatherDTO
+		prevLoaded bool
+	)
+
+	if len(a.rules) == 0 {
+		return
+	}
+
+	keys := a.ruleKeys(ctx)
+	for _, rule := range a.rules {
+		if keys[rule.City] != weather.Name {
+			continue
+		}
+
+		value, ok := models.ReadingValue(weather, rule.Variable)
+		if !ok || !models.Compare(value, rule.Op, rule.Threshold) {
+			continue
+		}
+
+		// Предыдущее показание читается один раз и только если какое-то условие выполнено
+		if !prevLoaded {
+			var (
+				stale bool
+				err   error
+			)
+			prev, stale, err = a.previousInSeries(ctx, weather)
+			if err != nil {
+				pkg.FromContext(ctx).Error("failed to read previous reading for alerts", "city", weather.Name, "error", err)
+				return
+			}
+			if stale {
+				return
+			}
+			prevLoaded = true
+		}
+
+		// Условие выполнялось и на предыдущем показании источника - это не фронт
+		if prev != nil {
+			if prevValue, ok := models.ReadingValue(*prev, rule.Variable); ok && models.Compare(prevValue, rule.Op, rule.Threshold) {
+				continue
+			}
+		}
+
+		alert := models.Alert{
+			Channel:   rule.Channel,
+			Rule:      rule.Name,
+			Name:      weather.Name,
+			Variable:  rule.Variable,
+			Operator:  rule.Op,
+			Threshold: rule.Threshold,
+			Value:     value,
+			ReadingID: weather.ID,
+			Timestamp: weather.Timestamp,
+		}
+		if err := a.notifier.NotifyAlert(ctx, alert); err != nil {
+			pkg.FromContext(ctx).Error("failed to notify alert", "rule", rule.Name, "error", err)
+		}
+	}
+}
+
+// previousInSeries возвращает предыдущее показание того же источника, что и weather,
+// или nil, если это первое показание источника
+// stale = true, если в городе уже сохранено показание, измеренное позже weather
+func (a *alertEvaluator) previousInSeries(ctx context.Context, weather models.WeatherDTO) (prev *models.WeatherDTO, stale bool, err error) {
+	latest, err := a.readings.ReadWeatherByCity(ctx, weather.Name)
+	if err != nil {
+		return nil, false, err
+	}
+	if latest.Timestamp.After(weather.Timestamp) {
+		return nil, true, nil
+	}
+
+	previous, err := a.readings.ReadPreviousWeather(ctx, weather)
+	switch {
+	case err == nil:
+		return &previous, false, nil
+	case errors.Is(err, models.ErrCityNotFound):
+		// Первое показание источника: условие раньше не выполнялось
+		return nil, false, nil
+	default:
+		return nil, false, err
+	}
+}
+
+// validateAlertRule проверяет, что правило можно вычислить
+func validateAlertRule(rule config.AlertRule) error {
+	if rule.Channel == "" || rule.City == "" {
+		return fmt.Errorf("channel and city are required")
+	}
+	if !models.IsVariable(rule.Variable) {
+		return fmt.Errorf("unsupported variable %q", rule.Variable)
+	}
+	if !models.IsOperator(rule.Op) {
+		return fmt.Errorf("unsupported operator %q", rule.Op)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// frostRule - правило оповещения о морозе в Москве
+var frostRule = config.AlertRule{Name: "moscow-frost", Channel: "frost", City: "moscow", Op: models.OpLess, Threshold: 0}
+
+// newAlertService создает сервис с правилами оповещений поверх общего хранилища
+// Несколько сервисов над одним fakeStore моделируют реплики или перезапуск
+func newAlertService(store *fakeStore, rules ...config.AlertRule) *WeatherService {
+	return New(store, store, nil, nil, nil, store, rules, config.WeatherConfig{})
+}
+
+func TestAlertsFireOnEdge(t *testing.T) {
+	store := newFakeStore()
+	svc := newAlertService(store, frostRule)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Температуры последовательных показаний и ожидаемое количество оповещений после каждого
+	steps := []struct {
+		temperature float64
+		alerts      int
+	}{
+		{temperature: -1, alerts: 1}, // Первое показание уже ниже порога
+		{temperature: -3, alerts: 1}, // Условие продолжает выполняться
+		{temperature: 2, alerts: 1},  // Условие перестало выполняться
+		{temperature: -2, alerts: 2}, // Новое пересечение порога
+	}
+
+	for i, step := range steps {
+		err := svc.AddWeather(context.Background(), models.WeatherDTO{
+			Name: "moscow", Temperature: step.temperature, Timestamp: start.Add(time.Duration(i) * 15 * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(store.alerts) != step.alerts {
+			t.Fatalf("step %d (%g): %d alerts, want %d", i, step.temperature, len(store.alerts), step.alerts)
+		}
+	}
+
+	last := store.alerts[len(store.alerts)-1]
+	if last.Channel != "frost" || last.Rule != "moscow-frost" || last.Value != -2 || last.ReadingID != 4 {
+		t.Errorf("unexpected alert: %+v", last)
+	}
+}
+
+func TestAlertsSurviveRestartAndReplicas(t *testing.T) {
+	store := newFakeStore()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	if err := newAlertService(store, frostRule).AddWeather(ctx, models.WeatherDTO{Name: "moscow", Temperature: -1, Timestamp: start}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Перезапущенная реплика и другая реплика видят, что условие уже выполнялось
+	for _, svc := range []*WeatherService{newAlertService(store, frostRule), newAlertService(store, frostRule)} {
+		if err := svc.AddWeather(ctx, models.WeatherDTO{Name: "moscow", Temperature: -2, Timestamp: start.Add(15 * time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(store.alerts) != 1 {
+		t.Errorf("got %d alerts, want 1", len(store.alerts))
+	}
+}
+
+func TestAlertsIgnoreOtherCitiesAndRules(t *testing.T) {
+	store := newFakeStore()
+	heat := config.AlertRule{Name: "moscow-heat", Channel: "heat", City: "moscow", Op: models.OpGreaterEqual, Threshold: 30}
+	svc := newAlertService(store, frostRule, heat)
+
+	ctx := context.Background()
+	svc.AddWeather(ctx, models.WeatherDTO{Name: "kazan", Temperature: -10, Timestamp: time.Now()})
+	svc.AddWeather(ctx, models.WeatherDTO{Name: "moscow", Temperature: 30, Timestamp: time.Now()})
+
+	if len(store.alerts) != 1 || store.alerts[0].Rule != "moscow-heat" {
+		t.Errorf("got alerts %+v, want moscow-heat only", store.alerts)
+	}
+}
+
+func TestAlertsResolveRuleCityAlias(t *testing.T) {
+	store := newFakeStore()
+	frost := config.AlertRule{Name: "moscow-frost", Channel: "frost", City: "Москва", Op: models.OpLess, Threshold: 0}
+	svc := newAlertService(store, frost)
+	ctx := context.Background()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Пока город не геокодирован, правило сравнивается по своему названию и не срабатывает
+	svc.AddWeather(ctx, models.WeatherDTO{Name: "moscow", Temperature: 1, Timestamp: start})
+	store.SaveAliases(ctx, "moscow", []string{"moscow", "москва"}, models.AliasSourceGeocoding)
+	svc.AddWeather(ctx, models.WeatherDTO{Name: "moscow", Temperature: -1, Timestamp: start.Add(time.Hour)})
+
+	if len(store.alerts) != 1 || store.alerts[0].Name != "moscow" {
+		t.Errorf("got alerts %+v, want one alert for the key of the alias in the rule", store.alerts)
+	}
+}
+
+func TestAlertsOnDerivedVariable(t *testing.T) {
+	store := newFakeStore()
+	muggy := config.AlertRule{Name: "moscow-muggy", Channel: "muggy", City: "moscow", Variable: models.VariableDewPoint, Op: models.OpGreaterEqual, Threshold: 18}
+	svc := newAlertService(store, muggy)
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	humidity := func(v float64) *float64 { return &v }
+	steps := []models.WeatherDTO{
+		{Temperature: 30},                         // Без влажности точка росы неизвестна
+		{Temperature: 30, Humidity: humidity(40)}, // Точка росы 14.9
+		{Temperature: 30, Humidity: humidity(70)}, // Точка росы 23.9 - пересечение порога
+		{Temperature: 28, Humidity: humidity(80)}, // Точка росы 24.2 - условие продолжает выполняться
+	}
+	for i, weather := range steps {
+		weather.Name, weather.Timestamp = "moscow", start.Add(time.Duration(i)*time.Hour)
+		if err := svc.AddWeather(ctx, weather); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(store.alerts) != 1 || store.alerts[0].Value != 23.9 || store.alerts[0].Variable != models.VariableDewPoint {
+		t.Errorf("got alerts %+v, want one dew point alert at 23.9", store.alerts)
+	}
+}
+
+func TestAlertsTrackEdgePerSource(t *testing.T) {
+	store := newFakeStore()
+	svc := newAlertService(store, frostRule)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	openMeteo, mqtt, roof, sensor := models.SourceOpenMeteo, models.SourceMQTT, "roof", "home/roof"
+	// Open-Meteo держится ниже порога, а датчик на крыше - выше, показания чередуются
+	for i := range 4 {
+		at := start.Add(time.Duration(i) * 15 * time.Minute)
+		if err := svc.AddWeather(ctx, models.WeatherDTO{Name: "moscow", Temperature: -0.5, Timestamp: at, Source: &openMeteo}); err != nil {
+			t.Fatal(err)
+		}
+		station := models.WeatherDTO{Name: "moscow", Temperature: 0.5, Timestamp: at.Add(5 * time.Minute), Source: &mqtt, StationID: &roof, Sensor: &sensor}
+		if err := svc.AddWeather(ctx, station); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(store.alerts) != 1 || store.alerts[0].ReadingID != 1 {
+		t.Errorf("got alerts %+v, want one alert for the first Open-Meteo reading", store.alerts)
+	}
+}
+
+func TestAlertsSkipReadingsOlderThanLatest(t *testing.T) {
+	store := newFakeStore()
+	svc := newAlertService(store, frostRule)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	openMeteo, mqtt, roof := models.SourceOpenMeteo, models.SourceMQTT, "roof"
+	if err := svc.AddWeather(ctx, models.WeatherDTO{Name: "moscow", Temperature: 2, Timestamp: start.Add(time.Hour), Source: &openMeteo}); err != nil {
+		t.Fatal(err)
+	}
+	// Станция после потери связи досылает накопленное показание часовой давности
+	if err := svc.AddWeather(ctx, models.WeatherDTO{Name: "moscow", Temperature: -3, Timestamp: start, Source: &mqtt, StationID: &roof}); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.alerts) != 0 {
+		t.Fatalf("got alerts %+v for a backfilled reading, want none", store.alerts)
+	}
+
+	// Досланное показание сохранено и остается предыдущим для следующих показаний станции
+	for i, temperature := range []float64{-1, 1, -2} {
+		at := start.Add(time.Duration(i+2) * time.Hour)
+		if err := svc.AddWeather(ctx, models.WeatherDTO{Name: "moscow", Temperature: temperature, Timestamp: at, Source: &mqtt, StationID: &roof}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(store.alerts) != 1 || store.alerts[0].Value != -2 {
+		t.Errorf("got alerts %+v, want one alert at -2", store.alerts)
+	}
+}
+
+func TestNewAlertEvaluatorKeepsConfigRules(t *testing.T) {
+	rules := []config.AlertRule{{Name: "moscow-frost", Channel: "frost", City: " Москва ", Op: models.OpLess}}
+	want := slices.Clone(rules)
+
+	evaluator := newAlertEvaluator(nil, nil, nil, rules)
+
+	if !slices.Equal(rules, want) {
+		t.Errorf("config rules changed to %+v, want %+v", rules, want)
+	}
+	if got := evaluator.rules[0]; got.City != "москва" || got.Variable != models.VariableTemperature {
+		t.Errorf("evaluator rule = %+v, want normalized city and default variable", got)
+	}
+}
+
+func TestNewAlertEvaluatorRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.AlertRule
+	}{
+		{name: "no channel", rule: config.AlertRule{City: "moscow", Op: models.OpLess}},
+		{name: "no city", rule: config.AlertRule{Channel: "frost", Op: models.OpLess}},
+		{name: "unknown variable", rule: config.AlertRule{Channel: "frost", City: "moscow", Variable: "pressure", Op: models.OpLess}},
+		{name: "unknown operator", rule: config.AlertRule{Channel: "frost", City: "moscow", Op: "=="}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("invalid rule was accepted")
+				}
+			}()
+			newAlertEvaluator(nil, nil, nil, []config.AlertRule{tt.rule})
+		})
+	}
+}
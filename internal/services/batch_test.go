@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+func TestGetWeatherBatch(t *testing.T) {
+	now := time.Now().UTC()
+	fetched := now
+	store := newFakeStore()
+	store.readings = []models.WeatherDTO{
+		{ID: 1, Name: "moscow", Temperature: -5, Timestamp: now.Add(-time.Hour), FetchedAt: &fetched},
+		{ID: 2, Name: "moscow", Temperature: -3, Timestamp: now.Add(-15 * time.Minute), FetchedAt: &fetched},
+		{ID: 3, Name: "kazan", Temperature: 1, Timestamp: now.Add(-15 * time.Minute), FetchedAt: &fetched},
+	}
+	// omsk нет в хранилище, он запрашивается из внешних API
+	upstream := &fakeUpstream{temperature: 4, observedAt: openMeteoTime(now)}
+	svc := newRefreshService(store, upstream, config.WeatherConfig{MaxAge: time.Hour})
+
+	results, err := svc.GetWeatherBatch(context.Background(), []string{"moscow", "omsk", "kazan", "moscow", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if store.batchReads != 1 {
+		t.Errorf("storage was read %d times, want a single query", store.batchReads)
+	}
+	if upstream.calls.Load() != 1 {
+		t.Errorf("upstream was called %d times, want only for omsk", upstream.calls.Load())
+	}
+
+	want := []struct {
+		city        string
+		temperature float64
+	}{{"moscow", -3}, {"omsk", 4}, {"kazan", 1}}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.City != w.city || r.Err != nil || r.Weather == nil || r.Weather.Temperature != w.temperature {
+			t.Errorf("result %d = %+v, want %s at %g", i, r, w.city, w.temperature)
+		}
+	}
+}
+
+func TestGetWeatherBatchInlineErrors(t *testing.T) {
+	store := newFakeStore()
+	upstream := &fakeUpstream{geocodeErr: models.ErrCityNotFound}
+	svc := newRefreshService(store, upstream, config.WeatherConfig{})
+
+	fetched := time.Now()
+	store.readings = []models.WeatherDTO{{ID: 1, Name: "moscow", Temperature: 2, Timestamp: time.Now(), FetchedAt: &fetched}}
+
+	results, err := svc.GetWeatherBatch(context.Background(), []string{"atlantis", "moscow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !errors.Is(results[0].Err, models.ErrCityNotFound) || results[0].Weather != nil {
+		t.Errorf("atlantis = %+v, want ErrCityNotFound", results[0])
+	}
+	if results[1].Err != nil || results[1].Weather == nil {
+		t.Errorf("moscow = %+v, want stored reading", results[1])
+	}
+}
+
+func TestGetWeatherBatchInvalidQuery(t *testing.T) {
+	tooMany := make([]string, maxBatchCities+1)
+	for i := range tooMany {
+		tooMany[i] = "city" + strings.Repeat("x", i)
+	}
+
+	for name, cities := range map[string][]string{
+		"empty":    nil,
+		"blank":    {"", ""},
+		"too many": tooMany,
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := newFakeStore()
+			_, err := newTestService(store).GetWeatherBatch(context.Background(), cities)
+			if !errors.Is(err, models.ErrInvalidQuery) {
+				t.Errorf("got %v, want ErrInvalidQuery", err)
+			}
+			if store.batchReads != 0 {
+				t.Error("invalid query reached storage")
+			}
+		})
+	}
+}
+
+func TestGetWeatherBatchStorageError(t *testing.T) {
+	store := newFakeStore()
+	store.err = errors.New("connection refused")
+
+	if _, err := newTestService(store).GetWeatherBatch(context.Background(), []string{"moscow"}); !errors.Is(err, store.err) {
+		t.Errorf("got %v, want storage error", err)
+	}
+}
+
+func TestGetStoredWeather(t *testing.T) {
+	store := newFakeStore()
+	upstream := &fakeUpstream{temperature: 4, observedAt: openMeteoTime(time.Now().UTC())}
+	svc := newRefreshService(store, upstream, config.WeatherConfig{MaxAge: time.Minute})
+
+	// Показание старше MaxAge отдается как есть, без обращения к внешним API
+	fetched := time.Now().Add(-time.Hour)
+	store.readings = []models.WeatherDTO{{ID: 1, Name: "moscow", Temperature: 2, Timestamp: fetched, FetchedAt: &fetched}}
+
+	results, err := svc.GetStoredWeather(context.Background(), []string{"moscow", "omsk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upstream.calls.Load() != 0 {
+		t.Errorf("upstream was called %d times, want none", upstream.calls.Load())
+	}
+	if results[0].Err != nil || results[0].Weather == nil || results[0].Weather.Temperature != 2 {
+		t.Errorf("moscow = %+v, want the stored reading", results[0])
+	}
+	if !errors.Is(results[1].Err, models.ErrCityNotFound) || results[1].Weather != nil {
+		t.Errorf("omsk = %+v, want ErrCityNotFound", results[1])
+	}
+}
+
+func TestUniqueCities(t *testing.T) {
+	got := uniqueCities([]string{"b", "a", "", "b", "c", "a"})
+	if want := []string{"b", "a", "c"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/olezhek28/wether-service/internal/clients"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+)
+
+// ResolveCity возвращает ключ города, под которым хранятся показания, по названию из запроса
+// Название нормализуется (models.NormalizeCity) и ищется среди псевдонимов. Название,
+// которого нет среди псевдонимов, само становится ключом: город с ним еще не геокодирован,
+// и при первом обновлении RefreshWeather свяжет его с известным местом, если оно совпадет
+func (w *WeatherService) ResolveCity(ctx context.Context, city string) (string, error) {
+	key := models.NormalizeCity(city)
+	if key == "" {
+		return "", fmt.Errorf("%w: city must not be empty", models.ErrInvalidQuery)
+	}
+
+	name, err := w.weatherProvider.ReadCityByAlias(ctx, key)
+	switch {
+	case err == nil:
+		return name, nil
+	case errors.Is(err, models.ErrCityNotFound):
+		return key, nil
+	default:
+		return "", err
+	}
+}
+
+// resolveCities возвращает ключи городов по названиям из запроса одним чтением псевдонимов
+// Результат содержит ключ для каждого непустого названия
+func (w *WeatherService) resolveCities(ctx context.Context, cities []string) (map[string]string, error) {
+	keys := make(map[string]string, len(cities))
+	aliases := make([]string, 0, len(cities))
+	for _, city := range cities {
+		if key := models.NormalizeCity(city); key != "" {
+			keys[city] = key
+			aliases = append(aliases, key)
+		}
+	}
+
+	names, err := w.weatherProvider.ReadCitiesByAliases(ctx, aliases)
+	if err != nil {
+		return nil, err
+	}
+	for city, key := range keys {
+		if name, ok := names[key]; ok {
+			keys[city] = name
+		}
+	}
+
+	return keys, nil
+}
+
+// saveLocation сохраняет местоположение города по результату геокодинга и его псевдонимы
+// Возвращает ключ, под которым сохранять показания: если место уже известно под другим
+// ключом (город запрошен по названию на другом языке), показания пишутся в его ряд,
+// а название из запроса становится псевдонимом. Для нового места псевдонимами становятся
+// и его названия на языках weather.alias_languages
+func (w *WeatherService) saveLocation(ctx context.Context, key, query string, geo clients.GeocodingResponse) (string, error) {
+	known := false
+	if geo.ID != 0 {
+		name, err := w.weatherProvider.ReadCityByGeoID(ctx, geo.ID)
+		switch {
+		case err == nil:
+			key, known = name, true
+		case !errors.Is(err, models.ErrCityNotFound):
+			return "", err
+		}
+	}
+
+	location := models.Location{
+		Name:      geo.Name,
+		Country:   geo.Country,
+		Latitude:  geo.Latitude,
+		Longitude: geo.Longitude,
+	}
+	if err := w.weatherSaver.SaveLocation(ctx, key, geo.ID, location); err != nil {
+		return "", err
+	}
+
+	aliases := []string{key, models.NormalizeCity(query), models.NormalizeCity(geo.Name)}
+	if !known && geo.ID != 0 {
+		aliases = append(aliases, w.localizedAliases(ctx, query, geo.ID)...)
+	}
+	slices.Sort(aliases)
+	aliases = slices.Compact(aliases)
+	if aliases[0] == "" {
+		aliases = aliases[1:]
+	}
+
+	taken, err := w.weatherSaver.SaveAliases(ctx, key, aliases, models.AliasSourceGeocoding)
+	if err != nil {
+		return "", err
+	}
+	if len(taken) > 0 {
+		// Одноименные места (например, Paris во Франции и в Техасе): название остается за первым
+		pkg.FromContext(ctx).Debug("aliases belong to other cities", "city", key, "aliases", taken)
+	}
+
+	return key, nil
+}
+
+// localizedAliases возвращает нормализованные названия места geoID на языках weather.alias_languages
+// Ошибки геокодинга только логируются: без псевдонимов город все равно находится по ключу
+func (w *WeatherService) localizedAliases(ctx context.Context, query string, geoID int64) []string {
+	var aliases []string
+	for _, language := range w.config.AliasLanguages {
+		res, err := w.geocoder.GetLocalized(ctx, query, language)
+		if err != nil {
+			pkg.FromContext(ctx).Warn("failed to geocode localized name", "city", query, "language", language, "error", err)
+			continue
+		}
+		// Поиск на другом языке может найти другое место, его название псевдонимом не становится
+		if res.ID == geoID {
+			aliases = append(aliases, models.NormalizeCity(res.Name))
+		}
+	}
+	return aliases
+}
+
+// ListAliases возвращает псевдонимы города
+// Возвращает models.ErrCityNotFound, если у города нет псевдонимов (он не геокодирован)
+func (w *WeatherService) ListAliases(ctx context.Context, city string) ([]models.CityAlias, error) {
+	key, err := w.ResolveCity(ctx, city)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := w.weatherProvider.ReadAliases(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(aliases) == 0 {
+		return nil, models.ErrCityNotFound
+	}
+
+	return aliases, nil
+}
+
+// AddAlias добавляет городу псевдоним вручную
+// Город должен быть геокодирован. Повторное добавление псевдонима того же города
+// не является ошибкой, псевдоним другого города - ErrAliasTaken
+func (w *WeatherService) AddAlias(ctx context.Context, city, alias string) (models.CityAlias, error) {
+	aliases, err := w.ListAliases(ctx, city)
+	if err != nil {
+		return models.CityAlias{}, err
+	}
+	key := aliases[0].City
+
+	normalized := models.NormalizeCity(alias)
+	if normalized == "" {
+		return models.CityAlias{}, fmt.Errorf("%w: alias must not be empty", models.ErrInvalidQuery)
+	}
+
+	taken, err := w.weatherSaver.SaveAliases(ctx, key, []string{normalized}, models.AliasSourceManual)
+	if err != nil {
+		return models.CityAlias{}, err
+	}
+	if len(taken) > 0 {
+		return models.CityAlias{}, models.ErrAliasTaken
+	}
+
+	aliases, err = w.weatherProvider.ReadAliases(ctx, key)
+	if err != nil {
+		return models.CityAlias{}, err
+	}
+	i := slices.IndexFunc(aliases, func(a models.CityAlias) bool { return a.Alias == normalized })
+	if i < 0 {
+		// Псевдоним удалили между записью и чтением
+		return models.CityAlias{}, models.ErrCityNotFound
+	}
+
+	return aliases[i], nil
+}
+
+// DeleteAlias удаляет псевдоним города
+// Ключ города удалить нельзя: по нему город находится всегда
+func (w *WeatherService) DeleteAlias(ctx context.Context, city, alias string) error {
+	key, err := w.ResolveCity(ctx, city)
+	if err != nil {
+		return err
+	}
+
+	normalized := models.NormalizeCity(alias)
+	if normalized == key {
+		return fmt.Errorf("%w: the city key cannot be removed", models.ErrInvalidQuery)
+	}
+
+	return w.weatherSaver.DeleteAlias(ctx, key, normalized)
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// moscowGeoID - идентификатор Москвы в GeoNames, который возвращает Open-Meteo
+const moscowGeoID = 524901
+
+// aliasNames возвращает псевдонимы города из fakeStore
+func aliasNames(t *testing.T, store *fakeStore, city string) []string {
+	t.Helper()
+
+	aliases, err := store.ReadAliases(context.Background(), city)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(aliases))
+	for _, a := range aliases {
+		names = append(names, a.Alias)
+	}
+	return names
+}
+
+func TestResolveCity(t *testing.T) {
+	store := newFakeStore()
+	store.SaveAliases(context.Background(), "moscow", []string{"moscow", "москва"}, models.AliasSourceGeocoding)
+	svc := newTestService(store)
+
+	tests := []struct {
+		city string
+		want string
+	}{
+		{city: "Moscow", want: "moscow"},
+		{city: " МОСКВА ", want: "moscow"},
+		// Неизвестное название становится ключом как есть, после нормализации
+		{city: "Kazan", want: "kazan"},
+	}
+	for _, tt := range tests {
+		got, err := svc.ResolveCity(context.Background(), tt.city)
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveCity(%q) = %q, %v; want %q", tt.city, got, err, tt.want)
+		}
+	}
+
+	if _, err := svc.ResolveCity(context.Background(), " \t"); !errors.Is(err, models.ErrInvalidQuery) {
+		t.Errorf("blank city: err = %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestRefreshWeatherSavesLocalizedAliases(t *testing.T) {
+	store := newFakeStore()
+	upstream := &fakeUpstream{
+		observedAt: openMeteoTime(time.Now()),
+		geoID:      moscowGeoID,
+		localized:  map[string]string{"en": "Moscow", "ru": "Москва"},
+	}
+	svc := newRefreshService(store, upstream, config.WeatherConfig{MaxAge: time.Minute, AliasLanguages: []string{"en", "ru"}})
+
+	dto, err := svc.RefreshWeather(context.Background(), "Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dto.Name != "moscow" {
+		t.Errorf("reading name = %q, want the city key", dto.Name)
+	}
+	if got, want := aliasNames(t, store, "moscow"), []string{"moscow", "москва"}; !slices.Equal(got, want) {
+		t.Errorf("aliases = %v, want %v", got, want)
+	}
+
+	// Название на другом языке находит сохраненное показание без обращения к внешним API
+	calls := upstream.calls.Load()
+	weather, err := svc.GetWeather(context.Background(), "москва")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weather.Name != "moscow" || upstream.calls.Load() != calls {
+		t.Errorf("got %q with %d upstream calls, want the stored moscow reading", weather.Name, upstream.calls.Load()-calls)
+	}
+}
+
+func TestRefreshWeatherLinksKnownPlace(t *testing.T) {
+	store := newFakeStore()
+	upstream := &fakeUpstream{observedAt: openMeteoTime(time.Now()), geoID: moscowGeoID}
+	svc := newRefreshService(store, upstream, config.WeatherConfig{MaxAge: time.Minute})
+	ctx := context.Background()
+
+	if _, err := svc.RefreshWeather(ctx, "Moscow"); err != nil {
+		t.Fatal(err)
+	}
+
+	// "Moskau" нет среди псевдонимов, но геокодинг находит то же место:
+	// показание пишется в ряд moscow, а название становится псевдонимом
+	dto, err := svc.RefreshWeather(ctx, "Moskau")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dto.Name != "moscow" {
+		t.Errorf("reading name = %q, want moscow", dto.Name)
+	}
+	if _, ok := store.locations["moskau"]; ok {
+		t.Error("a second location was created for the same place")
+	}
+	if key, err := svc.ResolveCity(ctx, "MOSKAU"); err != nil || key != "moscow" {
+		t.Errorf("ResolveCity(MOSKAU) = %q, %v; want moscow", key, err)
+	}
+}
+
+func TestGetWeatherBatchResolvesAliases(t *testing.T) {
+	now := time.Now().UTC()
+	store := newFakeStore()
+	store.readings = []models.WeatherDTO{{ID: 1, Name: "moscow", Temperature: -3, Timestamp: now, FetchedAt: &now}}
+	store.SaveAliases(context.Background(), "moscow", []string{"moscow", "москва"}, models.AliasSourceGeocoding)
+	svc := newRefreshService(store, &fakeUpstream{}, config.WeatherConfig{MaxAge: time.Hour})
+
+	results, err := svc.GetWeatherBatch(context.Background(), []string{"Москва", "moscow", " "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	// Результаты сохраняют названия из запроса, а показание берется из ряда ключа
+	for _, r := range results[:2] {
+		if r.Err != nil || r.Weather == nil || r.Weather.Name != "moscow" {
+			t.Errorf("%q: got %+v, want the moscow reading", r.City, r)
+		}
+	}
+	if results[0].City != "Москва" {
+		t.Errorf("city = %q, want the requested name", results[0].City)
+	}
+	if !errors.Is(results[2].Err, models.ErrInvalidQuery) {
+		t.Errorf("blank city: err = %v, want ErrInvalidQuery", results[2].Err)
+	}
+}
+
+func TestManageAliases(t *testing.T) {
+	store := newFakeStore()
+	store.SaveAliases(context.Background(), "moscow", []string{"moscow"}, models.AliasSourceGeocoding)
+	store.SaveAliases(context.Background(), "paris", []string{"paris"}, models.AliasSourceGeocoding)
+	svc := newTestService(store)
+	ctx := context.Background()
+
+	alias, err := svc.AddAlias(ctx, "Moscow", " Мск ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alias.Alias != "мск" || alias.City != "moscow" || alias.Source != models.AliasSourceManual {
+		t.Errorf("alias = %+v, want manual мск of moscow", alias)
+	}
+	if key, _ := svc.ResolveCity(ctx, "МСК"); key != "moscow" {
+		t.Errorf("ResolveCity(МСК) = %q, want moscow", key)
+	}
+
+	if _, err := svc.AddAlias(ctx, "moscow", "Paris"); !errors.Is(err, models.ErrAliasTaken) {
+		t.Errorf("alias of another city: err = %v, want ErrAliasTaken", err)
+	}
+	if _, err := svc.AddAlias(ctx, "omsk", "омск"); !errors.Is(err, models.ErrCityNotFound) {
+		t.Errorf("city without aliases: err = %v, want ErrCityNotFound", err)
+	}
+
+	if err := svc.DeleteAlias(ctx, "мск", "moscow"); !errors.Is(err, models.ErrInvalidQuery) {
+		t.Errorf("deleting the key: err = %v, want ErrInvalidQuery", err)
+	}
+	if err := svc.DeleteAlias(ctx, "moscow", "мск"); err != nil {
+		t.Fatal(err)
+	}
+	if key, _ := svc.ResolveCity(ctx, "мск"); key != "мск" {
+		t.Errorf("deleted alias still resolves to %q", key)
+	}
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/clients"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// fakeStore подменяет хранилище в тестах сервиса: сохраняет показания в памяти
+// и реализует WeatherSaver, WeatherProvider и AlertNotifier
+type fakeStore struct {
+	mu        sync.Mutex
+	readings  []models.WeatherDTO
+	locations map[string]models.Location
+	geoIDs    map[int64]string            // Ключи городов по идентификатору места геокодинга
+	aliases   map[string]models.CityAlias // Псевдонимы по нормализованному названию
+	alerts    []models.Alert
+
+	history       []models.HistoryPoint // Ряд, из которого отдаются страницы истории
+	historyQuery  models.HistoryQuery   // Последний запрос истории
+	historyReads  int                   // Количество запросов истории
+	batchReads    int                   // Количество пакетных чтений последних показаний
+	stats         models.Stats
+	statsVariable string    // Переменная последнего запроса статистики
+	statsFrom     time.Time // Диапазон последнего запроса статистики
+	statsTo       time.Time
+	err           error // Ошибка, возвращаемая всеми методами чтения
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		locations: make(map[string]models.Location),
+		geoIDs:    make(map[int64]string),
+		aliases:   make(map[string]models.CityAlias),
+	}
+}
+
+func (f *fakeStore) CreateWeatherCity(_ context.Context, weather models.WeatherDTO) (int64, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Как и уникальный индекс (name, station_id, sensor, timestamp): повтор обновляет время получения
+	for i, r := range f.readings {
+		if r.Name == weather.Name && sameValue(r.StationID, weather.StationID) && sameValue(r.Sensor, weather.Sensor) && r.Timestamp.Equal(weather.Timestamp) {
+			f.readings[i].FetchedAt = weather.FetchedAt
+			return r.ID, false, nil
+		}
+	}
+
+	weather.ID = int64(len(f.readings) + 1)
+	f.readings = append(f.readings, weather)
+	return weather.ID, true, nil
+}
+
+// sameValue сравнивает необязательные поля показаний как индекс с nulls not distinct
+func sameValue(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (f *fakeStore) SaveLocation(_ context.Context, name string, geoID int64, location models.Location) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.locations[name] = location
+	if geoID != 0 {
+		f.geoIDs[geoID] = name
+	}
+	return nil
+}
+
+func (f *fakeStore) SaveAliases(_ context.Context, city string, aliases []string, source string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var taken []string
+	for _, alias := range aliases {
+		existing, ok := f.aliases[alias]
+		switch {
+		case !ok:
+			f.aliases[alias] = models.CityAlias{Alias: alias, City: city, Source: source, CreatedAt: time.Now()}
+		case existing.City != city:
+			taken = append(taken, alias)
+		}
+	}
+	return taken, nil
+}
+
+func (f *fakeStore) DeleteAlias(_ context.Context, city, alias string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if existing, ok := f.aliases[alias]; !ok || existing.City != city {
+		return models.ErrCityNotFound
+	}
+	delete(f.aliases, alias)
+	return nil
+}
+
+func (f *fakeStore) ReadCityByAlias(_ context.Context, alias string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return "", f.err
+	}
+	if a, ok := f.aliases[alias]; ok {
+		return a.City, nil
+	}
+	return "", models.ErrCityNotFound
+}
+
+func (f *fakeStore) ReadCitiesByAliases(_ context.Context, aliases []string) (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	cities := make(map[string]string)
+	for _, alias := range aliases {
+		if a, ok := f.aliases[alias]; ok {
+			cities[alias] = a.City
+		}
+	}
+	return cities, nil
+}
+
+func (f *fakeStore) ReadCityByGeoID(_ context.Context, geoID int64) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if name, ok := f.geoIDs[geoID]; ok {
+		return name, nil
+	}
+	return "", models.ErrCityNotFound
+}
+
+func (f *fakeStore) ReadAliases(_ context.Context, city string) ([]models.CityAlias, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var aliases []models.CityAlias
+	for _, a := range f.aliases {
+		if a.City == city {
+			aliases = append(aliases, a)
+		}
+	}
+	slices.SortFunc(aliases, func(a, b models.CityAlias) int { return strings.Compare(a.Alias, b.Alias) })
+	return aliases, f.err
+}
+
+func (f *fakeStore) NotifyAlert(_ context.Context, alert models.Alert) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.alerts = append(f.alerts, alert)
+	return nil
+}
+
+func (f *fakeStore) ReadWeatherByCity(_ context.Context, city string) (models.WeatherDTO, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return models.WeatherDTO{}, f.err
+	}
+	// Как и хранилище: самое позднее по времени измерения, из равных - последнее сохраненное
+	var latest *models.WeatherDTO
+	for i, r := range f.readings {
+		if r.Name == city && (latest == nil || !r.Timestamp.Before(latest.Timestamp)) {
+			latest = &f.readings[i]
+		}
+	}
+	if latest == nil {
+		return models.WeatherDTO{}, models.ErrCityNotFound
+	}
+	return *latest, nil
+}
+
+func (f *fakeStore) ReadWeatherByCities(_ context.Context, cities []string) ([]models.WeatherDTO, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.batchReads++
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	latest := make(map[string]models.WeatherDTO)
+	for _, r := range f.readings {
+		if slices.Contains(cities, r.Name) {
+			latest[r.Name] = r
+		}
+	}
+	return slices.Collect(maps.Values(latest)), nil
+}
+
+func (f *fakeStore) ReadPreviousWeather(_ context.Context, weather models.WeatherDTO) (models.WeatherDTO, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var prev *models.WeatherDTO
+	for i, r := range f.readings {
+		sameSeries := sameValue(r.Source, weather.Source) && sameValue(r.StationID, weather.StationID) && sameValue(r.Sensor, weather.Sensor)
+		if r.Name == weather.Name && sameSeries && r.Timestamp.Before(weather.Timestamp) && (prev == nil || !r.Timestamp.Before(prev.Timestamp)) {
+			prev = &f.readings[i]
+		}
+	}
+	if prev == nil {
+		return models.WeatherDTO{}, models.ErrCityNotFound
+	}
+	return *prev, nil
+}
+
+func (f *fakeStore) ReadWeatherHistory(_ context.Context, query models.HistoryQuery) ([]models.HistoryPoint, error) {
+	f.historyQuery = query
+	f.historyReads++
+
+	var points []models.HistoryPoint
+	for _, p := range f.history {
+		if p.Timestamp.Before(query.From) || !p.Timestamp.Before(query.To) {
+			continue
+		}
+		if len(points) == query.Limit {
+			break
+		}
+		points = append(points, p)
+	}
+	return points, f.err
+}
+
+func (f *fakeStore) StreamWeatherHistory(_ context.Context, query models.HistoryQuery, fn func(models.HistoryPoint) error) error {
+	f.historyQuery = query
+	f.historyReads++
+	if f.err != nil {
+		return f.err
+	}
+
+	for _, p := range f.history {
+		if p.Timestamp.Before(query.From) || !p.Timestamp.Before(query.To) {
+			continue
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) ReadWeatherSince(_ context.Context, city string, afterID int64, limit int) ([]models.WeatherDTO, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var readings []models.WeatherDTO
+	for _, r := range f.readings {
+		if r.Name == city && r.ID > afterID && len(readings) < limit {
+			readings = append(readings, r)
+		}
+	}
+	return readings, f.err
+}
+
+func (f *fakeStore) ReadWeatherStats(_ context.Context, city, variable string, from, to time.Time) (models.Stats, error) {
+	f.statsVariable, f.statsFrom, f.statsTo = variable, from, to
+	if f.err != nil {
+		return models.Stats{}, f.err
+	}
+	stats := f.stats
+	stats.Name, stats.From, stats.To = city, from, to
+	return stats, nil
+}
+
+func (f *fakeStore) ReadLocations(_ context.Context) ([]models.CityLocation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var locations []models.CityLocation
+	for name, location := range f.locations {
+		locations = append(locations, models.CityLocation{City: name, Location: location})
+	}
+	return locations, f.err
+}
+
+func (f *fakeStore) ReadLocationsWithin(_ context.Context, box models.BoundingBox) ([]models.CityLocation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var locations []models.CityLocation
+	for name, l := range f.locations {
+		inLon := l.Longitude >= box.MinLongitude && l.Longitude <= box.MaxLongitude ||
+			box.MinLongitude > box.MaxLongitude && (l.Longitude >= box.MinLongitude || l.Longitude <= box.MaxLongitude)
+		if l.Latitude >= box.MinLatitude && l.Latitude <= box.MaxLatitude && inLon {
+			locations = append(locations, models.CityLocation{City: name, Location: l})
+		}
+	}
+	return locations, f.err
+}
+
+// fakeUpstream подменяет внешние API (Geocoder и Forecaster) и считает обращения
+// Если задан release, GetTemperature ждет его закрытия, чтобы тест мог собрать
+// одновременные запросы до ответа внешнего API
+type fakeUpstream struct {
+	calls       atomic.Int32
+	release     chan struct{}
+	geocodeErr  error
+	forecastErr error
+	temperature float64
+	observedAt  string            // Время измерения в формате Open-Meteo
+	geoID       int64             // Идентификатор найденного места, 0 - геокодинг его не сообщает
+	localized   map[string]string // Название места по языку для GetLocalized
+}
+
+func (f *fakeUpstream) GetCoordinate(ctx context.Context, city string) (clients.GeocodingResponse, error) {
+	f.calls.Add(1)
+	return f.GetLocalized(ctx, city, "ru")
+}
+
+func (f *fakeUpstream) GetLocalized(_ context.Context, city, language string) (clients.GeocodingResponse, error) {
+	if f.geocodeErr != nil {
+		return clients.GeocodingResponse{}, f.geocodeErr
+	}
+	name := city
+	if localized, ok := f.localized[language]; ok {
+		name = localized
+	}
+	return clients.GeocodingResponse{ID: f.geoID, Name: name, Country: "Russia", Latitude: 55.75, Longitude: 37.62}, nil
+}
+
+func (f *fakeUpstream) GetTemperature(_ context.Context, _, _ float64) (clients.OpenMeteoResponse, error) {
+	if f.release != nil {
+		<-f.release
+	}
+
+	var resp clients.OpenMeteoResponse
+	if f.forecastErr != nil {
+		return resp, f.forecastErr
+	}
+	resp.Current.Time = f.observedAt
+	resp.Current.Temperature2m = f.temperature
+	return resp, nil
+}
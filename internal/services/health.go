@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+)
+
+// DBPinger определяет контракт проверки соединения с базой
+type DBPinger interface {
+	Ping(ctx context.Context) error
+}
+
+// LatestReader определяет контракт чтения последних показаний нескольких городов
+type LatestReader interface {
+	ReadWeatherByCities(ctx context.Context, cities []string) ([]models.WeatherDTO, error)
+}
+
+// CityResolver определяет контракт получения ключа города, под которым хранятся показания
+type CityResolver interface {
+	ResolveCity(ctx context.Context, city string) (string, error)
+}
+
+// Collector определяет контракт получения состояния периодического сбора
+type Collector interface {
+	State() models.CollectorState
+}
+
+// HealthService выполняет проверки готовности реплики
+type HealthService struct {
+	db        DBPinger
+	readings  LatestReader
+	resolver  CityResolver
+	collector Collector
+	cities    []string
+	config    config.HealthConfig
+}
+
+// NewHealth создает сервис проверок готовности
+// cities - города, собираемые по расписанию: свежесть проверяется только для них,
+// а города, однажды запрошенные через GET /{city}, сами не обновляются
+// Названия городов из конфигурации приводятся к ключам через resolver при каждой проверке:
+// город может получить другой ключ после геокодинга
+func NewHealth(db DBPinger, readings LatestReader, resolver CityResolver, collector Collector, cities []string, config config.HealthConfig) *HealthService {
+	return &HealthService{
+		db:        db,
+		readings:  readings,
+		resolver:  resolver,
+		collector: collector,
+		cities:    cities,
+		config:    config,
+	}
+}
+
+// Readiness проверяет соединение с базой, работу планировщика сбора и свежесть
+// показаний собираемых городов. Реплика готова, только если пройдены все проверки
+// Показание считается свежим, если получено не раньше FreshnessIntervals интервалов сбора назад
+func (h *HealthService) Readiness(ctx context.Context) models.Readiness {
+	now := time.Now().UTC()
+	state := h.collector.State()
+	maxAge := time.Duration(h.config.FreshnessIntervals) * state.Interval
+
+	readiness := models.Readiness{
+		Database:  h.checkDatabase(ctx),
+		Scheduler: checkScheduler(state, now),
+		MaxAge:    int64(maxAge.Seconds()),
+	}
+
+	// Без базы свежесть не проверить: города получают ту же ошибку
+	if readiness.Database.Status == models.HealthOK {
+		readiness.Locations = h.checkFreshness(ctx, now, maxAge)
+	} else {
+		for _, city := range h.cities {
+			readiness.Locations = append(readiness.Locations, models.LocationFreshness{
+				HealthCheck: failedCheck("database unavailable"),
+				City:        city,
+			})
+		}
+	}
+
+	readiness.Status = models.HealthOK
+	checks := []models.HealthCheck{readiness.Database, readiness.Scheduler.HealthCheck}
+	for _, location := range readiness.Locations {
+		checks = append(checks, location.HealthCheck)
+	}
+	for _, check := range checks {
+		if check.Status != models.HealthOK {
+			readiness.Status = models.HealthFail
+		}
+	}
+
+	return readiness
+}
+
+// checkDatabase проверяет соединение с базой с таймаутом из конфигурации
+func (h *HealthService) checkDatabase(ctx context.Context) models.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, h.config.PingTimeout)
+	defer cancel()
+
+	// Текст ошибки драйвера содержит имя пользователя и базы, а /readyz открыт без ключа,
+	// поэтому подробности только в логе
+	if err := h.db.Ping(ctx); err != nil {
+		pkg.FromContext(ctx).Error("readiness: database ping failed", "error", err)
+		return failedCheck("database ping failed")
+	}
+	return models.HealthCheck{Status: models.HealthOK}
+}
+
+// checkScheduler проверяет, что задача сбора запланирована и не зависла:
+// следующий запуск не должен отставать от текущего времени больше чем на интервал
+func checkScheduler(state models.CollectorState, now time.Time) models.SchedulerCheck {
+	if !state.Scheduled {
+		return models.SchedulerCheck{HealthCheck: failedCheck("collection job is not scheduled")}
+	}
+
+	check := models.SchedulerCheck{
+		HealthCheck: models.HealthCheck{Status: models.HealthOK},
+		NextRun:     &state.NextRun,
+	}
+	if !state.LastRun.IsZero() {
+		check.LastRun = &state.LastRun
+	}
+	if behind := now.Sub(state.NextRun); behind > state.Interval {
+		check.HealthCheck = failedCheck(fmt.Sprintf("collection job is %s behind schedule", behind.Round(time.Second)))
+	}
+	return check
+}
+
+// checkFreshness проверяет возраст последнего показания каждого собираемого города
+// Возраст считается от времени получения показания сервисом, а не от времени измерения:
+// источник обновляет данные реже, чем их может запрашивать сбор
+func (h *HealthService) checkFreshness(ctx context.Context, now time.Time, maxAge time.Duration) []models.LocationFreshness {
+	// Показания хранятся под ключом города, а не под названием из конфигурации
+	keys := make([]string, len(h.cities))
+	resolveErrs := make([]error, len(h.cities))
+	for i, city := range h.cities {
+		keys[i], resolveErrs[i] = h.resolver.ResolveCity(ctx, city)
+		if resolveErrs[i] != nil {
+			pkg.FromContext(ctx).Error("readiness: failed to resolve city", "city", city, "error", resolveErrs[i])
+		}
+	}
+
+	readings, err := h.readings.ReadWeatherByCities(ctx, keys)
+	if err != nil {
+		pkg.FromContext(ctx).Error("readiness: failed to read latest readings", "error", err)
+	}
+
+	latest := make(map[string]models.WeatherDTO, len(readings))
+	for _, r := range readings {
+		latest[r.Name] = r
+	}
+
+	locations := make([]models.LocationFreshness, 0, len(h.cities))
+	for i, city := range h.cities {
+		location := models.LocationFreshness{City: city}
+
+		r, ok := latest[keys[i]]
+		switch {
+		case resolveErrs[i] != nil:
+			location.HealthCheck = failedCheck("failed to resolve city")
+		case err != nil:
+			location.HealthCheck = failedCheck("failed to read latest reading")
+		case !ok:
+			location.HealthCheck = failedCheck("no readings yet")
+		default:
+			fetchedAt := r.ReceivedAt()
+			age := int64(now.Sub(fetchedAt).Seconds())
+			location.FetchedAt = &fetchedAt
+			location.AgeSeconds = &age
+
+			location.HealthCheck = models.HealthCheck{Status: models.HealthOK}
+			if now.Sub(fetchedAt) > maxAge {
+				location.HealthCheck = failedCheck(fmt.Sprintf("latest reading is older than %s", maxAge))
+			}
+		}
+		locations = append(locations, location)
+	}
+
+	return locations
+}
+
+// failedCheck возвращает непройденную проверку с причиной reason
+func failedCheck(reason string) models.HealthCheck {
+	return models.HealthCheck{Status: models.HealthFail, Error: reason}
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// fakePinger подменяет проверку соединения с базой
+type fakePinger struct {
+	err error
+}
+
+func (f fakePinger) Ping(context.Context) error {
+	return f.err
+}
+
+// fakeCollector подменяет планировщик сбора
+type fakeCollector struct {
+	state models.CollectorState
+}
+
+func (f fakeCollector) State() models.CollectorState {
+	return f.state
+}
+
+// healthFixture - хранилище с показаниями и планировщик, которые проходят все проверки
+// Интервал сбора 10 минут, допустимый возраст показаний - 3 интервала
+type healthFixture struct {
+	store     *fakeStore
+	pinger    fakePinger
+	collector fakeCollector
+	cities    []string // Города сбора из конфигурации
+}
+
+func newHealthFixture(now time.Time) *healthFixture {
+	store := newFakeStore()
+	for _, city := range []string{"moscow", "paris"} {
+		fetchedAt := now.Add(-5 * time.Minute)
+		store.readings = append(store.readings, models.WeatherDTO{Name: city, Timestamp: now.Add(-15 * time.Minute), FetchedAt: &fetchedAt})
+	}
+
+	return &healthFixture{
+		store:  store,
+		cities: []string{"moscow", "paris"},
+		collector: fakeCollector{state: models.CollectorState{
+			Scheduled: true,
+			LastRun:   now.Add(-5 * time.Minute),
+			NextRun:   now.Add(5 * time.Minute),
+			Interval:  10 * time.Minute,
+		}},
+	}
+}
+
+func (f *healthFixture) readiness() models.Readiness {
+	health := NewHealth(f.pinger, f.store, newTestService(f.store), f.collector, f.cities, config.HealthConfig{FreshnessIntervals: 3, PingTimeout: time.Second})
+	return health.Readiness(context.Background())
+}
+
+func TestReadinessReady(t *testing.T) {
+	readiness := newHealthFixture(time.Now().UTC()).readiness()
+
+	if !readiness.Ready() {
+		t.Fatalf("want ready, got %+v", readiness)
+	}
+	if readiness.MaxAge != 1800 {
+		t.Errorf("max age = %d, want 1800", readiness.MaxAge)
+	}
+	if len(readiness.Locations) != 2 || readiness.Locations[0].City != "moscow" || readiness.Locations[1].City != "paris" {
+		t.Fatalf("locations = %+v, want moscow and paris in config order", readiness.Locations)
+	}
+	if age := readiness.Locations[0].AgeSeconds; age == nil || *age < 299 || *age > 301 {
+		t.Errorf("age = %v, want about 300 seconds from fetched_at", age)
+	}
+	if readiness.Scheduler.LastRun == nil || readiness.Scheduler.NextRun == nil {
+		t.Errorf("scheduler times are missing: %+v", readiness.Scheduler)
+	}
+}
+
+func TestReadinessResolvesAliases(t *testing.T) {
+	f := newHealthFixture(time.Now().UTC())
+	f.store.SaveAliases(context.Background(), "moscow", []string{"moscow", "москва"}, models.AliasSourceGeocoding)
+	f.cities = []string{"Москва", "paris"}
+
+	readiness := f.readiness()
+	if !readiness.Ready() {
+		t.Fatalf("want ready with a city configured by alias, got %+v", readiness)
+	}
+	if readiness.Locations[0].City != "Москва" || readiness.Locations[0].AgeSeconds == nil {
+		t.Errorf("location = %+v, want the configured name with the age of moscow", readiness.Locations[0])
+	}
+}
+
+func TestReadinessFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(f *healthFixture, now time.Time)
+		check  func(t *testing.T, r models.Readiness)
+	}{
+		{
+			name:   "database down",
+			mutate: func(f *healthFixture, _ time.Time) { f.pinger.err = errors.New("dial tcp: connection refused") },
+			check: func(t *testing.T, r models.Readiness) {
+				if r.Database.Status != models.HealthFail || r.Database.Error != "database ping failed" {
+					t.Errorf("database = %+v, want failure without driver details", r.Database)
+				}
+				for _, l := range r.Locations {
+					if l.Status != models.HealthFail {
+						t.Errorf("location %s = %+v, want failure without database", l.City, l)
+					}
+				}
+				if r.Scheduler.Status != models.HealthOK {
+					t.Errorf("scheduler = %+v, want ok", r.Scheduler)
+				}
+			},
+		},
+		{
+			name: "scheduler not started",
+			mutate: func(f *healthFixture, _ time.Time) {
+				f.collector.state = models.CollectorState{Interval: 10 * time.Minute}
+			},
+			check: func(t *testing.T, r models.Readiness) {
+				if r.Scheduler.Status != models.HealthFail {
+					t.Errorf("scheduler = %+v, want failure", r.Scheduler)
+				}
+			},
+		},
+		{
+			name:   "scheduler stuck",
+			mutate: func(f *healthFixture, now time.Time) { f.collector.state.NextRun = now.Add(-time.Hour) },
+			check: func(t *testing.T, r models.Readiness) {
+				if r.Scheduler.Status != models.HealthFail {
+					t.Errorf("scheduler = %+v, want failure", r.Scheduler)
+				}
+			},
+		},
+		{
+			name: "stale location",
+			mutate: func(f *healthFixture, now time.Time) {
+				stale := now.Add(-31 * time.Minute)
+				f.store.readings[1].FetchedAt = &stale
+			},
+			check: func(t *testing.T, r models.Readiness) {
+				if r.Locations[0].Status != models.HealthOK || r.Locations[1].Status != models.HealthFail {
+					t.Errorf("locations = %+v, want only paris stale", r.Locations)
+				}
+			},
+		},
+		{
+			name:   "location without readings",
+			mutate: func(f *healthFixture, _ time.Time) { f.store.readings = f.store.readings[:1] },
+			check: func(t *testing.T, r models.Readiness) {
+				if r.Locations[1].Status != models.HealthFail || r.Locations[1].AgeSeconds != nil {
+					t.Errorf("paris = %+v, want failure without age", r.Locations[1])
+				}
+			},
+		},
+		{
+			name: "legacy reading without fetched_at",
+			mutate: func(f *healthFixture, now time.Time) {
+				f.store.readings[1].FetchedAt = nil
+				f.store.readings[1].Timestamp = now.Add(-2 * time.Hour)
+			},
+			check: func(t *testing.T, r models.Readiness) {
+				if r.Locations[1].Status != models.HealthFail {
+					t.Errorf("paris = %+v, want age from the observation time", r.Locations[1])
+				}
+			},
+		},
+		{
+			name:   "read error",
+			mutate: func(f *healthFixture, _ time.Time) { f.store.err = errors.New("timeout") },
+			check: func(t *testing.T, r models.Readiness) {
+				for _, l := range r.Locations {
+					if l.Status != models.HealthFail {
+						t.Errorf("location %s = %+v, want failure", l.City, l)
+					}
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().UTC()
+			f := newHealthFixture(now)
+			tt.mutate(f, now)
+
+			r := f.readiness()
+			if r.Ready() {
+				t.Fatalf("want not ready, got %+v", r)
+			}
+			tt.check(t, r)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// KeyStore определяет контракт хранилища ключей API
+type KeyStore interface {
+	CreateKey(ctx context.Context, key models.APIKey, hash []byte) (models.APIKey, error)
+	ReadKeyByHash(ctx context.Context, hash []byte) (models.APIKey, error)
+	ReadKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeKey(ctx context.Context, id int64) error
+	RecordUsage(ctx context.Context, id int64) (models.KeyCounters, error)
+	ReadKeyUsage(ctx context.Context, id int64, from time.Time) ([]models.KeyUsage, error)
+}
+
+// Параметры секретов ключей API
+const (
+	keySecretPrefix = "wsk_" // Отличает ключи сервиса от других секретов, например в сканерах утечек
+	keySecretBytes  = 32     // Случайная часть секрета
+	keyPrefixLength = 12     // Сколько первых символов секрета хранится открыто
+	maxUsageDays    = 366    // Максимальная глубина истории использования в одном запросе
+)
+
+// KeyService управляет ключами API и проверяет их при запросах
+type KeyService struct {
+	store  KeyStore
+	config config.AuthConfig
+}
+
+// NewKeys создает сервис ключей API поверх хранилища
+func NewKeys(store KeyStore, config config.AuthConfig) *KeyService {
+	return &KeyService{
+		store:  store,
+		config: config,
+	}
+}
+
+// CreateKey создает ключ и возвращает его вместе с секретом
+// Секрет возвращается только здесь: в хранилище попадает лишь его хэш
+func (k *KeyService) CreateKey(ctx context.Context, params models.NewAPIKey) (models.CreatedAPIKey, error) {
+	if params.Name == "" {
+		return models.CreatedAPIKey{}, fmt.Errorf("%w: name is required", models.ErrInvalidQuery)
+	}
+	if len(params.Scopes) == 0 {
+		return models.CreatedAPIKey{}, fmt.Errorf("%w: at least one scope is required", models.ErrInvalidQuery)
+	}
+	for _, scope := range params.Scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			return models.CreatedAPIKey{}, fmt.Errorf("%w: unknown scope %q", models.ErrInvalidQuery, scope)
+		}
+	}
+	if params.PerMinute < 0 || params.PerDay < 0 {
+		return models.CreatedAPIKey{}, fmt.Errorf("%w: quotas must not be negative", models.ErrInvalidQuery)
+	}
+
+	random := make([]byte, keySecretBytes)
+	rand.Read(random)
+	secret := keySecretPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	scopes := slices.Clone(params.Scopes)
+	slices.Sort(scopes)
+
+	key, err := k.store.CreateKey(ctx, models.APIKey{
+		Name:      params.Name,
+		Prefix:    secret[:keyPrefixLength],
+		Scopes:    slices.Compact(scopes),
+		PerMinute: params.PerMinute,
+		PerDay:    params.PerDay,
+	}, hashSecret(secret))
+	if err != nil {
+		return models.CreatedAPIKey{}, err
+	}
+
+	return models.CreatedAPIKey{Key: key, Secret: secret}, nil
+}
+
+// ListKeys возвращает все ключи, включая отозванные
+func (k *KeyService) ListKeys(ctx context.Context) ([]models.APIKey, error) {
+	return k.store.ReadKeys(ctx)
+}
+
+// RevokeKey отзывает ключ: следующие запросы с ним получают ErrUnauthorized
+func (k *KeyService) RevokeKey(ctx context.Context, id int64) error {
+	return k.store.RevokeKey(ctx, id)
+}
+
+// GetKeyUsage возвращает суточное использование ключа за последние days суток (UTC), включая текущие
+func (k *KeyService) GetKeyUsage(ctx context.Context, id int64, days int) ([]models.KeyUsage, error) {
+	if days < 1 || days > maxUsageDays {
+		return nil, fmt.Errorf("%w: days must be between 1 and %d", models.ErrInvalidQuery, maxUsageDays)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	return k.store.ReadKeyUsage(ctx, id, today.AddDate(0, 0, 1-days))
+}
+
+// Authenticate проверяет секрет ключа и его область доступа и учитывает запрос в квотах
+// Запрос без нужной области возвращает ErrForbidden и в квотах не учитывается
+// При исчерпанной квоте возвращается ErrQuotaExceeded вместе с состоянием квот,
+// чтобы вызывающий мог сообщить клиенту, когда повторить запрос
+func (k *KeyService) Authenticate(ctx context.Context, secret string, scope string) (models.KeyAccess, error) {
+	if secret == "" {
+		return models.KeyAccess{}, models.ErrUnauthorized
+	}
+
+	// Ключ из конфигурации не хранится в базе и не ограничен квотами
+	if k.config.BootstrapKey != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(k.config.BootstrapKey)) == 1 {
+		access := models.KeyAccess{Key: models.APIKey{Name: "bootstrap", Scopes: []string{models.ScopeAdmin}}}
+		if !access.Key.HasScope(scope) {
+			return access, models.ErrForbidden
+		}
+		return access, nil
+	}
+
+	key, err := k.store.ReadKeyByHash(ctx, hashSecret(secret))
+	if err != nil {
+		return models.KeyAccess{}, err
+	}
+	if key.RevokedAt != nil {
+		return models.KeyAccess{}, models.ErrUnauthorized
+	}
+
+	access := models.KeyAccess{Key: key}
+	if !key.HasScope(scope) {
+		return access, models.ErrForbidden
+	}
+
+	counters, err := k.store.RecordUsage(ctx, key.ID)
+	if err != nil {
+		return models.KeyAccess{}, err
+	}
+
+	access.Quota = quotaState(key, counters, time.Now().UTC())
+	if access.Quota.RetryAfter > 0 {
+		return access, models.ErrQuotaExceeded
+	}
+
+	return access, nil
+}
+
+// quotaState рассчитывает остаток квот после запроса и время до сброса исчерпанной квоты
+// Если исчерпаны обе квоты, клиенту нужно ждать сброса суточной
+func quotaState(key models.APIKey, counters models.KeyCounters, now time.Time) models.Quota {
+	quota := models.Quota{PerMinute: key.PerMinute, PerDay: key.PerDay}
+
+	if key.PerMinute > 0 {
+		quota.RemainingMinute = max(key.PerMinute-counters.Minute, 0)
+		if counters.Minute > key.PerMinute {
+			quota.RetryAfter = now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+		}
+	}
+	if key.PerDay > 0 {
+		quota.RemainingDay = max(key.PerDay-counters.Day, 0)
+		if counters.Day > key.PerDay {
+			quota.RetryAfter = now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+		}
+	}
+
+	return quota
+}
+
+// hashSecret возвращает SHA-256 секрета, под которым ключ хранится в базе
+func hashSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
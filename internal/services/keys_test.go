@@ -0,0 +1,301 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// fakeKeyStore хранит ключи API и счетчики в памяти
+// Счетчики не сбрасываются по времени: тесты задают их явно через counters
+type fakeKeyStore struct {
+	mu       sync.Mutex
+	keys     []models.APIKey
+	hashes   [][]byte
+	counters map[int64]models.KeyCounters
+	err      error
+}
+
+func newFakeKeyStore() *fakeKeyStore {
+	return &fakeKeyStore{counters: make(map[int64]models.KeyCounters)}
+}
+
+func (f *fakeKeyStore) CreateKey(_ context.Context, key models.APIKey, hash []byte) (models.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key.ID = int64(len(f.keys) + 1)
+	key.CreatedAt = time.Now()
+	f.keys = append(f.keys, key)
+	f.hashes = append(f.hashes, hash)
+	return key, f.err
+}
+
+func (f *fakeKeyStore) ReadKeyByHash(_ context.Context, hash []byte) (models.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return models.APIKey{}, f.err
+	}
+	for i, h := range f.hashes {
+		if bytes.Equal(h, hash) {
+			return f.keys[i], nil
+		}
+	}
+	return models.APIKey{}, models.ErrUnauthorized
+}
+
+func (f *fakeKeyStore) ReadKeys(context.Context) ([]models.APIKey, error) {
+	return f.keys, f.err
+}
+
+func (f *fakeKeyStore) RevokeKey(_ context.Context, id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id < 1 || int(id) > len(f.keys) {
+		return models.ErrKeyNotFound
+	}
+	now := time.Now()
+	f.keys[id-1].RevokedAt = &now
+	return nil
+}
+
+func (f *fakeKeyStore) RecordUsage(_ context.Context, id int64) (models.KeyCounters, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.counters[id]
+	c.Minute++
+	c.Day++
+	f.counters[id] = c
+	return c, f.err
+}
+
+func (f *fakeKeyStore) ReadKeyUsage(_ context.Context, id int64, from time.Time) ([]models.KeyUsage, error) {
+	return []models.KeyUsage{{Day: from, Requests: f.counters[id].Day}}, f.err
+}
+
+func TestCreateKeyStoresOnlyHash(t *testing.T) {
+	store := newFakeKeyStore()
+	svc := NewKeys(store, config.AuthConfig{})
+
+	created, err := svc.CreateKey(context.Background(), models.NewAPIKey{Name: "dashboard", Scopes: []string{"write", "read", "read"}, PerMinute: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(created.Secret, keySecretPrefix) || len(created.Secret) < 40 {
+		t.Errorf("weak secret %q", created.Secret)
+	}
+	if created.Key.Prefix != created.Secret[:keyPrefixLength] {
+		t.Errorf("prefix = %q, want the start of the secret", created.Key.Prefix)
+	}
+	if !slices.Equal(created.Key.Scopes, []string{"read", "write"}) {
+		t.Errorf("scopes = %v, want sorted and unique", created.Key.Scopes)
+	}
+	if bytes.Contains(store.hashes[0], []byte(created.Secret)) || !bytes.Equal(store.hashes[0], hashSecret(created.Secret)) {
+		t.Error("store must receive the SHA-256 of the secret only")
+	}
+
+	other, _ := svc.CreateKey(context.Background(), models.NewAPIKey{Name: "bot", Scopes: []string{"read"}})
+	if other.Secret == created.Secret {
+		t.Error("secrets repeat")
+	}
+}
+
+func TestCreateKeyValidation(t *testing.T) {
+	tests := map[string]models.NewAPIKey{
+		"no name":        {Scopes: []string{"read"}},
+		"no scopes":      {Name: "bot"},
+		"unknown scope":  {Name: "bot", Scopes: []string{"root"}},
+		"negative quota": {Name: "bot", Scopes: []string{"read"}, PerMinute: -1},
+	}
+
+	for name, params := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := newFakeKeyStore()
+			_, err := NewKeys(store, config.AuthConfig{}).CreateKey(context.Background(), params)
+			if !errors.Is(err, models.ErrInvalidQuery) || len(store.keys) != 0 {
+				t.Errorf("got %v, want ErrInvalidQuery and nothing stored", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeKeyStore()
+	svc := NewKeys(store, config.AuthConfig{})
+
+	reader, _ := svc.CreateKey(ctx, models.NewAPIKey{Name: "reader", Scopes: []string{models.ScopeRead}, PerMinute: 2, PerDay: 100})
+
+	access, err := svc.Authenticate(ctx, reader.Secret, models.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.Quota{PerMinute: 2, PerDay: 100, RemainingMinute: 1, RemainingDay: 99}
+	if access.Key.ID != reader.Key.ID || access.Quota != want {
+		t.Errorf("access = %+v, want quota %+v", access, want)
+	}
+
+	// Запрос без нужной области не расходует квоту
+	if _, err := svc.Authenticate(ctx, reader.Secret, models.ScopeAdmin); !errors.Is(err, models.ErrForbidden) {
+		t.Errorf("admin scope: got %v, want ErrForbidden", err)
+	}
+	if store.counters[reader.Key.ID].Minute != 1 {
+		t.Errorf("forbidden request was counted: %+v", store.counters[reader.Key.ID])
+	}
+
+	svc.Authenticate(ctx, reader.Secret, models.ScopeRead)
+	access, err = svc.Authenticate(ctx, reader.Secret, models.ScopeRead)
+	if !errors.Is(err, models.ErrQuotaExceeded) {
+		t.Fatalf("third request in a minute: got %v, want ErrQuotaExceeded", err)
+	}
+	if access.Quota.RemainingMinute != 0 || access.Quota.RetryAfter <= 0 || access.Quota.RetryAfter > time.Minute {
+		t.Errorf("quota = %+v, want retry within a minute", access.Quota)
+	}
+
+	if _, err := svc.Authenticate(ctx, "wsk_unknown", models.ScopeRead); !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("unknown secret: got %v, want ErrUnauthorized", err)
+	}
+	if _, err := svc.Authenticate(ctx, "", models.ScopeRead); !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("empty secret: got %v, want ErrUnauthorized", err)
+	}
+
+	svc.RevokeKey(ctx, reader.Key.ID)
+	if _, err := svc.Authenticate(ctx, reader.Secret, models.ScopeRead); !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("revoked key: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestAuthenticateBootstrapKey(t *testing.T) {
+	store := newFakeKeyStore()
+	svc := NewKeys(store, config.AuthConfig{BootstrapKey: "let-me-in"})
+
+	if _, err := svc.Authenticate(context.Background(), "let-me-in", models.ScopeAdmin); err != nil {
+		t.Errorf("admin: %v", err)
+	}
+	// Область admin включает read, поэтому первым ключом можно и проверить чтение
+	if _, err := svc.Authenticate(context.Background(), "let-me-in", models.ScopeRead); err != nil {
+		t.Errorf("read: %v", err)
+	}
+	if len(store.counters) != 0 {
+		t.Error("bootstrap key usage was recorded")
+	}
+
+	// Без настроенного ключа пустой секрет не совпадает с пустым bootstrap-ключом
+	if _, err := NewKeys(store, config.AuthConfig{}).Authenticate(context.Background(), "", models.ScopeAdmin); !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("empty bootstrap key: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestAuthenticateScopeOrder(t *testing.T) {
+	ctx := context.Background()
+	svc := NewKeys(newFakeKeyStore(), config.AuthConfig{})
+
+	tests := []struct {
+		scope   string
+		allowed []string // Области, доступные ключу; остальные - ErrForbidden
+	}{
+		{scope: models.ScopeRead, allowed: []string{models.ScopeRead}},
+		{scope: models.ScopeWrite, allowed: []string{models.ScopeRead, models.ScopeWrite}},
+		{scope: models.ScopeAdmin, allowed: []string{models.ScopeRead, models.ScopeWrite, models.ScopeAdmin}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			key, err := svc.CreateKey(ctx, models.NewAPIKey{Name: tt.scope, Scopes: []string{tt.scope}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, scope := range models.Scopes {
+				_, err := svc.Authenticate(ctx, key.Secret, scope)
+				if allowed := slices.Contains(tt.allowed, scope); allowed && err != nil || !allowed && !errors.Is(err, models.ErrForbidden) {
+					t.Errorf("%s: got %v, allowed %t", scope, err, allowed)
+				}
+			}
+			if _, err := svc.Authenticate(ctx, key.Secret, "owner"); !errors.Is(err, models.ErrForbidden) {
+				t.Errorf("unknown scope: got %v, want ErrForbidden", err)
+			}
+		})
+	}
+}
+
+func TestQuotaState(t *testing.T) {
+	now := time.Date(2025, 1, 1, 23, 59, 30, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		key      models.APIKey
+		counters models.KeyCounters
+		want     models.Quota
+	}{
+		{
+			name:     "unlimited",
+			counters: models.KeyCounters{Minute: 1000, Day: 100000},
+		},
+		{
+			name:     "within limits",
+			key:      models.APIKey{PerMinute: 10, PerDay: 100},
+			counters: models.KeyCounters{Minute: 10, Day: 50},
+			want:     models.Quota{PerMinute: 10, PerDay: 100, RemainingMinute: 0, RemainingDay: 50},
+		},
+		{
+			name:     "minute exceeded",
+			key:      models.APIKey{PerMinute: 10, PerDay: 100},
+			counters: models.KeyCounters{Minute: 11, Day: 50},
+			want:     models.Quota{PerMinute: 10, PerDay: 100, RemainingDay: 50, RetryAfter: 30 * time.Second},
+		},
+		{
+			name:     "both exceeded at the end of the day",
+			key:      models.APIKey{PerMinute: 10, PerDay: 100},
+			counters: models.KeyCounters{Minute: 11, Day: 101},
+			want:     models.Quota{PerMinute: 10, PerDay: 100, RetryAfter: 30 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quotaState(tt.key, tt.counters, now); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// В середине суток исчерпанная суточная квота сбрасывается в полночь UTC
+	noon := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	got := quotaState(models.APIKey{PerDay: 1}, models.KeyCounters{Minute: 1, Day: 2}, noon)
+	if got.RetryAfter != 12*time.Hour {
+		t.Errorf("retry after %s, want 12h", got.RetryAfter)
+	}
+}
+
+func TestGetKeyUsageRange(t *testing.T) {
+	svc := NewKeys(newFakeKeyStore(), config.AuthConfig{})
+
+	usage, err := svc.GetKeyUsage(context.Background(), 1, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if !usage[0].Day.Equal(today.AddDate(0, 0, -6)) {
+		t.Errorf("from = %s, want six days before today", usage[0].Day)
+	}
+
+	for _, days := range []int{0, maxUsageDays + 1} {
+		if _, err := svc.GetKeyUsage(context.Background(), 1, days); !errors.Is(err, models.ErrInvalidQuery) {
+			t.Errorf("days=%d: got %v, want ErrInvalidQuery", days, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/olezhek28/wether-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetNearestWeather возвращает последнее показание ближайшего к координатам отслеживаемого города
+// Кандидаты отбираются из хранилища по прямоугольнику вокруг точки, затем для них
+// считается расстояние по большому кругу и выбирается ближайший город не дальше RadiusKm
+// Показание города получается так же, как в GetWeather (с обновлением устаревших данных)
+// Если в радиусе нет городов, при query.Live погода запрашивается во внешнем API
+// для самих координат и не сохраняется, иначе возвращается ErrNoLocationNearby
+func (w *WeatherService) GetNearestWeather(ctx context.Context, query models.NearestQuery) (_ models.NearestWeather, err error) {
+	ctx, span := tracing.Start(ctx, "WeatherService.GetNearestWeather", trace.WithAttributes(
+		attribute.Float64("geo.latitude", query.Latitude),
+		attribute.Float64("geo.longitude", query.Longitude),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateNearestQuery(query); err != nil {
+		return models.NearestWeather{}, err
+	}
+
+	box := models.BoundingBoxAround(query.Latitude, query.Longitude, query.RadiusKm)
+	candidates, err := w.weatherProvider.ReadLocationsWithin(ctx, box)
+	if err != nil {
+		return models.NearestWeather{}, err
+	}
+
+	var (
+		nearest  *models.CityLocation
+		distance float64
+	)
+	for i, c := range candidates {
+		d := models.Distance(query.Latitude, query.Longitude, c.Location.Latitude, c.Location.Longitude)
+		// Прямоугольник шире круга: кандидаты в его углах дальше радиуса
+		if d > query.RadiusKm {
+			continue
+		}
+		// При равном расстоянии выбор не зависит от порядка строк в ответе базы
+		if nearest == nil || d < distance || (d == distance && c.City < nearest.City) {
+			nearest, distance = &candidates[i], d
+		}
+	}
+
+	if nearest == nil {
+		if !query.Live {
+			return models.NearestWeather{}, fmt.Errorf("%w of %g km", models.ErrNoLocationNearby, query.RadiusKm)
+		}
+		return w.liveWeather(ctx, query.Latitude, query.Longitude)
+	}
+
+	weather, err := w.GetWeather(ctx, nearest.City)
+	if err != nil {
+		return models.NearestWeather{}, err
+	}
+
+	return models.NearestWeather{City: nearest.City, DistanceKm: distance, Weather: weather}, nil
+}
+
+// validateNearestQuery проверяет координаты и радиус поиска
+// Условия записаны через отрицание допустимого диапазона, чтобы отклонять и NaN
+func validateNearestQuery(query models.NearestQuery) error {
+	if !(query.Latitude >= -90 && query.Latitude <= 90) {
+		return fmt.Errorf("%w: lat must be between -90 and 90", models.ErrInvalidQuery)
+	}
+	if !(query.Longitude >= -180 && query.Longitude <= 180) {
+		return fmt.Errorf("%w: lon must be between -180 and 180", models.ErrInvalidQuery)
+	}
+	if !(query.RadiusKm > 0 && query.RadiusKm <= models.MaxDistanceKm) {
+		return fmt.Errorf("%w: radius_km must be between 0 and %.0f", models.ErrInvalidQuery, models.MaxDistanceKm)
+	}
+	return nil
+}
+
+// liveWeather запрашивает текущую погоду для координат во внешнем API
+// У точки нет ключа города, поэтому показание не сохраняется и не попадает в потоки
+func (w *WeatherService) liveWeather(ctx context.Context, lat, lon float64) (models.NearestWeather, error) {
+	openmeteoRes, err := w.forecaster.GetTemperature(ctx, lat, lon)
+	if err != nil {
+		return models.NearestWeather{}, err
+	}
+
+	timestamp, err := time.Parse(openMeteoTimeLayout, openmeteoRes.Current.Time)
+	if err != nil {
+		return models.NearestWeather{}, err
+	}
+
+	fetchedAt := time.Now().UTC()
+	source := models.SourceOpenMeteo
+	dto := models.WeatherDTO{
+		Timestamp:   timestamp,
+		Temperature: openmeteoRes.Current.Temperature2m,
+		Humidity:    openmeteoRes.Current.RelativeHumidity2m,
+		WindSpeed:   openmeteoRes.Current.WindSpeed10m,
+		FetchedAt:   &fetchedAt,
+		Source:      &source,
+		Location:    &models.Location{Latitude: lat, Longitude: lon},
+	}
+
+	return models.NearestWeather{Live: true, Weather: w.presentWeather(dto)}, nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// newNearestStore создает хранилище с городами вокруг Москвы и показанием для каждого
+func newNearestStore() *fakeStore {
+	store := newFakeStore()
+	store.locations = map[string]models.Location{
+		"moscow":   {Name: "Москва", Latitude: 55.7558, Longitude: 37.6173},
+		"khimki":   {Name: "Химки", Latitude: 55.8970, Longitude: 37.4297},
+		"kazan":    {Name: "Казань", Latitude: 55.7963, Longitude: 49.1088},
+		"podolsk":  {Name: "Подольск", Latitude: 55.4242, Longitude: 37.5547},
+		"suva":     {Name: "Сува", Latitude: -18.1416, Longitude: 178.4419},
+		"taveuni":  {Name: "Тавеуни", Latitude: -16.8500, Longitude: -179.9667},
+		"nowhere":  {Name: "Нигде", Latitude: -60, Longitude: 100},
+		"khimki-2": {Name: "Химки", Latitude: 55.8970, Longitude: 37.4297},
+	}
+
+	fetched := time.Now()
+	for name := range store.locations {
+		store.readings = append(store.readings, models.WeatherDTO{
+			ID: int64(len(store.readings) + 1), Name: name, Temperature: 1, Timestamp: time.Now(), FetchedAt: &fetched,
+		})
+	}
+	return store
+}
+
+func TestGetNearestWeather(t *testing.T) {
+	svc := newTestService(newNearestStore())
+
+	tests := []struct {
+		name     string
+		query    models.NearestQuery
+		wantCity string
+		wantKm   float64
+	}{
+		{name: "exact point", query: models.NearestQuery{Latitude: 55.7558, Longitude: 37.6173, RadiusKm: 50}, wantCity: "moscow", wantKm: 0},
+		{name: "closer to khimki", query: models.NearestQuery{Latitude: 55.88, Longitude: 37.45, RadiusKm: 50}, wantCity: "khimki", wantKm: 2.3},
+		{name: "tie is broken by name", query: models.NearestQuery{Latitude: 55.8970, Longitude: 37.4297, RadiusKm: 1}, wantCity: "khimki", wantKm: 0},
+		{name: "across antimeridian", query: models.NearestQuery{Latitude: -16.9, Longitude: 179.9, RadiusKm: 100}, wantCity: "taveuni", wantKm: 15.2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.GetNearestWeather(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.City != tt.wantCity || got.Weather.Name != tt.wantCity || got.Live {
+				t.Errorf("got %+v, want %s", got, tt.wantCity)
+			}
+			if math.Abs(got.DistanceKm-tt.wantKm) > 1 {
+				t.Errorf("distance = %.2f km, want about %g", got.DistanceKm, tt.wantKm)
+			}
+		})
+	}
+}
+
+func TestGetNearestWeatherOutsideRadius(t *testing.T) {
+	store := newNearestStore()
+	svc := newTestService(store)
+
+	// Казань попадает в угол прямоугольника вокруг точки, но лежит за пределами круга:
+	// проверяется отсечение кандидатов по точному расстоянию
+	query := models.NearestQuery{Latitude: 55.7963 + 0.4, Longitude: 49.1088 + 0.7, RadiusKm: 60}
+	box := models.BoundingBoxAround(query.Latitude, query.Longitude, query.RadiusKm)
+	if d := models.Distance(query.Latitude, query.Longitude, 55.7963, 49.1088); d <= query.RadiusKm || 55.7963 < box.MinLatitude || 49.1088 < box.MinLongitude {
+		t.Fatalf("test setup: kazan must be in the box corner, it is %g km away, box %+v", d, box)
+	}
+
+	_, err := svc.GetNearestWeather(context.Background(), query)
+	if !errors.Is(err, models.ErrNoLocationNearby) {
+		t.Errorf("got %v, want ErrNoLocationNearby", err)
+	}
+}
+
+func TestGetNearestWeatherLiveFallback(t *testing.T) {
+	observed := time.Now().UTC().Truncate(15 * time.Minute)
+	store := newNearestStore()
+	upstream := &fakeUpstream{temperature: 12.5, observedAt: openMeteoTime(observed)}
+	svc := newRefreshService(store, upstream, config.WeatherConfig{})
+	readings := len(store.readings)
+
+	got, err := svc.GetNearestWeather(context.Background(), models.NearestQuery{Latitude: 0, Longitude: 0, RadiusKm: 10, Live: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Live || got.City != "" || got.Weather.Temperature != 12.5 || !got.Weather.ObservedAt.Equal(observed) {
+		t.Errorf("unexpected live weather: %+v", got)
+	}
+	if got.Weather.Location == nil || got.Weather.Location.Latitude != 0 || got.Weather.Location.Longitude != 0 {
+		t.Errorf("location = %+v, want the requested coordinates", got.Weather.Location)
+	}
+	if len(store.readings) != readings {
+		t.Error("live reading was stored")
+	}
+}
+
+func TestGetNearestWeatherInvalidQuery(t *testing.T) {
+	tests := map[string]models.NearestQuery{
+		"lat too big":     {Latitude: 91, RadiusKm: 10},
+		"lon too small":   {Longitude: -181, RadiusKm: 10},
+		"nan lat":         {Latitude: math.NaN(), RadiusKm: 10},
+		"zero radius":     {},
+		"radius too big":  {RadiusKm: models.MaxDistanceKm + 1},
+		"negative radius": {RadiusKm: -5},
+	}
+
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := newTestService(newFakeStore()).GetNearestWeather(context.Background(), query)
+			if !errors.Is(err, models.ErrInvalidQuery) {
+				t.Errorf("got %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
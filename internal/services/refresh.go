@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/olezhek28/wether-service/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// openMeteoTimeLayout - формат времени в ответе Open-Meteo (ISO 8601 без секунд, в UTC)
+const openMeteoTimeLayout = "2006-01-02T15:04"
+
+// RefreshWeather запрашивает текущую погоду для города во внешних API и сохраняет ее
+// Выполняет геокодинг города, сохраняет его местоположение и псевдонимы, получает температуру
+// по координатам и сохраняет показание через SaveReading. Возвращает сохраненные данные
+// Показание сохраняется под ключом города: если геокодинг нашел место, уже известное
+// под другим ключом, - под ключом этого места
+func (w *WeatherService) RefreshWeather(ctx context.Context, city string) (_ models.WeatherDTO, err error) {
+	ctx, span := tracing.Start(ctx, "WeatherService.RefreshWeather", trace.WithAttributes(attrCity(city)))
+	defer func() { tracing.End(span, err) }()
+
+	key, err := w.ResolveCity(ctx, city)
+	if err != nil {
+		return models.WeatherDTO{}, err
+	}
+
+	// 1. Получаем координаты города через геокодинг API
+	geocodingRes, err := w.geocoder.GetCoordinate(ctx, city)
+	if err != nil {
+		return models.WeatherDTO{}, err
+	}
+
+	location := models.Location{
+		Name:      geocodingRes.Name,
+		Country:   geocodingRes.Country,
+		Latitude:  geocodingRes.Latitude,
+		Longitude: geocodingRes.Longitude,
+	}
+	if key, err = w.saveLocation(ctx, key, city, geocodingRes); err != nil {
+		return models.WeatherDTO{}, err
+	}
+
+	// 2. Получаем температуру по координатам через OpenMeteo API
+	openmeteoRes, err := w.forecaster.GetTemperature(ctx, geocodingRes.Latitude, geocodingRes.Longitude)
+	if err != nil {
+		return models.WeatherDTO{}, err
+	}
+
+	// 3. Парсим временную метку из строкового формата
+	timestamp, err := time.Parse(openMeteoTimeLayout, openmeteoRes.Current.Time)
+	if err != nil {
+		return models.WeatherDTO{}, err
+	}
+
+	// 4. Сохраняем полученные данные в хранилище вместе со временем получения и источником
+	fetchedAt := time.Now().UTC()
+	source := models.SourceOpenMeteo
+	dto := models.WeatherDTO{
+		Name:        key,
+		Timestamp:   timestamp,
+		Temperature: openmeteoRes.Current.Temperature2m,
+		Humidity:    openmeteoRes.Current.RelativeHumidity2m,
+		WindSpeed:   openmeteoRes.Current.WindSpeed10m,
+		FetchedAt:   &fetchedAt,
+		Source:      &source,
+		Location:    &location,
+	}
+	saved, _, err := w.SaveReading(ctx, dto)
+	return saved, err
+}
+
+// refreshShared вызывает RefreshWeather так, что одновременные запросы для одного
+// города разделяют один поход во внешние API
+// Сам запрос не зависит от отмены контекста первого вызывающего: если клиент
+// отключился, остальные ожидающие все равно получат результат
+func (w *WeatherService) refreshShared(ctx context.Context, city string) (models.WeatherDTO, error) {
+	ch := w.refreshGroup.DoChan(city, func() (any, error) {
+		return w.RefreshWeather(context.WithoutCancel(ctx), city)
+	})
+
+	select {
+	case <-ctx.Done():
+		return models.WeatherDTO{}, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return models.WeatherDTO{}, res.Err
+		}
+		return res.Val.(models.WeatherDTO), nil
+	}
+}
+
+// isExpired сообщает, что сохраненные данные получены раньше допустимого возраста
+// Возраст считается от времени получения, а не измерения: Open-Meteo обновляет
+// текущие данные раз в четверть часа, и при max_age меньше этого интервала время
+// измерения всегда выглядело бы устаревшим. Для старых записей без времени
+// получения используется время измерения
+func (w *WeatherService) isExpired(dto models.WeatherDTO) bool {
+	if w.config.MaxAge <= 0 {
+		return false
+	}
+
+	return time.Since(dto.ReceivedAt()) > w.config.MaxAge
+}
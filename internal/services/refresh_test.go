@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// newRefreshService создает сервис с подмененными внешними API
+func newRefreshService(store *fakeStore, upstream *fakeUpstream, cfg config.WeatherConfig) *WeatherService {
+	return New(store, store, upstream, upstream, nil, store, nil, cfg)
+}
+
+// openMeteoTime форматирует время так, как его возвращает Open-Meteo
+func openMeteoTime(t time.Time) string {
+	return t.UTC().Format(openMeteoTimeLayout)
+}
+
+func TestGetWeatherFetchesMissingCity(t *testing.T) {
+	observed := time.Now().UTC().Truncate(15 * time.Minute)
+	store := newFakeStore()
+	upstream := &fakeUpstream{temperature: -7, observedAt: openMeteoTime(observed)}
+	svc := newRefreshService(store, upstream, config.WeatherConfig{MaxAge: time.Minute})
+
+	weather, err := svc.GetWeather(context.Background(), "moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weather.Temperature != -7 || !weather.ObservedAt.Equal(observed) || weather.Source != models.SourceOpenMeteo {
+		t.Errorf("unexpected weather: %+v", weather)
+	}
+	if weather.Location == nil || weather.Location.Country != "Russia" {
+		t.Errorf("location = %+v, want geocoded location", weather.Location)
+	}
+	if _, ok := store.locations["moscow"]; !ok {
+		t.Error("location was not saved")
+	}
+}
+
+func TestGetWeatherSharesRefresh(t *testing.T) {
+	store := newFakeStore()
+	upstream := &fakeUpstream{
+		release:    make(chan struct{}),
+		observedAt: openMeteoTime(time.Now()),
+	}
+	svc := newRefreshService(store, upstream, config.WeatherConfig{MaxAge: time.Minute})
+
+	const clients = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, clients)
+	for range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.GetWeather(context.Background(), "moscow")
+			errs <- err
+		}()
+	}
+
+	// Ждем, пока первый запрос дойдет до внешнего API, и даем остальным присоединиться
+	for upstream.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(upstream.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := upstream.calls.Load(); got != 1 {
+		t.Errorf("upstream called %d times, want 1", got)
+	}
+	if len(store.readings) != 1 {
+		t.Errorf("saved %d readings, want 1", len(store.readings))
+	}
+}
+
+func TestGetWeatherServesStoredOnRefreshError(t *testing.T) {
+	fetchedAt := time.Now().Add(-2 * time.Hour)
+	store := newFakeStore()
+	store.readings = []models.WeatherDTO{{ID: 1, Name: "moscow", Temperature: 3, Timestamp: fetchedAt, FetchedAt: &fetchedAt}}
+	upstream := &fakeUpstream{forecastErr: errors.New("upstream is down")}
+	svc := newRefreshService(store, upstream, config.WeatherConfig{MaxAge: time.Minute, StaleAfter: time.Hour})
+
+	weather, err := svc.GetWeather(context.Background(), "moscow")
+	if err != nil {
+		t.Fatalf("err = %v, want stored data", err)
+	}
+	if weather.ID != 1 || !weather.Stale {
+		t.Errorf("got %+v, want stored reading marked stale", weather)
+	}
+	if upstream.calls.Load() != 1 {
+		t.Errorf("upstream called %d times, want 1", upstream.calls.Load())
+	}
+}
+
+func TestGetWeatherGeocodingNotFound(t *testing.T) {
+	upstream := &fakeUpstream{geocodeErr: models.ErrCityNotFound}
+	svc := newRefreshService(newFakeStore(), upstream, config.WeatherConfig{MaxAge: time.Minute})
+
+	_, err := svc.GetWeather(context.Background(), "atlantis")
+	if !errors.Is(err, models.ErrCityNotFound) {
+		t.Errorf("err = %v, want ErrCityNotFound", err)
+	}
+}
+
+func TestGetWeatherExpiryUsesFetchedAt(t *testing.T) {
+	// Время измерения выровнено по четверти часа и старше max_age,
+	// но данные получены только что - повторный запрос не нужен
+	observed := time.Now().Add(-14 * time.Minute)
+	fetchedAt := time.Now()
+	store := newFakeStore()
+	store.readings = []models.WeatherDTO{{ID: 1, Name: "moscow", Timestamp: observed, FetchedAt: &fetchedAt}}
+	upstream := &fakeUpstream{observedAt: openMeteoTime(observed)}
+	svc := newRefreshService(store, upstream, config.WeatherConfig{MaxAge: 5 * time.Minute})
+
+	if _, err := svc.GetWeather(context.Background(), "moscow"); err != nil {
+		t.Fatal(err)
+	}
+	if upstream.calls.Load() != 0 {
+		t.Errorf("upstream called %d times, want 0", upstream.calls.Load())
+	}
+}
+
+func TestRefreshWeatherDoesNotDuplicate(t *testing.T) {
+	observed := time.Now().UTC().Truncate(15 * time.Minute)
+	store := newFakeStore()
+	upstream := &fakeUpstream{observedAt: openMeteoTime(observed)}
+	svc := newRefreshService(store, upstream, config.WeatherConfig{})
+
+	for range 3 {
+		if _, err := svc.RefreshWeather(context.Background(), "moscow"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(store.readings) != 1 {
+		t.Errorf("saved %d readings, want 1", len(store.readings))
+	}
+}
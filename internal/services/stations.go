@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+	"github.com/olezhek28/wether-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StationStore определяет контракт хранилища метеостанций
+type StationStore interface {
+	CreateStation(ctx context.Context, station models.NewStation) (models.Station, error)
+	ReadStation(ctx context.Context, id string) (models.Station, error)
+	ReadStations(ctx context.Context) ([]models.Station, error)
+	CreateStationKey(ctx context.Context, stationID, prefix string, hash []byte) (models.StationKey, error)
+	ReadStationKeys(ctx context.Context, stationID string) ([]models.StationKey, error)
+	RevokeStationKey(ctx context.Context, stationID string, id int64) error
+	UseStationKey(ctx context.Context, hash []byte) (models.StationKey, error)
+	ReserveSubmission(ctx context.Context, stationID, key string, requestHash []byte, expiredBefore time.Time) (models.Submission, bool, error)
+	CompleteSubmission(ctx context.Context, stationID, key string, result models.SubmissionResult) error
+	ReleaseSubmission(ctx context.Context, stationID, key string) error
+}
+
+// ReadingSaver определяет контракт сохранения показаний станций в ряды городов
+// Реализуется WeatherService: показания станций проходят ту же точку сохранения,
+// что и показания внешних API, включая проверку правил оповещений
+type ReadingSaver interface {
+	ResolveCity(ctx context.Context, city string) (string, error)
+	SaveReading(ctx context.Context, weather models.WeatherDTO) (models.WeatherDTO, bool, error)
+}
+
+// Параметры секретов ключей станций и запросов
+const (
+	stationSecretPrefix  = "wss_" // Отличает ключи станций от ключей API (wsk_)
+	maxStationName       = 200    // Максимальная длина описания станции
+	maxIdempotencyKeyLen = 255    // Максимальная длина ключа идемпотентности
+)
+
+// StationService регистрирует метеостанции, выдает им ключи и принимает их показания
+type StationService struct {
+	store    StationStore
+	readings ReadingSaver
+	config   config.StationsConfig
+}
+
+// NewStations создает сервис метеостанций поверх хранилища и точки сохранения показаний
+func NewStations(store StationStore, readings ReadingSaver, config config.StationsConfig) *StationService {
+	return &StationService{
+		store:    store,
+		readings: readings,
+		config:   config,
+	}
+}
+
+// RegisterStation регистрирует станцию
+// Город станции приводится к ключу города, поэтому ее показания попадают в тот же ряд,
+// что и показания внешних API. Занятый идентификатор - ErrStationExists
+func (s *StationService) RegisterStation(ctx context.Context, params models.NewStation) (models.Station, error) {
+	if !models.IsStationID(params.ID) {
+		return models.Station{}, fmt.Errorf("%w: id must be 1-64 lowercase letters, digits, '-' or '_'", models.ErrInvalidQuery)
+	}
+
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" || len(params.Name) > maxStationName {
+		return models.Station{}, fmt.Errorf("%w: name must be 1-%d characters", models.ErrInvalidQuery, maxStationName)
+	}
+
+	if (params.Latitude == nil) != (params.Longitude == nil) {
+		return models.Station{}, fmt.Errorf("%w: latitude and longitude must be set together", models.ErrInvalidQuery)
+	}
+	if params.Latitude != nil && (*params.Latitude < -90 || *params.Latitude > 90 || *params.Longitude < -180 || *params.Longitude > 180) {
+		return models.Station{}, fmt.Errorf("%w: coordinates are out of range", models.ErrInvalidQuery)
+	}
+
+	city, err := s.readings.ResolveCity(ctx, params.City)
+	if err != nil {
+		return models.Station{}, err
+	}
+	params.City = city
+
+	return s.store.CreateStation(ctx, params)
+}
+
+// ListStations возвращает все зарегистрированные станции
+func (s *StationService) ListStations(ctx context.Context) ([]models.Station, error) {
+	return s.store.ReadStations(ctx)
+}
+
+// CreateStationKey создает ключ станции и возвращает его вместе с секретом
+// Секрет возвращается только здесь: в хранилище попадает лишь его хэш
+func (s *StationService) CreateStationKey(ctx context.Context, stationID string) (models.CreatedStationKey, error) {
+	random := make([]byte, keySecretBytes)
+	rand.Read(random)
+	secret := stationSecretPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	key, err := s.store.CreateStationKey(ctx, stationID, secret[:keyPrefixLength], hashSecret(secret))
+	if err != nil {
+		return models.CreatedStationKey{}, err
+	}
+
+	return models.CreatedStationKey{Key: key, Secret: secret}, nil
+}
+
+// ListStationKeys возвращает ключи станции, включая отозванные
+func (s *StationService) ListStationKeys(ctx context.Context, stationID string) ([]models.StationKey, error) {
+	return s.store.ReadStationKeys(ctx, stationID)
+}
+
+// RevokeStationKey отзывает ключ станции: следующие запросы с ним получают ErrInvalidStationKey
+func (s *StationService) RevokeStationKey(ctx context.Context, stationID string, id int64) error {
+	return s.store.RevokeStationKey(ctx, stationID, id)
+}
+
+// Authenticate проверяет секрет ключа станции stationID и возвращает станцию
+// Неизвестный или отозванный ключ - ErrInvalidStationKey, ключ другой станции - ErrWrongStation
+func (s *StationService) Authenticate(ctx context.Context, stationID, secret string) (models.Station, error) {
+	if secret == "" {
+		return models.Station{}, models.ErrInvalidStationKey
+	}
+
+	key, err := s.store.UseStationKey(ctx, hashSecret(secret))
+	if err != nil {
+		return models.Station{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(key.StationID), []byte(stationID)) != 1 {
+		return models.Station{}, models.ErrWrongStation
+	}
+
+	return s.store.ReadStation(ctx, stationID)
+}
+
+// Submit принимает показания станции и сохраняет их в ряд ее города
+// Показания проверяются целиком до сохранения: одно некорректное показание отклоняет
+// весь запрос. Показание, которое уже было сохранено (та же станция и время измерения),
+// учитывается как дубликат. С непустым idempotencyKey повтор того же запроса возвращает
+// сохраненный результат и replayed = true, а повтор ключа с другими показаниями - ErrIdempotencyMismatch
+func (s *StationService) Submit(ctx context.Context, station models.Station, idempotencyKey string, observations []models.Observation) (_ models.SubmissionResult, replayed bool, err error) {
+	ctx, span := tracing.Start(ctx, "StationService.Submit", trace.WithAttributes(
+		attribute.String("station.id", station.ID),
+		attribute.Int("station.observations", len(observations)),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	normalized, err := s.normalize(observations, time.Now())
+	if err != nil {
+		return models.SubmissionResult{}, false, err
+	}
+
+	if idempotencyKey != "" {
+		if len(idempotencyKey) > maxIdempotencyKeyLen {
+			return models.SubmissionResult{}, false, fmt.Errorf("%w: Idempotency-Key must be at most %d characters", models.ErrInvalidQuery, maxIdempotencyKeyLen)
+		}
+
+		hash, err := requestHash(normalized)
+		if err != nil {
+			return models.SubmissionResult{}, false, err
+		}
+
+		submission, reserved, err := s.store.ReserveSubmission(ctx, station.ID, idempotencyKey, hash, time.Now().Add(-s.config.IdempotencyTTL))
+		if err != nil {
+			return models.SubmissionResult{}, false, err
+		}
+		if !reserved {
+			switch {
+			case subtle.ConstantTimeCompare(submission.RequestHash, hash) != 1:
+				return models.SubmissionResult{}, false, models.ErrIdempotencyMismatch
+			case submission.Result == nil:
+				return models.SubmissionResult{}, false, models.ErrIdempotencyInProgress
+			default:
+				return *submission.Result, true, nil
+			}
+		}
+	}
+
+	result, err := s.save(ctx, station, normalized)
+	if idempotencyKey == "" {
+		return result, false, err
+	}
+
+	if err == nil {
+		err = s.store.CompleteSubmission(ctx, station.ID, idempotencyKey, result)
+	}
+	if err != nil {
+		// Освобождаем ключ, чтобы станция могла повторить запрос: уже сохраненные
+		// показания при повторе будут учтены как дубликаты. Отмена запроса клиентом
+		// не должна оставить ключ занятым до истечения срока
+		if releaseErr := s.store.ReleaseSubmission(context.WithoutCancel(ctx), station.ID, idempotencyKey); releaseErr != nil {
+			pkg.FromContext(ctx).Error("failed to release idempotency key", "station", station.ID, "error", releaseErr)
+		}
+		return models.SubmissionResult{}, false, err
+	}
+
+	return result, false, nil
+}
+
+// normalize переводит показания в единицы хранения и проверяет количество и время измерения
+func (s *StationService) normalize(observations []models.Observation, now time.Time) ([]models.Observation, error) {
+	if len(observations) == 0 {
+		return nil, fmt.Errorf("%w: at least one observation is required", models.ErrInvalidQuery)
+	}
+	if len(observations) > s.config.MaxBatch {
+		return nil, fmt.Errorf("%w: at most %d observations per request", models.ErrInvalidQuery, s.config.MaxBatch)
+	}
+
+	from, to := now.Add(-s.config.MaxPast), now.Add(s.config.MaxFuture)
+	normalized := make([]models.Observation, len(observations))
+	for i, o := range observations {
+		n, err := o.Normalize()
+		if err == nil && (n.Timestamp.Before(from) || n.Timestamp.After(to)) {
+			err = fmt.Errorf("%w: timestamp must be between %s and %s", models.ErrInvalidQuery, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("observations[%d]: %w", i, err)
+		}
+		normalized[i] = n
+	}
+
+	return normalized, nil
+}
+
+// requestHash возвращает SHA-256 нормализованных показаний запроса
+// Хэшируются нормализованные показания: тот же запрос в других единицах - тот же запрос
+func requestHash(observations []models.Observation) ([]byte, error) {
+	body, err := json.Marshal(observations)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	return sum[:], nil
+}
+
+// save сохраняет нормализованные показания станции в ряд ее города
+func (s *StationService) save(ctx context.Context, station models.Station, observations []models.Observation) (models.SubmissionResult, error) {
+	result := models.SubmissionResult{
+		Station:  station.ID,
+		City:     station.City,
+		Readings: make([]int64, 0, len(observations)),
+	}
+
+	source := models.SourceStation
+	for _, o := range observations {
+		fetchedAt := time.Now().UTC()
+		saved, created, err := s.readings.SaveReading(ctx, models.WeatherDTO{
+			Name:        station.City,
+			Temperature: *o.Temperature,
+			Timestamp:   o.Timestamp,
+			FetchedAt:   &fetchedAt,
+			Source:      &source,
+			Humidity:    o.Humidity,
+			WindSpeed:   o.WindSpeed,
+			StationID:   &station.ID,
+		})
+		if err != nil {
+			return models.SubmissionResult{}, err
+		}
+
+		if created {
+			result.Accepted++
+		} else {
+			result.Duplicates++
+		}
+		result.Readings = append(result.Readings, saved.ID)
+	}
+
+	return result, nil
+}
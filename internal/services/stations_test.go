@@ -0,0 +1,379 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// fakeStationStore хранит станции, их ключи и запросы с ключами идемпотентности в памяти
+type fakeStationStore struct {
+	mu          sync.Mutex
+	stations    map[string]models.Station
+	keys        []models.StationKey
+	hashes      [][]byte
+	submissions map[string]models.Submission // По ключу идемпотентности, срок хранения не учитывается
+	released    []string                     // Освобожденные ключи идемпотентности
+}
+
+func newFakeStationStore() *fakeStationStore {
+	return &fakeStationStore{
+		stations:    make(map[string]models.Station),
+		submissions: make(map[string]models.Submission),
+	}
+}
+
+func (f *fakeStationStore) CreateStation(_ context.Context, station models.NewStation) (models.Station, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.stations[station.ID]; ok {
+		return models.Station{}, models.ErrStationExists
+	}
+	created := models.Station{ID: station.ID, Name: station.Name, City: station.City, Latitude: station.Latitude, Longitude: station.Longitude, CreatedAt: time.Now()}
+	f.stations[station.ID] = created
+	return created, nil
+}
+
+func (f *fakeStationStore) ReadStation(_ context.Context, id string) (models.Station, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	station, ok := f.stations[id]
+	if !ok {
+		return models.Station{}, models.ErrStationNotFound
+	}
+	return station, nil
+}
+
+func (f *fakeStationStore) ReadStations(context.Context) ([]models.Station, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stations := make([]models.Station, 0, len(f.stations))
+	for _, s := range f.stations {
+		stations = append(stations, s)
+	}
+	return stations, nil
+}
+
+func (f *fakeStationStore) CreateStationKey(_ context.Context, stationID, prefix string, hash []byte) (models.StationKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.stations[stationID]; !ok {
+		return models.StationKey{}, models.ErrStationNotFound
+	}
+	key := models.StationKey{ID: int64(len(f.keys) + 1), StationID: stationID, Prefix: prefix, CreatedAt: time.Now()}
+	f.keys = append(f.keys, key)
+	f.hashes = append(f.hashes, hash)
+	return key, nil
+}
+
+func (f *fakeStationStore) ReadStationKeys(_ context.Context, stationID string) ([]models.StationKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var keys []models.StationKey
+	for _, k := range f.keys {
+		if k.StationID == stationID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeStationStore) RevokeStationKey(_ context.Context, stationID string, id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, k := range f.keys {
+		if k.ID == id && k.StationID == stationID {
+			now := time.Now()
+			f.keys[i].RevokedAt = &now
+			return nil
+		}
+	}
+	return models.ErrKeyNotFound
+}
+
+func (f *fakeStationStore) UseStationKey(_ context.Context, hash []byte) (models.StationKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, h := range f.hashes {
+		if bytes.Equal(h, hash) && f.keys[i].RevokedAt == nil {
+			return f.keys[i], nil
+		}
+	}
+	return models.StationKey{}, models.ErrInvalidStationKey
+}
+
+func (f *fakeStationStore) ReserveSubmission(_ context.Context, stationID, key string, requestHash []byte, _ time.Time) (models.Submission, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if existing, ok := f.submissions[stationID+"/"+key]; ok {
+		return existing, false, nil
+	}
+	f.submissions[stationID+"/"+key] = models.Submission{RequestHash: requestHash}
+	return models.Submission{RequestHash: requestHash}, true, nil
+}
+
+func (f *fakeStationStore) CompleteSubmission(_ context.Context, stationID, key string, result models.SubmissionResult) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	submission := f.submissions[stationID+"/"+key]
+	submission.Result = &result
+	f.submissions[stationID+"/"+key] = submission
+	return nil
+}
+
+func (f *fakeStationStore) ReleaseSubmission(_ context.Context, stationID, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.submissions, stationID+"/"+key)
+	f.released = append(f.released, key)
+	return nil
+}
+
+// failingSaver возвращает ошибку при сохранении показаний
+type failingSaver struct {
+	*WeatherService
+}
+
+func (failingSaver) SaveReading(context.Context, models.WeatherDTO) (models.WeatherDTO, bool, error) {
+	return models.WeatherDTO{}, false, errors.New("database is down")
+}
+
+// testStationsConfig - параметры приема показаний в тестах
+var testStationsConfig = config.StationsConfig{MaxBatch: 3, MaxFuture: 5 * time.Minute, MaxPast: 24 * time.Hour, IdempotencyTTL: time.Hour}
+
+// newStationService создает сервис станций, сохраняющий показания в store,
+// и регистрирует станцию roof-1 в городе moscow
+func newStationService(t *testing.T, store *fakeStore) (*StationService, *fakeStationStore, models.Station) {
+	t.Helper()
+
+	store.SaveAliases(context.Background(), "moscow", []string{"moscow", "москва"}, models.AliasSourceGeocoding)
+	stations := newFakeStationStore()
+	svc := NewStations(stations, newTestService(store), testStationsConfig)
+
+	station, err := svc.RegisterStation(context.Background(), models.NewStation{ID: "roof-1", Name: "Roof", City: "Москва"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc, stations, station
+}
+
+func ptr(v float64) *float64 { return &v }
+
+func TestRegisterStation(t *testing.T) {
+	svc, _, station := newStationService(t, newFakeStore())
+	if station.City != "moscow" {
+		t.Errorf("city = %q, want the city key moscow", station.City)
+	}
+
+	tests := []struct {
+		name   string
+		params models.NewStation
+		want   error
+	}{
+		{name: "taken id", params: models.NewStation{ID: "roof-1", Name: "Other", City: "moscow"}, want: models.ErrStationExists},
+		{name: "uppercase id", params: models.NewStation{ID: "Roof-2", Name: "Roof", City: "moscow"}, want: models.ErrInvalidQuery},
+		{name: "blank name", params: models.NewStation{ID: "roof-2", Name: " ", City: "moscow"}, want: models.ErrInvalidQuery},
+		{name: "blank city", params: models.NewStation{ID: "roof-2", Name: "Roof", City: " "}, want: models.ErrInvalidQuery},
+		{name: "latitude only", params: models.NewStation{ID: "roof-2", Name: "Roof", City: "moscow", Latitude: ptr(55)}, want: models.ErrInvalidQuery},
+		{name: "latitude out of range", params: models.NewStation{ID: "roof-2", Name: "Roof", City: "moscow", Latitude: ptr(91), Longitude: ptr(37)}, want: models.ErrInvalidQuery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.RegisterStation(context.Background(), tt.params); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStationAuthenticate(t *testing.T) {
+	svc, _, station := newStationService(t, newFakeStore())
+	ctx := context.Background()
+	if _, err := svc.RegisterStation(ctx, models.NewStation{ID: "roof-2", Name: "Roof", City: "moscow"}); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := svc.CreateStationKey(ctx, station.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Secret, stationSecretPrefix) || !strings.HasPrefix(created.Secret, created.Key.Prefix) {
+		t.Errorf("secret %q does not match prefix %q", created.Secret, created.Key.Prefix)
+	}
+
+	got, err := svc.Authenticate(ctx, "roof-1", created.Secret)
+	if err != nil || got.ID != "roof-1" {
+		t.Fatalf("Authenticate = %+v, %v; want roof-1", got, err)
+	}
+	if _, err := svc.Authenticate(ctx, "roof-2", created.Secret); !errors.Is(err, models.ErrWrongStation) {
+		t.Errorf("another station: err = %v, want ErrWrongStation", err)
+	}
+	if _, err := svc.Authenticate(ctx, "roof-1", ""); !errors.Is(err, models.ErrInvalidStationKey) {
+		t.Errorf("no secret: err = %v, want ErrInvalidStationKey", err)
+	}
+
+	if err := svc.RevokeStationKey(ctx, "roof-1", created.Key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Authenticate(ctx, "roof-1", created.Secret); !errors.Is(err, models.ErrInvalidStationKey) {
+		t.Errorf("revoked key: err = %v, want ErrInvalidStationKey", err)
+	}
+	if _, err := svc.CreateStationKey(ctx, "omsk-1"); !errors.Is(err, models.ErrStationNotFound) {
+		t.Errorf("unknown station: err = %v, want ErrStationNotFound", err)
+	}
+}
+
+func TestSubmitObservations(t *testing.T) {
+	store := newFakeStore()
+	svc, _, station := newStationService(t, store)
+	at := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	result, replayed, err := svc.Submit(context.Background(), station, "", []models.Observation{
+		{Timestamp: at, Temperature: ptr(14), TemperatureUnit: models.UnitFahrenheit, WindSpeed: ptr(5), WindSpeedUnit: models.UnitMetersPerSecond},
+		{Timestamp: at.Add(time.Minute), Temperature: ptr(-10), Humidity: ptr(80)},
+	})
+	if err != nil || replayed {
+		t.Fatalf("Submit: replayed %v, err %v", replayed, err)
+	}
+	if result.City != "moscow" || result.Accepted != 2 || result.Duplicates != 0 || !slices.Equal(result.Readings, []int64{1, 2}) {
+		t.Errorf("result = %+v", result)
+	}
+
+	// Показания сохраняются в ряд города в °C и км/ч с тегом станции
+	r := store.readings[0]
+	if r.Name != "moscow" || math.Abs(r.Temperature+10) > 1e-9 || math.Abs(*r.WindSpeed-18) > 1e-9 {
+		t.Errorf("stored %s: %g °C, wind %g km/h; want moscow, -10 °C, 18 km/h", r.Name, r.Temperature, *r.WindSpeed)
+	}
+	if *r.Source != models.SourceStation || *r.StationID != "roof-1" {
+		t.Errorf("source %q, station %q", *r.Source, *r.StationID)
+	}
+
+	// Повтор того же времени измерения - дубликат, а не новое показание
+	result, _, err = svc.Submit(context.Background(), station, "", []models.Observation{{Timestamp: at, Temperature: ptr(-10)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Accepted != 0 || result.Duplicates != 1 || !slices.Equal(result.Readings, []int64{1}) {
+		t.Errorf("repeated observation: result = %+v", result)
+	}
+}
+
+func TestSubmitValidation(t *testing.T) {
+	svc, _, station := newStationService(t, newFakeStore())
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		observations []models.Observation
+		want         string
+	}{
+		{name: "empty", want: "at least one"},
+		{name: "too many", observations: make([]models.Observation, 4), want: "at most 3"},
+		{name: "future", observations: []models.Observation{{Timestamp: now.Add(time.Hour), Temperature: ptr(1)}}, want: "observations[0]: invalid query: timestamp"},
+		{name: "too old", observations: []models.Observation{{Timestamp: now.Add(-48 * time.Hour), Temperature: ptr(1)}}, want: "timestamp must be between"},
+		{name: "second invalid", observations: []models.Observation{
+			{Timestamp: now, Temperature: ptr(1)},
+			{Timestamp: now, Temperature: ptr(100)},
+		}, want: "observations[1]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := svc.Submit(context.Background(), station, "", tt.observations)
+			if !errors.Is(err, models.ErrInvalidQuery) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want ErrInvalidQuery with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubmitIdempotency(t *testing.T) {
+	store := newFakeStore()
+	svc, stations, station := newStationService(t, store)
+	ctx := context.Background()
+	at := time.Now().Add(-time.Minute)
+	celsius := []models.Observation{{Timestamp: at, Temperature: ptr(0)}}
+
+	first, replayed, err := svc.Submit(ctx, station, "req-1", celsius)
+	if err != nil || replayed {
+		t.Fatalf("first: replayed %v, err %v", replayed, err)
+	}
+
+	// Те же показания в других единицах - тот же запрос: возвращается сохраненный результат
+	kelvin := []models.Observation{{Timestamp: at, Temperature: ptr(273.15), TemperatureUnit: models.UnitKelvin}}
+	again, replayed, err := svc.Submit(ctx, station, "req-1", kelvin)
+	if err != nil || !replayed {
+		t.Fatalf("replay: replayed %v, err %v", replayed, err)
+	}
+	if again.Accepted != first.Accepted || again.Duplicates != 0 || len(store.readings) != 1 {
+		t.Errorf("replay = %+v with %d readings, want the first result %+v", again, len(store.readings), first)
+	}
+
+	other := []models.Observation{{Timestamp: at, Temperature: ptr(5)}}
+	if _, _, err := svc.Submit(ctx, station, "req-1", other); !errors.Is(err, models.ErrIdempotencyMismatch) {
+		t.Errorf("other request: err = %v, want ErrIdempotencyMismatch", err)
+	}
+
+	stations.ReserveSubmission(ctx, station.ID, "req-2", nil, time.Time{})
+	if _, _, err := svc.Submit(ctx, station, "req-2", other); !errors.Is(err, models.ErrIdempotencyMismatch) {
+		t.Errorf("reserved with another hash: err = %v, want ErrIdempotencyMismatch", err)
+	}
+
+	if _, _, err := svc.Submit(ctx, station, strings.Repeat("k", maxIdempotencyKeyLen+1), other); !errors.Is(err, models.ErrInvalidQuery) {
+		t.Errorf("long key: err = %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestSubmitInProgress(t *testing.T) {
+	svc, stations, station := newStationService(t, newFakeStore())
+	ctx := context.Background()
+	observations := []models.Observation{{Timestamp: time.Now(), Temperature: ptr(1)}}
+
+	// Первый запрос зарезервировал ключ, но еще не сохранил результат
+	normalized, err := svc.normalize(observations, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := requestHash(normalized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stations.ReserveSubmission(ctx, station.ID, "req-1", hash, time.Time{})
+
+	if _, _, err := svc.Submit(ctx, station, "req-1", observations); !errors.Is(err, models.ErrIdempotencyInProgress) {
+		t.Errorf("err = %v, want ErrIdempotencyInProgress", err)
+	}
+}
+
+func TestSubmitReleasesKeyOnFailure(t *testing.T) {
+	store := newFakeStore()
+	stations := newFakeStationStore()
+	svc := NewStations(stations, failingSaver{newTestService(store)}, testStationsConfig)
+	station := models.Station{ID: "roof-1", City: "moscow"}
+
+	_, _, err := svc.Submit(context.Background(), station, "req-1", []models.Observation{{Timestamp: time.Now(), Temperature: ptr(1)}})
+	if err == nil {
+		t.Fatal("want the save error")
+	}
+	if !slices.Equal(stations.released, []string{"req-1"}) || len(stations.submissions) != 0 {
+		t.Errorf("released %v, submissions %v; want req-1 released", stations.released, stations.submissions)
+	}
+}
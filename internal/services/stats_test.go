@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+func TestGetStatsPeriods(t *testing.T) {
+	// 31 марта минус месяц нормализуется AddDate в 3 марта (февраль короче)
+	to := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	customFrom := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		query    models.StatsQuery
+		wantFrom time.Time
+	}{
+		{name: "day", query: models.StatsQuery{Period: models.PeriodDay, To: to}, wantFrom: to.Add(-24 * time.Hour)},
+		{name: "week", query: models.StatsQuery{Period: models.PeriodWeek, To: to}, wantFrom: to.Add(-7 * 24 * time.Hour)},
+		{name: "month", query: models.StatsQuery{Period: models.PeriodMonth, To: to}, wantFrom: time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)},
+		{name: "custom", query: models.StatsQuery{Period: models.PeriodCustom, From: customFrom, To: to}, wantFrom: customFrom},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			tt.query.City = "moscow"
+
+			stats, err := newTestService(store).GetStats(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !store.statsFrom.Equal(tt.wantFrom) || !store.statsTo.Equal(to) {
+				t.Errorf("range = [%v, %v), want [%v, %v)", store.statsFrom, store.statsTo, tt.wantFrom, to)
+			}
+			if stats.Period != tt.query.Period {
+				t.Errorf("period = %q, want %q", stats.Period, tt.query.Period)
+			}
+		})
+	}
+}
+
+func TestGetStatsInvalidQuery(t *testing.T) {
+	to := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query models.StatsQuery
+	}{
+		{name: "unknown period", query: models.StatsQuery{Period: "year", To: to}},
+		{name: "custom without from", query: models.StatsQuery{Period: models.PeriodCustom, To: to}},
+		{name: "custom with from after to", query: models.StatsQuery{Period: models.PeriodCustom, From: to.Add(time.Hour), To: to}},
+		{name: "from with day", query: models.StatsQuery{Period: models.PeriodDay, From: to.Add(-time.Hour), To: to}},
+		{name: "from with month", query: models.StatsQuery{Period: models.PeriodMonth, From: to.Add(-time.Hour), To: to}},
+		{name: "unknown variable", query: models.StatsQuery{Variable: "pressure", Period: models.PeriodDay, To: to}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestService(newFakeStore()).GetStats(context.Background(), tt.query)
+			if !errors.Is(err, models.ErrInvalidQuery) {
+				t.Errorf("err = %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
+
+func TestGetStatsVariable(t *testing.T) {
+	to := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		variable string
+		want     string
+	}{
+		{variable: "", want: models.VariableTemperature},
+		{variable: models.VariableDewPoint, want: models.VariableDewPoint},
+	}
+
+	for _, tt := range tests {
+		store := newFakeStore()
+		stats, err := newTestService(store).GetStats(context.Background(), models.StatsQuery{City: "moscow", Variable: tt.variable, Period: models.PeriodDay, To: to})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if store.statsVariable != tt.want || stats.Variable != tt.want {
+			t.Errorf("variable %q: read %q, reported %q, want %q", tt.variable, store.statsVariable, stats.Variable, tt.want)
+		}
+	}
+}
+
+func TestGetStatsNoData(t *testing.T) {
+	store := newFakeStore()
+	store.err = models.ErrNoData
+
+	_, err := newTestService(store).GetStats(context.Background(), models.StatsQuery{City: "moscow", Period: models.PeriodDay, To: time.Now()})
+	if !errors.Is(err, models.ErrNoData) {
+		t.Errorf("err = %v, want ErrNoData", err)
+	}
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+)
+
+// KeySetLoader определяет контракт источника открытых ключей подписи JWT
+type KeySetLoader interface {
+	Load(ctx context.Context) (jose.JSONWebKeySet, error)
+}
+
+// Параметры проверки токенов
+const (
+	tokenLeeway       = time.Minute      // Допустимое расхождение часов с провайдером удостоверений
+	minKeySetReload   = 30 * time.Second // Минимальный интервал внеочередной загрузки JWKS при неизвестном kid
+	keySetLoadTimeout = 10 * time.Second // Таймаут загрузки JWKS при запуске
+)
+
+// tokenAlgorithms - допустимые алгоритмы подписи. Симметричные алгоритмы не принимаются:
+// JWKS содержит открытые ключи, и HS256 с ними позволил бы подделать токен
+var tokenAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// TokenService проверяет bearer-токены административных маршрутов по JWKS
+// и сопоставляет роли из токена с разрешениями
+type TokenService struct {
+	loader KeySetLoader
+	config config.JWTConfig
+
+	mu       sync.Mutex
+	keys     jose.JSONWebKeySet // Последний загруженный набор ключей
+	loadedAt time.Time          // Время последней загрузки набора
+}
+
+// NewTokens создает сервис проверки токенов и загружает JWKS
+// Вызывает панику, если JWKS недоступен или роль ссылается на неизвестное разрешение:
+// с такой конфигурацией административные маршруты были бы недоступны
+func NewTokens(ctx context.Context, loader KeySetLoader, config config.JWTConfig) *TokenService {
+	for role, permissions := range config.Roles {
+		for _, permission := range permissions {
+			if !slices.Contains(models.Permissions, permission) {
+				panic(fmt.Sprintf("unknown permission %q for role %q", permission, role))
+			}
+		}
+	}
+
+	t := &TokenService{
+		loader: loader,
+		config: config,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, keySetLoadTimeout)
+	defer cancel()
+	if err := t.reload(ctx); err != nil {
+		panic("failed to load JWKS: " + err.Error())
+	}
+	return t
+}
+
+// RefreshKeys перечитывает JWKS с интервалом из конфигурации до отмены ctx,
+// чтобы подхватывать ротацию ключей провайдера
+// При ошибке загрузки продолжает использовать предыдущий набор
+func (t *TokenService) RefreshKeys(ctx context.Context) {
+	ticker := time.NewTicker(t.config.Refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.reload(ctx); err != nil {
+				pkg.FromContext(ctx).Error("failed to refresh JWKS", "error", err)
+			}
+		}
+	}
+}
+
+// Verify проверяет подпись и срок действия токена, claims iss и aud
+// и возвращает субъекта с разрешениями его ролей
+// Любая ошибка проверки оборачивает models.ErrInvalidToken
+func (t *TokenService) Verify(ctx context.Context, token string) (models.Principal, error) {
+	if token == "" {
+		return models.Principal{}, models.ErrInvalidToken
+	}
+
+	parsed, err := jwt.ParseSigned(token, tokenAlgorithms)
+	if err != nil {
+		return models.Principal{}, fmt.Errorf("%w: %v", models.ErrInvalidToken, err)
+	}
+
+	key, err := t.signingKey(ctx, parsed.Headers[0].KeyID)
+	if err != nil {
+		return models.Principal{}, err
+	}
+
+	var claims jwt.Claims
+	var custom map[string]any
+	if err := parsed.Claims(key, &claims, &custom); err != nil {
+		return models.Principal{}, fmt.Errorf("%w: %v", models.ErrInvalidToken, err)
+	}
+
+	// Токен без срока действия нельзя отозвать, поэтому exp обязателен
+	if claims.Expiry == nil {
+		return models.Principal{}, fmt.Errorf("%w: exp claim is required", models.ErrInvalidToken)
+	}
+
+	expected := jwt.Expected{Issuer: t.config.Issuer, Time: time.Now()}
+	if t.config.Audience != "" {
+		expected.AnyAudience = jwt.Audience{t.config.Audience}
+	}
+	if err := claims.ValidateWithLeeway(expected, tokenLeeway); err != nil {
+		return models.Principal{}, fmt.Errorf("%w: %v", models.ErrInvalidToken, err)
+	}
+
+	roles := claimStrings(custom, t.config.RolesClaim)
+	return models.Principal{
+		Subject:     claims.Subject,
+		Roles:       roles,
+		Permissions: t.permissions(roles),
+	}, nil
+}
+
+// signingKey возвращает открытый ключ с идентификатором kid
+// Неизвестный kid означает, что провайдер мог сменить ключи: набор загружается
+// заново, но не чаще minKeySetReload, чтобы поток токенов с чужим kid не нагружал провайдера
+// Токен без kid принимается, только если в наборе один ключ
+func (t *TokenService) signingKey(ctx context.Context, kid string) (jose.JSONWebKey, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key, ok := findKey(t.keys, kid)
+	if !ok && kid != "" && time.Since(t.loadedAt) >= minKeySetReload {
+		if err := t.reloadLocked(ctx); err != nil {
+			pkg.FromContext(ctx).Error("failed to reload JWKS", "error", err)
+		}
+		key, ok = findKey(t.keys, kid)
+	}
+	if !ok {
+		return jose.JSONWebKey{}, fmt.Errorf("%w: unknown signing key %q", models.ErrInvalidToken, kid)
+	}
+	return key, nil
+}
+
+// reload загружает набор ключей
+func (t *TokenService) reload(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.reloadLocked(ctx)
+}
+
+// reloadLocked загружает набор ключей, вызывается под t.mu
+// Время попытки запоминается и при ошибке, чтобы ограничить частоту повторов
+func (t *TokenService) reloadLocked(ctx context.Context) error {
+	t.loadedAt = time.Now()
+
+	keys, err := t.loader.Load(ctx)
+	if err != nil {
+		return err
+	}
+	t.keys = keys
+	return nil
+}
+
+// permissions объединяет разрешения ролей из конфигурации. Неизвестные роли пропускаются
+func (t *TokenService) permissions(roles []string) []string {
+	var permissions []string
+	for _, role := range roles {
+		for _, permission := range t.config.Roles[role] {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	slices.Sort(permissions)
+	return permissions
+}
+
+// findKey ищет в наборе открытый ключ подписи с идентификатором kid
+// Ключи с назначением, отличным от подписи, и симметричные ключи пропускаются
+func findKey(keys jose.JSONWebKeySet, kid string) (jose.JSONWebKey, bool) {
+	var candidates []jose.JSONWebKey
+	for _, key := range keys.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		public := key.Public()
+		if !public.Valid() {
+			continue
+		}
+		if kid == "" || key.KeyID == kid {
+			candidates = append(candidates, public)
+		}
+	}
+
+	if len(candidates) != 1 {
+		return jose.JSONWebKey{}, false
+	}
+	return candidates[0], true
+}
+
+// claimStrings возвращает строки claim по пути path через точку (например, realm_access.roles)
+// Claim может быть массивом строк или одной строкой, остальные значения игнорируются
+func claimStrings(claims map[string]any, path string) []string {
+	var value any = claims
+	for name := range strings.SplitSeq(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
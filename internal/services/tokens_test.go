@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/olezhek28/wether-service/internal/clients"
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+const (
+	testIssuer   = "https://idp.example.com"
+	testAudience = "weather-service"
+)
+
+// signingKey - закрытый ключ тестового провайдера удостоверений с идентификатором kid
+type signingKey struct {
+	kid  string
+	priv *ecdsa.PrivateKey
+}
+
+func newSigningKey(t *testing.T, kid string) signingKey {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingKey{kid: kid, priv: priv}
+}
+
+// sign подписывает токен со стандартными claims и произвольными claims custom
+func (k signingKey) sign(t *testing.T, claims jwt.Claims, custom map[string]any) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: k.priv, KeyID: k.kid}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := jwt.Signed(signer).Claims(claims).Claims(custom).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// jwksJSON возвращает документ JWKS с открытыми ключами keys
+func jwksJSON(t *testing.T, keys ...signingKey) []byte {
+	t.Helper()
+
+	var set jose.JSONWebKeySet
+	for _, k := range keys {
+		set.Keys = append(set.Keys, jose.JSONWebKey{Key: &k.priv.PublicKey, KeyID: k.kid, Algorithm: string(jose.ES256), Use: "sig"})
+	}
+	raw, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// jwtConfig возвращает конфигурацию с ролями admin (все разрешения) и auditor (только чтение)
+func jwtConfig() config.JWTConfig {
+	return config.JWTConfig{
+		Refresh:    time.Minute,
+		Issuer:     testIssuer,
+		Audience:   testAudience,
+		RolesClaim: "roles",
+		Roles: map[string][]string{
+			"admin":   {models.PermissionKeysRead, models.PermissionKeysWrite},
+			"auditor": {models.PermissionKeysRead},
+		},
+	}
+}
+
+// newFileTokens создает сервис токенов со статическим JWKS из временного файла
+func newFileTokens(t *testing.T, cfg config.JWTConfig, keys ...signingKey) *TokenService {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksJSON(t, keys...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return NewTokens(context.Background(), clients.NewJWKS(nil, path, ""), cfg)
+}
+
+// validClaims возвращает claims, проходящие проверку с jwtConfig
+func validClaims() jwt.Claims {
+	now := time.Now()
+	return jwt.Claims{
+		Subject:  "alice",
+		Issuer:   testIssuer,
+		Audience: jwt.Audience{testAudience},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+}
+
+func TestVerifyMapsRolesToPermissions(t *testing.T) {
+	key := newSigningKey(t, "k1")
+	tokens := newFileTokens(t, jwtConfig(), key)
+
+	tests := []struct {
+		name  string
+		roles any
+		want  []string
+	}{
+		{name: "admin", roles: []string{"admin"}, want: []string{models.PermissionKeysRead, models.PermissionKeysWrite}},
+		{name: "auditor", roles: []string{"auditor"}, want: []string{models.PermissionKeysRead}},
+		{name: "overlapping roles", roles: []string{"auditor", "admin"}, want: []string{models.PermissionKeysRead, models.PermissionKeysWrite}},
+		{name: "single string", roles: "auditor", want: []string{models.PermissionKeysRead}},
+		{name: "unknown role", roles: []string{"viewer"}},
+		{name: "no roles"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			custom := map[string]any{}
+			if tt.roles != nil {
+				custom["roles"] = tt.roles
+			}
+
+			principal, err := tokens.Verify(context.Background(), key.sign(t, validClaims(), custom))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if principal.Subject != "alice" {
+				t.Errorf("subject = %q, want alice", principal.Subject)
+			}
+			if !slices.Equal(principal.Permissions, tt.want) {
+				t.Errorf("permissions = %v, want %v", principal.Permissions, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyNestedRolesClaim(t *testing.T) {
+	key := newSigningKey(t, "k1")
+	cfg := jwtConfig()
+	cfg.RolesClaim = "realm_access.roles"
+	tokens := newFileTokens(t, cfg, key)
+
+	token := key.sign(t, validClaims(), map[string]any{
+		"realm_access": map[string]any{"roles": []string{"auditor"}},
+		"roles":        []string{"admin"}, // Claim верхнего уровня не должен учитываться
+	})
+
+	principal, err := tokens.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(principal.Permissions, []string{models.PermissionKeysRead}) {
+		t.Errorf("permissions = %v, want [%s]", principal.Permissions, models.PermissionKeysRead)
+	}
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	key := newSigningKey(t, "k1")
+	other := newSigningKey(t, "k1") // Тот же kid, но ключ не из JWKS
+	tokens := newFileTokens(t, jwtConfig(), key)
+
+	withClaims := func(mutate func(*jwt.Claims)) string {
+		claims := validClaims()
+		mutate(&claims)
+		return key.sign(t, claims, map[string]any{"roles": []string{"admin"}})
+	}
+
+	hmacSigner, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("0123456789abcdef0123456789abcdef")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacToken, err := jwt.Signed(hmacSigner).Claims(validClaims()).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "empty", token: ""},
+		{name: "malformed", token: "not-a-jwt"},
+		{name: "expired", token: withClaims(func(c *jwt.Claims) { c.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Hour)) })},
+		{name: "not yet valid", token: withClaims(func(c *jwt.Claims) { c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour)) })},
+		{name: "without exp", token: withClaims(func(c *jwt.Claims) { c.Expiry = nil })},
+		{name: "wrong issuer", token: withClaims(func(c *jwt.Claims) { c.Issuer = "https://evil.example.com" })},
+		{name: "wrong audience", token: withClaims(func(c *jwt.Claims) { c.Audience = jwt.Audience{"other-service"} })},
+		{name: "foreign key", token: other.sign(t, validClaims(), nil)},
+		{name: "unknown kid", token: newSigningKey(t, "k2").sign(t, validClaims(), nil)},
+		{name: "symmetric algorithm", token: hmacToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tokens.Verify(context.Background(), tt.token)
+			if !errors.Is(err, models.ErrInvalidToken) {
+				t.Errorf("got %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyReloadsKeySetOnUnknownKid(t *testing.T) {
+	oldKey := newSigningKey(t, "old")
+	newKey := newSigningKey(t, "new")
+
+	// Провайдер удостоверений публикует новый ключ после запуска сервиса
+	var rotated atomic.Bool
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		if rotated.Load() {
+			w.Write(jwksJSON(t, oldKey, newKey))
+			return
+		}
+		w.Write(jwksJSON(t, oldKey))
+	}))
+	defer srv.Close()
+
+	tokens := NewTokens(context.Background(), clients.NewJWKS(srv.Client(), "", srv.URL), jwtConfig())
+	token := newKey.sign(t, validClaims(), map[string]any{"roles": []string{"admin"}})
+
+	// Сразу после загрузки набор не перечитывается, даже если kid неизвестен
+	rotated.Store(true)
+	if _, err := tokens.Verify(context.Background(), token); !errors.Is(err, models.ErrInvalidToken) {
+		t.Fatalf("got %v, want ErrInvalidToken before the reload interval", err)
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", got)
+	}
+
+	tokens.mu.Lock()
+	tokens.loadedAt = time.Now().Add(-minKeySetReload)
+	tokens.mu.Unlock()
+
+	principal, err := tokens.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("unexpected error after rotation: %v", err)
+	}
+	if !principal.HasPermission(models.PermissionKeysWrite) {
+		t.Errorf("permissions = %v, want %s", principal.Permissions, models.PermissionKeysWrite)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+}
+
+func TestNewTokensPanicsOnUnknownPermission(t *testing.T) {
+	cfg := jwtConfig()
+	cfg.Roles["admin"] = append(cfg.Roles["admin"], "keys:delete")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for unknown permission")
+		}
+	}()
+	newFileTokens(t, cfg, newSigningKey(t, "k1"))
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans устанавливает глобальный провайдер, записывающий завершенные спаны,
+// и возвращает функцию поиска завершенного спана по имени
+func recordSpans(t *testing.T) func(name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	return func(name string) sdktrace.ReadOnlySpan {
+		t.Helper()
+		for _, span := range rec.Ended() {
+			if span.Name() == name {
+				return span
+			}
+		}
+		t.Fatalf("no %s span", name)
+		return nil
+	}
+}
+
+func TestGetWeatherSpans(t *testing.T) {
+	span := recordSpans(t)
+	upstream := &fakeUpstream{observedAt: openMeteoTime(time.Now())}
+	svc := newRefreshService(newFakeStore(), upstream, config.WeatherConfig{MaxAge: time.Minute})
+
+	if _, err := svc.GetWeather(context.Background(), "moscow"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read-through: обновление из внешних API и сохранение вложены в спан запроса
+	get, refresh, add := span("WeatherService.GetWeather"), span("WeatherService.RefreshWeather"), span("WeatherService.AddWeather")
+	if refresh.Parent().SpanID() != get.SpanContext().SpanID() {
+		t.Error("RefreshWeather is not a child of GetWeather")
+	}
+	if add.Parent().SpanID() != refresh.SpanContext().SpanID() {
+		t.Error("AddWeather is not a child of RefreshWeather")
+	}
+	for _, kv := range get.Attributes() {
+		if kv.Key == "weather.city" && kv.Value.AsString() != "moscow" {
+			t.Errorf("weather.city = %q, want moscow", kv.Value.AsString())
+		}
+	}
+	if get.Status().Code == codes.Error {
+		t.Errorf("successful request has status %+v", get.Status())
+	}
+}
+
+func TestGetWeatherSpanError(t *testing.T) {
+	span := recordSpans(t)
+	upstream := &fakeUpstream{geocodeErr: errors.New("geocoding unavailable")}
+	svc := newRefreshService(newFakeStore(), upstream, config.WeatherConfig{MaxAge: time.Minute})
+
+	if _, err := svc.GetWeather(context.Background(), "moscow"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	for _, name := range []string{"WeatherService.GetWeather", "WeatherService.RefreshWeather"} {
+		if s := span(name); s.Status().Code != codes.Error || s.Status().Description != "geocoding unavailable" {
+			t.Errorf("%s status = %+v, want the upstream error", name, s.Status())
+		}
+	}
+}
+
+func TestHistorySpans(t *testing.T) {
+	span := recordSpans(t)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	store.history = hourlyHistory(from, 3)
+	svc := newTestService(store)
+	query := models.HistoryQuery{City: "moscow", From: from, To: from.Add(3 * time.Hour), Step: time.Hour, Agg: models.AggMax, Limit: 10}
+
+	if _, err := svc.GetHistory(context.Background(), query); err != nil {
+		t.Fatal(err)
+	}
+	stop := errors.New("client gone")
+	if err := svc.StreamHistory(context.Background(), query, func(models.HistoryPoint) error { return stop }); !errors.Is(err, stop) {
+		t.Fatalf("StreamHistory error = %v, want callback error", err)
+	}
+
+	want := map[attribute.Key]string{"weather.city": "moscow", "weather.step": "1h0m0s", "weather.agg": models.AggMax}
+	for _, name := range []string{"WeatherService.GetHistory", "WeatherService.StreamHistory"} {
+		s := span(name)
+		got := make(map[attribute.Key]string)
+		for _, kv := range s.Attributes() {
+			got[kv.Key] = kv.Value.AsString()
+		}
+		for key, value := range want {
+			if got[key] != value {
+				t.Errorf("%s %s = %q, want %q", name, key, got[key], value)
+			}
+		}
+	}
+
+	// Ошибка передачи точек клиенту завершает спан выгрузки с ошибкой
+	if s := span("WeatherService.StreamHistory"); s.Status().Code != codes.Error || s.Status().Description != "client gone" {
+		t.Errorf("StreamHistory status = %+v, want the callback error", s.Status())
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/olezhek28/wether-service/internal/events"
+)
+
+// WatchWeather подписывает на новые показания города
+// Если afterID > 0, дополнительно возвращает сохраненные показания с id больше afterID
+// (не более resumeLimit), чтобы клиент мог продолжить поток после переподключения
+// Подписка оформляется до чтения пропущенных показаний, поэтому показание,
+// сохраненное между этими шагами, не теряется: оно может прийти и в backlog, и
+// в подписку, и вызывающий должен пропускать события с id не больше последнего отправленного
+// Показания приходят с ключом города (см. ResolveCity), а не названием из запроса
+// Вызывающий обязан отменить подписку через Unsubscribe
+func (w *WeatherService) WatchWeather(ctx context.Context, city string, afterID int64, resumeLimit int) (*events.Subscription, []models.WeatherDTO, error) {
+	city, err := w.ResolveCity(ctx, city)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sub := w.broker.Subscribe(events.ReadingTopic(city))
+
+	if afterID <= 0 {
+		return sub, nil, nil
+	}
+
+	backlog, err := w.weatherProvider.ReadWeatherSince(ctx, city, afterID, resumeLimit)
+	if err != nil {
+		sub.Unsubscribe()
+		return nil, nil, err
+	}
+
+	return sub, backlog, nil
+}
+
+// SubscribeEvents создает пустую подписку на события брокера
+// Темы (города и каналы оповещений) добавляются и удаляются вызывающим во время работы
+// Вызывающий обязан отменить подписку через Unsubscribe
+func (w *WeatherService) SubscribeEvents() *events.Subscription {
+	return w.broker.Subscribe()
+}
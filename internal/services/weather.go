@@ -2,62 +2,512 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
 	"time"
 
+	"github.com/olezhek28/wether-service/internal/clients"
+	"github.com/olezhek28/wether-service/internal/config"
 	"github.com/olezhek28/wether-service/internal/domain/models"
+	"github.com/olezhek28/wether-service/internal/events"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+	"github.com/olezhek28/wether-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 )
 
 // WeatherSaver определяет контракт для сохранения погодных данных
 // Это интерфейс, который абстрагирует конкретную реализацию хранилища
 type WeatherSaver interface {
-	CreateWeatherCity(ctx context.Context, name string, temperature float64, timestamp time.Time) error
+	CreateWeatherCity(ctx context.Context, weather models.WeatherDTO) (int64, bool, error)
+	SaveLocation(ctx context.Context, name string, geoID int64, location models.Location) error
+	SaveAliases(ctx context.Context, city string, aliases []string, source string) ([]string, error)
+	DeleteAlias(ctx context.Context, city, alias string) error
+}
+
+// AlertNotifier определяет контракт для рассылки сработавших оповещений
+type AlertNotifier interface {
+	NotifyAlert(ctx context.Context, alert models.Alert) error
 }
 
 // WeatherProvider определяет контракт для получения погодных данных
 // Интерфейс позволяет работать с разными источниками данных (БД, API, кэш и т.д.)
 type WeatherProvider interface {
 	ReadWeatherByCity(ctx context.Context, city string) (models.WeatherDTO, error)
+	ReadWeatherByCities(ctx context.Context, cities []string) ([]models.WeatherDTO, error)
+	ReadWeatherHistory(ctx context.Context, query models.HistoryQuery) ([]models.HistoryPoint, error)
+	StreamWeatherHistory(ctx context.Context, query models.HistoryQuery, fn func(models.HistoryPoint) error) error
+	ReadWeatherSince(ctx context.Context, city string, afterID int64, limit int) ([]models.WeatherDTO, error)
+	ReadWeatherStats(ctx context.Context, city, variable string, from, to time.Time) (models.Stats, error)
+	ReadLocations(ctx context.Context) ([]models.CityLocation, error)
+	ReadLocationsWithin(ctx context.Context, box models.BoundingBox) ([]models.CityLocation, error)
+	ReadCityByAlias(ctx context.Context, alias string) (string, error)
+	ReadCitiesByAliases(ctx context.Context, aliases []string) (map[string]string, error)
+	ReadCityByGeoID(ctx context.Context, geoID int64) (string, error)
+	ReadAliases(ctx context.Context, city string) ([]models.CityAlias, error)
+	AlertReader
+}
+
+// Ограничения на параметры исторических запросов
+const (
+	minHistoryStep = time.Minute // Минимальная длина интервала агрегации
+	maxHistoryPage = 5000        // Максимальное количество точек на одной странице
+)
+
+// Ограничения пакетного запроса погоды
+const (
+	maxBatchCities   = 100 // Максимальное количество городов в одном запросе
+	batchRefreshJobs = 4   // Сколько городов одного запроса одновременно обновляются из внешних API
+)
+
+// attrCity возвращает атрибут спана с названием города
+func attrCity(city string) attribute.KeyValue {
+	return attribute.String("weather.city", city)
+}
+
+// historyAttrs возвращает атрибуты спана исторического запроса: город, шаг и агрегацию
+func historyAttrs(query models.HistoryQuery) trace.SpanStartOption {
+	return trace.WithAttributes(
+		attrCity(query.City),
+		attribute.String("weather.step", query.Step.String()),
+		attribute.String("weather.agg", query.Agg),
+	)
+}
+
+// Geocoder определяет контракт для получения координат города по названию
+// GetLocalized выполняет тот же поиск с названием места на другом языке
+type Geocoder interface {
+	GetCoordinate(ctx context.Context, city string) (clients.GeocodingResponse, error)
+	GetLocalized(ctx context.Context, city, language string) (clients.GeocodingResponse, error)
+}
+
+// Forecaster определяет контракт для получения текущей погоды по координатам
+type Forecaster interface {
+	GetTemperature(ctx context.Context, lat, long float64) (clients.OpenMeteoResponse, error)
+}
+
+// ReadingBroker определяет контракт для подписки на новые показания
+type ReadingBroker interface {
+	Subscribe(topics ...string) *events.Subscription
 }
 
 // WeatherService представляет сервисный слой для работы с погодными данными
 // Реализует бизнес-логику приложения, используя внедренные зависимости
 type WeatherService struct {
-	weatherSaver    WeatherSaver    // зависимость для сохранения данных
-	weatherProvider WeatherProvider // зависимость для получения данных
+	weatherSaver    WeatherSaver         // зависимость для сохранения данных
+	weatherProvider WeatherProvider      // зависимость для получения данных
+	geocoder        Geocoder             // зависимость для геокодинга городов
+	forecaster      Forecaster           // зависимость для получения текущей погоды из внешнего API
+	broker          ReadingBroker        // зависимость для подписки на новые показания
+	alerts          *alertEvaluator      // правила оповещений, проверяемые при сохранении показаний
+	config          config.WeatherConfig // параметры свежести данных
+	refreshGroup    singleflight.Group   // объединяет одновременные запросы к внешним API для одного города
 }
 
 // New создает новый экземпляр WeatherService с внедренными зависимостями
-// Принимает реализации интерфейсов WeatherSaver и WeatherProvider
+// Принимает реализации интерфейсов WeatherSaver и WeatherProvider,
+// клиенты внешних API, брокер показаний, правила оповещений и параметры свежести данных
 // Это пример Dependency Injection (DI) - принцип инверсии зависимостей
-func New(weatherSaver WeatherSaver, weatherProvider WeatherProvider) *WeatherService {
+func New(
+	weatherSaver WeatherSaver,
+	weatherProvider WeatherProvider,
+	geocoder Geocoder,
+	forecaster Forecaster,
+	broker ReadingBroker,
+	alertNotifier AlertNotifier,
+	alertRules []config.AlertRule,
+	config config.WeatherConfig,
+) *WeatherService {
 	return &WeatherService{
 		weatherSaver:    weatherSaver,
 		weatherProvider: weatherProvider,
+		geocoder:        geocoder,
+		forecaster:      forecaster,
+		broker:          broker,
+		alerts:          newAlertEvaluator(alertNotifier, weatherProvider, weatherProvider, alertRules),
+		config:          config,
 	}
 }
 
 // AddWeather добавляет новые погодные данные для города
 // Делегирует операцию сохранения реализации WeatherSaver
 // Является фасадом над методом хранилища, может содержать дополнительную бизнес-логику
-func (w *WeatherService) AddWeather(ctx context.Context, name string, temperature float64, timestamp time.Time) error {
-	return w.weatherSaver.CreateWeatherCity(ctx, name, temperature, timestamp)
+func (w *WeatherService) AddWeather(ctx context.Context, weather models.WeatherDTO) error {
+	_, _, err := w.SaveReading(ctx, weather)
+	return err
+}
+
+// SaveReading сохраняет показание и возвращает его с присвоенным идентификатором
+// Это единая точка сохранения показаний: сразу после записи в базу
+// проверяются правила оповещений. Подписчики потоков узнают о новом показании
+// из уведомления, которое отправляет триггер таблицы reading
+// Повторно полученное показание (тот же город, станция и время измерения) не создает
+// новой записи, поэтому ни уведомления, ни проверки правил для него нет: created = false
+func (w *WeatherService) SaveReading(ctx context.Context, weather models.WeatherDTO) (_ models.WeatherDTO, created bool, err error) {
+	ctx, span := tracing.Start(ctx, "WeatherService.AddWeather", trace.WithAttributes(attrCity(weather.Name)))
+	defer func() { tracing.End(span, err) }()
+
+	id, created, err := w.weatherSaver.CreateWeatherCity(ctx, weather)
+	if err != nil {
+		return models.WeatherDTO{}, false, err
+	}
+	weather.ID = id
+
+	if created {
+		w.alerts.evaluate(ctx, weather)
+	}
+
+	return weather, created, nil
 }
 
 // GetWeather получает погодные данные для указанного города
 // Возвращает данные в формате доменной модели Weather
 // Преобразует DTO (Data Transfer Object) в доменную модель
-func (w *WeatherService) GetWeather(ctx context.Context, city string) (models.Weather, error) {
-	var weather models.Weather // Доменная модель для возврата
+// Если сохраненных данных нет или они старше maxAge, данные запрашиваются
+// из внешних API, сохраняются и возвращаются (read-through)
+func (w *WeatherService) GetWeather(ctx context.Context, city string) (_ models.Weather, err error) {
+	ctx, span := tracing.Start(ctx, "WeatherService.GetWeather", trace.WithAttributes(attrCity(city)))
+	defer func() { tracing.End(span, err) }()
+
+	// Любой псевдоним города читает и пополняет один и тот же ряд
+	city, err = w.ResolveCity(ctx, city)
+	if err != nil {
+		return models.Weather{}, err
+	}
 
 	// Получаем данные через провайдер в формате DTO
-	dto, err := w.weatherProvider.ReadWeatherByCity(ctx, city)
+	dto, readErr := w.weatherProvider.ReadWeatherByCity(ctx, city)
+	if readErr != nil && !errors.Is(readErr, models.ErrCityNotFound) {
+		return models.Weather{}, readErr // Возвращаем ошибку если хранилище недоступно
+	}
+
+	return w.resolveWeather(ctx, city, dto, readErr == nil)
+}
+
+// GetWeatherBatch возвращает последние показания нескольких городов
+// Сохраненные показания читаются одним запросом к хранилищу, а города без данных
+// или с устаревшими данными обновляются из внешних API так же, как в GetWeather
+// Ошибка отдельного города возвращается в его результате, ошибка возвращается
+// целиком только для некорректного запроса или недоступного хранилища
+// Повторы городов убираются, порядок результатов совпадает с порядком первых упоминаний
+// Города результатов названы так же, как в запросе, а не ключами, в которые они разрешились
+func (w *WeatherService) GetWeatherBatch(ctx context.Context, cities []string) (_ []models.CityWeather, err error) {
+	cities = uniqueCities(cities)
+
+	ctx, span := tracing.Start(ctx, "WeatherService.GetWeatherBatch", trace.WithAttributes(attribute.Int("weather.cities", len(cities))))
+	defer func() { tracing.End(span, err) }()
+
+	if len(cities) == 0 {
+		return nil, fmt.Errorf("%w: at least one city is required", models.ErrInvalidQuery)
+	}
+	if len(cities) > maxBatchCities {
+		return nil, fmt.Errorf("%w: at most %d cities are allowed", models.ErrInvalidQuery, maxBatchCities)
+	}
+
+	keys, err := w.resolveCities(ctx, cities)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := w.weatherProvider.ReadWeatherByCities(ctx, slices.Collect(maps.Values(keys)))
+	if err != nil {
+		return nil, err
+	}
+
+	byCity := make(map[string]models.WeatherDTO, len(stored))
+	for _, dto := range stored {
+		byCity[dto.Name] = dto
+	}
+
+	// Каждая горутина пишет только в свой элемент results, поэтому синхронизация не нужна
+	results := make([]models.CityWeather, len(cities))
+	g := new(errgroup.Group)
+	g.SetLimit(batchRefreshJobs)
+
+	for i, city := range cities {
+		results[i].City = city
+
+		key, ok := keys[city]
+		if !ok {
+			results[i].Err = fmt.Errorf("%w: city must not be empty", models.ErrInvalidQuery)
+			continue
+		}
+
+		dto, ok := byCity[key]
+		if ok && !w.isExpired(dto) {
+			weather := w.presentWeather(dto)
+			results[i].Weather = &weather
+			continue
+		}
+
+		g.Go(func() error {
+			weather, err := w.resolveWeather(ctx, key, dto, ok)
+			if err != nil {
+				results[i].Err = err
+				return nil
+			}
+			results[i].Weather = &weather
+			return nil
+		})
+	}
+	g.Wait()
+
+	return results, nil
+}
+
+// GetStoredWeather возвращает сохраненные показания городов без обращения к внешним API
+// Город без показаний получает в результате models.ErrCityNotFound. Используется веб-панелью:
+// ее города обновляет сбор по расписанию, а запросы к панели не тратят запросы к внешним API
+// Повторы городов убираются, города результатов названы так же, как в запросе
+func (w *WeatherService) GetStoredWeather(ctx context.Context, cities []string) (_ []models.CityWeather, err error) {
+	cities = uniqueCities(cities)
+
+	ctx, span := tracing.Start(ctx, "WeatherService.GetStoredWeather", trace.WithAttributes(attribute.Int("weather.cities", len(cities))))
+	defer func() { tracing.End(span, err) }()
+
+	keys, err := w.resolveCities(ctx, cities)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := w.weatherProvider.ReadWeatherByCities(ctx, slices.Collect(maps.Values(keys)))
 	if err != nil {
-		return models.Weather{}, err // Возвращаем ошибку если данные не получены
+		return nil, err
+	}
+
+	byCity := make(map[string]models.WeatherDTO, len(stored))
+	for _, dto := range stored {
+		byCity[dto.Name] = dto
+	}
+
+	results := make([]models.CityWeather, len(cities))
+	for i, city := range cities {
+		results[i].City = city
+
+		dto, ok := byCity[keys[city]]
+		if !ok {
+			results[i].Err = models.ErrCityNotFound
+			continue
+		}
+		weather := w.presentWeather(dto)
+		results[i].Weather = &weather
+	}
+
+	return results, nil
+}
+
+// uniqueCities убирает пустые названия и повторы, сохраняя порядок
+func uniqueCities(cities []string) []string {
+	seen := make(map[string]struct{}, len(cities))
+	unique := make([]string, 0, len(cities))
+	for _, city := range cities {
+		if _, ok := seen[city]; ok || city == "" {
+			continue
+		}
+		seen[city] = struct{}{}
+		unique = append(unique, city)
+	}
+	return unique
+}
+
+// resolveWeather возвращает показание города по результату чтения из хранилища
+// stored сообщает, что в хранилище нашлось показание dto
+// Если сохраненных данных нет или они старше maxAge, данные запрашиваются
+// из внешних API; при недоступности внешних API отдаются сохраненные данные
+func (w *WeatherService) resolveWeather(ctx context.Context, city string, dto models.WeatherDTO, stored bool) (models.Weather, error) {
+	// Данных нет или они устарели - запрашиваем свежие из внешних API
+	if !stored || w.isExpired(dto) {
+		fresh, refreshErr := w.refreshShared(ctx, city)
+		switch {
+		case refreshErr == nil:
+			dto = fresh
+		case !stored:
+			// Сохраненных данных нет, отдавать нечего
+			return models.Weather{}, refreshErr
+		default:
+			// Внешний API недоступен, но есть устаревшие данные - лучше вернуть их, чем ошибку
+			pkg.FromContext(ctx).Error("failed to refresh weather, serving stored data", "city", city, "error", refreshErr)
+		}
 	}
 
-	// Преобразуем DTO в доменную модель
-	// Метод ToWeather вероятно заполняет поля структуры Weather
+	return w.presentWeather(dto), nil
+}
+
+// presentWeather преобразует DTO (Data Transfer Object) в доменную модель
+// Возраст и признак устаревания считаются от времени измерения на момент ответа
+func (w *WeatherService) presentWeather(dto models.WeatherDTO) models.Weather {
+	var weather models.Weather
 	dto.ToWeather(&weather)
 
-	return weather, nil // Возвращаем доменную модель
+	age := time.Since(dto.Timestamp)
+	weather.AgeSeconds = int64(age / time.Second)
+	weather.Stale = w.config.StaleAfter > 0 && age > w.config.StaleAfter
+
+	return weather
+}
+
+// GetHistory возвращает страницу агрегированного исторического ряда для города
+// Проверяет параметры запроса, применяет курсор и формирует курсор следующей страницы
+// Для определения наличия следующей страницы из хранилища запрашивается на одну точку больше
+func (w *WeatherService) GetHistory(ctx context.Context, query models.HistoryQuery) (_ models.History, err error) {
+	ctx, span := tracing.Start(ctx, "WeatherService.GetHistory", historyAttrs(query))
+	defer func() { tracing.End(span, err) }()
+
+	// Курсор привязан к ключу города, поэтому действует при запросе по любому псевдониму
+	if query.City, err = w.ResolveCity(ctx, query.City); err != nil {
+		return models.History{}, err
+	}
+
+	query, err = prepareHistoryQuery(query)
+	if err != nil {
+		return models.History{}, err
+	}
+
+	page := query
+	page.Limit = query.Limit + 1
+
+	points, err := w.weatherProvider.ReadWeatherHistory(ctx, page)
+	if err != nil {
+		return models.History{}, err
+	}
+
+	history := models.History{
+		Name:   query.City,
+		Step:   query.Step.String(),
+		Agg:    query.Agg,
+		Points: points,
+	}
+
+	// Лишняя точка означает, что данные продолжаются: она становится началом следующей страницы
+	if len(points) > query.Limit {
+		history.NextCursor = models.EncodeCursor(query, points[query.Limit].Timestamp)
+		history.Points = points[:query.Limit]
+	}
+
+	return history, nil
+}
+
+// StreamHistory передает в fn все точки исторического ряда начиная с курсора
+// Используется для выгрузки в CSV и NDJSON, параметр Limit не применяется
+// Ряд читается одним запросом к хранилищу, и каждая точка передается в fn по мере чтения:
+// выгрузка соответствует одному снимку данных, а в памяти находится одна точка
+// Спан охватывает всю выгрузку, включая передачу точек клиенту
+func (w *WeatherService) StreamHistory(ctx context.Context, query models.HistoryQuery, fn func(models.HistoryPoint) error) (err error) {
+	ctx, span := tracing.Start(ctx, "WeatherService.StreamHistory", historyAttrs(query))
+	defer func() { tracing.End(span, err) }()
+
+	city, err := w.ResolveCity(ctx, query.City)
+	if err != nil {
+		return err
+	}
+	query.City = city
+
+	// Limit проверяется вместе с остальными параметрами, но к выгрузке не применяется
+	query.Limit = maxHistoryPage
+	query, err = prepareHistoryQuery(query)
+	if err != nil {
+		return err
+	}
+
+	return w.weatherProvider.StreamWeatherHistory(ctx, query, fn)
+}
+
+// prepareHistoryQuery проверяет параметры исторического запроса и применяет курсор
+// Курсор хранит начало следующей страницы и сужает диапазон слева
+func prepareHistoryQuery(query models.HistoryQuery) (models.HistoryQuery, error) {
+	if !query.From.Before(query.To) {
+		return models.HistoryQuery{}, fmt.Errorf("%w: from must be before to", models.ErrInvalidQuery)
+	}
+	if query.Step < minHistoryStep {
+		return models.HistoryQuery{}, fmt.Errorf("%w: step must be at least %s", models.ErrInvalidQuery, minHistoryStep)
+	}
+	if query.Limit <= 0 || query.Limit > maxHistoryPage {
+		return models.HistoryQuery{}, fmt.Errorf("%w: limit must be between 1 and %d", models.ErrInvalidQuery, maxHistoryPage)
+	}
+	for _, variable := range query.Variables {
+		if !models.IsVariable(variable) {
+			return models.HistoryQuery{}, fmt.Errorf("%w: unsupported variable %q", models.ErrInvalidQuery, variable)
+		}
+	}
+
+	if query.Cursor != "" {
+		next, err := models.DecodeCursor(query, query.Cursor)
+		if err != nil {
+			return models.HistoryQuery{}, err
+		}
+		if next.Before(query.From) || !next.Before(query.To) {
+			return models.HistoryQuery{}, fmt.Errorf("%w: cursor is outside of the requested range", models.ErrInvalidQuery)
+		}
+		query.From = next
+	}
+
+	return query, nil
+}
+
+// GetStats возвращает сводную статистику переменной показания для города за период
+// Для day, week и month диапазон отсчитывается назад от query.To,
+// для custom используется явно переданный диапазон [From, To)
+// Без переменной статистика считается по температуре
+func (w *WeatherService) GetStats(ctx context.Context, query models.StatsQuery) (_ models.Stats, err error) {
+	ctx, span := tracing.Start(ctx, "WeatherService.GetStats", trace.WithAttributes(attrCity(query.City)))
+	defer func() { tracing.End(span, err) }()
+
+	city, err := w.ResolveCity(ctx, query.City)
+	if err != nil {
+		return models.Stats{}, err
+	}
+
+	from, to := query.From, query.To
+
+	variable := query.Variable
+	if variable == "" {
+		variable = models.VariableTemperature
+	}
+	if !models.IsVariable(variable) {
+		return models.Stats{}, fmt.Errorf("%w: unsupported variable %q", models.ErrInvalidQuery, variable)
+	}
+
+	// Для фиксированных периодов начало диапазона вычисляется, явное from было бы молча проигнорировано
+	if query.Period != models.PeriodCustom && !from.IsZero() {
+		return models.Stats{}, fmt.Errorf("%w: from is only allowed for custom period", models.ErrInvalidQuery)
+	}
+
+	switch query.Period {
+	case models.PeriodDay:
+		from = to.Add(-24 * time.Hour)
+	case models.PeriodWeek:
+		from = to.Add(-7 * 24 * time.Hour)
+	case models.PeriodMonth:
+		from = to.AddDate(0, -1, 0)
+	case models.PeriodCustom:
+		if from.IsZero() {
+			return models.Stats{}, fmt.Errorf("%w: from is required for custom period", models.ErrInvalidQuery)
+		}
+	default:
+		return models.Stats{}, fmt.Errorf("%w: period must be one of day, week, month, custom", models.ErrInvalidQuery)
+	}
+
+	if !from.Before(to) {
+		return models.Stats{}, fmt.Errorf("%w: from must be before to", models.ErrInvalidQuery)
+	}
+
+	stats, err := w.weatherProvider.ReadWeatherStats(ctx, city, variable, from, to)
+	if err != nil {
+		return models.Stats{}, err
+	}
+
+	stats.Variable = variable
+	stats.Period = query.Period
+
+	return stats, nil
+}
+
+// ListLocations возвращает города, прошедшие геокодинг
+// Город попадает в список при первом обновлении данных из внешних API
+func (w *WeatherService) ListLocations(ctx context.Context) ([]models.CityLocation, error) {
+	return w.weatherProvider.ReadLocations(ctx)
 }
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// newTestService создает сервис поверх fakeStore без внешних API
+func newTestService(store *fakeStore) *WeatherService {
+	return New(store, store, nil, nil, nil, store, nil, config.WeatherConfig{})
+}
+
+// hourlyHistory возвращает ряд из n часовых точек начиная с from
+func hourlyHistory(from time.Time, n int) []models.HistoryPoint {
+	points := make([]models.HistoryPoint, n)
+	for i := range points {
+		points[i] = models.HistoryPoint{Timestamp: from.Add(time.Duration(i) * time.Hour), Temperature: float64(i), Count: 1}
+	}
+	return points
+}
+
+func TestGetHistoryPages(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	store.history = hourlyHistory(from, 5)
+	svc := newTestService(store)
+	ctx := context.Background()
+
+	query := models.HistoryQuery{City: "moscow", From: from, To: from.Add(24 * time.Hour), Step: time.Hour, Agg: models.AggAvg, Limit: 2}
+
+	// Страницы по 2 точки: 2 + 2 + 1, курсор есть у всех страниц, кроме последней
+	var got []models.HistoryPoint
+	for page := 1; ; page++ {
+		history, err := svc.GetHistory(ctx, query)
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		if store.historyQuery.Limit != query.Limit+1 {
+			t.Errorf("page %d: storage limit = %d, want limit+1 = %d", page, store.historyQuery.Limit, query.Limit+1)
+		}
+		if len(history.Points) > query.Limit {
+			t.Fatalf("page %d: got %d points, limit is %d", page, len(history.Points), query.Limit)
+		}
+		got = append(got, history.Points...)
+
+		if history.NextCursor == "" {
+			if page != 3 {
+				t.Errorf("got %d pages, want 3", page)
+			}
+			break
+		}
+		query.Cursor = history.NextCursor
+	}
+
+	if len(got) != len(store.history) {
+		t.Fatalf("got %d points across pages, want %d", len(got), len(store.history))
+	}
+	for i := range got {
+		if !got[i].Timestamp.Equal(store.history[i].Timestamp) {
+			t.Errorf("point %d: timestamp %v, want %v", i, got[i].Timestamp, store.history[i].Timestamp)
+		}
+	}
+}
+
+func TestGetHistoryExactPage(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	store.history = hourlyHistory(from, 2)
+
+	// Ровно limit точек - следующей страницы нет
+	history, err := newTestService(store).GetHistory(context.Background(), models.HistoryQuery{
+		City: "moscow", From: from, To: from.Add(24 * time.Hour), Step: time.Hour, Agg: models.AggAvg, Limit: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Points) != 2 || history.NextCursor != "" {
+		t.Errorf("got %d points and cursor %q, want 2 points and no cursor", len(history.Points), history.NextCursor)
+	}
+}
+
+func TestGetHistoryInvalidQuery(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	valid := models.HistoryQuery{City: "moscow", From: from, To: from.Add(24 * time.Hour), Step: time.Hour, Agg: models.AggAvg, Limit: 10}
+
+	tests := []struct {
+		name   string
+		modify func(q *models.HistoryQuery)
+	}{
+		{name: "from after to", modify: func(q *models.HistoryQuery) { q.From = q.To.Add(time.Hour) }},
+		{name: "step below minimum", modify: func(q *models.HistoryQuery) { q.Step = time.Second }},
+		{name: "zero limit", modify: func(q *models.HistoryQuery) { q.Limit = 0 }},
+		{name: "limit above maximum", modify: func(q *models.HistoryQuery) { q.Limit = maxHistoryPage + 1 }},
+		{name: "unknown variable", modify: func(q *models.HistoryQuery) { q.Variables = []string{"pressure"} }},
+		{name: "malformed cursor", modify: func(q *models.HistoryQuery) { q.Cursor = "not a cursor" }},
+		{name: "cursor outside range", modify: func(q *models.HistoryQuery) { q.Cursor = models.EncodeCursor(*q, q.To.Add(time.Hour)) }},
+		{name: "cursor for another step", modify: func(q *models.HistoryQuery) {
+			other := *q
+			other.Step = 15 * time.Minute
+			q.Cursor = models.EncodeCursor(other, q.From.Add(time.Hour))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := valid
+			tt.modify(&query)
+
+			_, err := newTestService(newFakeStore()).GetHistory(context.Background(), query)
+			if !errors.Is(err, models.ErrInvalidQuery) {
+				t.Errorf("err = %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
+
+func TestStreamHistorySingleQuery(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	store.history = hourlyHistory(from, 2*maxHistoryPage+1)
+
+	var got []models.HistoryPoint
+	err := newTestService(store).StreamHistory(context.Background(), models.HistoryQuery{
+		City: "moscow", From: from, To: from.Add(time.Duration(len(store.history)) * time.Hour), Step: time.Hour, Agg: models.AggAvg,
+	}, func(p models.HistoryPoint) error {
+		got = append(got, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ряд длиннее страницы истории читается одним запросом, без повторной агрегации по страницам
+	if store.historyReads != 1 {
+		t.Errorf("storage was queried %d times, want a single query", store.historyReads)
+	}
+	if len(got) != len(store.history) {
+		t.Fatalf("streamed %d points, want %d", len(got), len(store.history))
+	}
+	for i := range got {
+		if !got[i].Timestamp.Equal(store.history[i].Timestamp) {
+			t.Fatalf("point %d: timestamp %v, want %v", i, got[i].Timestamp, store.history[i].Timestamp)
+		}
+	}
+}
+
+func TestStreamHistoryStopsOnCallbackError(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	store.history = hourlyHistory(from, 10)
+	stop := errors.New("client gone")
+
+	calls := 0
+	err := newTestService(store).StreamHistory(context.Background(), models.HistoryQuery{
+		City: "moscow", From: from, To: from.Add(24 * time.Hour), Step: time.Hour, Agg: models.AggAvg,
+	}, func(models.HistoryPoint) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want callback error after 1 call", err, calls)
+	}
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// ReadCityByAlias возвращает ключ города по нормализованному псевдониму
+// Возвращает models.ErrCityNotFound, если псевдоним неизвестен
+func (w *Weather) ReadCityByAlias(ctx context.Context, alias string) (string, error) {
+	var name string
+	err := w.db.QueryRow(ctx, "select name from location_alias where alias = $1", alias).Scan(&name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", models.ErrCityNotFound
+	}
+	return name, err
+}
+
+// ReadCitiesByAliases возвращает ключи городов по нормализованным псевдонимам одним запросом
+// Неизвестные псевдонимы в результат не попадают
+func (w *Weather) ReadCitiesByAliases(ctx context.Context, aliases []string) (map[string]string, error) {
+	rows, err := w.db.Query(ctx, "select alias, name from location_alias where alias = any($1)", aliases)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cities := make(map[string]string, len(aliases))
+	for rows.Next() {
+		var alias, name string
+		if err := rows.Scan(&alias, &name); err != nil {
+			return nil, err
+		}
+		cities[alias] = name
+	}
+
+	return cities, rows.Err()
+}
+
+// ReadCityByGeoID возвращает ключ города, местоположение которого - место geoID в GeoNames
+// Возвращает models.ErrCityNotFound, если такого города нет
+func (w *Weather) ReadCityByGeoID(ctx context.Context, geoID int64) (string, error) {
+	var name string
+	err := w.db.QueryRow(ctx, "select name from location where geonames_id = $1", geoID).Scan(&name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", models.ErrCityNotFound
+	}
+	return name, err
+}
+
+// ReadAliases возвращает псевдонимы города в порядке добавления
+func (w *Weather) ReadAliases(ctx context.Context, city string) ([]models.CityAlias, error) {
+	query := `select alias, name, source, created_at
+from location_alias
+where name = $1
+order by created_at, alias`
+
+	rows, err := w.db.Query(ctx, query, city)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.CityAlias])
+}
+
+// SaveAliases добавляет городу нормализованные псевдонимы из источника source
+// Псевдонимы, которые уже указывают на другой город, не переназначаются: первый
+// город, получивший название, сохраняет его. Возвращает псевдонимы, которые
+// принадлежат другим городам
+func (w *Weather) SaveAliases(ctx context.Context, city string, aliases []string, source string) ([]string, error) {
+	query := `insert into location_alias (alias, name, source)
+select unnest($1::text[]), $2, $3
+on conflict (alias) do nothing`
+
+	if _, err := w.db.Exec(ctx, query, aliases, city, source); err != nil {
+		return nil, err
+	}
+
+	rows, err := w.db.Query(ctx, "select alias from location_alias where alias = any($1) and name <> $2 order by alias", aliases, city)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// DeleteAlias удаляет псевдоним города
+// Возвращает models.ErrCityNotFound, если у города нет такого псевдонима
+func (w *Weather) DeleteAlias(ctx context.Context, city, alias string) error {
+	tag, err := w.db.Exec(ctx, "delete from location_alias where name = $1 and alias = $2", city, alias)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrCityNotFound
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// aggFunctions сопоставляет функции агрегации из запроса с SQL-функциями
+// Имя функции подставляется в текст запроса, поэтому допускаются только значения из этой карты
+var aggFunctions = map[string]string{
+	models.AggAvg: "avg",
+	models.AggMin: "min",
+	models.AggMax: "max",
+}
+
+// ReadWeatherHistory возвращает агрегированный по интервалам ряд температур для города
+// Группировка выполняется в PostgreSQL через date_bin, интервалы выровнены от начала эпохи,
+// поэтому соседние страницы стыкуются без пропусков и пересечений
+// Возвращает не более query.Limit точек в порядке возрастания времени
+// Строки читаются целиком до возврата, поэтому соединение с базой не удерживается,
+// пока вызывающий обрабатывает результат
+func (w *Weather) ReadWeatherHistory(ctx context.Context, query models.HistoryQuery) ([]models.HistoryPoint, error) {
+	sql, err := historySQL(query, "\nlimit $5")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := w.db.Query(ctx, sql, query.City, query.Step.Seconds(), query.From, query.To, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// CollectRows закрывает rows и возвращает первую возникшую ошибку
+	return pgx.CollectRows(rows, historyScanner(query.Variables))
+}
+
+// StreamWeatherHistory передает в fn точки того же ряда, что и ReadWeatherHistory, без ограничения
+// количества: ряд читается одним запросом, и каждая точка передается, как только пришла из базы
+// Весь ряд соответствует одному снимку данных, но соединение с базой занято, пока fn
+// обрабатывает точки, поэтому медленный получатель должен прерываться отменой ctx
+// Ошибка fn прекращает чтение и возвращается как есть
+func (w *Weather) StreamWeatherHistory(ctx context.Context, query models.HistoryQuery, fn func(models.HistoryPoint) error) error {
+	sql, err := historySQL(query, "")
+	if err != nil {
+		return err
+	}
+
+	rows, err := w.db.Query(ctx, sql, query.City, query.Step.Seconds(), query.From, query.To)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	scan := historyScanner(query.Variables)
+	for rows.Next() {
+		point, err := scan(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(point); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// historySQL возвращает запрос агрегированного ряда с окончанием tail (например, limit)
+// Параметры запроса: $1 - город, $2 - длина интервала в секундах, $3 и $4 - диапазон
+// Каждая переменная query.Variables агрегируется той же функцией в отдельную колонку
+// после count; значения NULL в агрегат не входят
+func historySQL(query models.HistoryQuery, tail string) (string, error) {
+	agg, ok := aggFunctions[query.Agg]
+	if !ok {
+		return "", fmt.Errorf("%w: unsupported agg %q", models.ErrInvalidQuery, query.Agg)
+	}
+
+	// Колонки нельзя передать параметрами, поэтому они подставляются в текст запроса
+	// Переменные проверяет сервис, экранирование - защита от ошибки вызывающего кода
+	var columns strings.Builder
+	for _, variable := range query.Variables {
+		fmt.Fprintf(&columns, ",\n       %s(%s)", agg, pgx.Identifier{variable}.Sanitize())
+	}
+
+	// Фильтр по (name, timestamp) обслуживается индексом reading_name_timestamp_idx,
+	// поэтому запрос читает только строки из запрошенного диапазона даже на месяцах данных
+	return fmt.Sprintf(`select date_bin(make_interval(secs => $2), timestamp, 'epoch') as bucket,
+       %s(temperature) as temperature,
+       count(*) as count%s
+from reading
+where name = $1 and timestamp >= $3 and timestamp < $4
+group by bucket
+order by bucket`, agg, columns.String()) + tail, nil
+}
+
+// historyScanner возвращает функцию чтения точки ряда из строки запроса historySQL
+// с колонками переменных variables
+func historyScanner(variables []string) pgx.RowToFunc[models.HistoryPoint] {
+	return func(row pgx.CollectableRow) (models.HistoryPoint, error) {
+		var point models.HistoryPoint
+		values := make([]*float64, len(variables))
+
+		dest := []any{&point.Timestamp, &point.Temperature, &point.Count}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := row.Scan(dest...); err != nil {
+			return models.HistoryPoint{}, err
+		}
+
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			if point.Values == nil {
+				point.Values = make(map[string]float64, len(variables))
+			}
+			point.Values[variables[i]] = *v
+		}
+		return point, nil
+	}
+}
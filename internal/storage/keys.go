@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// Keys представляет слой доступа к ключам API и счетчикам их использования
+type Keys struct {
+	db *pgxpool.Pool
+}
+
+// NewKeys создает хранилище ключей API поверх пула подключений
+func NewKeys(db *pgxpool.Pool) *Keys {
+	return &Keys{
+		db: db,
+	}
+}
+
+// keyColumns - колонки api_key в порядке сканирования scanKey
+const keyColumns = `id, name, prefix, scopes, per_minute, per_day, created_at, last_used_at, revoked_at`
+
+// CreateKey сохраняет ключ с хэшем секрета и возвращает его с присвоенным идентификатором
+func (k *Keys) CreateKey(ctx context.Context, key models.APIKey, hash []byte) (models.APIKey, error) {
+	query := `insert into api_key (name, prefix, hash, scopes, per_minute, per_day)
+values ($1, $2, $3, $4, $5, $6)
+returning ` + keyColumns
+
+	return scanKey(k.db.QueryRow(ctx, query, key.Name, key.Prefix, hash, key.Scopes, key.PerMinute, key.PerDay))
+}
+
+// ReadKeyByHash возвращает ключ по хэшу секрета, в том числе отозванный
+// Возвращает ErrUnauthorized, если ключа с таким хэшем нет
+func (k *Keys) ReadKeyByHash(ctx context.Context, hash []byte) (models.APIKey, error) {
+	key, err := scanKey(k.db.QueryRow(ctx, `select `+keyColumns+` from api_key where hash = $1`, hash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.APIKey{}, models.ErrUnauthorized
+	}
+	return key, err
+}
+
+// ReadKeys возвращает все ключи в порядке создания
+func (k *Keys) ReadKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := k.db.Query(ctx, `select `+keyColumns+` from api_key order by id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeKey отзывает ключ; повторный отзыв сохраняет исходное время
+// Возвращает ErrKeyNotFound, если ключа нет
+func (k *Keys) RevokeKey(ctx context.Context, id int64) error {
+	tag, err := k.db.Exec(ctx, `update api_key set revoked_at = coalesce(revoked_at, now()) where id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrKeyNotFound
+	}
+	return nil
+}
+
+// RecordUsage учитывает запрос с ключом и возвращает счетчики текущей минуты и суток
+// с учетом этого запроса. Счетчики хранятся в базе, поэтому квота общая для всех реплик
+// Минутный счетчик хранится в строке ключа и сбрасывается с началом новой минуты,
+// суточный - в api_key_usage, где остается историей использования
+func (k *Keys) RecordUsage(ctx context.Context, id int64) (models.KeyCounters, error) {
+	query := `with minute as (
+    update api_key
+    set minute_count = case when minute_bucket = date_trunc('minute', now()) then minute_count + 1 else 1 end,
+        minute_bucket = date_trunc('minute', now()),
+        last_used_at = now()
+    where id = $1
+    returning minute_count
+), day as (
+    insert into api_key_usage (key_id, day, requests)
+    values ($1, (now() at time zone 'utc')::date, 1)
+    on conflict (key_id, day) do update
+    set requests = api_key_usage.requests + 1
+    returning requests
+)
+select minute.minute_count, day.requests from minute, day`
+
+	var counters models.KeyCounters
+	err := k.db.QueryRow(ctx, query, id).Scan(&counters.Minute, &counters.Day)
+	return counters, err
+}
+
+// ReadKeyUsage возвращает суточное использование ключа начиная с from (UTC) в порядке дат
+// Возвращает ErrKeyNotFound, если ключа нет
+func (k *Keys) ReadKeyUsage(ctx context.Context, id int64, from time.Time) ([]models.KeyUsage, error) {
+	var exists bool
+	if err := k.db.QueryRow(ctx, `select exists(select 1 from api_key where id = $1)`, id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, models.ErrKeyNotFound
+	}
+
+	// Параметр сравнивается с колонкой date, поэтому передается календарная дата from в UTC
+	rows, err := k.db.Query(ctx, `select day, requests from api_key_usage where key_id = $1 and day >= $2 order by day`, id, from.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []models.KeyUsage
+	for rows.Next() {
+		var u models.KeyUsage
+		if err := rows.Scan(&u.Day, &u.Requests); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+
+	return usage, rows.Err()
+}
+
+// scanKey сканирует строку api_key с колонками keyColumns
+func scanKey(row pgx.Row) (models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Scopes, &key.PerMinute, &key.PerDay, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt)
+	return key, err
+}
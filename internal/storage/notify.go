@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+	pkg "github.com/olezhek28/wether-service/internal/pkg/logger"
+)
+
+// Каналы PostgreSQL для уведомлений о событиях
+const (
+	// readingChannel - канал, в который триггер reading_notify отправляет
+	// каждое новое показание в формате row_to_json
+	readingChannel = "reading"
+	// alertChannel - канал сработавших оповещений в формате JSON модели Alert
+	alertChannel = "alert"
+)
+
+// EventHandler определяет контракт получателя событий из уведомлений PostgreSQL
+type EventHandler interface {
+	PublishReading(weather models.WeatherDTO)
+	PublishAlert(alert models.Alert)
+}
+
+// listenRetryDelay - пауза перед повторным подключением после ошибки
+const listenRetryDelay = 5 * time.Second
+
+// ListenEvents подписывается на уведомления о новых показаниях и оповещениях
+// и передает их в handler
+// Уведомления приходят от любой реплики, которая сохранила показание, поэтому
+// подписчики получают события независимо от того, где выполнялся сбор
+// Работает до отмены ctx, после обрыва соединения переподключается
+func (w *Weather) ListenEvents(ctx context.Context, handler EventHandler) {
+	for ctx.Err() == nil {
+		if err := w.listen(ctx, handler); err != nil && ctx.Err() == nil {
+			pkg.FromContext(ctx).Error("reading listener failed, reconnecting", "error", err)
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(listenRetryDelay):
+			}
+		}
+	}
+}
+
+// listen занимает отдельное соединение из пула и читает уведомления до первой ошибки
+func (w *Weather) listen(ctx context.Context, handler EventHandler) error {
+	conn, err := w.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	for _, channel := range []string{readingChannel, alertChannel} {
+		if _, err := conn.Exec(ctx, "listen "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := dispatchNotification(notification.Channel, notification.Payload, handler); err != nil {
+			pkg.FromContext(ctx).Error("failed to decode notification", "channel", notification.Channel, "error", err)
+		}
+	}
+}
+
+// dispatchNotification разбирает уведомление канала reading или alert и передает событие в handler
+// Уведомления других каналов игнорируются
+func dispatchNotification(channel, payload string, handler EventHandler) error {
+	switch channel {
+	case readingChannel:
+		var weather models.WeatherDTO
+		if err := json.Unmarshal([]byte(payload), &weather); err != nil {
+			return err
+		}
+		handler.PublishReading(weather)
+	case alertChannel:
+		var alert models.Alert
+		if err := json.Unmarshal([]byte(payload), &alert); err != nil {
+			return err
+		}
+		handler.PublishAlert(alert)
+	}
+
+	return nil
+}
+
+// NotifyAlert рассылает оповещение всем репликам через канал alert
+// Сами оповещения не хранятся: клиенты, не подключенные в момент срабатывания, их не получат
+func (w *Weather) NotifyAlert(ctx context.Context, alert models.Alert) error {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.db.Exec(ctx, "select pg_notify($1, $2)", alertChannel, string(payload))
+	return err
+}
+
+// ReadWeatherSince возвращает показания города с id больше afterID в порядке id
+// Используется для продолжения потока событий с Last-Event-ID
+// Для показаний, сохраненных после миграции 0003, порядок id совпадает с порядком
+// фиксации (см. CreateWeatherCity), поэтому продолжение не теряет показаний.
+// Старым записям id назначен миграцией в порядке хранения на диске, и при продолжении
+// с id из этого диапазона они приходят в порядке id, а не времени измерения
+func (w *Weather) ReadWeatherSince(ctx context.Context, city string, afterID int64, limit int) ([]models.WeatherDTO, error) {
+	query := `select id, name, timestamp, temperature, fetched_at, source, humidity, wind_speed, station_id, sensor
+from reading
+where name = $1 and id > $2
+order by id
+limit $3`
+
+	rows, err := w.db.Query(ctx, query, city, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByNameLax[models.WeatherDTO])
+}
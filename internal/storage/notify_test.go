@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// recordingHandler запоминает события, переданные из уведомлений
+type recordingHandler struct {
+	readings []models.WeatherDTO
+	alerts   []models.Alert
+}
+
+func (h *recordingHandler) PublishReading(weather models.WeatherDTO) {
+	h.readings = append(h.readings, weather)
+}
+
+func (h *recordingHandler) PublishAlert(alert models.Alert) {
+	h.alerts = append(h.alerts, alert)
+}
+
+func TestDispatchReadingNotification(t *testing.T) {
+	// Формат row_to_json для строки reading, как его отправляет триггер reading_notify
+	payload := `{"name":"moscow","temperature":-3.5,"timestamp":"2025-01-02T03:00:00+00:00",` +
+		`"fetched_at":"2025-01-02T03:05:12.345678+00:00","source":"open-meteo","id":42}`
+
+	var h recordingHandler
+	if err := dispatchNotification(readingChannel, payload, &h); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.readings) != 1 {
+		t.Fatalf("got %d readings, want 1", len(h.readings))
+	}
+
+	got := h.readings[0]
+	if got.ID != 42 || got.Name != "moscow" || got.Temperature != -3.5 {
+		t.Errorf("unexpected reading: %+v", got)
+	}
+	if want := time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC); !got.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %v, want %v", got.Timestamp, want)
+	}
+	if got.FetchedAt == nil || got.FetchedAt.Nanosecond() != 345678000 {
+		t.Errorf("fetched_at = %v, want microsecond precision", got.FetchedAt)
+	}
+	if got.Source == nil || *got.Source != models.SourceOpenMeteo {
+		t.Errorf("source = %v, want %q", got.Source, models.SourceOpenMeteo)
+	}
+}
+
+func TestDispatchLegacyReadingNotification(t *testing.T) {
+	// У старых записей fetched_at и source равны NULL
+	payload := `{"name":"moscow","temperature":1,"timestamp":"2025-01-02T03:00:00+03:00","fetched_at":null,"source":null,"id":1}`
+
+	var h recordingHandler
+	if err := dispatchNotification(readingChannel, payload, &h); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.readings[0]; got.FetchedAt != nil || got.Source != nil {
+		t.Errorf("got fetched_at %v and source %v, want nil", got.FetchedAt, got.Source)
+	}
+}
+
+func TestDispatchReadingNotificationHumidityWind(t *testing.T) {
+	// Вычисленные колонки тоже попадают в row_to_json, модель их пропускает и вычисляет заново
+	payload := `{"name":"moscow","temperature":20,"timestamp":"2025-07-02T03:00:00+00:00","fetched_at":null,"source":null,` +
+		`"id":7,"humidity":50,"wind_speed":12.5,"dew_point":9.3,"heat_index":null,"wind_chill":null,` +
+		`"humidex":null,"absolute_humidity":8.62,"feels_like":20}`
+
+	var h recordingHandler
+	if err := dispatchNotification(readingChannel, payload, &h); err != nil {
+		t.Fatal(err)
+	}
+	got := h.readings[0]
+	if got.Humidity == nil || *got.Humidity != 50 || got.WindSpeed == nil || *got.WindSpeed != 12.5 {
+		t.Fatalf("got humidity %v and wind speed %v, want 50 and 12.5", got.Humidity, got.WindSpeed)
+	}
+	if d := got.Derived(); d.DewPoint == nil || *d.DewPoint != 9.3 {
+		t.Errorf("dew point = %v, want 9.3", d.DewPoint)
+	}
+}
+
+func TestDispatchStationReadingNotification(t *testing.T) {
+	payload := `{"name":"moscow","temperature":-1.2,"timestamp":"2025-01-02T03:04:05+00:00","fetched_at":null,` +
+		`"source":"station","id":9,"station_id":"roof-1"}`
+
+	var h recordingHandler
+	if err := dispatchNotification(readingChannel, payload, &h); err != nil {
+		t.Fatal(err)
+	}
+
+	var weather models.Weather
+	h.readings[0].ToWeather(&weather)
+	if weather.Station != "roof-1" || weather.Source != models.SourceStation {
+		t.Errorf("got station %q and source %q, want roof-1 from station", weather.Station, weather.Source)
+	}
+}
+
+func TestDispatchAlertNotification(t *testing.T) {
+	payload := `{"channel":"frost","rule":"moscow-frost","name":"moscow","variable":"temperature",` +
+		`"op":"<","threshold":0,"value":-2,"reading_id":42,"timestamp":"2025-01-02T03:00:00Z"}`
+
+	var h recordingHandler
+	if err := dispatchNotification(alertChannel, payload, &h); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.alerts) != 1 || h.alerts[0].Channel != "frost" || h.alerts[0].Operator != models.OpLess || h.alerts[0].ReadingID != 42 {
+		t.Errorf("unexpected alerts: %+v", h.alerts)
+	}
+}
+
+func TestDispatchNotificationErrors(t *testing.T) {
+	var h recordingHandler
+
+	if err := dispatchNotification(readingChannel, "not json", &h); err == nil {
+		t.Error("malformed reading payload was accepted")
+	}
+	if err := dispatchNotification(alertChannel, `{"threshold":"zero"}`, &h); err == nil {
+		t.Error("malformed alert payload was accepted")
+	}
+	if err := dispatchNotification("other", "anything", &h); err != nil {
+		t.Errorf("unknown channel: %v", err)
+	}
+	if len(h.readings)+len(h.alerts) != 0 {
+		t.Errorf("handler received events from invalid notifications: %+v", h)
+	}
+}
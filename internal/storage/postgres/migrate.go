@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrations содержит SQL-файлы миграций, встроенные в бинарник.
+// Файлы применяются в лексикографическом порядке имен (0001_, 0002_, ...).
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrate применяет к базе данных все еще не примененные миграции.
+// Список примененных версий хранится в таблице schema_migrations,
+// каждая миграция выполняется в отдельной транзакции.
+func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	query := "create table if not exists schema_migrations (version text primary key, applied_at timestamptz not null default now())"
+	if _, err := pool.Exec(ctx, query); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := applyMigration(ctx, pool, name); err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration выполняет одну миграцию, если она еще не была применена.
+func applyMigration(ctx context.Context, pool *pgxpool.Pool, name string) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка защищает от одновременного применения миграций несколькими репликами
+	if _, err := tx.Exec(ctx, "select pg_advisory_xact_lock(hashtext('schema_migrations'))"); err != nil {
+		return err
+	}
+
+	var applied bool
+	err = tx.QueryRow(ctx, "select exists(select 1 from schema_migrations where version = $1)", name).Scan(&applied)
+	if err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	raw, err := migrations.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, string(raw)); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "insert into schema_migrations (version) values ($1)", name); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
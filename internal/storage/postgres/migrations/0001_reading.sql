@@ -0,0 +1,9 @@
+-- Таблица показаний погоды, в которую пишет cron-задача
+create table if not exists reading (
+    name        text             not null,
+    temperature double precision not null,
+    timestamp   timestamptz      not null
+);
+
+-- Составной индекс под выборки последней записи и исторических диапазонов по городу
+create index if not exists reading_name_timestamp_idx on reading (name, timestamp desc);
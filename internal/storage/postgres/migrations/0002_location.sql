@@ -0,0 +1,13 @@
+-- Местоположения городов по данным геокодинга
+create table if not exists location (
+    name         text primary key,           -- ключ города, под которым хранятся показания
+    display_name text             not null,  -- название по данным геокодинга
+    country      text             not null,
+    latitude     double precision not null,
+    longitude    double precision not null,
+    updated_at   timestamptz      not null default now()
+);
+
+-- Время получения показания сервисом и его источник
+alter table reading add column if not exists fetched_at timestamptz;
+alter table reading add column if not exists source text;
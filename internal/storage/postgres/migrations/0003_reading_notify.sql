@@ -0,0 +1,17 @@
+-- Идентификатор показания: используется как id события в потоке SSE
+-- и для продолжения потока с Last-Event-ID
+alter table reading add column if not exists id bigserial;
+create unique index if not exists reading_id_idx on reading (id);
+
+-- Уведомление о каждом новом показании, чтобы все реплики сервиса
+-- получали его независимо от того, какая реплика выполнила сбор
+create or replace function notify_reading() returns trigger as $$
+begin
+    perform pg_notify('reading', row_to_json(new)::text);
+    return new;
+end;
+$$ language plpgsql;
+
+drop trigger if exists reading_notify on reading;
+create trigger reading_notify after insert on reading
+    for each row execute function notify_reading();
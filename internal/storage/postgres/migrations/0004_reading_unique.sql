@@ -0,0 +1,12 @@
+-- Open-Meteo выравнивает время текущих данных по четверти часа, поэтому повторный
+-- сбор в пределах интервала возвращает то же показание. Одно показание на город
+-- и время измерения: удаляем накопившиеся повторы, оставляя самую раннюю запись
+delete from reading r
+using reading d
+where d.name = r.name
+  and d.timestamp = r.timestamp
+  and d.id < r.id;
+
+-- Уникальный индекс заменяет прежний индекс по (name, timestamp)
+create unique index if not exists reading_name_timestamp_key on reading (name, timestamp);
+drop index if exists reading_name_timestamp_idx;
@@ -0,0 +1,4 @@
+-- Поиск ближайшего города отбирает кандидатов по прямоугольнику вокруг точки:
+-- диапазон широт читается из индекса, долгота проверяется по второй колонке индекса
+-- без обращения к таблице. Точное расстояние по большому кругу считает сервис
+create index if not exists location_latitude_longitude_idx on location (latitude, longitude);
@@ -0,0 +1,24 @@
+-- Ключи API. Хранится только SHA-256 секрета: секрет случайный и длинный,
+-- поэтому медленная функция хэширования паролей не нужна, а поиск по хэшу - точный
+create table if not exists api_key (
+    id            bigserial primary key,
+    name          text        not null,
+    prefix        text        not null,             -- начало секрета, чтобы узнать ключ в списке
+    hash          bytea       not null unique,
+    scopes        text[]      not null,
+    per_minute    integer     not null default 0,   -- квота запросов в минуту, 0 - без ограничения
+    per_day       integer     not null default 0,   -- квота запросов в сутки (UTC), 0 - без ограничения
+    created_at    timestamptz not null default now(),
+    last_used_at  timestamptz,
+    revoked_at    timestamptz,
+    minute_bucket timestamptz,                      -- текущая минута счетчика minute_count
+    minute_count  integer     not null default 0
+);
+
+-- Количество запросов с ключом по суткам (UTC): история использования и счетчик суточной квоты
+create table if not exists api_key_usage (
+    key_id   bigint  not null references api_key (id) on delete cascade,
+    day      date    not null,
+    requests integer not null,
+    primary key (key_id, day)
+);
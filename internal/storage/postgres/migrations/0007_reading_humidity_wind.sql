@@ -0,0 +1,13 @@
+-- Относительная влажность (%) и скорость ветра на высоте 10 м (км/ч) показания
+-- и величины, вычисленные по ним доменным слоем при сохранении (models.Derive).
+-- Вычисленные величины хранятся, чтобы статистика по ним считалась в базе, как по температуре.
+-- NULL - величина неизвестна (старые записи) или условия вне области применимости формулы
+alter table reading
+    add column if not exists humidity          double precision,
+    add column if not exists wind_speed        double precision,
+    add column if not exists dew_point         double precision,
+    add column if not exists heat_index        double precision,
+    add column if not exists wind_chill        double precision,
+    add column if not exists humidex           double precision,
+    add column if not exists absolute_humidity double precision,
+    add column if not exists feels_like        double precision;
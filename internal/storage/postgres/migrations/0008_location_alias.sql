@@ -0,0 +1,58 @@
+-- Канонические ключи городов и псевдонимы
+-- Раньше город определялся точным совпадением названия из запроса, и "Moscow", "moscow"
+-- и "Москва" были тремя разными рядами. Теперь показания хранятся под нормализованным ключом,
+-- а названия из запросов находят его через псевдонимы
+
+-- Ключ старых записей: NFKC, нижний регистр и схлопнутые пробелы. Сервис вместо lower()
+-- выполняет свертку регистра Unicode (models.NormalizeCity), результаты расходятся только
+-- для немногих символов вроде "ß", и такие города получат псевдоним при следующем геокодинге
+create function pg_temp.city_key(name text) returns text
+language sql immutable
+as $$ select btrim(regexp_replace(lower(normalize(name, NFKC)), '\s+', ' ', 'g')) $$;
+
+-- Ряды, различавшиеся только записью названия, объединяются. Из показаний одного времени
+-- остается самое раннее, как при добавлении уникального индекса в 0004
+delete from reading
+where id in (
+    select id
+    from (
+        select id, row_number() over (partition by pg_temp.city_key(name), timestamp order by id) as n
+        from reading
+    ) dup
+    where n > 1
+);
+update reading set name = pg_temp.city_key(name) where name <> pg_temp.city_key(name);
+
+-- Из местоположений одного ключа остается геокодированное последним
+delete from location
+where name in (
+    select name
+    from (
+        select name, row_number() over (partition by pg_temp.city_key(name) order by updated_at desc, name) as n
+        from location
+    ) dup
+    where n > 1
+);
+update location set name = pg_temp.city_key(name) where name <> pg_temp.city_key(name);
+
+-- Идентификатор места в GeoNames из ответа геокодинга: по нему название на другом языке,
+-- геокодированное впервые, становится псевдонимом уже известного города, а не новым рядом
+-- NULL - место геокодировано до появления колонки, идентификатор заполнится при следующем геокодинге
+alter table location add column if not exists geonames_id bigint;
+create unique index if not exists location_geonames_id_key on location (geonames_id);
+
+-- Псевдонимы городов: нормализованное название -> ключ города
+-- Ключ города тоже записывается псевдонимом, чтобы его нельзя было назначить другому городу
+create table if not exists location_alias (
+    alias      text primary key,                   -- нормализованное название (models.NormalizeCity)
+    name       text        not null,               -- ключ города, под которым хранятся показания
+    source     text        not null,               -- geocoding или manual
+    created_at timestamptz not null default now()
+);
+create index if not exists location_alias_name_idx on location_alias (name);
+
+insert into location_alias (alias, name, source)
+select name, name, 'geocoding' from location
+union all
+select pg_temp.city_key(display_name), name, 'geocoding' from location
+on conflict (alias) do nothing;
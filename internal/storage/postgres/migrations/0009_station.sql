@@ -0,0 +1,43 @@
+-- Собственные метеостанции, их ключи и запросы с ключами идемпотентности
+create table if not exists station (
+    id         text primary key,                   -- идентификатор, выбранный администратором
+    name       text        not null,
+    city       text        not null,               -- ключ города, в ряд которого пишутся показания
+    latitude   double precision,
+    longitude  double precision,
+    created_at timestamptz not null default now()
+);
+
+-- Ключи станций. Как и у ключей API, хранится только SHA-256 секрета
+create table if not exists station_key (
+    id           bigserial primary key,
+    station_id   text        not null references station (id) on delete cascade,
+    prefix       text        not null,              -- начало секрета, чтобы узнать ключ в списке
+    hash         bytea       not null unique,
+    created_at   timestamptz not null default now(),
+    last_used_at timestamptz,
+    revoked_at   timestamptz
+);
+create index if not exists station_key_station_id_idx on station_key (station_id);
+
+-- Запросы станций с заголовком Idempotency-Key: повтор с тем же ключом получает
+-- сохраненный результат. result NULL - запрос еще обрабатывается
+create table if not exists station_submission (
+    station_id      text        not null references station (id) on delete cascade,
+    idempotency_key text        not null,
+    request_hash    bytea       not null,           -- SHA-256 нормализованных показаний
+    result          jsonb,
+    created_at      timestamptz not null default now(),
+    primary key (station_id, idempotency_key)
+);
+
+-- Показание станции помечается ее идентификатором, показания внешних API - NULL
+alter table reading add column if not exists station_id text references station (id);
+
+-- Станция может прислать показание города на то же время, что и Open-Meteo, или другая
+-- станция того же города, поэтому уникально показание источника: город, станция и время
+-- Индекс (name, timestamp) остается неуникальным для поиска последних показаний и истории
+create unique index if not exists reading_name_station_timestamp_key
+    on reading (name, station_id, timestamp) nulls not distinct;
+create index if not exists reading_name_timestamp_idx on reading (name, timestamp desc);
+drop index if exists reading_name_timestamp_key;
@@ -0,0 +1,8 @@
+-- Показание датчика MQTT помечается темой сообщения, остальные показания - NULL
+alter table reading add column if not exists sensor text;
+
+-- Датчик может прислать показание города на то же время, что и Open-Meteo, станция
+-- или другой датчик того же города, поэтому датчик входит в ключ показания источника
+create unique index if not exists reading_name_station_sensor_timestamp_key
+    on reading (name, station_id, sensor, timestamp) nulls not distinct;
+drop index if exists reading_name_station_timestamp_key;
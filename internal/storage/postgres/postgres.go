@@ -5,12 +5,15 @@ import (
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/olezhek28/wether-service/internal/config"
 )
 
 // New создает и возвращает новое подключение к пулу PostgreSQL.
 // Принимает контекст выполнения и указатель на конфигурацию приложения.
-func New(context context.Context, config *config.Config) *pgx.Conn {
+// После подключения применяет встроенные миграции схемы.
+// tracer получает каждый запрос пула, например для метрик времени запросов и спанов; может быть nil.
+func New(context context.Context, config *config.Config, tracer pgx.QueryTracer) *pgxpool.Pool {
 	dbHost := fmt.Sprintf(
 		"postgresql://%s:%s@%s:%s/%s",
 		config.DB.Username, // Имя пользователя
@@ -20,14 +23,26 @@ func New(context context.Context, config *config.Config) *pgx.Conn {
 		config.DB.DBName,   // Название базы данных
 	)
 
-	conn, err := pgx.Connect(context, dbHost)
+	// Пул соединений нужен, так как HTTP-обработчики и cron-задачи
+	// обращаются к базе конкурентно, а одиночный pgx.Conn это не поддерживает
+	poolConfig, err := pgxpool.ParseConfig(dbHost)
 	if err != nil {
 		panic(err)
 	}
+	poolConfig.ConnConfig.Tracer = tracer
 
-	if err := conn.Ping(context); err != nil {
+	pool, err := pgxpool.NewWithConfig(context, poolConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := pool.Ping(context); err != nil {
+		panic(err)
+	}
+
+	if err := Migrate(context, pool); err != nil {
 		panic(err)
 	}
 
-	return conn
+	return pool
 }
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// Stations представляет слой доступа к метеостанциям, их ключам и запросам с ключами идемпотентности
+type Stations struct {
+	db *pgxpool.Pool
+}
+
+// NewStations создает хранилище метеостанций поверх пула подключений
+func NewStations(db *pgxpool.Pool) *Stations {
+	return &Stations{
+		db: db,
+	}
+}
+
+// Колонки в порядке сканирования scanStation и scanStationKey
+const (
+	stationColumns    = `id, name, city, latitude, longitude, created_at`
+	stationKeyColumns = `id, station_id, prefix, created_at, last_used_at, revoked_at`
+)
+
+// CreateStation регистрирует станцию
+// Возвращает ErrStationExists, если идентификатор занят
+func (s *Stations) CreateStation(ctx context.Context, station models.NewStation) (models.Station, error) {
+	query := `insert into station (id, name, city, latitude, longitude)
+values ($1, $2, $3, $4, $5)
+on conflict (id) do nothing
+returning ` + stationColumns
+
+	created, err := scanStation(s.db.QueryRow(ctx, query, station.ID, station.Name, station.City, station.Latitude, station.Longitude))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Station{}, models.ErrStationExists
+	}
+	return created, err
+}
+
+// ReadStation возвращает станцию по идентификатору
+// Возвращает ErrStationNotFound, если станции нет
+func (s *Stations) ReadStation(ctx context.Context, id string) (models.Station, error) {
+	station, err := scanStation(s.db.QueryRow(ctx, `select `+stationColumns+` from station where id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Station{}, models.ErrStationNotFound
+	}
+	return station, err
+}
+
+// ReadStations возвращает все станции в порядке идентификаторов
+func (s *Stations) ReadStations(ctx context.Context) ([]models.Station, error) {
+	rows, err := s.db.Query(ctx, `select `+stationColumns+` from station order by id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stations []models.Station
+	for rows.Next() {
+		station, err := scanStation(rows)
+		if err != nil {
+			return nil, err
+		}
+		stations = append(stations, station)
+	}
+
+	return stations, rows.Err()
+}
+
+// CreateStationKey сохраняет ключ станции с хэшем секрета
+// Возвращает ErrStationNotFound, если станции нет
+func (s *Stations) CreateStationKey(ctx context.Context, stationID, prefix string, hash []byte) (models.StationKey, error) {
+	query := `insert into station_key (station_id, prefix, hash)
+select id, $2, $3 from station where id = $1
+returning ` + stationKeyColumns
+
+	key, err := scanStationKey(s.db.QueryRow(ctx, query, stationID, prefix, hash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.StationKey{}, models.ErrStationNotFound
+	}
+	return key, err
+}
+
+// ReadStationKeys возвращает ключи станции, включая отозванные, в порядке создания
+// Возвращает ErrStationNotFound, если станции нет
+func (s *Stations) ReadStationKeys(ctx context.Context, stationID string) ([]models.StationKey, error) {
+	var exists bool
+	if err := s.db.QueryRow(ctx, `select exists(select 1 from station where id = $1)`, stationID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, models.ErrStationNotFound
+	}
+
+	rows, err := s.db.Query(ctx, `select `+stationKeyColumns+` from station_key where station_id = $1 order by id`, stationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.StationKey
+	for rows.Next() {
+		key, err := scanStationKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeStationKey отзывает ключ станции; повторный отзыв сохраняет исходное время
+// Возвращает ErrKeyNotFound, если у станции нет такого ключа
+func (s *Stations) RevokeStationKey(ctx context.Context, stationID string, id int64) error {
+	query := `update station_key set revoked_at = coalesce(revoked_at, now()) where id = $1 and station_id = $2`
+
+	tag, err := s.db.Exec(ctx, query, id, stationID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrKeyNotFound
+	}
+	return nil
+}
+
+// UseStationKey возвращает действующий ключ по хэшу секрета и отмечает время его использования
+// Возвращает ErrInvalidStationKey, если ключа с таким хэшем нет или он отозван
+func (s *Stations) UseStationKey(ctx context.Context, hash []byte) (models.StationKey, error) {
+	query := `update station_key set last_used_at = now()
+where hash = $1 and revoked_at is null
+returning ` + stationKeyColumns
+
+	key, err := scanStationKey(s.db.QueryRow(ctx, query, hash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.StationKey{}, models.ErrInvalidStationKey
+	}
+	return key, err
+}
+
+// ReserveSubmission резервирует ключ идемпотентности станции за запросом с хэшем requestHash
+// Если ключ свободен, возвращает reserved = true. Иначе возвращает ранее сохраненный запрос:
+// вызывающий сравнивает хэши и отдает сохраненный результат. Записи старше expiredBefore
+// удаляются в той же транзакции, поэтому ключ после истечения срока можно использовать снова
+func (s *Stations) ReserveSubmission(ctx context.Context, stationID, key string, requestHash []byte, expiredBefore time.Time) (models.Submission, bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return models.Submission{}, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `delete from station_submission where station_id = $1 and created_at < $2`, stationID, expiredBefore); err != nil {
+		return models.Submission{}, false, err
+	}
+
+	tag, err := tx.Exec(ctx, `insert into station_submission (station_id, idempotency_key, request_hash)
+values ($1, $2, $3)
+on conflict (station_id, idempotency_key) do nothing`, stationID, key, requestHash)
+	if err != nil {
+		return models.Submission{}, false, err
+	}
+	if tag.RowsAffected() == 1 {
+		return models.Submission{RequestHash: requestHash}, true, tx.Commit(ctx)
+	}
+
+	var (
+		submission models.Submission
+		result     []byte
+	)
+	err = tx.QueryRow(ctx, `select request_hash, result from station_submission where station_id = $1 and idempotency_key = $2`, stationID, key).
+		Scan(&submission.RequestHash, &result)
+	if err != nil {
+		return models.Submission{}, false, err
+	}
+	if result != nil {
+		submission.Result = new(models.SubmissionResult)
+		if err := json.Unmarshal(result, submission.Result); err != nil {
+			return models.Submission{}, false, err
+		}
+	}
+
+	return submission, false, tx.Commit(ctx)
+}
+
+// CompleteSubmission сохраняет результат запроса для ответов на повторы
+func (s *Stations) CompleteSubmission(ctx context.Context, stationID, key string, result models.SubmissionResult) error {
+	_, err := s.db.Exec(ctx, `update station_submission set result = $3 where station_id = $1 and idempotency_key = $2`, stationID, key, result)
+	return err
+}
+
+// ReleaseSubmission освобождает ключ идемпотентности запроса, который не удалось обработать,
+// чтобы станция могла повторить его с тем же ключом
+func (s *Stations) ReleaseSubmission(ctx context.Context, stationID, key string) error {
+	_, err := s.db.Exec(ctx, `delete from station_submission where station_id = $1 and idempotency_key = $2 and result is null`, stationID, key)
+	return err
+}
+
+// scanStation сканирует строку station с колонками stationColumns
+func scanStation(row pgx.Row) (models.Station, error) {
+	var station models.Station
+	err := row.Scan(&station.ID, &station.Name, &station.City, &station.Latitude, &station.Longitude, &station.CreatedAt)
+	return station, err
+}
+
+// scanStationKey сканирует строку station_key с колонками stationKeyColumns
+func scanStationKey(row pgx.Row) (models.StationKey, error) {
+	var key models.StationKey
+	err := row.Scan(&key.ID, &key.StationID, &key.Prefix, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt)
+	return key, err
+}
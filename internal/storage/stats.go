@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/olezhek28/wether-service/internal/domain/models"
+)
+
+// ReadWeatherStats рассчитывает сводную статистику переменной показания за диапазон [from, to)
+// variable - переменная из models.Variables, она же колонка reading; показания,
+// в которых значение переменной неизвестно (NULL), не учитываются
+// Все агрегаты, включая перцентили и время экстремумов, считаются одним SQL-запросом,
+// чтобы не выгружать исходные показания в память сервиса
+// Возвращает models.ErrNoData, если в диапазоне нет показаний
+func (w *Weather) ReadWeatherStats(ctx context.Context, city, variable string, from, to time.Time) (models.Stats, error) {
+	// Колонку нельзя передать параметром, поэтому она подставляется в текст запроса
+	// Переменную проверяет сервис, экранирование - защита от ошибки вызывающего кода
+	column := pgx.Identifier{variable}.Sanitize()
+	query := fmt.Sprintf(`with r as (
+    select %s as value, timestamp
+    from reading
+    where name = $1 and timestamp >= $2 and timestamp < $3 and %s is not null
+)
+select count(*),
+       min(value),
+       max(value),
+       avg(value),
+       percentile_cont(0.5) within group (order by value),
+       percentile_cont(0.1) within group (order by value),
+       percentile_cont(0.9) within group (order by value),
+       coalesce(stddev_samp(value), 0),
+       (select timestamp from r order by value asc, timestamp asc limit 1),
+       (select timestamp from r order by value desc, timestamp asc limit 1)
+from r`, column, column)
+
+	stats := models.Stats{Name: city, From: from, To: to}
+
+	// Агрегаты возвращают NULL на пустом диапазоне, поэтому сканируем их в указатели
+	var (
+		minValue, maxValue, mean, median, p10, p90, stddev *float64
+		minAt, maxAt                                       *time.Time
+	)
+
+	err := w.db.QueryRow(ctx, query, city, from, to).Scan(
+		&stats.Count, &minValue, &maxValue, &mean, &median, &p10, &p90, &stddev, &minAt, &maxAt,
+	)
+	if err != nil {
+		return models.Stats{}, err
+	}
+
+	if stats.Count == 0 {
+		return models.Stats{}, models.ErrNoData
+	}
+
+	stats.Min, stats.Max, stats.Mean = *minValue, *maxValue, *mean
+	stats.Median, stats.P10, stats.P90 = *median, *p10, *p90
+	stats.StdDev = *stddev
+	stats.MinAt, stats.MaxAt = *minAt, *maxAt
+
+	return stats, nil
+}
@@ -3,66 +3,180 @@ package storage
 import (
 	"context"
 	"errors"
-	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/olezhek28/wether-service/internal/domain/models"
 )
 
 // Weather представляет слой доступа к данным для работы с погодными данными
 // Содержит подключение к базе данных для выполнения операций
 type Weather struct {
-	db *pgx.Conn // Подключение к PostgreSQL через драйвер pgx
+	db *pgxpool.Pool // Пул подключений к PostgreSQL через драйвер pgx
 }
 
 // New создает и возвращает новый экземпляр Weather с переданным подключением к БД
 // Используется для инициализации хранилища в основном приложении
-func New(db *pgx.Conn) *Weather {
+func New(db *pgxpool.Pool) *Weather {
 	return &Weather{
 		db: db,
 	}
 }
 
+// Ping проверяет, что база доступна: пул выдает соединение и оно отвечает
+func (w *Weather) Ping(ctx context.Context) error {
+	return w.db.Ping(ctx)
+}
+
+// readingLockClass - первый ключ advisory-блокировки, под которой сохраняются показания
+// города (второй ключ - хэш названия). Двухключевая форма не пересекается
+// с одноключевыми блокировками, например блокировкой миграций
+const readingLockClass = 1
+
 // CreateWeatherCity создает новую запись о погоде для указанного города
-// Принимает контекст для управления таймаутами и отменой, название города,
-// температуру и временную метку измерения
-// Возвращает ошибку в случае неудачи операции
-func (w *Weather) CreateWeatherCity(ctx context.Context, name string, temperature float64, timestamp time.Time) error {
+// Принимает контекст для управления таймаутами и отменой и данные показания:
+// название города, температуру, временную метку измерения, время получения и источник
+// Показание с тем же городом и временем измерения не дублируется: у существующей
+// записи обновляется только время получения. Возвращает идентификатор записи и
+// признак того, что запись создана (только новая запись уходит в поток событий)
+//
+// Вставка выполняется под advisory-блокировкой города до конца транзакции:
+// id берется из последовательности уже под блокировкой, поэтому показания одного
+// города фиксируются (и уведомления о них доставляются) строго в порядке id, даже
+// если их одновременно сохраняют cron и read-through на разных репликах. Потоки
+// событий опираются на это, пропуская показания с id не больше последнего отправленного
+func (w *Weather) CreateWeatherCity(ctx context.Context, weather models.WeatherDTO) (int64, bool, error) {
 	// SQL-запрос для вставки данных в таблицу reading
-	// Используются позиционные параметры $1, $2, $3 для защиты от SQL-инъекций
-	query := "insert into reading (name, temperature, timestamp) values ($1, $2, $3)"
+	// Используются позиционные параметры $1, $2, ... для защиты от SQL-инъекций
+	// При конфликте срабатывает ветка update, а триггер уведомлений - только на insert
+	// RETURNING возвращает идентификатор и признак вставки (xmax = 0 только у новой строки)
+	// Производные величины вычисляются при сохранении, чтобы статистика по ним считалась в базе
+	// Показание уникально для города, станции (NULL у внешних API), датчика MQTT (NULL у остальных
+	// источников) и времени измерения
+	query := `insert into reading (name, temperature, timestamp, fetched_at, source, humidity, wind_speed,
+    dew_point, heat_index, wind_chill, humidex, absolute_humidity, feels_like, station_id, sensor)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+on conflict (name, station_id, sensor, timestamp) do update
+set fetched_at = greatest(reading.fetched_at, excluded.fetched_at)
+returning id, xmax = 0`
+
+	derived := weather.Derived()
+
+	var (
+		id      int64
+		created bool
+	)
+	err := pgx.BeginFunc(ctx, w.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "select pg_advisory_xact_lock($1, hashtext($2))", readingLockClass, weather.Name); err != nil {
+			return err
+		}
+
+		// Выполнение SQL-запроса с передачей параметров
+		return tx.QueryRow(ctx, query,
+			weather.Name, weather.Temperature, weather.Timestamp, weather.FetchedAt, weather.Source, weather.Humidity, weather.WindSpeed,
+			derived.DewPoint, derived.HeatIndex, derived.WindChill, derived.Humidex, derived.AbsoluteHumidity, derived.FeelsLike,
+			weather.StationID, weather.Sensor,
+		).Scan(&id, &created)
+	})
+	if err != nil {
+		return 0, false, err // Возвращаем ошибку если запрос не выполнился
+	}
+
+	return id, created, nil
+}
+
+// SaveLocation создает или обновляет местоположение города по данным геокодинга
+// name - ключ города, под которым сохраняются показания, geoID - идентификатор места
+// в GeoNames (0, если геокодинг его не вернул: тогда сохраненный идентификатор не меняется)
+func (w *Weather) SaveLocation(ctx context.Context, name string, geoID int64, location models.Location) error {
+	query := `insert into location (name, display_name, country, latitude, longitude, geonames_id, updated_at)
+values ($1, $2, $3, $4, $5, nullif($6, 0), now())
+on conflict (name) do update
+set display_name = excluded.display_name,
+    country = excluded.country,
+    latitude = excluded.latitude,
+    longitude = excluded.longitude,
+    geonames_id = coalesce(excluded.geonames_id, location.geonames_id),
+    updated_at = excluded.updated_at`
+
+	_, err := w.db.Exec(ctx, query, name, location.Name, location.Country, location.Latitude, location.Longitude, geoID)
+	return err
+}
 
-	// Выполнение SQL-запроса с передачей параметров
-	rows, err := w.db.Exec(ctx, query, name, temperature, timestamp)
+// ReadLocations возвращает все города, прошедшие геокодинг, в порядке ключей
+func (w *Weather) ReadLocations(ctx context.Context) ([]models.CityLocation, error) {
+	query := `select name, display_name, country, latitude, longitude, updated_at
+from location
+order by name`
+
+	rows, err := w.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectLocations(rows)
+}
+
+// ReadLocationsWithin возвращает города, координаты которых попадают в прямоугольник box
+// Прямоугольник, пересекающий 180-й меридиан (MinLongitude > MaxLongitude),
+// проверяется как объединение двух диапазонов долгот
+// Запрос обслуживается индексом location_latitude_longitude_idx
+func (w *Weather) ReadLocationsWithin(ctx context.Context, box models.BoundingBox) ([]models.CityLocation, error) {
+	query := `select name, display_name, country, latitude, longitude, updated_at
+from location
+where latitude between $1 and $2
+  and (longitude between $3 and $4 or ($3 > $4 and (longitude >= $3 or longitude <= $4)))`
+
+	rows, err := w.db.Query(ctx, query, box.MinLatitude, box.MaxLatitude, box.MinLongitude, box.MaxLongitude)
 	if err != nil {
-		return err // Возвращаем ошибку если запрос не выполнился
+		return nil, err
 	}
 
-	// Проверяем, что хотя бы одна строка была затронута операцией
-	// Это гарантирует, что данные действительно были добавлены
-	if rows.RowsAffected() == 0 {
-		return errors.New("Weather was not add")
+	return collectLocations(rows)
+}
+
+// collectLocations сканирует строки location и закрывает rows
+// Порядок колонок: name, display_name, country, latitude, longitude, updated_at
+func collectLocations(rows pgx.Rows) ([]models.CityLocation, error) {
+	defer rows.Close()
+
+	var locations []models.CityLocation
+	for rows.Next() {
+		var l models.CityLocation
+		err := rows.Scan(&l.City, &l.Location.Name, &l.Location.Country, &l.Location.Latitude, &l.Location.Longitude, &l.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
 	}
-	return nil
+
+	return locations, rows.Err()
 }
 
 // ReadWeatherByCity возвращает последние погодные данные для указанного города
 // Выполняет поиск самой свежей записи по временной метке
 // Возвращает структуру WeatherDTO с данными или ошибку если город не найден
 func (w *Weather) ReadWeatherByCity(ctx context.Context, city string) (models.WeatherDTO, error) {
-	var weatherDto models.WeatherDTO // Структура для хранения результата
-
 	// SQL-запрос для выборки последней записи погоды по городу
 	// ORDER BY timestamp DESC - сортировка по убыванию времени
+	// На одно время измерения может быть несколько показаний (Open-Meteo и станции),
+	// из них берется последнее сохраненное (id DESC), чтобы ответ не менялся между запросами
 	// LIMIT 1 - берем только самую свежую запись
-	query := "select name, timestamp,temperature from reading where name = $1 order by timestamp desc limit 1"
+	// LEFT JOIN добавляет местоположение, если город уже проходил геокодинг
+	query := `select r.id, r.name, r.timestamp, r.temperature, r.fetched_at, r.source, r.humidity, r.wind_speed, r.station_id, r.sensor,
+       l.display_name, l.country, l.latitude, l.longitude
+from reading r
+left join location l on l.name = r.name
+where r.name = $1
+order by r.timestamp desc, r.id desc
+limit 1`
 
 	// Выполнение запроса и сканирование результата в структуру
-	err := w.db.QueryRow(ctx, query, city).Scan(&weatherDto.Name, &weatherDto.Timestamp, &weatherDto.Temperature)
+	weatherDto, err := scanWeather(w.db.QueryRow(ctx, query, city))
 	if err != nil {
 		// Обработка случая когда город не найден в базе данных
 		if errors.Is(err, pgx.ErrNoRows) {
-			return models.WeatherDTO{}, errors.New("No city with same name")
+			return models.WeatherDTO{}, models.ErrCityNotFound
 		}
 		// Возвращаем другие ошибки (проблемы с подключением, синтаксисом и т.д.)
 		return models.WeatherDTO{}, err
@@ -70,3 +184,93 @@ func (w *Weather) ReadWeatherByCity(ctx context.Context, city string) (models.We
 
 	return weatherDto, nil // Возвращаем успешно найденные данные
 }
+
+// ReadWeatherByCities возвращает последние показания сразу нескольких городов одним запросом
+// Города без показаний в результат не попадают, порядок результата не определен
+func (w *Weather) ReadWeatherByCities(ctx context.Context, cities []string) ([]models.WeatherDTO, error) {
+	// distinct on оставляет по одной, самой свежей, строке на город
+	// Для каждого города это тот же поиск по индексу (name, timestamp), что и в ReadWeatherByCity,
+	// с тем же выбором последнего сохраненного среди показаний на одно время
+	query := `select distinct on (r.name) r.id, r.name, r.timestamp, r.temperature, r.fetched_at, r.source, r.humidity, r.wind_speed, r.station_id, r.sensor,
+       l.display_name, l.country, l.latitude, l.longitude
+from reading r
+left join location l on l.name = r.name
+where r.name = any($1)
+order by r.name, r.timestamp desc, r.id desc`
+
+	rows, err := w.db.Query(ctx, query, cities)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readings []models.WeatherDTO
+	for rows.Next() {
+		weather, err := scanWeather(rows)
+		if err != nil {
+			return nil, err
+		}
+		readings = append(readings, weather)
+	}
+
+	return readings, rows.Err()
+}
+
+// scanWeather сканирует показание вместе с местоположением из left join location
+// Порядок колонок: id, name, timestamp, temperature, fetched_at, source, humidity, wind_speed,
+// station_id, sensor, display_name, country, latitude, longitude
+func scanWeather(row pgx.Row) (models.WeatherDTO, error) {
+	var weatherDto models.WeatherDTO
+
+	// Поля местоположения могут быть NULL, поэтому сканируем их в указатели
+	var (
+		displayName, country *string
+		latitude, longitude  *float64
+	)
+
+	err := row.Scan(
+		&weatherDto.ID, &weatherDto.Name, &weatherDto.Timestamp, &weatherDto.Temperature, &weatherDto.FetchedAt, &weatherDto.Source,
+		&weatherDto.Humidity, &weatherDto.WindSpeed, &weatherDto.StationID, &weatherDto.Sensor,
+		&displayName, &country, &latitude, &longitude,
+	)
+	if err != nil {
+		return models.WeatherDTO{}, err
+	}
+
+	if displayName != nil {
+		weatherDto.Location = &models.Location{
+			Name:      *displayName,
+			Country:   *country,
+			Latitude:  *latitude,
+			Longitude: *longitude,
+		}
+	}
+
+	return weatherDto, nil
+}
+
+// ReadPreviousWeather возвращает последнее показание города weather.Name того же источника
+// (source, station_id и sensor), что и weather, измеренное раньше weather.Timestamp
+// Используется для определения фронта условий оповещений
+// Возвращает ErrCityNotFound, если более ранних показаний источника нет
+func (w *Weather) ReadPreviousWeather(ctx context.Context, weather models.WeatherDTO) (models.WeatherDTO, error) {
+	// is not distinct from сравнивает NULL как значение, как и уникальный индекс показаний
+	query := `select id, name, timestamp, temperature, fetched_at, source, humidity, wind_speed, station_id, sensor
+from reading
+where name = $1 and timestamp < $2
+  and source is not distinct from $3 and station_id is not distinct from $4 and sensor is not distinct from $5
+order by timestamp desc, id desc
+limit 1`
+
+	rows, err := w.db.Query(ctx, query, weather.Name, weather.Timestamp, weather.Source, weather.StationID, weather.Sensor)
+	if err != nil {
+		return models.WeatherDTO{}, err
+	}
+
+	previous, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByNameLax[models.WeatherDTO])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.WeatherDTO{}, models.ErrCityNotFound
+	}
+
+	return previous, err
+}
@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// querySpanKey - ключ контекста, под которым хранится спан запроса
+type querySpanKey struct{}
+
+// queryTracer записывает запросы pgx клиентскими спанами
+type queryTracer struct{}
+
+// QueryTracer возвращает трассировщик запросов pgx для pgx.ConnConfig.Tracer,
+// который записывает каждый запрос спаном, дочерним к спану из контекста запроса
+// Текст запроса попадает в спан с плейсхолдерами, значения аргументов не записываются
+func QueryTracer() pgx.QueryTracer {
+	return queryTracer{}
+}
+
+// TraceQueryStart начинает спан запроса
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	operation := sqlOperation(data.SQL)
+	ctx, span := Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(data.SQL),
+		),
+	)
+	return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+// TraceQueryEnd завершает спан запроса, записывая ошибку
+// Спан берется по собственному ключу: без начала запроса в контексте
+// нельзя завершить чужой спан, например спан HTTP-запроса
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span, ok := ctx.Value(querySpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	End(span, data.Err)
+}
+
+// sqlOperation возвращает первое слово запроса в верхнем регистре или QUERY
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
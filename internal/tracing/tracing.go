@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/olezhek28/wether-service/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scope - имя инструментирующей библиотеки в спанах сервиса
+const scope = "github.com/olezhek28/wether-service"
+
+// New настраивает глобальные провайдер спанов и пропагатор W3C Trace Context и Baggage
+// и возвращает функцию остановки, которая отправляет накопленные спаны
+// Пропагатор устанавливается и при выключенном экспорте, чтобы контекст трассировки
+// входящих запросов передавался во внешние API
+// Вызывает панику, если экспортер не удалось создать
+func New(ctx context.Context, config config.TracingConfig) func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, err := newExporter(ctx, config)
+	if err != nil {
+		panic(err)
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(config.ServiceName)),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Решение о записи принимается в начале трассы: входящий traceparent
+		// с флагом sampled записывается независимо от доли
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown
+}
+
+// newExporter создает экспортер спанов из конфигурации. Для off возвращает nil
+func newExporter(ctx context.Context, cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case config.TracingExporterOTLP:
+		var opts []otlptracegrpc.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		// Соединение устанавливается лениво: недоступный приемник не мешает запуску
+		return otlptracegrpc.New(ctx, opts...)
+	case config.TracingExporterStdout:
+		return stdouttrace.New()
+	case config.TracingExporterOff:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+}
+
+// Start начинает спан в глобальном провайдере
+// Трассировщик запрашивается при каждом вызове, поэтому спаны попадают
+// в провайдер, установленный после создания компонентов
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(scope).Start(ctx, name, opts...)
+}
+
+// End записывает ошибку операции в спан и завершает его
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
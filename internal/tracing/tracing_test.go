@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/olezhek28/wether-service/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans устанавливает глобальный провайдер, записывающий завершенные спаны
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return rec
+}
+
+// attr возвращает значение атрибута спана
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestNew(t *testing.T) {
+	for _, exporter := range []string{config.TracingExporterOff, config.TracingExporterStdout, config.TracingExporterOTLP} {
+		t.Run(exporter, func(t *testing.T) {
+			shutdown := New(context.Background(), config.TracingConfig{
+				Exporter:    exporter,
+				Endpoint:    "127.0.0.1:1",
+				Insecure:    true,
+				SampleRatio: 1,
+				ServiceName: "weather-service",
+			})
+
+			// W3C Trace Context передается при любом экспортере
+			fields := otel.GetTextMapPropagator().Fields()
+			if !slices.Contains(fields, "traceparent") || !slices.Contains(fields, "baggage") {
+				t.Errorf("propagator fields = %v, want traceparent and baggage", fields)
+			}
+
+			// Приемник OTLP недоступен, но без спанов остановка не обращается к нему
+			if err := shutdown(context.Background()); err != nil {
+				t.Errorf("shutdown: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewUnknownExporter(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for unknown exporter")
+		}
+	}()
+	New(context.Background(), config.TracingConfig{Exporter: "jaeger"})
+}
+
+func TestQueryTracer(t *testing.T) {
+	rec := recordSpans(t)
+	tracer := QueryTracer()
+
+	ctx, parent := Start(context.Background(), "WeatherService.GetWeather")
+	queryCtx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "\n  select name from location where name = $1"})
+	tracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{Err: errors.New("canceled")})
+
+	// Без начала запроса в контексте завершать нечего, родительский спан остается открытым
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	if len(rec.Ended()) != 1 {
+		t.Fatalf("ended spans = %d, want only the query span", len(rec.Ended()))
+	}
+	parent.End()
+
+	query := rec.Ended()[0]
+	if query.Name() != "SELECT" {
+		t.Errorf("name = %q, want SELECT", query.Name())
+	}
+	if query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("query span is not a child of the span from the query context")
+	}
+	if attr(query, "db.system.name") != "postgresql" || attr(query, "db.operation.name") != "SELECT" {
+		t.Errorf("unexpected attributes: %v", query.Attributes())
+	}
+	if query.Status().Code != codes.Error || query.Status().Description != "canceled" {
+		t.Errorf("status = %+v, want error canceled", query.Status())
+	}
+}
+
+func TestTransport(t *testing.T) {
+	rec := recordSpans(t)
+
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: Transport("open_meteo", srv.Client().Transport)}
+
+	ctx, parent := Start(context.Background(), "WeatherService.RefreshWeather")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v1/forecast", nil)
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	parent.End()
+
+	var outbound sdktrace.ReadOnlySpan
+	for _, span := range rec.Ended() {
+		if span.Name() == "open_meteo GET" {
+			outbound = span
+		}
+	}
+	if outbound == nil {
+		t.Fatalf("no open_meteo GET span among %d spans", len(rec.Ended()))
+	}
+	if outbound.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("outbound span is not a child of the caller span")
+	}
+
+	// Внешний API получает контекст исходящего спана
+	want := "00-" + outbound.SpanContext().TraceID().String() + "-" + outbound.SpanContext().SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("traceparent = %q, want %q", traceparent, want)
+	}
+}
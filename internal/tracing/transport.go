@@ -0,0 +1,16 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Transport оборачивает next так, что каждый запрос к внешнему API записывается клиентским
+// спаном с именем "<client> <method>", а контекст трассировки передается в заголовке traceparent
+// Если next равен nil, используется http.DefaultTransport
+func Transport(client string, next http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(next, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return client + " " + r.Method
+	}))
+}